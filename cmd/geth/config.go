@@ -20,10 +20,12 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"reflect"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -287,6 +289,11 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, backend, cfg.Ethstats.URL)
 	}
+	// Add the Monero daemon compatible mining RPC if requested.
+	if ctx.Bool(utils.RandomXDaemonFlag.Name) {
+		endpoint := net.JoinHostPort(ctx.String(utils.RandomXDaemonAddrFlag.Name), strconv.Itoa(ctx.Int(utils.RandomXDaemonPortFlag.Name)))
+		utils.RegisterRandomXDaemon(stack, eth, endpoint)
+	}
 	// Configure synchronization override service
 	var synctarget common.Hash
 	if ctx.IsSet(utils.SyncTargetFlag.Name) {
@@ -349,11 +356,10 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 			log.Error("Cannot start mining without etherbase address")
 			log.Error("Set the etherbase with --miner.etherbase <address>")
 		} else {
-			// Use all available CPUs for mining by default
-			// This can be controlled by GOMAXPROCS environment variable
-			threads := runtime.NumCPU()
+			// Use all available CPUs for mining unless limited by --randomx.threads
+			threads := cfg.Eth.RandomX.Threads
 			if threads <= 0 {
-				threads = 1
+				threads = runtime.NumCPU()
 			}
 
 			log.Info("Mining will start after node initialization", "etherbase", etherbase, "threads", threads)
@@ -120,7 +120,18 @@ var (
 		utils.MinerExtraDataFlag,
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerPendingFeeRecipientFlag,
+		utils.MinerNotifyFlag,
+		utils.MinerNotifyFullFlag,
 		utils.MinerNewPayloadTimeoutFlag, // deprecated
+		utils.RandomXLightModeFlag,
+		utils.RandomXCacheDirFlag,
+		utils.RandomXThreadsFlag,
+		utils.RandomXHugePagesFlag,
+		utils.RandomXJITFlag,
+		utils.RandomXPrecomputeDatasetFlag,
+		utils.RandomXDaemonFlag,
+		utils.RandomXDaemonAddrFlag,
+		utils.RandomXDaemonPortFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV4Flag,
@@ -39,6 +39,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/fdlimit"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/randomx"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/txpool/blobpool"
@@ -553,6 +554,69 @@ var (
 		Usage:    "0x prefixed public address for the pending block producer (not used for actual block production)",
 		Category: flags.MinerCategory,
 	}
+	MinerNotifyFlag = &cli.StringFlag{
+		Name:     "miner.notify",
+		Usage:    "Comma separated HTTP URL list to notify of new work packages",
+		Category: flags.MinerCategory,
+	}
+	MinerNotifyFullFlag = &cli.BoolFlag{
+		Name:     "miner.notify.full",
+		Usage:    "Notify with pending block headers instead of work packages",
+		Category: flags.MinerCategory,
+	}
+
+	// RandomX settings
+	RandomXLightModeFlag = &cli.BoolFlag{
+		Name:     "randomx.lightmode",
+		Usage:    "Verify and mine without the 2 GiB RandomX dataset (low memory, reduced hashrate)",
+		Category: flags.RandomXCategory,
+	}
+	RandomXCacheDirFlag = &flags.DirectoryFlag{
+		Name:     "randomx.cachedir",
+		Usage:    "Directory to store the RandomX datasets (default = inside the datadir)",
+		Category: flags.RandomXCategory,
+	}
+	RandomXThreadsFlag = &cli.IntFlag{
+		Name:     "randomx.threads",
+		Usage:    "Number of CPU threads to mine with (0 = all cores)",
+		Value:    ethconfig.Defaults.RandomX.Threads,
+		Category: flags.RandomXCategory,
+	}
+	RandomXHugePagesFlag = &cli.BoolFlag{
+		Name:     "randomx.hugepages",
+		Usage:    "Allocate the RandomX cache and dataset in huge pages if available",
+		Value:    ethconfig.Defaults.RandomX.HugePages,
+		Category: flags.RandomXCategory,
+	}
+	RandomXJITFlag = &cli.BoolFlag{
+		Name:     "randomx.jit",
+		Usage:    "Enable the RandomX JIT compiler if supported by the platform",
+		Value:    ethconfig.Defaults.RandomX.JIT,
+		Category: flags.RandomXCategory,
+	}
+	RandomXPrecomputeDatasetFlag = &cli.BoolFlag{
+		Name:     "randomx.precompute-dataset",
+		Usage:    "Build the dataset of the next RandomX epoch ahead of the transition (holds two datasets in memory)",
+		Value:    ethconfig.Defaults.RandomX.PrecomputeDataset,
+		Category: flags.RandomXCategory,
+	}
+	RandomXDaemonFlag = &cli.BoolFlag{
+		Name:     "randomx.daemon",
+		Usage:    "Enable the Monero daemon compatible mining RPC, for solo mining with xmrig in daemon mode",
+		Category: flags.RandomXCategory,
+	}
+	RandomXDaemonAddrFlag = &cli.StringFlag{
+		Name:     "randomx.daemon.addr",
+		Usage:    "Monero daemon mining RPC server listening interface",
+		Value:    "127.0.0.1",
+		Category: flags.RandomXCategory,
+	}
+	RandomXDaemonPortFlag = &cli.IntFlag{
+		Name:     "randomx.daemon.port",
+		Usage:    "Monero daemon mining RPC server listening port",
+		Value:    18081,
+		Category: flags.RandomXCategory,
+	}
 
 	// Account settings
 	PasswordFileFlag = &cli.PathFlag{
@@ -1573,6 +1637,33 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	}
 }
 
+func setRandomX(ctx *cli.Context, cfg *randomx.Config) {
+	if ctx.IsSet(RandomXLightModeFlag.Name) {
+		cfg.LightMode = ctx.Bool(RandomXLightModeFlag.Name)
+	}
+	if ctx.IsSet(RandomXCacheDirFlag.Name) {
+		cfg.CacheDir = ctx.String(RandomXCacheDirFlag.Name)
+	}
+	if ctx.IsSet(RandomXThreadsFlag.Name) {
+		cfg.Threads = ctx.Int(RandomXThreadsFlag.Name)
+	}
+	if ctx.IsSet(RandomXHugePagesFlag.Name) {
+		cfg.HugePages = ctx.Bool(RandomXHugePagesFlag.Name)
+	}
+	if ctx.IsSet(RandomXJITFlag.Name) {
+		cfg.JIT = ctx.Bool(RandomXJITFlag.Name)
+	}
+	if ctx.IsSet(RandomXPrecomputeDatasetFlag.Name) {
+		cfg.PrecomputeDataset = ctx.Bool(RandomXPrecomputeDatasetFlag.Name)
+	}
+	if ctx.IsSet(MinerNotifyFlag.Name) {
+		cfg.Notify = SplitAndTrim(ctx.String(MinerNotifyFlag.Name))
+	}
+	if ctx.IsSet(MinerNotifyFullFlag.Name) {
+		cfg.NotifyFull = ctx.Bool(MinerNotifyFullFlag.Name)
+	}
+}
+
 func setRequiredBlocks(ctx *cli.Context, cfg *ethconfig.Config) {
 	requiredBlocks := ctx.String(EthRequiredBlocksFlag.Name)
 	if requiredBlocks == "" {
@@ -1613,6 +1704,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	setTxPool(ctx, &cfg.TxPool)
 	setBlobPool(ctx, &cfg.BlobPool)
 	setMiner(ctx, &cfg.Miner)
+	setRandomX(ctx, &cfg.RandomX)
 	setRequiredBlocks(ctx, cfg)
 
 	// Cap the cache allowance and tune the garbage collector
@@ -2057,6 +2149,18 @@ func RegisterEthStatsService(stack *node.Node, backend *eth.EthAPIBackend, url s
 	}
 }
 
+// RegisterRandomXDaemon adds the Monero daemon compatible mining RPC to the node.
+func RegisterRandomXDaemon(stack *node.Node, eth *eth.Ethereum, endpoint string) {
+	engine, ok := eth.Engine().(*randomx.RandomX)
+	if !ok {
+		Fatalf("The Monero daemon mining RPC requires the RandomX consensus engine")
+	}
+	synced := func() bool {
+		return eth.Downloader().Progress().Done()
+	}
+	stack.RegisterLifecycle(randomx.NewDaemonServer(engine, eth.BlockChain(), synced, endpoint))
+}
+
 // RegisterGraphQLService adds the GraphQL API to the node.
 func RegisterGraphQLService(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cfg *node.Config) {
 	err := graphql.New(stack, backend, filterSystem, cfg.GraphQLCors, cfg.GraphQLVirtualHosts)
@@ -2260,7 +2364,12 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readonly bool) (*core.BlockCh
 	if err != nil {
 		Fatalf("%v", err)
 	}
-	engine, err := ethconfig.CreateConsensusEngine(config, chainDb)
+	randomxConfig := ethconfig.Defaults.RandomX
+	setRandomX(ctx, &randomxConfig)
+	if randomxConfig.CacheDir != "" {
+		randomxConfig.CacheDir = stack.ResolvePath(randomxConfig.CacheDir)
+	}
+	engine, err := ethconfig.CreateConsensusEngine(config, &randomxConfig, chainDb)
 	if err != nil {
 		Fatalf("%v", err)
 	}
@@ -17,6 +17,7 @@
 package randomx
 
 import (
+	"context"
 	"errors"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -25,14 +26,22 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/holiman/uint256"
 )
 
 var (
 	errRandomXStopped    = errors.New("randomx stopped")
 	errNoMiningWork      = errors.New("no mining work available yet")
 	errInvalidSealResult = errors.New("invalid or stale proof-of-work solution")
+	errStaleTemplate     = errors.New("work template not built on top of the chain head")
+	errRewardTooFar      = errors.New("block too far beyond the chain head")
 )
 
+// maxRewardLookahead is the number of blocks beyond the chain head whose
+// reward schedule can be queried. Smooth emission rewards are replayed block
+// by block, so arbitrary numbers would let any caller burn CPU.
+const maxRewardLookahead = 100_000
+
 // API exposes RandomX related methods for the RPC interface.
 type API struct {
 	randomx *RandomX
@@ -70,6 +79,51 @@ func (api *API) GetWork() ([4]string, error) {
 	}
 }
 
+// NewWork creates a subscription that is sent the work package, as returned by
+// GetWork, each time the node has a new block to mine, starting with the
+// current one. Remote miners subscribe with randomx_subscribe("newWork")
+// instead of polling GetWork.
+func (api *API) NewWork(ctx context.Context) (*rpc.Subscription, error) {
+	if api.randomx.remote == nil {
+		return nil, errors.New("not supported")
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	var (
+		rpcSub = notifier.CreateSubscription()
+		remote = api.randomx.remote
+		works  = remote.subscribeWork()
+	)
+	go func() {
+		defer remote.unsubscribeWork(works)
+
+		for {
+			select {
+			case work := <-works:
+				notifier.Notify(rpcSub.ID, work)
+			case <-rpcSub.Err():
+				return
+			case <-remote.exitCh:
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// SealTemplate hands a block built for a remote miner to the remote sealer,
+// besides the one being mined, and returns its work package. The block is sent
+// to the results channel once a miner submits a solution for it through
+// SubmitWork. Templates expire with a new chain head.
+func (randomx *RandomX) SealTemplate(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block) ([4]string, error) {
+	if randomx.remote == nil {
+		return [4]string{}, errors.New("not supported")
+	}
+	return randomx.remote.addTemplate(chain, block, results)
+}
+
 // SubmitWork can be used by external miner to submit their POW solution.
 // It returns an indication if the work was accepted.
 // Note either an invalid solution, a stale work a non-existent work will return false.
@@ -77,22 +131,7 @@ func (api *API) SubmitWork(nonce types.BlockNonce, hash, digest common.Hash) boo
 	if api.randomx.remote == nil {
 		return false
 	}
-
-	var errc = make(chan error, 1)
-
-	select {
-	case api.randomx.remote.submitWorkCh <- &mineResult{
-		nonce:     nonce,
-		mixDigest: digest,
-		hash:      hash,
-		errc:      errc,
-	}:
-	case <-api.randomx.remote.exitCh:
-		return false
-	}
-
-	err := <-errc
-	return err == nil
+	return api.randomx.remote.submitWork(nonce, hash, digest) == nil
 }
 
 // SubmitHashrate can be used for remote miners to submit their hash rate.
@@ -162,7 +201,80 @@ func (randomx *RandomX) APIs(chain consensus.ChainHeaderReader) []rpc.API {
 			Service:   &API{randomx},
 			Public:    true,
 		},
+		{
+			Namespace: "randomx",
+			Service:   &RewardAPI{randomx, chain},
+			Public:    true,
+		},
 	}
 	log.Info("RandomX APIs registered", "count", len(apis), "namespaces", []string{"eth", "randomx"}, "remote", randomx.remote != nil)
 	return apis
 }
+
+// RewardAPI exposes the block reward schedule of the chain over RPC.
+type RewardAPI struct {
+	randomx *RandomX
+	chain   consensus.ChainHeaderReader
+}
+
+// BlockReward is the breakdown of the scheduled reward of a block, excluding
+// any uncle inclusion rewards.
+type BlockReward struct {
+	Number      hexutil.Uint64  `json:"number"`
+	Reward      *hexutil.Big    `json:"reward"`      // Base block reward
+	MinerReward *hexutil.Big    `json:"minerReward"` // Base block reward paid to the miner
+	FundReward  *hexutil.Big    `json:"fundReward"`  // Share of the base block reward paid to the treasury
+	FundAddress *common.Address `json:"fundAddress,omitempty"`
+}
+
+// GetBlockReward returns the scheduled reward of the given block.
+func (api *RewardAPI) GetBlockReward(number rpc.BlockNumber) (*BlockReward, error) {
+	num, err := api.resolve(number)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		config      = api.chain.Config()
+		reward      = blockReward(config, &api.randomx.emissions, num)
+		fund, share = fundShare(config, num, reward)
+		result      = &BlockReward{
+			Number:      hexutil.Uint64(num),
+			Reward:      (*hexutil.Big)(reward.ToBig()),
+			MinerReward: (*hexutil.Big)(new(uint256.Int).Sub(reward, share).ToBig()),
+			FundReward:  (*hexutil.Big)(share.ToBig()),
+		}
+	)
+	if fund != nil {
+		result.FundAddress = &fund.Address
+	}
+	return result, nil
+}
+
+// GetEmission returns the sum of the scheduled rewards of all blocks up to and
+// including the given one, excluding any uncle rewards.
+func (api *RewardAPI) GetEmission(number rpc.BlockNumber) (*hexutil.Big, error) {
+	num, err := api.resolve(number)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(emission(api.chain.Config(), &api.randomx.emissions, num).ToBig()), nil
+}
+
+// resolve maps an RPC block number to a concrete one. The schedule does not
+// depend on chain content, so blocks up to maxRewardLookahead beyond the current
+// head can be queried.
+func (api *RewardAPI) resolve(number rpc.BlockNumber) (uint64, error) {
+	if api.chain == nil {
+		return 0, errors.New("chain not available")
+	}
+	head := api.chain.CurrentHeader().Number.Uint64()
+	switch {
+	case number == rpc.PendingBlockNumber:
+		return head + 1, nil
+	case number < 0:
+		return head, nil
+	case uint64(number) > head+maxRewardLookahead:
+		return 0, errRewardTooFar
+	}
+	return uint64(number), nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import (
+	"encoding/binary"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	argonMemory     = 262144 // Number of 1 KiB blocks in the cache
+	argonIterations = 3      // Number of passes over the cache
+	argonVersion    = 0x13   // Argon2 version 1.3
+	argonSyncPoints = 4      // Number of slices per pass
+	argonBlockWords = 128    // Number of 64-bit words in a block
+
+	argonSegmentLength = argonMemory / argonSyncPoints
+)
+
+// argonSalt is the Argon2 salt of RandomX, part of the consensus rules.
+var argonSalt = []byte("RandomX\x03")
+
+// argonBlock is a 1 KiB block of Argon2 memory.
+type argonBlock [argonBlockWords]uint64
+
+// argon2dFill fills the memory of a RandomX cache with Argon2d, keyed with the
+// given seed. RandomX uses a single lane and only keeps the memory, no tag is
+// computed from it.
+func argon2dFill(key []byte) []argonBlock {
+	memory := make([]argonBlock, argonMemory)
+
+	// Derive the first two blocks from the initial hash of the parameters
+	h, _ := blake2b.New512(nil)
+	for _, v := range []uint32{1, 0, argonMemory, argonIterations, argonVersion, 0, uint32(len(key))} {
+		h.Write(binary.LittleEndian.AppendUint32(nil, v))
+	}
+	h.Write(key)
+	h.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(argonSalt))))
+	h.Write(argonSalt)
+	h.Write(make([]byte, 8)) // empty secret and associated data
+
+	seed := make([]byte, 0, blake2b.Size+8)
+	seed = h.Sum(seed)
+	seed = append(seed, make([]byte, 8)...)
+	for i := 0; i < 2; i++ {
+		binary.LittleEndian.PutUint32(seed[blake2b.Size:], uint32(i))
+
+		var buf [argonBlockWords * 8]byte
+		blake2bLong(buf[:], seed)
+		for j := range memory[i] {
+			memory[i][j] = binary.LittleEndian.Uint64(buf[j*8:])
+		}
+	}
+	// Fill the rest of the memory, referencing data dependent blocks
+	for pass := 0; pass < argonIterations; pass++ {
+		for slice := 0; slice < argonSyncPoints; slice++ {
+			argon2dFillSegment(memory, pass, slice)
+		}
+	}
+	return memory
+}
+
+// argon2dFillSegment fills a slice of the single lane in the given pass.
+func argon2dFillSegment(memory []argonBlock, pass, slice int) {
+	start := 0
+	if pass == 0 && slice == 0 {
+		start = 2
+	}
+	for index := start; index < argonSegmentLength; index++ {
+		// The previous block of the first one is the last of the lane
+		curr := slice*argonSegmentLength + index
+		prev := (curr + argonMemory - 1) % argonMemory
+
+		ref := argon2dReference(pass, slice, index, uint32(memory[prev][0]))
+		argon2Compress(&memory[curr], &memory[prev], &memory[ref], pass > 0)
+	}
+}
+
+// argon2dReference returns the position of the block referenced when filling
+// the given index of a slice.
+func argon2dReference(pass, slice, index int, rand uint32) uint64 {
+	var area uint64
+	switch {
+	case pass == 0 && slice == 0:
+		area = uint64(index - 1)
+	case pass == 0:
+		area = uint64(slice*argonSegmentLength + index - 1)
+	default:
+		area = uint64(argonMemory - argonSegmentLength + index - 1)
+	}
+	pos := uint64(rand)
+	pos = pos * pos >> 32
+	pos = area - 1 - (area * pos >> 32)
+
+	var start uint64
+	if pass != 0 && slice != argonSyncPoints-1 {
+		start = uint64((slice + 1) * argonSegmentLength)
+	}
+	return (start + pos) % argonMemory
+}
+
+// argon2Compress computes the Argon2 compression of the previous and the
+// referenced block into out, either overwriting or xor-ing it.
+func argon2Compress(out, prev, ref *argonBlock, xor bool) {
+	var r argonBlock
+	for i := range r {
+		r[i] = prev[i] ^ ref[i]
+	}
+	t := r
+	for i := 0; i < argonBlockWords; i += 16 {
+		blamkaRound(&t,
+			i+0, i+1, i+2, i+3, i+4, i+5, i+6, i+7,
+			i+8, i+9, i+10, i+11, i+12, i+13, i+14, i+15)
+	}
+	for i := 0; i < 16; i += 2 {
+		blamkaRound(&t,
+			i, i+1, i+16, i+17, i+32, i+33, i+48, i+49,
+			i+64, i+65, i+80, i+81, i+96, i+97, i+112, i+113)
+	}
+	if xor {
+		for i := range out {
+			out[i] ^= r[i] ^ t[i]
+		}
+	} else {
+		for i := range out {
+			out[i] = r[i] ^ t[i]
+		}
+	}
+}
+
+// blamkaRound applies the BlaMka variant of the BLAKE2b round function to the
+// 16 words of a block at the given positions.
+func blamkaRound(b *argonBlock, i0, i1, i2, i3, i4, i5, i6, i7, i8, i9, i10, i11, i12, i13, i14, i15 int) {
+	v0, v1, v2, v3 := b[i0], b[i1], b[i2], b[i3]
+	v4, v5, v6, v7 := b[i4], b[i5], b[i6], b[i7]
+	v8, v9, v10, v11 := b[i8], b[i9], b[i10], b[i11]
+	v12, v13, v14, v15 := b[i12], b[i13], b[i14], b[i15]
+
+	v0, v4, v8, v12 = blamkaG(v0, v4, v8, v12)
+	v1, v5, v9, v13 = blamkaG(v1, v5, v9, v13)
+	v2, v6, v10, v14 = blamkaG(v2, v6, v10, v14)
+	v3, v7, v11, v15 = blamkaG(v3, v7, v11, v15)
+	v0, v5, v10, v15 = blamkaG(v0, v5, v10, v15)
+	v1, v6, v11, v12 = blamkaG(v1, v6, v11, v12)
+	v2, v7, v8, v13 = blamkaG(v2, v7, v8, v13)
+	v3, v4, v9, v14 = blamkaG(v3, v4, v9, v14)
+
+	b[i0], b[i1], b[i2], b[i3] = v0, v1, v2, v3
+	b[i4], b[i5], b[i6], b[i7] = v4, v5, v6, v7
+	b[i8], b[i9], b[i10], b[i11] = v8, v9, v10, v11
+	b[i12], b[i13], b[i14], b[i15] = v12, v13, v14, v15
+}
+
+// blamkaG is the BlaMka G function, with multiplications hardening the BLAKE2b
+// additions.
+func blamkaG(a, b, c, d uint64) (uint64, uint64, uint64, uint64) {
+	a += b + 2*uint64(uint32(a))*uint64(uint32(b))
+	d ^= a
+	d = d>>32 | d<<32
+	c += d + 2*uint64(uint32(c))*uint64(uint32(d))
+	b ^= c
+	b = b>>24 | b<<40
+	a += b + 2*uint64(uint32(a))*uint64(uint32(b))
+	d ^= a
+	d = d>>16 | d<<48
+	c += d + 2*uint64(uint32(c))*uint64(uint32(d))
+	b ^= c
+	b = b>>63 | b<<1
+	return a, b, c, d
+}
+
+// blake2bLong is the variable length hash function of Argon2, filling out with
+// the hash of in. The length of out must be a multiple of 32 bytes above 64.
+func blake2bLong(out []byte, in []byte) {
+	h, _ := blake2b.New512(nil)
+	h.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(out))))
+	h.Write(in)
+	sum := h.Sum(nil)
+
+	// Output the first half of each hash, chaining them until the last one
+	for len(out) > blake2b.Size {
+		copy(out, sum[:blake2b.Size/2])
+		out = out[blake2b.Size/2:]
+
+		next := blake2b.Sum512(sum)
+		sum = next[:]
+	}
+	copy(out, sum)
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import (
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// defaultParams are the consensus parameters used for every value not set in
+// the chain configuration.
+var defaultParams = params.RandomXParams{
+	EpochLength:               EpochLength,
+	EpochLag:                  EpochLag,
+	LWMAWindowSize:            LWMAWindowSize,
+	LWMATargetBlockTime:       LWMATargetBlockTime,
+	LWMABurstDetectionWindow:  LWMABurstDetectionWindow,
+	LWMABurstSustainedPercent: LWMABurstSustainedPercent,
+	AllowedFutureBlockTime:    uint64(allowedFutureBlockTimeSeconds),
+}
+
+// activeParams returns the fully populated consensus parameters in effect at
+// the given block number. A nil config yields the defaults.
+func activeParams(config *params.ChainConfig, number uint64) params.RandomXParams {
+	if config == nil || config.RandomX == nil {
+		return defaultParams
+	}
+	active := config.RandomX.ParamsAt(number)
+	if active.EpochLength == 0 {
+		active.EpochLength = defaultParams.EpochLength
+	}
+	if active.EpochLag == 0 {
+		active.EpochLag = defaultParams.EpochLag
+	}
+	if active.LWMAWindowSize == 0 {
+		active.LWMAWindowSize = defaultParams.LWMAWindowSize
+	}
+	if active.LWMATargetBlockTime == 0 {
+		active.LWMATargetBlockTime = defaultParams.LWMATargetBlockTime
+	}
+	if active.LWMABurstDetectionWindow == 0 {
+		active.LWMABurstDetectionWindow = defaultParams.LWMABurstDetectionWindow
+	}
+	if active.LWMABurstSustainedPercent == 0 {
+		active.LWMABurstSustainedPercent = defaultParams.LWMABurstSustainedPercent
+	}
+	if active.AllowedFutureBlockTime == 0 {
+		active.AllowedFutureBlockTime = defaultParams.AllowedFutureBlockTime
+	}
+	return active
+}
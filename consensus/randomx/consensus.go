@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"runtime"
 	"time"
 
 	mapset "github.com/deckarep/golang-set/v2"
@@ -44,7 +45,8 @@ var (
 	ByzantiumBlockReward          = uint256.NewInt(3e+18) // Block reward in wei for successfully mining a block upward from Byzantium
 	ConstantinopleBlockReward     = uint256.NewInt(2e+18) // Block reward in wei for successfully mining a block upward from Constantinople
 	maxUncles                     = 2                     // Maximum number of uncles allowed in a single block
-	allowedFutureBlockTimeSeconds = int64(15)             // Max seconds from current time allowed for blocks, before they're considered future blocks
+	uncleDepthWindow              = 8                     // Uncles must be less than this many blocks older than the including block
+	allowedFutureBlockTimeSeconds = int64(15)             // Default max seconds from current time allowed for blocks, before they're considered future blocks
 
 	// calcDifficultyEip5133 is the difficulty adjustment algorithm as specified by EIP 5133.
 	// It offsets the bomb a total of 11.4M blocks.
@@ -111,7 +113,7 @@ func (randomx *RandomX) VerifyHeader(chain consensus.ChainHeaderReader, header *
 		return consensus.ErrUnknownAncestor
 	}
 	// Sanity checks passed, do a proper verification
-	return randomx.verifyHeader(chain, header, parent, false, time.Now().Unix())
+	return randomx.verifyHeader(chain, header, parent, false, time.Now().Unix(), nil)
 }
 
 // VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers
@@ -126,32 +128,135 @@ func (randomx *RandomX) VerifyHeaders(chain consensus.ChainHeaderReader, headers
 		}
 		return abort, results
 	}
-	abort := make(chan struct{})
-	results := make(chan error, len(headers))
-	unixNow := time.Now().Unix()
-
-	go func() {
-		for i, header := range headers {
-			var parent *types.Header
-			if i == 0 {
-				parent = chain.GetHeader(headers[0].ParentHash, headers[0].Number.Uint64()-1)
-			} else if headers[i-1].Hash() == headers[i].ParentHash {
-				parent = headers[i-1]
-			}
-			var err error
-			if parent == nil {
-				err = consensus.ErrUnknownAncestor
-			} else {
-				err = randomx.verifyHeader(chain, header, parent, false, unixNow)
+	// Spawn as many workers as allowed threads, each leasing its own VM
+	workers := runtime.NumCPU()
+	if len(headers) < workers {
+		workers = len(headers)
+	}
+	// Create a task channel and spawn the verifiers
+	var (
+		inputs  = make(chan int)
+		done    = make(chan int, workers)
+		errs    = make([]error, len(headers))
+		abort   = make(chan struct{})
+		unixNow = time.Now().Unix()
+		batch   = newBatchChainReader(chain, headers)
+	)
+	for i := 0; i < workers; i++ {
+		go func() {
+			vm := new(verifyVM)
+			defer randomx.releaseVerifyVM(vm)
+
+			for index := range inputs {
+				errs[index] = randomx.verifyHeaderWorker(batch, index, unixNow, vm)
+				done <- index
 			}
+		}()
+	}
+	errorsOut := make(chan error, len(headers))
+	go func() {
+		defer close(inputs)
+		var (
+			in, out = 0, 0
+			checked = make([]bool, len(headers))
+			inputs  = inputs
+		)
+		for {
 			select {
+			case inputs <- in:
+				if in++; in == len(headers) {
+					// Reached end of headers. Stop sending to workers.
+					inputs = nil
+				}
+			case index := <-done:
+				for checked[index] = true; checked[out]; out++ {
+					errorsOut <- errs[out]
+					if out == len(headers)-1 {
+						return
+					}
+				}
 			case <-abort:
 				return
-			case results <- err:
 			}
 		}
 	}()
-	return abort, results
+	return abort, errorsOut
+}
+
+// verifyHeaderWorker verifies a single header of a batch, looking up its parent
+// either in the batch itself or in the chain.
+func (randomx *RandomX) verifyHeaderWorker(chain *batchChainReader, index int, unixNow int64, vm *verifyVM) error {
+	var (
+		headers = chain.headers
+		parent  *types.Header
+	)
+	if index == 0 {
+		parent = chain.GetHeader(headers[0].ParentHash, headers[0].Number.Uint64()-1)
+	} else if chain.hashes[index-1] == headers[index].ParentHash {
+		parent = headers[index-1]
+	}
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	return randomx.verifyHeader(chain, headers[index], parent, false, unixNow, vm)
+}
+
+// batchChainReader is the chain reader of a header batch verification. The
+// difficulty, median time past and seed of a header depend on its ancestors,
+// which are looked up in the batch first as it is not written to the database
+// before being verified.
+type batchChainReader struct {
+	consensus.ChainHeaderReader
+
+	headers []*types.Header
+	hashes  []common.Hash // Hashes of the headers, computed once for all lookups
+}
+
+// newBatchChainReader creates a chain reader looking up the given batch of
+// consecutive headers before the chain.
+func newBatchChainReader(chain consensus.ChainHeaderReader, headers []*types.Header) *batchChainReader {
+	hashes := make([]common.Hash, len(headers))
+	for i, header := range headers {
+		hashes[i] = header.Hash()
+	}
+	return &batchChainReader{ChainHeaderReader: chain, headers: headers, hashes: hashes}
+}
+
+// index returns the position of the header with the given number in the batch,
+// or -1 if the number is outside the batch.
+func (r *batchChainReader) index(number uint64) int {
+	first := r.headers[0].Number.Uint64()
+	if number < first || number-first >= uint64(len(r.headers)) {
+		return -1
+	}
+	return int(number - first)
+}
+
+// GetHeader retrieves a header by hash and number from the batch or the chain.
+func (r *batchChainReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if i := r.index(number); i >= 0 && r.hashes[i] == hash {
+		return r.headers[i]
+	}
+	return r.ChainHeaderReader.GetHeader(hash, number)
+}
+
+// GetHeaderByNumber retrieves a header by number, from the batch if it covers
+// the number as the batch is the chain being verified.
+func (r *batchChainReader) GetHeaderByNumber(number uint64) *types.Header {
+	if i := r.index(number); i >= 0 {
+		return r.headers[i]
+	}
+	return r.ChainHeaderReader.GetHeaderByNumber(number)
+}
+
+// GetHeaderByHash retrieves a header by hash from the batch or the chain.
+func (r *batchChainReader) GetHeaderByHash(hash common.Hash) *types.Header {
+	for i := range r.hashes {
+		if r.hashes[i] == hash {
+			return r.headers[i]
+		}
+	}
+	return r.ChainHeaderReader.GetHeaderByHash(hash)
 }
 
 // VerifyUncles verifies that the given block's uncles conform to the consensus
@@ -172,7 +277,7 @@ func (randomx *RandomX) VerifyUncles(chain consensus.ChainReader, block *types.B
 	uncles, ancestors := mapset.NewSet[common.Hash](), make(map[common.Hash]*types.Header)
 
 	number, parent := block.NumberU64()-1, block.ParentHash()
-	for i := 0; i < 7; i++ {
+	for i := 0; i < uncleDepthWindow-1; i++ {
 		ancestorHeader := chain.GetHeader(parent, number)
 		if ancestorHeader == nil {
 			break
@@ -210,7 +315,7 @@ func (randomx *RandomX) VerifyUncles(chain consensus.ChainReader, block *types.B
 		if ancestors[uncle.ParentHash] == nil || uncle.ParentHash == block.ParentHash() {
 			return errDanglingUncle
 		}
-		if err := randomx.verifyHeader(chain, uncle, ancestors[uncle.ParentHash], true, time.Now().Unix()); err != nil {
+		if err := randomx.verifyHeader(chain, uncle, ancestors[uncle.ParentHash], true, time.Now().Unix(), nil); err != nil {
 			return err
 		}
 	}
@@ -220,14 +325,17 @@ func (randomx *RandomX) VerifyUncles(chain consensus.ChainReader, block *types.B
 // verifyHeader checks whether a header conforms to the consensus rules of the
 // stock Ethereum RandomX engine.
 // See YP section 4.3.4. "Block Header Validity"
-func (randomx *RandomX) verifyHeader(chain consensus.ChainHeaderReader, header, parent *types.Header, uncle bool, unixNow int64) error {
+//
+// The optional vm is a leased verification VM to hash the seal with.
+func (randomx *RandomX) verifyHeader(chain consensus.ChainHeaderReader, header, parent *types.Header, uncle bool, unixNow int64, vm *verifyVM) error {
 	// Ensure that the header's extra-data section is of a reasonable size
 	if uint64(len(header.Extra)) > params.MaximumExtraDataSize {
 		return fmt.Errorf("extra-data too long: %d > %d", len(header.Extra), params.MaximumExtraDataSize)
 	}
 	// Verify the header's timestamp
 	if !uncle {
-		if header.Time > uint64(unixNow+allowedFutureBlockTimeSeconds) {
+		allowedFuture := activeParams(chain.Config(), header.Number.Uint64()).AllowedFutureBlockTime
+		if header.Time > uint64(unixNow)+allowedFuture {
 			return consensus.ErrFutureBlock
 		}
 	}
@@ -272,24 +380,24 @@ func (randomx *RandomX) verifyHeader(chain consensus.ChainHeaderReader, header,
 	if diff := new(big.Int).Sub(header.Number, parent.Number); diff.Cmp(big.NewInt(1)) != 0 {
 		return consensus.ErrInvalidNumber
 	}
-	if chain.Config().IsShanghai(header.Number, header.Time) {
-		return errors.New("randomx does not support shanghai fork")
+	// Shanghai, Cancun and Prague only change the execution rules on RandomX,
+	// the later forks are not supported yet.
+	if chain.Config().IsOsaka(header.Number, header.Time) {
+		return errors.New("randomx does not support osaka fork")
 	}
-	// Verify the non-existence of withdrawalsHash.
-	if header.WithdrawalsHash != nil {
-		return fmt.Errorf("invalid withdrawalsHash: have %x, expected nil", header.WithdrawalsHash)
-	}
-	if chain.Config().IsCancun(header.Number, header.Time) {
-		return errors.New("randomx does not support cancun fork")
-	}
-	// Verify the non-existence of cancun-specific header fields
+	// Verify the non-existence of the beacon chain and blob header fields,
+	// which the post-merge forks carry on proof-of-stake chains only.
 	switch {
+	case header.WithdrawalsHash != nil:
+		return fmt.Errorf("invalid withdrawalsHash: have %x, expected nil", header.WithdrawalsHash)
 	case header.ExcessBlobGas != nil:
 		return fmt.Errorf("invalid excessBlobGas: have %d, expected nil", header.ExcessBlobGas)
 	case header.BlobGasUsed != nil:
 		return fmt.Errorf("invalid blobGasUsed: have %d, expected nil", header.BlobGasUsed)
 	case header.ParentBeaconRoot != nil:
 		return fmt.Errorf("invalid parentBeaconRoot, have %#x, expected nil", header.ParentBeaconRoot)
+	case header.RequestsHash != nil:
+		return fmt.Errorf("invalid requestsHash, have %#x, expected nil", header.RequestsHash)
 	}
 	// Add some fake checks for tests
 	if randomx.fakeDelay != nil {
@@ -300,7 +408,7 @@ func (randomx *RandomX) verifyHeader(chain consensus.ChainHeaderReader, header,
 	}
 	// Verify the RandomX proof-of-work (skip in fake/test modes)
 	if !randomx.fakeFull && (randomx.config == nil || randomx.config.PowMode == ModeNormal) {
-		if err := randomx.verifyPoW(chain, header); err != nil {
+		if err := randomx.verifyPoW(chain, header, vm); err != nil {
 			return err
 		}
 	}
@@ -356,20 +464,25 @@ func (randomx *RandomX) verifyMedianTimePast(chain consensus.ChainHeaderReader,
 	return nil
 }
 
-// verifyPoW verifies the RandomX proof-of-work for a sealed header
-func (randomx *RandomX) verifyPoW(chain consensus.ChainHeaderReader, header *types.Header) error {
+// verifyPoW verifies the RandomX proof-of-work for a sealed header. If a leased
+// VM is given, it is used for hashing, otherwise one is leased for the call.
+// Verification always runs in light mode on the cache of the header's epoch.
+func (randomx *RandomX) verifyPoW(chain consensus.ChainHeaderReader, header *types.Header, vm *verifyVM) error {
 	blockHash := header.Hash()
+	verifyRequestsCounter.Inc(1)
 
 	// DoS protection: Check if we've recently verified this block
 	randomx.verifyMutex.Lock()
 	if randomx.recentBlocks.Contains(blockHash) {
 		randomx.verifyMutex.Unlock()
+		verifyCachedCounter.Inc(1)
 		return nil // Already verified successfully
 	}
 
 	// Check fail cache to avoid re-verifying known bad blocks
 	if err, exists := randomx.failCache.Get(blockHash); exists {
 		randomx.verifyMutex.Unlock()
+		verifyCachedFailCounter.Inc(1)
 		return err.(error) // Return cached error
 	}
 	randomx.verifyMutex.Unlock()
@@ -381,44 +494,31 @@ func (randomx *RandomX) verifyPoW(chain consensus.ChainHeaderReader, header *typ
 		return fmt.Errorf("failed to calculate RandomX seed: %w", err)
 	}
 
-	// Initialize RandomX cache with the epoch seed
-	// Cache is reused for all blocks in the same epoch (2048 blocks)
-	if err := randomx.initCache(seedHash); err != nil {
+	// Retrieve the RandomX cache of the epoch, holding a reference to it for
+	// the entire verification so it can't be freed while in use
+	entry, err := randomx.caches.acquire(seedHash)
+	if err != nil {
 		return fmt.Errorf("failed to initialize RandomX cache: %w", err)
 	}
+	defer randomx.caches.release(entry)
 
-	// Get seal hash (before locking, as it doesn't need cache)
-	sealHash := randomx.SealHash(header)
-
-	// CRITICAL: Hold read lock for entire verification to prevent cache rotation
-	// race condition where another thread could free the cache while we're using it
-	randomx.cacheMutex.RLock()
-	defer randomx.cacheMutex.RUnlock()
+	// Build the next epoch's cache in the background ahead of the transition
+	randomx.precompute(chain, header.Number.Uint64())
+	updateEpochGauge(GetEpochNumber(chain.Config(), header.Number.Uint64()))
 
-	// Verify cache key matches expected seed (prevent race where another thread
-	// rotated cache to different epoch between initCache() and RLock acquisition)
-	if randomx.cacheKey != seedHash {
-		randomx.cacheMutex.RUnlock()
-		// Cache was rotated by another thread, retry initialization
-		if err := randomx.initCache(seedHash); err != nil {
-			return fmt.Errorf("failed to re-initialize RandomX cache: %w", err)
-		}
-		randomx.cacheMutex.RLock()
-		// Check again after re-init
-		if randomx.cacheKey != seedHash {
-			return fmt.Errorf("cache key mismatch after retry for block %d", header.Number)
-		}
-	}
-
-	cache := randomx.cache
-	if cache == nil {
-		return fmt.Errorf("randomx cache not initialized for block %d", header.Number)
+	// Verify PoW with a light-mode VM bound to the cache (all C operations are
+	// in randomx.go), leasing a one-off VM if the caller didn't provide one
+	if vm == nil {
+		vm = new(verifyVM)
+		defer randomx.releaseVerifyVM(vm)
 	}
+	start := time.Now()
+	err = randomx.verifyPoWWithVM(vm, entry, randomx.SealHash(header), header)
+	verifyTimer.UpdateSince(start)
+	verifyHashedCounter.Inc(1)
+	if err != nil {
+		verifyFailedCounter.Inc(1)
 
-	// Verify PoW using the cache (all C operations are in randomx.go)
-	// Cache is protected by RLock for entire duration
-	dataset := randomx.datasetReadyLocked()
-	if err := verifyPoWWithCache(cache, dataset, sealHash, header); err != nil {
 		verifyErr := fmt.Errorf("proof-of-work verification failed: %w", err)
 		// Cache the failure to prevent re-verification attacks
 		randomx.verifyMutex.Lock()
@@ -666,7 +766,7 @@ func (randomx *RandomX) Prepare(chain consensus.ChainHeaderReader, header *types
 // Finalize implements consensus.Engine, accumulating the block and uncle rewards.
 func (randomx *RandomX) Finalize(chain consensus.ChainHeaderReader, header *types.Header, state vm.StateDB, body *types.Body) {
 	// Accumulate any block and uncle rewards
-	accumulateRewards(chain.Config(), state, header, body.Uncles)
+	accumulateRewards(chain.Config(), &randomx.emissions, state, header, body.Uncles)
 }
 
 // FinalizeAndAssemble implements consensus.Engine, accumulating the block and
@@ -675,6 +775,9 @@ func (randomx *RandomX) FinalizeAndAssemble(chain consensus.ChainHeaderReader, h
 	if len(body.Withdrawals) > 0 {
 		return nil, errors.New("randomx does not support withdrawals")
 	}
+	if header.ParentBeaconRoot != nil || header.RequestsHash != nil {
+		return nil, errors.New("randomx does not support beacon roots and requests")
+	}
 	// Finalize block
 	randomx.Finalize(chain, header, state, body)
 
@@ -719,37 +822,46 @@ func (randomx *RandomX) SealHash(header *types.Header) (hash common.Hash) {
 	if header.ParentBeaconRoot != nil {
 		panic("parent beacon root set on randomx")
 	}
+	if header.RequestsHash != nil {
+		panic("requests hash set on randomx")
+	}
 	rlp.Encode(hasher, enc)
 	hasher.Sum(hash[:0])
 	return hash
 }
 
 // accumulateRewards credits the coinbase of the given block with the mining
-// reward. The total reward consists of the static block reward and rewards for
-// included uncles. The coinbase of each uncle block is also rewarded.
-func accumulateRewards(config *params.ChainConfig, stateDB vm.StateDB, header *types.Header, uncles []*types.Header) {
-	// Select the correct block reward based on chain progression
-	blockReward := FrontierBlockReward
-	if config.IsByzantium(header.Number) {
-		blockReward = ByzantiumBlockReward
-	}
-	if config.IsConstantinople(header.Number) {
-		blockReward = ConstantinopleBlockReward
-	}
+// reward. The total reward consists of the scheduled block reward, minus the
+// share of the active treasury fund, and rewards for included uncles. The
+// coinbase of each uncle block is also rewarded.
+func accumulateRewards(config *params.ChainConfig, emissions *emissionCache, stateDB vm.StateDB, header *types.Header, uncles []*types.Header) {
+	// Select the block reward based on the chain's reward schedule
+	blockReward := blockReward(config, emissions, header.Number.Uint64())
+	uncleDivisor, nephewDivisor := uncleRewardDivisors(config)
+
 	// Accumulate the rewards for the miner and any included uncles
 	reward := new(uint256.Int).Set(blockReward)
 	r := new(uint256.Int)
 	hNum, _ := uint256.FromBig(header.Number)
 	for _, uncle := range uncles {
 		uNum, _ := uint256.FromBig(uncle.Number)
-		r.AddUint64(uNum, 8)
-		r.Sub(r, hNum)
+		r.AddUint64(uNum, uint64(uncleDepthWindow))
+		if r.Lt(hNum) {
+			r.Clear()
+		} else {
+			r.Sub(r, hNum)
+		}
 		r.Mul(r, blockReward)
-		r.Rsh(r, 3)
+		r.Div(r, uint256.NewInt(uncleDivisor))
 		stateDB.AddBalance(uncle.Coinbase, r, tracing.BalanceIncreaseRewardMineUncle)
 
-		r.Rsh(blockReward, 5)
+		r.Div(blockReward, uint256.NewInt(nephewDivisor))
 		reward.Add(reward, r)
 	}
+	// Split off the treasury share of the base block reward
+	if fund, share := fundShare(config, header.Number.Uint64(), blockReward); fund != nil {
+		reward.Sub(reward, share)
+		stateDB.AddBalance(fund.Address, share, tracing.BalanceIncreaseRewardMineBlock)
+	}
 	stateDB.AddBalance(header.Coinbase, reward, tracing.BalanceIncreaseRewardMineBlock)
 }
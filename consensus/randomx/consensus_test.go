@@ -197,3 +197,56 @@ func TestRandomXEngine(t *testing.T) {
 		}
 	})
 }
+
+// Tests that a batch of headers longer than the LWMA window verifies, the
+// difficulty and median time past of the headers depending on the ones before
+// them in the batch, not yet in the chain.
+func TestVerifyHeadersBatch(t *testing.T) {
+	config := &params.ChainConfig{
+		ChainID:        big.NewInt(33669),
+		HomesteadBlock: big.NewInt(0),
+		RandomX: &params.RandomXConfig{
+			// Start LWMA past its window, so that it averages varied difficulties
+			LWMAActivationBlock: big.NewInt(2 * LWMAWindowSize),
+		},
+	}
+	engine := New(&Config{PowMode: ModeFake})
+	defer engine.Close()
+
+	var (
+		full    = newLWMAChainReader(config)
+		genesis = &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(131072), GasLimit: 5000000, Time: 1700000000}
+		headers []*types.Header
+		parent  = genesis
+	)
+	full.addHeader(genesis)
+	for i := 1; i <= 4*LWMAWindowSize; i++ {
+		// Alternate fast and slow blocks for the difficulty to move
+		time := parent.Time + 5
+		if i%3 == 0 {
+			time = parent.Time + 30
+		}
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			UncleHash:  types.EmptyUncleHash,
+			Number:     big.NewInt(int64(i)),
+			GasLimit:   parent.GasLimit,
+			Time:       time,
+		}
+		header.Difficulty = engine.CalcDifficulty(full, header.Time, parent)
+		full.addHeader(header)
+		headers = append(headers, header)
+		parent = header
+	}
+	chain := newLWMAChainReader(config)
+	chain.addHeader(genesis)
+
+	abort, results := engine.VerifyHeaders(chain, headers)
+	defer close(abort)
+
+	for i := range headers {
+		if err := <-results; err != nil {
+			t.Fatalf("header %d: verification failed: %v", headers[i].Number, err)
+		}
+	}
+}
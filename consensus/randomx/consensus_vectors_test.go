@@ -44,7 +44,7 @@ func TestConsensusVectorEpochRotation(t *testing.T) {
 
 	for _, tv := range vectors {
 		t.Run(tv.description, func(t *testing.T) {
-			seedBlockNum := seedBlock(tv.blockNum)
+			seedBlockNum := seedBlock(nil, tv.blockNum)
 			if seedBlockNum != tv.expectedSeedBlock {
 				t.Errorf("Block %d: expected seed block %d, got %d",
 					tv.blockNum, tv.expectedSeedBlock, seedBlockNum)
@@ -517,7 +517,7 @@ func TestConsensusVectorLWMAConsistency(t *testing.T) {
 			}
 		}
 
-		burstDetected := detectHashrateBurst(blockTimes, LWMAWindowSize)
+		burstDetected := detectHashrateBurst(blockTimes, LWMAWindowSize, defaultParams)
 		if !burstDetected {
 			t.Error("Expected burst detection to trigger on fast blocks, but it didn't")
 		}
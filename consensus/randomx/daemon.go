@@ -0,0 +1,401 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Monero daemon RPC
+//
+// Monero miners such as xmrig can solo mine against a Monero daemon, fetching
+// block templates and submitting solved blocks over its JSON-RPC API. The
+// DaemonServer serves the subset of that API they use, so that they can mine
+// against geth directly instead of through the stratum proxy:
+//
+//	POST /json_rpc    get_block_template, submit_block, get_info, get_height
+//	GET  /getheight   chain height, polled for new blocks
+//	GET  /getinfo     chain status
+//
+// The block template is the 43-byte rx-eth-v1 preimage of the block being mined,
+// with a distinct extra nonce for each template so that miners don't overlap.
+// Solved templates are handed to the remote sealer like eth_submitWork, with
+// the mix digest computed by the node as daemon clients don't submit it.
+
+// Error codes of the Monero daemon RPC.
+const (
+	daemonErrParse       = -32700
+	daemonErrMethod      = -32601
+	daemonErrParams      = -32602
+	daemonErrAddress     = -2 // CORE_RPC_ERROR_CODE_WRONG_WALLET_ADDRESS
+	daemonErrReserveSize = -3 // CORE_RPC_ERROR_CODE_TOO_BIG_RESERVE_SIZE
+	daemonErrWrongBlob   = -6 // CORE_RPC_ERROR_CODE_WRONG_BLOCKBLOB
+	daemonErrNotAccepted = -7 // CORE_RPC_ERROR_CODE_BLOCK_NOT_ACCEPTED
+	daemonErrBusy        = -9 // CORE_RPC_ERROR_CODE_CORE_BUSY
+)
+
+const (
+	daemonStatusOK = "OK"
+
+	// daemonReservedOffset is the offset of the extra nonce in the template,
+	// reported as the reserved space of the miner.
+	daemonReservedOffset = 32
+
+	// daemonReserveSize is the size of the extra nonce.
+	daemonReserveSize = 4
+
+	// daemonMaxRequestSize is the size limit of the requests, far above that
+	// of a block template submission.
+	daemonMaxRequestSize = 64 * 1024
+)
+
+// daemonError is an error response of the Monero daemon RPC.
+type daemonError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *daemonError) Error() string {
+	return err.Message
+}
+
+// daemonRequest is a Monero daemon JSON-RPC request.
+type daemonRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// daemonResponse is a Monero daemon JSON-RPC response.
+type daemonResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *daemonError    `json:"error,omitempty"`
+}
+
+// daemonTemplateRequest are the parameters of get_block_template.
+type daemonTemplateRequest struct {
+	WalletAddress string `json:"wallet_address"`
+	ReserveSize   uint64 `json:"reserve_size"`
+}
+
+// daemonTemplate is the result of get_block_template.
+type daemonTemplate struct {
+	BlocktemplateBlob string `json:"blocktemplate_blob"`
+	BlockhashingBlob  string `json:"blockhashing_blob"`
+	Difficulty        uint64 `json:"difficulty"`
+	WideDifficulty    string `json:"wide_difficulty"`
+	Height            uint64 `json:"height"`
+	PrevHash          string `json:"prev_hash"`
+	ReservedOffset    uint64 `json:"reserved_offset"`
+	SeedHeight        uint64 `json:"seed_height"`
+	SeedHash          string `json:"seed_hash"`
+	NextSeedHash      string `json:"next_seed_hash"`
+	Status            string `json:"status"`
+	Untrusted         bool   `json:"untrusted"`
+}
+
+// daemonHeight is the result of get_height.
+type daemonHeight struct {
+	Height    uint64 `json:"height"`
+	Hash      string `json:"hash"`
+	Status    string `json:"status"`
+	Untrusted bool   `json:"untrusted"`
+}
+
+// daemonInfo is the result of get_info.
+type daemonInfo struct {
+	Height         uint64 `json:"height"`
+	TopBlockHash   string `json:"top_block_hash"`
+	Difficulty     uint64 `json:"difficulty"`
+	WideDifficulty string `json:"wide_difficulty"`
+	Target         uint64 `json:"target"`
+	Synchronized   bool   `json:"synchronized"`
+	Status         string `json:"status"`
+	Untrusted      bool   `json:"untrusted"`
+}
+
+// daemonSubmitResult is the result of submit_block.
+type daemonSubmitResult struct {
+	Status    string `json:"status"`
+	Untrusted bool   `json:"untrusted"`
+}
+
+// DaemonServer serves the Monero daemon mining RPC on its own listener.
+type DaemonServer struct {
+	randomx  *RandomX
+	chain    consensus.ChainHeaderReader
+	synced   func() bool // Reports whether the node caught up with the network
+	endpoint string
+
+	server     *http.Server
+	extraNonce atomic.Uint32 // Extra nonce of the last block template
+}
+
+// NewDaemonServer creates a Monero daemon RPC server for the remote sealer of
+// the engine, listening on the given endpoint once started. The synced callback
+// reports whether the node is done syncing, which miners wait for.
+func NewDaemonServer(randomx *RandomX, chain consensus.ChainHeaderReader, synced func() bool, endpoint string) *DaemonServer {
+	s := &DaemonServer{
+		randomx:  randomx,
+		chain:    chain,
+		synced:   synced,
+		endpoint: endpoint,
+	}
+	s.extraNonce.Store(rand.Uint32())
+	return s
+}
+
+// Start implements node.Lifecycle, opening the listener.
+func (s *DaemonServer) Start() error {
+	if s.randomx.remote == nil {
+		return errors.New("remote mining not supported by the RandomX engine")
+	}
+	listener, err := net.Listen("tcp", s.endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to open Monero daemon RPC endpoint: %w", err)
+	}
+	s.server = &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+	go s.server.Serve(listener)
+
+	log.Info("Monero daemon RPC endpoint opened", "url", "http://"+listener.Addr().String())
+	return nil
+}
+
+// Stop implements node.Lifecycle, closing the listener.
+func (s *DaemonServer) Stop() error {
+	if s.server != nil {
+		s.server.Close()
+		log.Info("Monero daemon RPC endpoint closed", "endpoint", s.endpoint)
+	}
+	return nil
+}
+
+// ServeHTTP dispatches the JSON-RPC and plain endpoints of the daemon.
+func (s *DaemonServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/json_rpc":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.serveJSONRPC(w, r)
+	case "/getheight", "/get_height":
+		writeDaemonJSON(w, s.getHeight())
+	case "/getinfo", "/get_info":
+		writeDaemonJSON(w, s.getInfo())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveJSONRPC answers a JSON-RPC request.
+func (s *DaemonServer) serveJSONRPC(w http.ResponseWriter, r *http.Request) {
+	var (
+		req  daemonRequest
+		resp = daemonResponse{Version: "2.0"}
+	)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, daemonMaxRequestSize)).Decode(&req); err != nil {
+		resp.Error = &daemonError{Code: daemonErrParse, Message: "Parse error"}
+		writeDaemonJSON(w, resp)
+		return
+	}
+	resp.ID = req.ID
+
+	var err error
+	switch req.Method {
+	case "get_block_template", "getblocktemplate":
+		resp.Result, err = s.getBlockTemplate(req.Params)
+	case "submit_block", "submitblock":
+		resp.Result, err = s.submitBlock(req.Params)
+	case "get_info":
+		resp.Result = s.getInfo()
+	case "get_height", "getblockcount":
+		resp.Result = s.getHeight()
+	default:
+		err = &daemonError{Code: daemonErrMethod, Message: "Method not found"}
+	}
+	if err != nil {
+		var rpcErr *daemonError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &daemonError{Code: daemonErrBusy, Message: err.Error()}
+		}
+		resp.Result, resp.Error = nil, rpcErr
+	}
+	writeDaemonJSON(w, resp)
+}
+
+// getBlockTemplate returns the blob of the block being mined, with a fresh
+// extra nonce.
+func (s *DaemonServer) getBlockTemplate(params json.RawMessage) (*daemonTemplate, error) {
+	var req daemonTemplateRequest
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, &daemonError{Code: daemonErrParams, Message: "Invalid params"}
+		}
+	}
+	if req.ReserveSize > daemonReserveSize {
+		return nil, &daemonError{Code: daemonErrReserveSize, Message: fmt.Sprintf("Too big reserved size, maximum %d", daemonReserveSize)}
+	}
+	block, work, err := s.randomx.remote.template()
+	if err != nil {
+		return nil, &daemonError{Code: daemonErrBusy, Message: err.Error()}
+	}
+	// Blocks are always mined for the etherbase of the node, refuse to hand out
+	// templates to miners expecting the rewards elsewhere
+	if common.IsHexAddress(req.WalletAddress) && common.HexToAddress(req.WalletAddress) != block.Coinbase() {
+		return nil, &daemonError{Code: daemonErrAddress, Message: fmt.Sprintf("Wallet address doesn't match the etherbase %s", block.Coinbase().Hex())}
+	}
+	remoteWorkCounter.Inc(1)
+
+	var (
+		extraNonce = s.extraNonce.Add(1)
+		blob       = hex.EncodeToString(sealPreimage(common.HexToHash(work[0]), uint64(extraNonce)<<32))
+		number     = block.NumberU64()
+	)
+	difficulty, wide := daemonDifficulty(block.Difficulty())
+	return &daemonTemplate{
+		BlocktemplateBlob: blob,
+		BlockhashingBlob:  blob,
+		Difficulty:        difficulty,
+		WideDifficulty:    wide,
+		Height:            number,
+		PrevHash:          hex.EncodeToString(block.ParentHash().Bytes()),
+		ReservedOffset:    daemonReservedOffset,
+		SeedHeight:        seedBlock(s.chain.Config(), number),
+		SeedHash:          strings.TrimPrefix(work[1], "0x"),
+		Status:            daemonStatusOK,
+	}, nil
+}
+
+// submitBlock seals the block of a solved template.
+func (s *DaemonServer) submitBlock(params json.RawMessage) (*daemonSubmitResult, error) {
+	var blobs []string
+	if err := json.Unmarshal(params, &blobs); err != nil || len(blobs) != 1 {
+		return nil, &daemonError{Code: daemonErrWrongBlob, Message: "Wrong block blob"}
+	}
+	sealHash, nonce, err := parseDaemonBlob(blobs[0])
+	if err != nil {
+		return nil, &daemonError{Code: daemonErrWrongBlob, Message: "Wrong block blob: " + err.Error()}
+	}
+	if err := s.randomx.remote.submitWork(nonce, sealHash, common.Hash{}); err != nil {
+		log.Debug("Monero daemon RPC block rejected", "hash", sealHash, "nonce", nonce.Uint64(), "err", err)
+		return nil, &daemonError{Code: daemonErrNotAccepted, Message: "Block not accepted"}
+	}
+	log.Info("Monero daemon RPC block accepted", "hash", sealHash, "nonce", nonce.Uint64())
+	return &daemonSubmitResult{Status: daemonStatusOK}, nil
+}
+
+// getHeight returns the number of blocks of the chain, which is also the
+// height of the block being mined.
+func (s *DaemonServer) getHeight() *daemonHeight {
+	head := s.chain.CurrentHeader()
+	return &daemonHeight{
+		Height: head.Number.Uint64() + 1,
+		Hash:   hex.EncodeToString(head.Hash().Bytes()),
+		Status: daemonStatusOK,
+	}
+}
+
+// getInfo returns the status of the chain.
+func (s *DaemonServer) getInfo() *daemonInfo {
+	head := s.chain.CurrentHeader()
+	difficulty, wide := daemonDifficulty(head.Difficulty)
+	return &daemonInfo{
+		Height:         head.Number.Uint64() + 1,
+		TopBlockHash:   hex.EncodeToString(head.Hash().Bytes()),
+		Difficulty:     difficulty,
+		WideDifficulty: wide,
+		Target:         activeParams(s.chain.Config(), head.Number.Uint64()).LWMATargetBlockTime,
+		Synchronized:   s.synced(),
+		Status:         daemonStatusOK,
+	}
+}
+
+// template returns the block being mined and its work package.
+func (s *remoteSealer) template() (*types.Block, [4]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.currentTask == nil {
+		return nil, [4]string{}, errNoMiningWork
+	}
+	return s.currentTask.block, s.currentWork, nil
+}
+
+// parseDaemonBlob extracts the seal hash and the nonce64 of a solved template.
+func parseDaemonBlob(blob string) (common.Hash, types.BlockNonce, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(blob, "0x"))
+	if err != nil {
+		return common.Hash{}, types.BlockNonce{}, errors.New("invalid hex")
+	}
+	if len(data) != 43 {
+		return common.Hash{}, types.BlockNonce{}, fmt.Errorf("invalid length %d, want 43", len(data))
+	}
+	if data[36] != 0 || data[37] != 0 || data[38] != 0 {
+		return common.Hash{}, types.BlockNonce{}, errors.New("padding modified")
+	}
+	var (
+		extraNonce = binary.LittleEndian.Uint32(data[32:36])
+		minerNonce = binary.LittleEndian.Uint32(data[39:43])
+	)
+	return common.BytesToHash(data[:32]), types.EncodeNonce(uint64(extraNonce)<<32 | uint64(minerNonce)), nil
+}
+
+// daemonDifficulty returns a difficulty as the 64-bit and wide fields of the
+// daemon RPC, the former saturating.
+func daemonDifficulty(difficulty *big.Int) (uint64, string) {
+	if difficulty == nil {
+		return 0, "0x0"
+	}
+	wide := fmt.Sprintf("0x%x", difficulty)
+	if !difficulty.IsUint64() {
+		return math.MaxUint64, wide
+	}
+	return difficulty.Uint64(), wide
+}
+
+// writeDaemonJSON writes a JSON response.
+func writeDaemonJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debug("Failed to write Monero daemon RPC response", "err", err)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// headChain is a chain reader only knowing its head.
+type headChain struct {
+	head *types.Header
+}
+
+func (c *headChain) Config() *params.ChainConfig                             { return nil }
+func (c *headChain) CurrentHeader() *types.Header                            { return c.head }
+func (c *headChain) GetHeader(hash common.Hash, number uint64) *types.Header { return nil }
+func (c *headChain) GetHeaderByNumber(number uint64) *types.Header           { return nil }
+func (c *headChain) GetHeaderByHash(hash common.Hash) *types.Header          { return nil }
+
+// daemonCall performs a JSON-RPC call against the daemon server.
+func daemonCall(t *testing.T, server *DaemonServer, method string, params string) daemonResponse {
+	body := `{"jsonrpc":"2.0","id":"0","method":"` + method + `","params":` + params + `}`
+	req := httptest.NewRequest(http.MethodPost, "/json_rpc", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	var resp struct {
+		daemonResponse
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("%s: invalid response: %v", method, err)
+	}
+	resp.daemonResponse.Result = resp.Result
+	return resp.daemonResponse
+}
+
+func TestDaemonBlockTemplate(t *testing.T) {
+	randomx := New(&Config{PowMode: ModeTest})
+	defer randomx.Close()
+
+	genesis := &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1)}
+	server := NewDaemonServer(randomx, &headChain{head: genesis}, func() bool { return true }, "127.0.0.1:0")
+
+	// No template until the node mines
+	if resp := daemonCall(t, server, "get_block_template", `{"reserve_size":0}`); resp.Error == nil || resp.Error.Code != daemonErrBusy {
+		t.Fatalf("template without work: have %+v", resp.Error)
+	}
+	coinbase := common.HexToAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	header := &types.Header{
+		ParentHash: genesis.Hash(),
+		Coinbase:   coinbase,
+		Number:     big.NewInt(1),
+		Difficulty: new(big.Int).Lsh(big.NewInt(1), 70),
+	}
+	pushWork(t, randomx, header)
+	if _, err := (&API{randomx}).GetWork(); err != nil {
+		t.Fatalf("failed to get work: %v", err)
+	}
+
+	var templates [2]daemonTemplate
+	for i := range templates {
+		resp := daemonCall(t, server, "get_block_template", `{"wallet_address":"`+coinbase.Hex()+`","reserve_size":4}`)
+		if resp.Error != nil {
+			t.Fatalf("get_block_template: %v", resp.Error)
+		}
+		if err := json.Unmarshal(resp.Result.(json.RawMessage), &templates[i]); err != nil {
+			t.Fatalf("invalid template: %v", err)
+		}
+	}
+	template := templates[0]
+	blob, err := hex.DecodeString(template.BlockhashingBlob)
+	if err != nil || len(blob) != 43 {
+		t.Fatalf("invalid hashing blob %q", template.BlockhashingBlob)
+	}
+	if common.BytesToHash(blob[:32]) != randomx.SealHash(header) {
+		t.Errorf("blob seal hash mismatch: have %x", blob[:32])
+	}
+	if template.Height != 1 || template.PrevHash != hex.EncodeToString(genesis.Hash().Bytes()) {
+		t.Errorf("template position mismatch: height %d, prev %s", template.Height, template.PrevHash)
+	}
+	if template.Difficulty != ^uint64(0) || template.WideDifficulty != "0x"+header.Difficulty.Text(16) {
+		t.Errorf("difficulty mismatch: have %d / %s", template.Difficulty, template.WideDifficulty)
+	}
+	if template.BlockhashingBlob[64:72] == templates[1].BlockhashingBlob[64:72] {
+		t.Errorf("templates share the extra nonce %s", template.BlockhashingBlob[64:72])
+	}
+
+	// Templates are refused to miners expecting rewards on another address
+	if resp := daemonCall(t, server, "get_block_template", `{"wallet_address":"0x0000000000000000000000000000000000000001"}`); resp.Error == nil || resp.Error.Code != daemonErrAddress {
+		t.Fatalf("template for foreign address: have %+v", resp.Error)
+	}
+	// Malformed and unknown blocks are rejected
+	padded := template.BlockhashingBlob[:72] + "01" + template.BlockhashingBlob[74:]
+	for blob, code := range map[string]int{
+		"zz":                           daemonErrWrongBlob,
+		template.BlockhashingBlob[:84]: daemonErrWrongBlob, // Truncated
+		padded:                         daemonErrWrongBlob, // Padding modified
+		strings.Repeat("00", 43):       daemonErrNotAccepted,
+	} {
+		if resp := daemonCall(t, server, "submit_block", `["`+blob+`"]`); resp.Error == nil || resp.Error.Code != code {
+			t.Errorf("submit_block(%s): have %+v, want code %d", blob, resp.Error, code)
+		}
+	}
+	if resp := daemonCall(t, server, "no_such_method", `{}`); resp.Error == nil || resp.Error.Code != daemonErrMethod {
+		t.Errorf("unknown method: have %+v", resp.Error)
+	}
+}
+
+func TestDaemonInfo(t *testing.T) {
+	randomx := New(&Config{PowMode: ModeTest})
+	defer randomx.Close()
+
+	var synced bool
+	genesis := &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1)}
+	server := NewDaemonServer(randomx, &headChain{head: genesis}, func() bool { return synced }, "127.0.0.1:0")
+
+	for _, want := range []bool{false, true} {
+		synced = want
+
+		resp := daemonCall(t, server, "get_info", `{}`)
+		if resp.Error != nil {
+			t.Fatalf("get_info: %v", resp.Error)
+		}
+		var info daemonInfo
+		if err := json.Unmarshal(resp.Result.(json.RawMessage), &info); err != nil {
+			t.Fatalf("invalid info: %v", err)
+		}
+		if info.Synchronized != want {
+			t.Errorf("synchronized mismatch: have %v, want %v", info.Synchronized, want)
+		}
+		if info.Height != 1 || info.TopBlockHash != hex.EncodeToString(genesis.Hash().Bytes()) {
+			t.Errorf("info position mismatch: height %d, top %s", info.Height, info.TopBlockHash)
+		}
+	}
+}
+
+func TestParseDaemonBlob(t *testing.T) {
+	sealHash := common.HexToHash("0x1234")
+	blob := hex.EncodeToString(sealPreimage(sealHash, 0xdeadbeef01020304))
+
+	hash, nonce, err := parseDaemonBlob(blob)
+	if err != nil {
+		t.Fatalf("failed to parse blob: %v", err)
+	}
+	if hash != sealHash || nonce.Uint64() != 0xdeadbeef01020304 {
+		t.Fatalf("blob mismatch: have %x / %x", hash, nonce.Uint64())
+	}
+}
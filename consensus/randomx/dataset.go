@@ -0,0 +1,210 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// datasetItemSize is the size of a RandomX dataset item in bytes.
+	datasetItemSize = 64
+
+	// datasetsOnDisk is the number of datasets kept in the cache directory,
+	// enough for the current and next epoch.
+	datasetsOnDisk = 2
+
+	// datasetFileVersion is the version of the dataset file format, part of
+	// the file names so that other versions are garbage collected.
+	datasetFileVersion = 1
+
+	// staleDatasetWrite is the time after which the temporary file of an
+	// unfinished dataset write is considered left over by a crash.
+	staleDatasetWrite = 10 * time.Minute
+
+	// datasetHeaderSize is the size of the header preceding the dataset items
+	// in a dataset file:
+	//
+	//	magic (8) | version (4) | checksum (4) | items (8) | seed (32) | zero (8)
+	datasetHeaderSize = 64
+)
+
+var (
+	datasetMagic = [8]byte{'R', 'X', 'D', 'A', 'T', 'A', 'S', 'T'}
+
+	// datasetChecksumTable is the CRC-32C table the dataset items are checked
+	// with, hardware accelerated on the common platforms.
+	datasetChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+	errDatasetCorrupt     = errors.New("randomx: corrupt dataset file")
+	errDatasetUnsupported = errors.New("randomx: dataset not supported by this build")
+)
+
+// datasetPath returns the file the dataset of the given seed is stored in.
+func datasetPath(dir string, seed common.Hash) string {
+	return filepath.Join(dir, fmt.Sprintf("full-R%d-%x", datasetFileVersion, seed[:8]))
+}
+
+// encodeDatasetHeader returns the file header of a dataset.
+func encodeDatasetHeader(seed common.Hash, data []byte) []byte {
+	header := make([]byte, datasetHeaderSize)
+	copy(header, datasetMagic[:])
+	binary.LittleEndian.PutUint32(header[8:], datasetFileVersion)
+	binary.LittleEndian.PutUint32(header[12:], crc32.Checksum(data, datasetChecksumTable))
+	binary.LittleEndian.PutUint64(header[16:], uint64(len(data)/datasetItemSize))
+	copy(header[24:], seed[:])
+	return header
+}
+
+// checkDatasetFile verifies that the content of a dataset file holds the
+// dataset of the given seed with the expected number of items, returning the
+// items.
+func checkDatasetFile(file []byte, seed common.Hash, items uint64) ([]byte, error) {
+	if uint64(len(file)) != datasetHeaderSize+items*datasetItemSize {
+		return nil, fmt.Errorf("%w: size %d, want %d items", errDatasetCorrupt, len(file), items)
+	}
+	header, data := file[:datasetHeaderSize], file[datasetHeaderSize:]
+	switch {
+	case !bytes.Equal(header[:8], datasetMagic[:]):
+		return nil, fmt.Errorf("%w: invalid magic %x", errDatasetCorrupt, header[:8])
+	case binary.LittleEndian.Uint32(header[8:]) != datasetFileVersion:
+		return nil, fmt.Errorf("%w: unsupported version %d", errDatasetCorrupt, binary.LittleEndian.Uint32(header[8:]))
+	case binary.LittleEndian.Uint64(header[16:]) != items:
+		return nil, fmt.Errorf("%w: %d items, want %d", errDatasetCorrupt, binary.LittleEndian.Uint64(header[16:]), items)
+	case common.BytesToHash(header[24:56]) != seed:
+		return nil, fmt.Errorf("%w: seed %x, want %x", errDatasetCorrupt, header[24:56], seed)
+	}
+	if have, want := crc32.Checksum(data, datasetChecksumTable), binary.LittleEndian.Uint32(header[12:]); have != want {
+		return nil, fmt.Errorf("%w: checksum %08x, want %08x", errDatasetCorrupt, have, want)
+	}
+	return data, nil
+}
+
+// loadDataset fills data with the dataset of the given seed stored in dir.
+// An error wrapping os.ErrNotExist is returned if there is none, and one
+// wrapping errDatasetCorrupt if the stored dataset is unusable.
+func loadDataset(dir string, seed common.Hash, data []byte) error {
+	path := datasetPath(dir, seed)
+	file, unmap, err := mmapFile(path)
+	if err != nil {
+		return err
+	}
+	defer unmap()
+
+	items, err := checkDatasetFile(file, seed, uint64(len(data)/datasetItemSize))
+	if err != nil {
+		return err
+	}
+	copy(data, items)
+
+	// Mark the dataset as recently used to spare it from garbage collection
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return nil
+}
+
+// saveDataset stores the dataset of the given seed in dir. The file is written
+// under a temporary name first, so that an interrupted write never leaves a
+// partial dataset behind.
+func saveDataset(dir string, seed common.Hash, data []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := datasetPath(dir, seed)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(encodeDatasetHeader(seed, data)); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// gcDatasets removes the stored datasets of older epochs and of other file
+// format versions, keeping the datasetsOnDisk most recently used ones.
+func gcDatasets(dir string) {
+	matches, err := filepath.Glob(filepath.Join(dir, "full-R*"))
+	if err != nil {
+		return
+	}
+	type dataset struct {
+		path string
+		used time.Time
+	}
+	var (
+		current = fmt.Sprintf("full-R%d-", datasetFileVersion)
+		keep    []dataset
+	)
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		name := filepath.Base(path)
+		if strings.HasSuffix(name, ".tmp") {
+			if time.Since(info.ModTime()) > staleDatasetWrite {
+				removeDataset(path)
+			}
+			continue
+		}
+		if !strings.HasPrefix(name, current) {
+			removeDataset(path)
+			continue
+		}
+		keep = append(keep, dataset{path, info.ModTime()})
+	}
+	sort.Slice(keep, func(i, j int) bool { return keep[i].used.After(keep[j].used) })
+	for i := datasetsOnDisk; i < len(keep); i++ {
+		removeDataset(keep[i].path)
+	}
+}
+
+// removeDataset deletes a stored dataset.
+func removeDataset(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Warn("Failed to remove RandomX dataset", "path", path, "err", err)
+		return
+	}
+	log.Info("Removed old RandomX dataset", "path", path)
+}
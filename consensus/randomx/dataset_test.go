@@ -0,0 +1,166 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func testDatasetItems(n int, fill byte) []byte {
+	data := make([]byte, n*datasetItemSize)
+	for i := range data {
+		data[i] = fill + byte(i)
+	}
+	return data
+}
+
+// Tests that a stored dataset is loaded back unchanged.
+func TestDatasetStoreLoad(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		seed = common.HexToHash("0x01")
+		data = testDatasetItems(16, 1)
+	)
+	if err := saveDataset(dir, seed, data); err != nil {
+		t.Fatalf("failed to store dataset: %v", err)
+	}
+	loaded := make([]byte, len(data))
+	if err := loadDataset(dir, seed, loaded); err != nil {
+		t.Fatalf("failed to load dataset: %v", err)
+	}
+	if !bytes.Equal(loaded, data) {
+		t.Fatal("loaded dataset differs from the stored one")
+	}
+	if _, err := os.Stat(datasetPath(dir, seed) + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file left behind: %v", err)
+	}
+}
+
+// Tests that missing and damaged datasets are reported as such.
+func TestDatasetLoadErrors(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		seed = common.HexToHash("0x01")
+		data = testDatasetItems(16, 1)
+	)
+	if err := loadDataset(dir, seed, make([]byte, len(data))); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing dataset: have %v, want %v", err, os.ErrNotExist)
+	}
+	if err := saveDataset(dir, seed, data); err != nil {
+		t.Fatalf("failed to store dataset: %v", err)
+	}
+	// Different item count, and seed colliding on the file name
+	if err := loadDataset(dir, seed, make([]byte, 2*len(data))); !errors.Is(err, errDatasetCorrupt) {
+		t.Fatalf("item count mismatch: have %v, want %v", err, errDatasetCorrupt)
+	}
+	other := seed
+	other[31] = 0x02
+	if err := loadDataset(dir, other, make([]byte, len(data))); !errors.Is(err, errDatasetCorrupt) {
+		t.Fatalf("seed mismatch: have %v, want %v", err, errDatasetCorrupt)
+	}
+	// Flipped bit in the items
+	path := datasetPath(dir, seed)
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file[datasetHeaderSize+100] ^= 0x01
+	if err := os.WriteFile(path, file, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadDataset(dir, seed, make([]byte, len(data))); !errors.Is(err, errDatasetCorrupt) {
+		t.Fatalf("flipped bit: have %v, want %v", err, errDatasetCorrupt)
+	}
+}
+
+// Tests that only the most recently used datasets are kept on disk.
+func TestDatasetGC(t *testing.T) {
+	dir := t.TempDir()
+
+	var paths []string
+	for i := 0; i < 4; i++ {
+		seed := common.Hash{byte(i + 1)}
+		if err := saveDataset(dir, seed, testDatasetItems(1, byte(i))); err != nil {
+			t.Fatalf("failed to store dataset %d: %v", i, err)
+		}
+		used := time.Now().Add(time.Duration(i-4) * time.Hour)
+		if err := os.Chtimes(datasetPath(dir, seed), used, used); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, datasetPath(dir, seed))
+	}
+	var (
+		oldVersion = filepath.Join(dir, "full-R0-0102030405060708")
+		staleTmp   = filepath.Join(dir, "full-R1-0a0b0c0d0e0f0000.tmp")
+		activeTmp  = filepath.Join(dir, "full-R1-0a0b0c0d0e0f0001.tmp")
+		unrelated  = filepath.Join(dir, "cache-R1")
+	)
+	for _, path := range []string{oldVersion, staleTmp, activeTmp, unrelated} {
+		if err := os.WriteFile(path, []byte{1}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stale := time.Now().Add(-2 * staleDatasetWrite)
+	if err := os.Chtimes(staleTmp, stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	gcDatasets(dir)
+
+	for _, path := range []string{paths[2], paths[3], activeTmp, unrelated} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s removed: %v", filepath.Base(path), err)
+		}
+	}
+	for _, path := range []string{paths[0], paths[1], oldVersion, staleTmp} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s not removed: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+// Tests that builds without dataset support stay in light mode instead of
+// attempting to build one.
+func TestDatasetUnsupported(t *testing.T) {
+	if haveDataset {
+		t.Skip("dataset supported by this build")
+	}
+	randomx := New(&Config{PowMode: ModeNormal, PrecomputeDataset: true})
+	defer randomx.Close()
+
+	if randomx.shouldUseDataset() {
+		t.Fatal("dataset used without support")
+	}
+	entry := &epochCache{seed: common.HexToHash("0x01")}
+	randomx.prepareDataset(entry)
+	randomx.precomputeDataset(entry)
+	if randomx.datasetJob != nil || randomx.nextDatasetJob != nil {
+		t.Fatal("dataset build started without support")
+	}
+	if err := randomx.ensureDatasetLocked(entry); !errors.Is(err, errDatasetUnsupported) {
+		t.Fatalf("dataset allocation error mismatch: have %v, want %v", err, errDatasetUnsupported)
+	}
+	if checkDatasetItems(nil, testDatasetItems(16, 1), nil) {
+		t.Fatal("stored dataset accepted without support")
+	}
+}
@@ -23,10 +23,13 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 // RandomX Epoch Configuration
-// Following Monero's model for cache stability and miner compatibility
+// Following Monero's model for cache stability and miner compatibility.
+// These are the defaults, chains may override them in their genesis config.
 const (
 	// EpochLength defines how many blocks before the RandomX seed changes.
 	// Using 2048 blocks (~7 hours at 13s) like Monero for:
@@ -44,11 +47,11 @@ const (
 )
 
 // seedBlock returns the block number used for RandomX seed calculation
-// at the given block height.
+// at the given block height, using the epoch parameters active at that height.
 //
 // Formula: seedBlock = (blockNumber - EpochLag) / EpochLength * EpochLength
 //
-// Examples:
+// Examples (default parameters):
 //   - Block 0-2047: seed from block 0 (genesis)
 //   - Block 2048-4095: seed from block 1984 (2048 - 64)
 //   - Block 4096-6143: seed from block 4032 (4096 - 64)
@@ -57,18 +60,20 @@ const (
 //   - Same seed for entire epoch (2048 blocks)
 //   - Seed is 64 blocks old (lag protection)
 //   - Miners can prepare cache in advance
-func seedBlock(blockNumber uint64) uint64 {
+func seedBlock(config *params.ChainConfig, blockNumber uint64) uint64 {
+	p := activeParams(config, blockNumber)
+
 	// Handle genesis and early blocks
-	if blockNumber < EpochLag {
+	if blockNumber < p.EpochLag {
 		return 0
 	}
 
 	// Calculate lagged block number
-	laggedBlock := blockNumber - EpochLag
+	laggedBlock := blockNumber - p.EpochLag
 
 	// Round down to epoch boundary
-	epochNumber := laggedBlock / EpochLength
-	return epochNumber * EpochLength
+	epochNumber := laggedBlock / p.EpochLength
+	return epochNumber * p.EpochLength
 }
 
 // calcSeedHash returns the RandomX seed hash for the given block number.
@@ -82,7 +87,7 @@ func calcSeedHash(chain consensus.ChainHeaderReader, blockNumber *big.Int) (comm
 	}
 
 	num := blockNumber.Uint64()
-	seedBlockNum := seedBlock(num)
+	seedBlockNum := seedBlock(chain.Config(), num)
 
 	// For genesis or if seed block is genesis
 	if seedBlockNum == 0 {
@@ -118,31 +123,70 @@ func (randomx *RandomX) GetSeedHash(chain consensus.ChainHeaderReader, blockNumb
 }
 
 // GetEpochNumber returns the epoch number for a given block.
-// Epoch numbers are sequential: 0, 1, 2, ...
-func GetEpochNumber(blockNumber uint64) uint64 {
-	if blockNumber < EpochLag {
+// Epoch numbers are sequential: 0, 1, 2, ... as long as the epoch parameters
+// of the chain are not changed by a fork.
+func GetEpochNumber(config *params.ChainConfig, blockNumber uint64) uint64 {
+	p := activeParams(config, blockNumber)
+	if blockNumber < p.EpochLag {
 		return 0
 	}
-	return (blockNumber - EpochLag) / EpochLength
+	return (blockNumber - p.EpochLag) / p.EpochLength
 }
 
 // IsEpochTransition returns true if the given block number is the first block
 // of a new epoch (where seed changes).
-func IsEpochTransition(blockNumber uint64) bool {
-	if blockNumber < EpochLag {
+func IsEpochTransition(config *params.ChainConfig, blockNumber uint64) bool {
+	p := activeParams(config, blockNumber)
+	if blockNumber < p.EpochLag {
 		return blockNumber == 0
 	}
-	laggedBlock := blockNumber - EpochLag
-	return laggedBlock%EpochLength == 0
+	laggedBlock := blockNumber - p.EpochLag
+	return laggedBlock%p.EpochLength == 0
 }
 
-// GetEpochTransitionBlock returns the block number where the next epoch starts.
-func GetEpochTransitionBlock(currentBlock uint64) uint64 {
-	if currentBlock < EpochLag {
-		return EpochLag
+// GetEpochTransitionBlock returns the block number where the next epoch starts,
+// assuming the epoch parameters active at currentBlock remain in effect.
+func GetEpochTransitionBlock(config *params.ChainConfig, currentBlock uint64) uint64 {
+	p := activeParams(config, currentBlock)
+	if currentBlock < p.EpochLag {
+		return p.EpochLag
 	}
-	laggedBlock := currentBlock - EpochLag
-	currentEpoch := laggedBlock / EpochLength
+	laggedBlock := currentBlock - p.EpochLag
+	currentEpoch := laggedBlock / p.EpochLength
 	nextEpoch := currentEpoch + 1
-	return (nextEpoch * EpochLength) + EpochLag
+	return (nextEpoch * p.EpochLength) + p.EpochLag
+}
+
+// precompute builds the RandomX cache of the epoch following the given block in
+// the background, so that the transition doesn't stall verification or mining.
+// The seed block of the next epoch becomes known EpochLag blocks before the
+// transition, until then there is nothing to do. If enabled, the dataset of
+// the next epoch is built too.
+func (randomx *RandomX) precompute(chain consensus.ChainHeaderReader, number uint64) {
+	config := chain.Config()
+	next := GetEpochTransitionBlock(config, number)
+	if seedBlock(config, next) >= number {
+		return // seed block not yet in the chain
+	}
+	seed, err := calcSeedHash(chain, new(big.Int).SetUint64(next))
+	if err != nil {
+		return
+	}
+	dataset := randomx.shouldUseDataset() && randomx.config != nil && randomx.config.PrecomputeDataset
+	if randomx.caches.contains(seed) && (!dataset || randomx.datasetPrecomputed(seed)) {
+		return
+	}
+	go func() {
+		entry, err := randomx.caches.acquire(seed)
+		if err != nil {
+			log.Warn("Failed to precompute RandomX cache", "epoch", GetEpochNumber(config, next), "err", err)
+			return
+		}
+		defer randomx.caches.release(entry)
+
+		log.Debug("Precomputed RandomX cache of next epoch", "epoch", GetEpochNumber(config, next), "seed", seed)
+		if dataset {
+			randomx.precomputeDataset(entry)
+		}
+	}()
 }
@@ -49,7 +49,7 @@ func TestSeedBlock(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		result := seedBlock(tt.blockNumber)
+		result := seedBlock(nil, tt.blockNumber)
 		if result != tt.expected {
 			t.Errorf("%s: seedBlock(%d) = %d, expected %d",
 				tt.description, tt.blockNumber, result, tt.expected)
@@ -74,7 +74,7 @@ func TestGetEpochNumber(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		result := GetEpochNumber(tt.blockNumber)
+		result := GetEpochNumber(nil, tt.blockNumber)
 		if result != tt.expected {
 			t.Errorf("%s: GetEpochNumber(%d) = %d, expected %d",
 				tt.description, tt.blockNumber, result, tt.expected)
@@ -100,7 +100,7 @@ func TestIsEpochTransition(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		result := IsEpochTransition(tt.blockNumber)
+		result := IsEpochTransition(nil, tt.blockNumber)
 		if result != tt.expected {
 			t.Errorf("%s: IsEpochTransition(%d) = %v, expected %v",
 				tt.description, tt.blockNumber, result, tt.expected)
@@ -124,7 +124,7 @@ func TestGetEpochTransitionBlock(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		result := GetEpochTransitionBlock(tt.currentBlock)
+		result := GetEpochTransitionBlock(nil, tt.currentBlock)
 		if result != tt.expected {
 			t.Errorf("%s: GetEpochTransitionBlock(%d) = %d, expected %d",
 				tt.description, tt.currentBlock, result, tt.expected)
@@ -307,7 +307,7 @@ func TestSeedBlockConsistencyWithMonero(t *testing.T) {
 	}
 
 	for _, tt := range moneroTests {
-		result := seedBlock(tt.height)
+		result := seedBlock(nil, tt.height)
 		if result != tt.expected {
 			t.Errorf("Monero compat: seedBlock(%d) = %d, expected %d (epoch boundary mismatch)",
 				tt.height, result, tt.expected)
@@ -110,11 +110,13 @@ func TestRandomXEndToEndVerification(t *testing.T) {
 	// Test cache initialization
 	t.Run("CacheInitialization", func(t *testing.T) {
 		seedHash, _ := engine.GetSeedHash(chain, big.NewInt(0))
-		err := engine.initCache(seedHash)
+		entry, err := engine.caches.acquire(seedHash)
 		if err != nil {
 			t.Fatalf("Cache initialization failed: %v", err)
 		}
-		if engine.cache == nil {
+		defer engine.caches.release(entry)
+
+		if entry.cache == nil {
 			t.Error("Cache should be initialized")
 		}
 		t.Log("Cache initialized successfully")
@@ -167,7 +169,7 @@ func TestRandomXEndToEndVerification(t *testing.T) {
 		}
 
 		// Test burst detection on recent blocks
-		burstDetected := detectHashrateBurst(blockTimes, len(blockTimes))
+		burstDetected := detectHashrateBurst(blockTimes, len(blockTimes), defaultParams)
 		if !burstDetected {
 			t.Error("Burst should be detected in fast blocks pattern")
 		}
@@ -254,9 +256,11 @@ func TestRandomXMiningAndVerification(t *testing.T) {
 	if err != nil {
 		t.Fatalf("GetSeedHash failed: %v", err)
 	}
-	if err := engine.initCache(seedHash); err != nil {
+	entry, err := engine.caches.acquire(seedHash)
+	if err != nil {
 		t.Fatalf("Cache init failed: %v", err)
 	}
+	defer engine.caches.release(entry)
 
 	// Perform simple mining (find valid nonce)
 	t.Log("Mining test block (low difficulty)...")
@@ -306,9 +310,11 @@ func BenchmarkRandomXHashing(b *testing.B) {
 	defer engine.Close()
 
 	seedHash := common.HexToHash("0x1234567890abcdef")
-	if err := engine.initCache(seedHash); err != nil {
+	entry, err := engine.caches.acquire(seedHash)
+	if err != nil {
 		b.Fatalf("Cache init failed: %v", err)
 	}
+	defer engine.caches.release(entry)
 
 	header := &types.Header{
 		Number:     big.NewInt(1),
@@ -320,6 +326,6 @@ func BenchmarkRandomXHashing(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		header.Nonce = types.EncodeNonce(uint64(i))
-		_ = verifyPoWWithCache(engine.cache, engine.dataset, sealHash, header)
+		_ = verifyPoWWithCache(engine.flagsForDataset(nil), entry.cache, nil, sealHash, header)
 	}
 }
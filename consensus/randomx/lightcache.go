@@ -0,0 +1,82 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+const (
+	// cacheAccesses is the number of cache lines mixed into a dataset item,
+	// each after running one of the superscalar programs.
+	cacheAccesses = 8
+
+	// cacheLines is the number of 64 byte lines in the cache.
+	cacheLines = argonMemory * argonBlockWords * 8 / datasetItemSize
+)
+
+// Constants initialising the registers of a dataset item.
+const (
+	superscalarMul0 = 6364136223846793005
+	superscalarAdd1 = 9298411001130361340
+	superscalarAdd2 = 12065312585734608966
+	superscalarAdd3 = 9306329213124626780
+	superscalarAdd4 = 5281919268842080866
+	superscalarAdd5 = 10536153434571861004
+	superscalarAdd6 = 3398623926847679864
+	superscalarAdd7 = 9549104520008361294
+)
+
+// lightCache is a RandomX cache computed in Go: the Argon2d memory and the
+// superscalar programs deriving the dataset items from it. It is enough to
+// compute hashes in light mode.
+type lightCache struct {
+	memory   []argonBlock
+	programs [cacheAccesses]*superscalarProgram
+}
+
+// newLightCache computes the cache of the given key.
+func newLightCache(key []byte) *lightCache {
+	cache := &lightCache{memory: argon2dFill(key)}
+
+	gen := newBlake2Generator(key, 0)
+	for i := range cache.programs {
+		cache.programs[i] = generateSuperscalar(gen)
+	}
+	return cache
+}
+
+// datasetItem computes the dataset item of the given number into r.
+func (cache *lightCache) datasetItem(item uint64, r *[8]uint64) {
+	r[0] = (item + 1) * superscalarMul0
+	r[1] = r[0] ^ superscalarAdd1
+	r[2] = r[0] ^ superscalarAdd2
+	r[3] = r[0] ^ superscalarAdd3
+	r[4] = r[0] ^ superscalarAdd4
+	r[5] = r[0] ^ superscalarAdd5
+	r[6] = r[0] ^ superscalarAdd6
+	r[7] = r[0] ^ superscalarAdd7
+
+	line := item
+	for _, prog := range cache.programs {
+		prog.execute(r)
+
+		// Mix in the cache line selected by the previous program
+		line %= cacheLines
+		words := cache.memory[line/16][line%16*8:]
+		for i := range r {
+			r[i] ^= words[i]
+		}
+		line = r[prog.addressReg]
+	}
+}
@@ -0,0 +1,490 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	scratchpadL1 = 16384   // Size of the L1 scratchpad level in bytes
+	scratchpadL2 = 262144  // Size of the L2 scratchpad level in bytes
+	scratchpadL3 = 2097152 // Size of the scratchpad in bytes
+
+	scratchpadL1Mask   = (scratchpadL1 - 1) &^ 7
+	scratchpadL2Mask   = (scratchpadL2 - 1) &^ 7
+	scratchpadL3Mask   = (scratchpadL3 - 1) &^ 7
+	scratchpadL3Mask64 = (scratchpadL3 - 1) &^ 63
+
+	programSize       = 256  // Number of instructions in a program
+	programIterations = 2048 // Number of iterations of a program
+	programCount      = 8    // Number of chained programs in a hash
+	programEntropy    = 16   // Number of 64-bit entropy words preceding the instructions
+	programBytes      = programEntropy*8 + programSize*8
+
+	datasetBaseSize   = 2147483648
+	datasetExtraItems = 33554368 / datasetItemSize
+	datasetAlignMask  = (datasetBaseSize - 1) &^ (datasetItemSize - 1)
+
+	conditionOffset   = 8   // Lowest bit of the CBRANCH condition mask
+	conditionMask     = 255 // CBRANCH condition mask before shifting
+	storeL3Condition  = 14  // Lowest ISTORE condition writing to the whole scratchpad
+	registerFileBytes = 256 // Size of the serialised register file
+)
+
+// Masks of the floating point registers.
+const (
+	mantissaMask        = (1 << 52) - 1
+	dynamicMantissaMask = (1 << 56) - 1
+	exponentMask        = (1 << 11) - 1
+	exponentBias        = 1023
+	scaleMask           = 0x80F0000000000000
+)
+
+// vmOpcode is the type of a VM instruction.
+type vmOpcode uint8
+
+const (
+	vmIADD_RS vmOpcode = iota
+	vmIADD_M
+	vmISUB_R
+	vmISUB_M
+	vmIMUL_R
+	vmIMUL_M
+	vmIMULH_R
+	vmIMULH_M
+	vmISMULH_R
+	vmISMULH_M
+	vmIMUL_RCP
+	vmINEG_R
+	vmIXOR_R
+	vmIXOR_M
+	vmIROR_R
+	vmIROL_R
+	vmISWAP_R
+	vmFSWAP_R
+	vmFADD_R
+	vmFADD_M
+	vmFSUB_R
+	vmFSUB_M
+	vmFSCAL_R
+	vmFMUL_R
+	vmFDIV_M
+	vmFSQRT_R
+	vmCBRANCH
+	vmCFROUND
+	vmISTORE
+	vmNOP
+)
+
+// vmFrequencies are the number of the 256 opcode values mapping to each
+// instruction type.
+var vmFrequencies = [...]int{
+	vmIADD_RS: 16, vmIADD_M: 7, vmISUB_R: 16, vmISUB_M: 7, vmIMUL_R: 16, vmIMUL_M: 4,
+	vmIMULH_R: 4, vmIMULH_M: 1, vmISMULH_R: 4, vmISMULH_M: 1, vmIMUL_RCP: 8, vmINEG_R: 2,
+	vmIXOR_R: 15, vmIXOR_M: 5, vmIROR_R: 8, vmIROL_R: 2, vmISWAP_R: 4, vmFSWAP_R: 4,
+	vmFADD_R: 16, vmFADD_M: 5, vmFSUB_R: 16, vmFSUB_M: 5, vmFSCAL_R: 6, vmFMUL_R: 32,
+	vmFDIV_M: 4, vmFSQRT_R: 6, vmCBRANCH: 25, vmCFROUND: 1, vmISTORE: 16, vmNOP: 0,
+}
+
+// vmOpcodes maps the opcode byte of an instruction to its type.
+var vmOpcodes [256]vmOpcode
+
+func init() {
+	var next int
+	for op, freq := range vmFrequencies {
+		for i := 0; i < freq; i++ {
+			vmOpcodes[next] = vmOpcode(op)
+			next++
+		}
+	}
+	if next != len(vmOpcodes) {
+		panic("randomx: instruction frequencies don't add up to 256")
+	}
+}
+
+// vmInstruction is a VM instruction decoded for the interpreter.
+type vmInstruction struct {
+	op       vmOpcode
+	dst, src int
+	useImm   bool   // Integer source replaced by srcImm
+	srcImm   uint64 // Constant source operand
+	imm      uint64 // Immediate, sign extended
+	memMask  uint64 // Scratchpad mask of the memory operand, condition mask of CBRANCH
+	shift    uint   // Shift of IADD_RS
+	target   int    // Instruction preceding the CBRANCH target
+}
+
+// lightVM computes RandomX hashes in light mode, deriving the dataset items
+// from the cache as they are accessed. It must not be used concurrently.
+type lightVM struct {
+	cache      *lightCache
+	scratchpad []byte
+	program    [programBytes]byte
+	code       [programSize]vmInstruction
+
+	r       [8]uint64
+	f, e, a [4][2]float64
+
+	mx, ma        uint32
+	readReg       [4]int
+	datasetOffset uint64
+	eMask         [2]uint64
+	rounding      roundingMode
+}
+
+// newLightVM creates a light-mode VM hashing with the given cache.
+func newLightVM(cache *lightCache) *lightVM {
+	return &lightVM{cache: cache, scratchpad: make([]byte, scratchpadL3)}
+}
+
+// hash computes the RandomX hash of the input.
+func (vm *lightVM) hash(input []byte) common.Hash {
+	seed := blake2b.Sum512(input)
+	fillAes1Rx4(&seed, vm.scratchpad)
+
+	vm.rounding = roundNearest
+	for i := 0; i < programCount-1; i++ {
+		vm.run(&seed)
+		seed = blake2b.Sum512(vm.registerFile())
+	}
+	vm.run(&seed)
+
+	// Replace the group A registers by the fingerprint of the scratchpad
+	file := vm.registerFile()
+	hashAes1Rx4(vm.scratchpad, file[192:])
+	return blake2b.Sum256(file)
+}
+
+// registerFile serialises the registers.
+func (vm *lightVM) registerFile() []byte {
+	file := make([]byte, 0, registerFileBytes)
+	for _, r := range vm.r {
+		file = binary.LittleEndian.AppendUint64(file, r)
+	}
+	for _, group := range [][4][2]float64{vm.f, vm.e, vm.a} {
+		for _, reg := range group {
+			file = binary.LittleEndian.AppendUint64(file, math.Float64bits(reg[0]))
+			file = binary.LittleEndian.AppendUint64(file, math.Float64bits(reg[1]))
+		}
+	}
+	return file
+}
+
+func (vm *lightVM) entropy(i int) uint64 {
+	return binary.LittleEndian.Uint64(vm.program[8*i:])
+}
+
+// run generates a program from the seed and executes it.
+func (vm *lightVM) run(seed *[64]byte) {
+	fillAes4Rx4(seed, vm.program[:])
+
+	// Initialise the configuration of the program from its entropy
+	for i := range vm.a {
+		vm.a[i][0] = math.Float64frombits(smallPositiveFloatBits(vm.entropy(2 * i)))
+		vm.a[i][1] = math.Float64frombits(smallPositiveFloatBits(vm.entropy(2*i + 1)))
+	}
+	vm.ma = uint32(vm.entropy(8) & datasetAlignMask)
+	vm.mx = uint32(vm.entropy(10))
+	for i, sel := 0, vm.entropy(12); i < len(vm.readReg); i, sel = i+1, sel>>1 {
+		vm.readReg[i] = 2*i + int(sel&1)
+	}
+	vm.datasetOffset = vm.entropy(13) % (datasetExtraItems + 1) * datasetItemSize
+	vm.eMask[0] = floatMask(vm.entropy(14))
+	vm.eMask[1] = floatMask(vm.entropy(15))
+
+	vm.compile()
+	vm.execute()
+}
+
+// smallPositiveFloatBits returns a float in [1, 2^32) built from the entropy.
+func smallPositiveFloatBits(entropy uint64) uint64 {
+	exponent := (entropy>>59 + exponentBias) & exponentMask
+	return exponent<<52 | entropy&mantissaMask
+}
+
+// floatMask returns the mask setting the exponent and low mantissa bits of the
+// group E registers.
+func floatMask(entropy uint64) uint64 {
+	const mask22bit = (1 << 22) - 1
+	exponent := uint64(0x300) | (entropy>>60)<<4
+	return entropy&mask22bit | exponent<<52
+}
+
+// compile decodes the instructions of the program.
+func (vm *lightVM) compile() {
+	var lastUse [8]int
+	for i := range lastUse {
+		lastUse[i] = -1
+	}
+	for i := range vm.code {
+		var (
+			raw   = vm.program[programEntropy*8+8*i:]
+			op    = vmOpcodes[raw[0]]
+			dst   = int(raw[1] % 8)
+			src   = int(raw[2] % 8)
+			mod   = raw[3]
+			imm32 = binary.LittleEndian.Uint32(raw[4:])
+			ins   = vmInstruction{op: op, dst: dst, src: src, imm: signExtend(imm32)}
+		)
+		memMask := uint64(scratchpadL2Mask)
+		if mod%4 != 0 {
+			memMask = scratchpadL1Mask
+		}
+		switch op {
+		case vmIADD_RS:
+			ins.shift = uint(mod>>2) % 4
+			if dst != superscalarDisplacementReg {
+				ins.imm = 0
+			}
+			lastUse[dst] = i
+
+		case vmIADD_M, vmISUB_M, vmIMUL_M, vmIMULH_M, vmISMULH_M, vmIXOR_M:
+			// Without a source register, the immediate addresses the whole
+			// scratchpad
+			ins.memMask = memMask
+			if src == dst {
+				ins.useImm, ins.memMask = true, scratchpadL3Mask
+			}
+			lastUse[dst] = i
+
+		case vmISUB_R, vmIMUL_R, vmIXOR_R, vmIROR_R, vmIROL_R:
+			ins.useImm, ins.srcImm = src == dst, ins.imm
+			lastUse[dst] = i
+
+		case vmIMULH_R, vmISMULH_R, vmINEG_R:
+			lastUse[dst] = i
+
+		case vmIMUL_RCP:
+			if imm32&(imm32-1) == 0 {
+				ins.op = vmNOP
+				break
+			}
+			ins.op, ins.useImm, ins.srcImm = vmIMUL_R, true, reciprocal(uint64(imm32))
+			lastUse[dst] = i
+
+		case vmISWAP_R:
+			if src == dst {
+				ins.op = vmNOP
+				break
+			}
+			lastUse[dst], lastUse[src] = i, i
+
+		case vmFSWAP_R:
+			// Swaps group F or E registers, selected by the full destination
+
+		case vmFADD_R, vmFSUB_R, vmFMUL_R:
+			ins.dst, ins.src = dst%4, src%4
+
+		case vmFADD_M, vmFSUB_M, vmFDIV_M:
+			ins.dst, ins.memMask = dst%4, memMask
+
+		case vmFSCAL_R, vmFSQRT_R:
+			ins.dst = dst % 4
+
+		case vmCBRANCH:
+			// Jump back after the last modification of the register if the
+			// condition bits are all zero, at most twice in a row
+			shift := uint(mod>>4) + conditionOffset
+			ins.imm |= 1 << shift
+			ins.imm &^= 1 << (shift - 1)
+			ins.memMask = conditionMask << shift
+			ins.target = lastUse[dst]
+			for j := range lastUse {
+				lastUse[j] = i
+			}
+
+		case vmCFROUND:
+			ins.imm = uint64(imm32 & 63)
+
+		case vmISTORE:
+			ins.memMask = memMask
+			if mod>>4 >= storeL3Condition {
+				ins.memMask = scratchpadL3Mask
+			}
+		}
+		vm.code[i] = ins
+	}
+}
+
+// execute runs the compiled program.
+func (vm *lightVM) execute() {
+	vm.r = [8]uint64{}
+
+	spAddr0, spAddr1 := vm.mx, vm.ma
+	for it := 0; it < programIterations; it++ {
+		// Load the registers from the scratchpad
+		spMix := vm.r[vm.readReg[0]] ^ vm.r[vm.readReg[1]]
+		spAddr0 = (spAddr0 ^ uint32(spMix)) & scratchpadL3Mask64
+		spAddr1 = (spAddr1 ^ uint32(spMix>>32)) & scratchpadL3Mask64
+
+		for i := range vm.r {
+			vm.r[i] ^= binary.LittleEndian.Uint64(vm.scratchpad[spAddr0+8*uint32(i):])
+		}
+		for i := range vm.f {
+			vm.f[i] = vm.loadFloats(uint64(spAddr1) + 8*uint64(i))
+		}
+		for i := range vm.e {
+			vm.e[i] = vm.loadFloats(uint64(spAddr1) + 8*uint64(4+i))
+			for j := range vm.e[i] {
+				vm.e[i][j] = math.Float64frombits(math.Float64bits(vm.e[i][j])&dynamicMantissaMask | vm.eMask[j])
+			}
+		}
+		vm.interpret()
+
+		// Mix in a dataset item and write the registers back
+		vm.mx ^= uint32(vm.r[vm.readReg[2]] ^ vm.r[vm.readReg[3]])
+		vm.mx &= datasetAlignMask
+
+		var item [8]uint64
+		vm.cache.datasetItem((vm.datasetOffset+uint64(vm.ma))/datasetItemSize, &item)
+		for i := range vm.r {
+			vm.r[i] ^= item[i]
+		}
+		vm.mx, vm.ma = vm.ma, vm.mx
+
+		for i := range vm.r {
+			binary.LittleEndian.PutUint64(vm.scratchpad[spAddr1+8*uint32(i):], vm.r[i])
+		}
+		for i := range vm.f {
+			for j := range vm.f[i] {
+				bits := math.Float64bits(vm.f[i][j]) ^ math.Float64bits(vm.e[i][j])
+				vm.f[i][j] = math.Float64frombits(bits)
+				binary.LittleEndian.PutUint64(vm.scratchpad[spAddr0+16*uint32(i)+8*uint32(j):], bits)
+			}
+		}
+		spAddr0, spAddr1 = 0, 0
+	}
+}
+
+// loadFloats converts two 32-bit signed integers of the scratchpad to floats.
+func (vm *lightVM) loadFloats(addr uint64) [2]float64 {
+	return [2]float64{
+		float64(int32(binary.LittleEndian.Uint32(vm.scratchpad[addr:]))),
+		float64(int32(binary.LittleEndian.Uint32(vm.scratchpad[addr+4:]))),
+	}
+}
+
+// interpret runs the instructions of the program once.
+func (vm *lightVM) interpret() {
+	r := &vm.r
+	for pc := 0; pc < programSize; pc++ {
+		ins := &vm.code[pc]
+
+		src := r[ins.src]
+		if ins.useImm {
+			src = ins.srcImm
+		}
+		switch ins.op {
+		case vmIADD_RS:
+			r[ins.dst] += src<<ins.shift + ins.imm
+		case vmIADD_M:
+			r[ins.dst] += vm.load64(src, ins)
+		case vmISUB_R:
+			r[ins.dst] -= src
+		case vmISUB_M:
+			r[ins.dst] -= vm.load64(src, ins)
+		case vmIMUL_R:
+			r[ins.dst] *= src
+		case vmIMUL_M:
+			r[ins.dst] *= vm.load64(src, ins)
+		case vmIMULH_R:
+			r[ins.dst], _ = bits.Mul64(r[ins.dst], src)
+		case vmIMULH_M:
+			r[ins.dst], _ = bits.Mul64(r[ins.dst], vm.load64(src, ins))
+		case vmISMULH_R:
+			r[ins.dst] = smulh(r[ins.dst], src)
+		case vmISMULH_M:
+			r[ins.dst] = smulh(r[ins.dst], vm.load64(src, ins))
+		case vmINEG_R:
+			r[ins.dst] = -r[ins.dst]
+		case vmIXOR_R:
+			r[ins.dst] ^= src
+		case vmIXOR_M:
+			r[ins.dst] ^= vm.load64(src, ins)
+		case vmIROR_R:
+			r[ins.dst] = bits.RotateLeft64(r[ins.dst], -int(src&63))
+		case vmIROL_R:
+			r[ins.dst] = bits.RotateLeft64(r[ins.dst], int(src&63))
+		case vmISWAP_R:
+			r[ins.dst], r[ins.src] = r[ins.src], r[ins.dst]
+
+		case vmFSWAP_R:
+			reg := &vm.e[ins.dst%4]
+			if ins.dst < 4 {
+				reg = &vm.f[ins.dst]
+			}
+			reg[0], reg[1] = reg[1], reg[0]
+		case vmFADD_R:
+			for j := range vm.f[ins.dst] {
+				vm.f[ins.dst][j] = fpAdd(vm.f[ins.dst][j], vm.a[ins.src][j], vm.rounding)
+			}
+		case vmFADD_M:
+			mem := vm.loadFloats((src + ins.imm) & ins.memMask)
+			for j := range vm.f[ins.dst] {
+				vm.f[ins.dst][j] = fpAdd(vm.f[ins.dst][j], mem[j], vm.rounding)
+			}
+		case vmFSUB_R:
+			for j := range vm.f[ins.dst] {
+				vm.f[ins.dst][j] = fpSub(vm.f[ins.dst][j], vm.a[ins.src][j], vm.rounding)
+			}
+		case vmFSUB_M:
+			mem := vm.loadFloats((src + ins.imm) & ins.memMask)
+			for j := range vm.f[ins.dst] {
+				vm.f[ins.dst][j] = fpSub(vm.f[ins.dst][j], mem[j], vm.rounding)
+			}
+		case vmFSCAL_R:
+			for j := range vm.f[ins.dst] {
+				vm.f[ins.dst][j] = math.Float64frombits(math.Float64bits(vm.f[ins.dst][j]) ^ scaleMask)
+			}
+		case vmFMUL_R:
+			for j := range vm.e[ins.dst] {
+				vm.e[ins.dst][j] = fpMul(vm.e[ins.dst][j], vm.a[ins.src][j], vm.rounding)
+			}
+		case vmFDIV_M:
+			mem := vm.loadFloats((src + ins.imm) & ins.memMask)
+			for j := range vm.e[ins.dst] {
+				divisor := math.Float64frombits(math.Float64bits(mem[j])&dynamicMantissaMask | vm.eMask[j])
+				vm.e[ins.dst][j] = fpDiv(vm.e[ins.dst][j], divisor, vm.rounding)
+			}
+		case vmFSQRT_R:
+			for j := range vm.e[ins.dst] {
+				vm.e[ins.dst][j] = fpSqrt(vm.e[ins.dst][j], vm.rounding)
+			}
+
+		case vmCBRANCH:
+			r[ins.dst] += ins.imm
+			if r[ins.dst]&ins.memMask == 0 {
+				pc = ins.target
+			}
+		case vmCFROUND:
+			vm.rounding = roundingMode(bits.RotateLeft64(src, -int(ins.imm)) % 4)
+		case vmISTORE:
+			binary.LittleEndian.PutUint64(vm.scratchpad[(r[ins.dst]+ins.imm)&ins.memMask:], src)
+		}
+	}
+}
+
+// load64 reads the memory operand of an instruction from the scratchpad.
+func (vm *lightVM) load64(src uint64, ins *vmInstruction) uint64 {
+	return binary.LittleEndian.Uint64(vm.scratchpad[(src+ins.imm)&ins.memMask:])
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Test vectors of the RandomX reference implementation.
+var hashVectors = []struct {
+	key   string
+	input []byte
+	hash  common.Hash
+}{
+	{
+		key:   "test key 000",
+		input: []byte("This is a test"),
+		hash:  common.HexToHash("639183aae1bf4c9a35884cb46b09cad9175f04efd7684e7262a0ac1c2f0b4e3f"),
+	},
+	{
+		key:   "test key 000",
+		input: []byte("Lorem ipsum dolor sit amet"),
+		hash:  common.HexToHash("300a0adb47603dedb42228ccb2b211104f4da45af709cd7547cd049e9489c969"),
+	},
+	{
+		key:   "test key 000",
+		input: []byte("sed do eiusmod tempor incididunt ut labore et dolore magna aliqua"),
+		hash:  common.HexToHash("c36d4ed4191e617309867ed66a443be4075014e2b061bcdaf9ce7b721d2b77a8"),
+	},
+	{
+		key:   "test key 001",
+		input: []byte("sed do eiusmod tempor incididunt ut labore et dolore magna aliqua"),
+		hash:  common.HexToHash("e9ff4503201c0c2cca26d285c93ae883f9b1d30c9eb240b820756f2d5a7905fc"),
+	},
+	{
+		key:   "test key 001",
+		input: hexutil.MustDecode("0x0b0b98bea7e805e0010a2126d287a2a0cc833d312cb786385a7c2f9de69d25537f584a9bc9977b00000000666fd8753bf61a8631f12984e3fd44f4014eca629276817b56f32e9b68bd82f416"),
+		hash:  common.HexToHash("c56414121acda1713c2f2a819d8ae38aed7c80c35c2a769298d34f03833cd5f1"),
+	},
+}
+
+// Tests that the cache and the dataset items derived from it match the
+// reference implementation.
+func TestLightCacheVectors(t *testing.T) {
+	cache := newLightCache([]byte("test key 000"))
+
+	for i, want := range map[int]uint64{0: 0x191e0e1d23c02186, 1568413: 0xf1b62fe6210bf8b1, 33554431: 0x1f47f056d05cd99b} {
+		if have := cache.memory[i/argonBlockWords][i%argonBlockWords]; have != want {
+			t.Errorf("cache word %d mismatch: have %#x, want %#x", i, have, want)
+		}
+	}
+	for item, want := range map[uint64]uint64{0: 0x680588a85ae222db, 10000000: 0x7943a1f6186ffb72, 20000000: 0x9035244d718095e1, 30000000: 0x145a5091f7853099} {
+		var r [8]uint64
+		if cache.datasetItem(item, &r); r[0] != want {
+			t.Errorf("dataset item %d mismatch: have %#x, want %#x", item, r[0], want)
+		}
+	}
+}
+
+// Tests that the Go light mode and the hashing backend the package is built
+// with, the RandomX library if cgo is enabled, agree with the reference hashes.
+func TestHashVectors(t *testing.T) {
+	var (
+		key   string
+		light *lightVM
+		cache *rxCache
+		vm    *rxVM
+	)
+	defer func() {
+		if cache != nil {
+			destroyVM(vm)
+			releaseCache(cache)
+		}
+	}()
+	for i, tt := range hashVectors {
+		if tt.key != key {
+			if cache != nil {
+				destroyVM(vm)
+				releaseCache(cache)
+			}
+			key, light = tt.key, newLightVM(newLightCache([]byte(tt.key)))
+
+			cache = allocCache(flagDefault)
+			initCache(cache, []byte(tt.key))
+			vm = createVM(flagDefault, cache, nil)
+		}
+		if have := light.hash(tt.input); have != tt.hash {
+			t.Errorf("vector %d: light mode hash mismatch: have %x, want %x", i, have, tt.hash)
+		}
+		if have := hashRandomX(vm, tt.input); have != tt.hash {
+			t.Errorf("vector %d: backend hash mismatch: have %x, want %x", i, have, tt.hash)
+		}
+	}
+}
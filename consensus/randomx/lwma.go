@@ -16,24 +16,31 @@ const (
 	LWMAWindowSize              = 60
 	LWMATargetBlockTime         = 13
 	LWMAMinDifficulty           = 1
-	LWMAMaxAdjustmentUp         = 2    // 2× max increase per block (anti-pump)
-	LWMAMaxAdjustmentDown       = 2    // 2× max decrease per block (anti-dump)
-	LWMATimestampMaxFutureDrift = 15   // 15 seconds future tolerance
-	LWMATimestampMaxPastDrift   = 91   // 91 seconds past tolerance (7× target time)
-	LWMABurstDetectionWindow    = 10   // Last 10 blocks for burst detection
-	LWMABurstThreshold          = 3    // 3× variance = burst attack suspected
-	LWMADampingFactor           = 0.9  // Damping for rapid adjustments (90%)
+	LWMAMaxAdjustmentUp         = 2   // 2× max increase per block (anti-pump)
+	LWMAMaxAdjustmentDown       = 2   // 2× max decrease per block (anti-dump)
+	LWMATimestampMaxFutureDrift = 15  // 15 seconds future tolerance
+	LWMATimestampMaxPastDrift   = 91  // 91 seconds past tolerance (7× target time)
+	LWMABurstDetectionWindow    = 10  // Last 10 blocks for burst detection
+	LWMABurstThreshold          = 3   // 3× variance = burst attack suspected
+	LWMABurstSustainedPercent   = 60  // 60%+ fast blocks = sustained burst
+	LWMADampingFactor           = 0.9 // Damping for rapid adjustments (90%)
 )
 
-// CalcDifficultyLWMA calculates difficulty using LWMA-3 algorithm
+// CalcDifficultyLWMA calculates difficulty using LWMA-3 algorithm, with the
+// window and target block time active at the height of the new block.
 func CalcDifficultyLWMA(chain consensus.ChainHeaderReader, time uint64, parent *types.Header) *big.Int {
-	if parent.Number.Uint64() < LWMAWindowSize {
+	var (
+		p          = activeParams(chain.Config(), parent.Number.Uint64()+1)
+		windowSize = int(p.LWMAWindowSize)
+		targetTime = p.LWMATargetBlockTime
+	)
+	if parent.Number.Uint64() < p.LWMAWindowSize {
 		return big.NewInt(LWMAMinDifficulty)
 	}
 
 	var (
-		blockTimes            = make([]uint64, LWMAWindowSize)
-		difficulties          = make([]*big.Int, LWMAWindowSize)
+		blockTimes            = make([]uint64, windowSize)
+		difficulties          = make([]*big.Int, windowSize)
 		weightedSolveTimeSum  = big.NewInt(0)
 		weightSum             = big.NewInt(0)
 		weightedDifficultySum = big.NewInt(0)
@@ -41,7 +48,7 @@ func CalcDifficultyLWMA(chain consensus.ChainHeaderReader, time uint64, parent *
 	)
 
 	// Collect last N blocks
-	for i := LWMAWindowSize - 1; i >= 0; i-- {
+	for i := windowSize - 1; i >= 0; i-- {
 		if currentBlock == nil || currentBlock.Number.Uint64() == 0 {
 			return big.NewInt(LWMAMinDifficulty)
 		}
@@ -53,18 +60,18 @@ func CalcDifficultyLWMA(chain consensus.ChainHeaderReader, time uint64, parent *
 	}
 
 	// Detect hashrate burst attack by analyzing recent solve time variance
-	burstDetected := detectHashrateBurst(blockTimes, LWMAWindowSize)
+	burstDetected := detectHashrateBurst(blockTimes, windowSize, p)
 
 	// Calculate LWMA with burst protection
-	for i := 0; i < LWMAWindowSize-1; i++ {
+	for i := 0; i < windowSize-1; i++ {
 		solveTime := blockTimes[i+1] - blockTimes[i]
 		if solveTime == 0 {
 			solveTime = 1
 		}
 		// Extended clipping for anti-burst protection
 		// Cap extremely slow blocks at 10× target time (was 6×)
-		if solveTime > 10*LWMATargetBlockTime {
-			solveTime = 10 * LWMATargetBlockTime
+		if solveTime > 10*targetTime {
+			solveTime = 10 * targetTime
 		}
 
 		weight := int64(i + 1)
@@ -83,8 +90,8 @@ func CalcDifficultyLWMA(chain consensus.ChainHeaderReader, time uint64, parent *
 	// Apply damping if burst detected to prevent difficulty crash after attacker leaves
 	if burstDetected {
 		// Damping: blend 90% new difficulty + 10% parent difficulty
-		dampingNum := big.NewInt(9)   // 90%
-		dampingDen := big.NewInt(10)  // 100%
+		dampingNum := big.NewInt(9)  // 90%
+		dampingDen := big.NewInt(10) // 100%
 
 		dampedDiff := new(big.Int).Mul(nextDifficulty, dampingNum)
 		dampedDiff.Div(dampedDiff, dampingDen)
@@ -127,14 +134,15 @@ func ShouldUseLWMA(config *params.ChainConfig, blockNumber *big.Int) bool {
 
 // detectHashrateBurst detects sudden hashrate changes that indicate burst mining attack
 // Returns true if suspicious burst pattern detected in recent blocks
-func detectHashrateBurst(blockTimes []uint64, windowSize int) bool {
-	if windowSize < LWMABurstDetectionWindow+1 {
+func detectHashrateBurst(blockTimes []uint64, windowSize int, p params.RandomXParams) bool {
+	burstWindow := int(p.LWMABurstDetectionWindow)
+	if windowSize < burstWindow+1 {
 		return false // Not enough blocks
 	}
 
-	// Analyze last LWMABurstDetectionWindow blocks
-	recentStart := windowSize - LWMABurstDetectionWindow - 1
-	recentSolveTimes := make([]uint64, 0, LWMABurstDetectionWindow)
+	// Analyze last burstWindow blocks
+	recentStart := windowSize - burstWindow - 1
+	recentSolveTimes := make([]uint64, 0, burstWindow)
 
 	// Calculate solve times for recent window
 	for i := recentStart; i < windowSize-1; i++ {
@@ -180,17 +188,17 @@ func detectHashrateBurst(blockTimes []uint64, windowSize int) bool {
 	// Burst detected if:
 	// 1. Recent average solve time is much faster than target (< 50% target)
 	// 2. High variance (stdDev > 2× average) indicating unstable hashrate
-	fastBurst := avgSolveTime < (LWMATargetBlockTime / 2)
+	fastBurst := avgSolveTime < (p.LWMATargetBlockTime / 2)
 	highVariance := stdDev > (2 * avgSolveTime)
 
 	// Additional check: detect if most recent blocks are consistently fast
 	veryFastBlocks := 0
 	for _, t := range recentSolveTimes {
-		if t < (LWMATargetBlockTime / 2) {
+		if t < (p.LWMATargetBlockTime / 2) {
 			veryFastBlocks++
 		}
 	}
-	sustainedBurst := veryFastBlocks > (LWMABurstDetectionWindow * 6 / 10) // 60%+ fast blocks
+	sustainedBurst := uint64(veryFastBlocks) > (p.LWMABurstDetectionWindow * p.LWMABurstSustainedPercent / 100)
 
 	return (fastBurst && highVariance) || sustainedBurst
 }
@@ -10,26 +10,33 @@ import (
 	"github.com/ethereum/go-ethereum/params"
 )
 
-type mockChainReader struct {
+type lwmaChainReader struct {
 	headers map[uint64]*types.Header
+	head    *types.Header
 	config  *params.ChainConfig
 }
 
-func newMockChainReader(config *params.ChainConfig) *mockChainReader {
-	return &mockChainReader{
+func newLWMAChainReader(config *params.ChainConfig) *lwmaChainReader {
+	return &lwmaChainReader{
 		headers: make(map[uint64]*types.Header),
 		config:  config,
 	}
 }
 
-func (m *mockChainReader) Config() *params.ChainConfig { return m.config }
-func (m *mockChainReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+func (m *lwmaChainReader) Config() *params.ChainConfig  { return m.config }
+func (m *lwmaChainReader) CurrentHeader() *types.Header { return m.head }
+func (m *lwmaChainReader) GetHeader(hash common.Hash, number uint64) *types.Header {
 	return m.headers[number]
 }
-func (m *mockChainReader) GetHeaderByNumber(number uint64) *types.Header  { return m.headers[number] }
-func (m *mockChainReader) GetHeaderByHash(hash common.Hash) *types.Header { return nil }
-func (m *mockChainReader) GetTd(hash common.Hash, number uint64) *big.Int { return big.NewInt(0) }
-func (m *mockChainReader) addHeader(header *types.Header)                 { m.headers[header.Number.Uint64()] = header }
+func (m *lwmaChainReader) GetHeaderByNumber(number uint64) *types.Header  { return m.headers[number] }
+func (m *lwmaChainReader) GetHeaderByHash(hash common.Hash) *types.Header { return nil }
+func (m *lwmaChainReader) GetTd(hash common.Hash, number uint64) *big.Int { return big.NewInt(0) }
+func (m *lwmaChainReader) addHeader(header *types.Header) {
+	m.headers[header.Number.Uint64()] = header
+	if m.head == nil || header.Number.Cmp(m.head.Number) > 0 {
+		m.head = header
+	}
+}
 
 func TestLWMABasic(t *testing.T) {
 	config := &params.ChainConfig{
@@ -38,7 +45,7 @@ func TestLWMABasic(t *testing.T) {
 		RandomX:        &params.RandomXConfig{},
 	}
 
-	chain := newMockChainReader(config)
+	chain := newLWMAChainReader(config)
 	genesis := &types.Header{
 		Number:     big.NewInt(0),
 		Time:       1000,
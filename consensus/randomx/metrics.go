@@ -0,0 +1,55 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import "github.com/ethereum/go-ethereum/metrics"
+
+var (
+	// Proof-of-work verification. Requests answered from the recentBlocks or
+	// failCache caches are counted separately from the ones that were hashed,
+	// so that their hit rates are verifyCached / verifyRequests and
+	// verifyCachedFail / verifyRequests.
+	verifyRequestsCounter   = metrics.NewRegisteredCounter("randomx/verify/requests", nil)
+	verifyCachedCounter     = metrics.NewRegisteredCounter("randomx/verify/cached", nil)
+	verifyCachedFailCounter = metrics.NewRegisteredCounter("randomx/verify/cachedfail", nil)
+	verifyHashedCounter     = metrics.NewRegisteredCounter("randomx/verify/hashed", nil)
+	verifyFailedCounter     = metrics.NewRegisteredCounter("randomx/verify/failed", nil)
+	verifyTimer             = metrics.NewRegisteredTimer("randomx/verify/time", nil)
+
+	// Cache and dataset lifecycle
+	cacheInitTimer    = metrics.NewRegisteredTimer("randomx/cache/init", nil)
+	datasetBuildTimer = metrics.NewRegisteredTimer("randomx/dataset/build", nil)
+	datasetLoadTimer  = metrics.NewRegisteredTimer("randomx/dataset/load", nil)
+	epochGauge        = metrics.NewRegisteredGauge("randomx/epoch", nil)
+
+	// Remote mining
+	remoteWorkCounter     = metrics.NewRegisteredCounter("randomx/remote/work", nil)
+	remoteAcceptedCounter = metrics.NewRegisteredCounter("randomx/remote/solutions/accepted", nil)
+	remoteRejectedCounter = metrics.NewRegisteredCounter("randomx/remote/solutions/rejected", nil)
+
+	// Hash rates, refreshed by the remote sealer
+	localHashrateGauge  = metrics.NewRegisteredGaugeFloat64("randomx/hashrate/local", nil)
+	remoteHashrateGauge = metrics.NewRegisteredGaugeFloat64("randomx/hashrate/remote", nil)
+)
+
+// updateEpochGauge reports the epoch of a verified or sealed block, unless an
+// older block is being processed, e.g. on a side chain.
+func updateEpochGauge(epoch uint64) {
+	if int64(epoch) > epochGauge.Snapshot().Value() {
+		epochGauge.Update(int64(epoch))
+	}
+}
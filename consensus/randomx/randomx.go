@@ -15,75 +15,70 @@
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
 // Package randomx implements the RandomX proof-of-work consensus engine.
+//
+// Hashes are computed with the RandomX C library if cgo is enabled. Otherwise a
+// pure Go implementation of the light mode is used, fast enough to verify
+// blocks but not to mine them.
 package randomx
 
-/*
-#cgo CFLAGS: -O3 -march=native
-#cgo LDFLAGS: -lrandomx -lm -lstdc++
-
-#include <stdlib.h>
-
-// Forward declarations for RandomX C API
-typedef struct randomx_cache randomx_cache;
-typedef struct randomx_dataset randomx_dataset;
-typedef struct randomx_vm randomx_vm;
-
-// RandomX flags
-typedef enum {
-	RANDOMX_FLAG_DEFAULT = 0,
-	RANDOMX_FLAG_LARGE_PAGES = 1,
-	RANDOMX_FLAG_HARD_AES = 2,
-	RANDOMX_FLAG_FULL_MEM = 4,
-	RANDOMX_FLAG_JIT = 8,
-	RANDOMX_FLAG_SECURE = 16,
-	RANDOMX_FLAG_ARGON2_SSSE3 = 32,
-	RANDOMX_FLAG_ARGON2_AVX2 = 64,
-	RANDOMX_FLAG_ARGON2 = 96
-} randomx_flags;
-
-// RandomX C API functions
-extern randomx_cache *randomx_alloc_cache(randomx_flags flags);
-extern void randomx_init_cache(randomx_cache *cache, const void *key, size_t keySize);
-extern void randomx_release_cache(randomx_cache* cache);
-
-extern randomx_dataset *randomx_alloc_dataset(randomx_flags flags);
-extern unsigned long randomx_dataset_item_count(void);
-extern void randomx_init_dataset(randomx_dataset *dataset, randomx_cache *cache, unsigned long startItem, unsigned long itemCount);
-extern void randomx_release_dataset(randomx_dataset *dataset);
-
-extern randomx_vm *randomx_create_vm(randomx_flags flags, randomx_cache *cache, randomx_dataset *dataset);
-extern void randomx_vm_set_cache(randomx_vm *machine, randomx_cache* cache);
-extern void randomx_vm_set_dataset(randomx_vm *machine, randomx_dataset *dataset);
-extern void randomx_destroy_vm(randomx_vm *machine);
-
-extern void randomx_calculate_hash(randomx_vm *machine, const void *input, size_t inputSize, void *output);
-extern void randomx_calculate_hash_first(randomx_vm *machine, const void *input, size_t inputSize);
-extern void randomx_calculate_hash_next(randomx_vm *machine, const void *input, size_t inputSize, void *output);
-*/
-import "C"
 import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
+	"net/http"
+	"os"
 	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
-	"unsafe"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	lru "github.com/ethereum/go-ethereum/common/lru"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 )
 
+// Flags of the RandomX library, selecting the features the caches, datasets
+// and VMs are created with.
+const (
+	flagDefault    rxFlags = 0
+	flagLargePages rxFlags = 1
+	flagHardAES    rxFlags = 2
+	flagFullMem    rxFlags = 4
+	flagJIT        rxFlags = 8
+)
+
 var (
-	optimalFlagsOnce  sync.Once
-	optimalFlagsValue = C.randomx_flags(C.RANDOMX_FLAG_DEFAULT | C.RANDOMX_FLAG_HARD_AES)
+	optimalFlagsLock  sync.Mutex
+	optimalFlagsCache = make(map[[2]bool]rxFlags) // (hugepages, jit) -> probed flags
+)
+
+const (
+	// hashrateMarkInterval is the number of hashes a mining thread accumulates
+	// before reporting them to the hashrate meter.
+	hashrateMarkInterval = 64
+
+	// cachedEpochs is the number of RandomX caches kept in memory, enough for
+	// the previous, current and next epoch.
+	cachedEpochs = 3
+
+	// notifyTimeout is the time allowed for a notification URL to accept a new
+	// work package.
+	notifyTimeout = time.Second
+
+	// maxWorkTemplates is the number of work templates tracked for remote
+	// miners at once, the oldest ones are dropped beyond.
+	maxWorkTemplates = 256
 )
 
 // RandomX is a consensus engine based on proof-of-work implementing the RandomX
@@ -92,21 +87,27 @@ type RandomX struct {
 	config *Config
 
 	// Caching and dataset
-	cache           *C.randomx_cache
-	dataset         *C.randomx_dataset
-	cacheKey        common.Hash
-	cacheMutex      sync.RWMutex
-	datasetDisabled atomic.Bool
+	caches          *cacheManager // Caches of the recently used epochs
+	dataset         *rxDataset    // Dataset of the epoch being mined
 	datasetJob      *datasetBuild
+	datasetMutex    sync.RWMutex // Protects the dataset, held for reading while mining on it
+	datasetDisabled atomic.Bool
+
+	nextDataset      *rxDataset // Dataset precomputed for the next epoch
+	nextDatasetJob   *datasetBuild
+	nextDatasetMutex sync.Mutex // Protects the precomputed dataset, taken after datasetMutex
 
-	// VM pool for parallel mining
-	vmPool *VMPool
+	threads int        // Number of threads to mine on if mining
+	lock    sync.Mutex // Protects the mining thread count
 
 	// Remote mining support
-	remote *remoteSealer
+	remote    *remoteSealer
+	closeOnce sync.Once // Ensures the remote sealer is stopped only once
 
 	// Hashrate tracking
-	hashrate metrics.Meter
+	hashrate *metrics.Meter
+
+	emissions emissionCache // Emission checkpoints of the chain's reward schedule
 
 	// DoS protection
 	recentBlocks *lru.Cache[common.Hash, bool]  // Cache of recently verified blocks to prevent re-verification attacks
@@ -160,7 +161,9 @@ func (b *datasetBuild) ready() bool {
 
 // Config are the configuration parameters of the RandomX consensus engine.
 type Config struct {
-	// CacheDir is the directory for storing the RandomX cache/dataset
+	// CacheDir is the directory the datasets of the current and next epoch are
+	// stored in, to be reused after a restart instead of being rebuilt. They
+	// are not stored if empty.
 	CacheDir string
 
 	// PowMode defines the mining mode (normal, test, fake, etc.)
@@ -170,6 +173,37 @@ type Config struct {
 	// This keeps memory usage low (useful for tests or constrained
 	// environments) at the cost of significantly reduced hash rate.
 	LightMode bool
+
+	// Threads is the number of threads the local miner searches with
+	// (0 = all cores).
+	Threads int
+
+	// HugePages allows allocating the cache and dataset in large pages if the
+	// operating system has them reserved.
+	HugePages bool
+
+	// JIT enables the RandomX JIT compiler if the platform supports it,
+	// otherwise programs are interpreted.
+	JIT bool
+
+	// PrecomputeDataset builds the dataset of the next epoch in the background
+	// before the transition, temporarily holding two datasets in memory.
+	PrecomputeDataset bool
+
+	// Notify lists HTTP URLs that are sent each new work package in a POST
+	// request, so that remote miners don't have to poll for work.
+	Notify []string
+
+	// NotifyFull sends the full pending block header to the Notify URLs instead
+	// of the work package.
+	NotifyFull bool
+}
+
+// DefaultConfig contains the default settings of the RandomX engine.
+var DefaultConfig = Config{
+	PowMode:   ModeNormal,
+	HugePages: true,
+	JIT:       true,
 }
 
 // Mode defines the type of PoW mode
@@ -182,14 +216,15 @@ const (
 	ModeFullFake
 )
 
-// VMPool manages a pool of RandomX VMs for parallel mining
+// VMPool manages a pool of RandomX VMs bound to the same cache or dataset
 type VMPool struct {
-	vms      []*C.randomx_vm
+	vms      []*rxVM
 	mu       sync.Mutex
-	cache    *C.randomx_cache
-	dataset  *C.randomx_dataset
-	flags    C.randomx_flags
+	cache    *rxCache
+	dataset  *rxDataset
+	flags    rxFlags
 	poolSize int
+	closed   bool
 }
 
 // sealWork wraps a seal block with relative result channel.
@@ -207,6 +242,12 @@ type mineResult struct {
 	errc chan error
 }
 
+// sealedWork is a submitted solution whose proof-of-work checked out.
+type sealedWork struct {
+	result *mineResult
+	block  *types.Block // Block sealed with the solution
+}
+
 // hashrate wraps the hash rate submitted by the remote sealer.
 type hashrate struct {
 	id   common.Hash
@@ -222,6 +263,7 @@ type sealTask struct {
 	results  chan<- *types.Block
 	chain    consensus.ChainHeaderReader
 	sealHash common.Hash
+	template bool // Built for a remote miner, only valid on top of the head
 }
 
 // remoteSealer wraps the actual sealing work and listens for work requests and
@@ -233,12 +275,22 @@ type remoteSealer struct {
 	rates       map[common.Hash]hashrate
 	currentTask *sealTask
 	currentWork [4]string
-	notifyCtx   []chan [4]string // Notification channels for new work
-	reqWG       sync.WaitGroup   // Tracks remote sealing threads
+	templates   []common.Hash // Seal hashes of the work templates, oldest first
 	mutex       sync.Mutex
 
+	// New work notifications
+	workSubs     []chan [4]string   // Subscribers to new work packages
+	notifyURLs   []string           // HTTP URLs sent new work packages
+	notifyFull   bool               // Send the pending header instead of the work package
+	notifyCtx    context.Context    // Context of the notification requests
+	cancelNotify context.CancelFunc // Aborts the pending notification requests on exit
+	reqWG        sync.WaitGroup     // Tracks the pending notification requests and solution checks
+
+	verifySem chan struct{} // Limits the solutions checked concurrently
+
 	fetchWorkCh  chan *sealWork
 	submitWorkCh chan *mineResult
+	sealedCh     chan *sealedWork
 	submitRateCh chan *hashrate
 	fetchRateCh  chan chan uint64
 	requestExit  chan struct{}
@@ -251,9 +303,8 @@ type remoteSealer struct {
 // New creates a full-featured RandomX consensus engine with the given configuration.
 func New(config *Config) *RandomX {
 	if config == nil {
-		config = &Config{
-			PowMode: ModeNormal,
-		}
+		defaults := DefaultConfig
+		config = &defaults
 	}
 
 	// Initialize DoS protection caches
@@ -262,10 +313,12 @@ func New(config *Config) *RandomX {
 
 	randomx := &RandomX{
 		config:       config,
-		hashrate:     *metrics.NewMeter(),
+		threads:      config.Threads,
+		hashrate:     metrics.NewMeter(),
 		recentBlocks: recentBlocks,
 		failCache:    failCache,
 	}
+	randomx.caches = newCacheManager(cachedEpochs, randomx.flags)
 	randomx.remote = startRemoteSealer(randomx)
 
 	log.Info("RandomX DoS protection enabled", "blockCache", 1024, "failCache", 256)
@@ -275,12 +328,19 @@ func New(config *Config) *RandomX {
 
 // startRemoteSealer starts the remote sealer goroutine.
 func startRemoteSealer(randomx *RandomX) *remoteSealer {
+	ctx, cancel := context.WithCancel(context.Background())
 	sealer := &remoteSealer{
 		randomx:      randomx,
 		works:        make(map[common.Hash]*sealTask),
 		rates:        make(map[common.Hash]hashrate),
+		notifyURLs:   randomx.config.Notify,
+		notifyFull:   randomx.config.NotifyFull,
+		notifyCtx:    ctx,
+		cancelNotify: cancel,
+		verifySem:    make(chan struct{}, runtime.NumCPU()),
 		fetchWorkCh:  make(chan *sealWork),
 		submitWorkCh: make(chan *mineResult),
+		sealedCh:     make(chan *sealedWork),
 		submitRateCh: make(chan *hashrate),
 		fetchRateCh:  make(chan chan uint64),
 		requestExit:  make(chan struct{}),
@@ -297,182 +357,343 @@ func startRemoteSealer(randomx *RandomX) *remoteSealer {
 // all blocks' seal as valid, though they still have to conform to the Ethereum
 // consensus rules.
 func NewFaker() *RandomX {
-	return &RandomX{
+	randomx := &RandomX{
+		hashrate: metrics.NewMeter(),
 		fakeFull: false,
 	}
+	randomx.caches = newCacheManager(cachedEpochs, randomx.flags)
+	return randomx
 }
 
 // NewFakeFailer creates a RandomX consensus engine with a fake PoW scheme that
 // accepts all blocks as valid apart from the single one specified, though they
 // still have to conform to the Ethereum consensus rules.
 func NewFakeFailer(fail uint64) *RandomX {
-	return &RandomX{
+	randomx := &RandomX{
+		hashrate: metrics.NewMeter(),
 		fakeFail: &fail,
 	}
+	randomx.caches = newCacheManager(cachedEpochs, randomx.flags)
+	return randomx
 }
 
 // NewFakeDelayer creates a RandomX consensus engine with a fake PoW scheme that
 // accepts all blocks as valid, but delays verifications by some time, though
 // they still have to conform to the Ethereum consensus rules.
 func NewFakeDelayer(delay time.Duration) *RandomX {
-	return &RandomX{
+	randomx := &RandomX{
+		hashrate:  metrics.NewMeter(),
 		fakeDelay: &delay,
 	}
+	randomx.caches = newCacheManager(cachedEpochs, randomx.flags)
+	return randomx
 }
 
 // NewFullFaker creates a RandomX consensus engine with a full fake scheme that
 // accepts all blocks as valid, without checking any consensus rules whatsoever.
 func NewFullFaker() *RandomX {
-	return &RandomX{
+	randomx := &RandomX{
+		hashrate: metrics.NewMeter(),
 		fakeFull: true,
 	}
+	randomx.caches = newCacheManager(cachedEpochs, randomx.flags)
+	return randomx
 }
 
-// getOptimalFlags returns the best RandomX flags for this system with fallback
-func getOptimalFlags() C.randomx_flags {
-	optimalFlagsOnce.Do(func() {
-		// Try optimal flags: JIT + HardAES + Large Pages (best performance)
-		optimal := C.randomx_flags(C.RANDOMX_FLAG_JIT | C.RANDOMX_FLAG_HARD_AES | C.RANDOMX_FLAG_LARGE_PAGES)
-
-		testCache := C.randomx_alloc_cache(optimal)
-		if testCache != nil {
-			C.randomx_release_cache(testCache)
-			optimalFlagsValue = optimal
-			log.Info("RandomX using optimal flags", "jit", true, "hugepages", true, "hardAES", true)
-			return
-		}
+// getOptimalFlags returns the best RandomX flags for this system with fallback,
+// limited to the features allowed by the caller. The probe result is cached for
+// every combination of allowed features.
+func getOptimalFlags(hugePages, jit bool) rxFlags {
+	optimalFlagsLock.Lock()
+	defer optimalFlagsLock.Unlock()
 
-		// Fallback 1: JIT + HardAES (no huge pages)
-		fallback1 := C.randomx_flags(C.RANDOMX_FLAG_JIT | C.RANDOMX_FLAG_HARD_AES)
-		testCache = C.randomx_alloc_cache(fallback1)
-		if testCache != nil {
-			C.randomx_release_cache(testCache)
-			optimalFlagsValue = fallback1
-			log.Warn("RandomX using JIT without huge pages (performance -30%)", "jit", true, "hugepages", false)
-			return
-		}
+	key := [2]bool{hugePages, jit}
+	if flags, ok := optimalFlagsCache[key]; ok {
+		return flags
+	}
+	flags := probeFlags(hugePages, jit)
+	optimalFlagsCache[key] = flags
+	return flags
+}
 
-		// Fallback 2: HardAES only (no JIT, no huge pages) - slowest but stable
-		optimalFlagsValue = C.randomx_flags(C.RANDOMX_FLAG_DEFAULT | C.RANDOMX_FLAG_HARD_AES)
-		log.Warn("RandomX using interpreted mode (performance -10-15×)", "jit", false, "hugepages", false,
-			"hint", "Enable huge pages: sudo sysctl -w vm.nr_hugepages=1280")
-	})
-	return optimalFlagsValue
+// flags returns the RandomX flags to use for caches and light-mode VMs,
+// honouring the huge page and JIT settings of the engine configuration.
+func (randomx *RandomX) flags() rxFlags {
+	config := randomx.config
+	if config == nil {
+		config = &DefaultConfig
+	}
+	return getOptimalFlags(config.HugePages, config.JIT)
 }
 
-func withFullMemory(flags C.randomx_flags) C.randomx_flags {
-	return flags | C.randomx_flags(C.RANDOMX_FLAG_FULL_MEM)
+func withFullMemory(flags rxFlags) rxFlags {
+	return flags | flagFullMem
 }
 
-func flagsForDataset(dataset *C.randomx_dataset) C.randomx_flags {
-	flags := getOptimalFlags()
+func (randomx *RandomX) flagsForDataset(dataset *rxDataset) rxFlags {
+	flags := randomx.flags()
 	if dataset != nil {
 		flags = withFullMemory(flags)
 	}
 	return flags
 }
 
-// initCache initializes the RandomX cache with the given key
-func (randomx *RandomX) shouldUseDataset() bool {
-	if randomx.config == nil {
-		return true
+// epochCache is a RandomX cache initialised with the seed of an epoch. It is
+// shared through reference counting and released together with all the VMs
+// bound to it once it is evicted from the cache manager and unused.
+type epochCache struct {
+	seed  common.Hash
+	cache *rxCache
+	done  chan struct{} // Closed once the cache is initialised (or failed to)
+	err   error         // Initialisation failure, valid once done is closed
+	refs  int           // Number of users, plus one while cached (protected by the manager lock)
+
+	verifyPool *VMPool    // Light-mode VMs for verification, created lazily
+	minePool   *VMPool    // VMs for local mining, recreated if the dataset changes
+	poolLock   sync.Mutex // Protects the VM pools
+}
+
+// cacheManager keeps the caches of the most recently used epochs, so that
+// verifying blocks around an epoch transition doesn't rebuild them over and
+// over again.
+type cacheManager struct {
+	caches lru.BasicLRU[common.Hash, *epochCache]
+	flags  func() rxFlags // Flags to allocate new caches with
+	lock   sync.Mutex
+}
+
+// newCacheManager creates a cache manager holding at most limit caches.
+func newCacheManager(limit int, flags func() rxFlags) *cacheManager {
+	return &cacheManager{
+		caches: lru.NewBasicLRU[common.Hash, *epochCache](limit),
+		flags:  flags,
 	}
-	if randomx.config.LightMode {
-		return false
+}
+
+// acquire returns the cache of the given seed, creating it if needed. The
+// cache must be released after use.
+func (m *cacheManager) acquire(seed common.Hash) (*epochCache, error) {
+	m.lock.Lock()
+	entry, ok := m.caches.Get(seed)
+	if !ok {
+		entry = &epochCache{seed: seed, done: make(chan struct{}), refs: 1}
+		if _, evicted, ok := m.caches.Add3(seed, entry); ok {
+			m.releaseLocked(evicted)
+		}
 	}
-	return randomx.config.PowMode == ModeNormal
+	entry.refs++
+	m.lock.Unlock()
+
+	// Initialise new caches outside the lock, others wait for them to finish
+	if !ok {
+		m.initialise(entry)
+	}
+	<-entry.done
+	if entry.err != nil {
+		m.lock.Lock()
+		if cached, ok := m.caches.Peek(seed); ok && cached == entry {
+			m.caches.Remove(seed)
+			m.releaseLocked(entry)
+		}
+		m.releaseLocked(entry)
+		m.lock.Unlock()
+		return nil, entry.err
+	}
+	return entry, nil
 }
 
-func (randomx *RandomX) ensureDatasetLocked(flags C.randomx_flags) error {
-	if randomx.cache == nil {
-		return errors.New("randomx: cache must be initialised before dataset")
+// initialise allocates the cache of an entry and initialises it with its seed.
+func (m *cacheManager) initialise(entry *epochCache) {
+	defer close(entry.done)
+
+	start := time.Now()
+	cache := allocCache(m.flags())
+	if cache == nil {
+		entry.err = errors.New("randomx: failed to allocate cache")
+		return
 	}
+	initCache(cache, entry.seed[:])
+	entry.cache = cache
+	cacheInitTimer.UpdateSince(start)
+
+	log.Debug("Initialised RandomX cache", "seed", entry.seed, "elapsed", common.PrettyDuration(time.Since(start)))
+}
 
-	datasetFlags := withFullMemory(flags)
+// contains reports whether the cache of the given seed is available or being
+// initialised.
+func (m *cacheManager) contains(seed common.Hash) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 
-	// Reset the disabled flag since we're about to (re)attempt full dataset mode.
-	randomx.datasetDisabled.Store(false)
+	return m.caches.Contains(seed)
+}
 
-	if randomx.dataset == nil {
-		log.Info("Allocating RandomX dataset (full mode)")
-		randomx.dataset = C.randomx_alloc_dataset(datasetFlags)
-		if randomx.dataset == nil {
-			return errors.New("randomx: failed to allocate dataset")
+// retain adds a reference to an already acquired cache.
+func (m *cacheManager) retain(entry *epochCache) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	entry.refs++
+}
+
+// release drops a reference to a cache.
+func (m *cacheManager) release(entry *epochCache) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.releaseLocked(entry)
+}
+
+// releaseLocked drops a reference to a cache, freeing it and its VMs if it
+// was the last one. The manager lock must be held.
+func (m *cacheManager) releaseLocked(entry *epochCache) {
+	if entry.refs--; entry.refs > 0 {
+		return
+	}
+	entry.poolLock.Lock()
+	for _, pool := range []*VMPool{entry.verifyPool, entry.minePool} {
+		if pool != nil {
+			pool.Close()
 		}
 	}
+	entry.verifyPool, entry.minePool = nil, nil
+	entry.poolLock.Unlock()
 
-	randomx.startDatasetBuildLocked()
-	return nil
+	if entry.cache != nil {
+		releaseCache(entry.cache)
+		entry.cache = nil
+	}
 }
 
-func (randomx *RandomX) initCache(key common.Hash) error {
-	randomx.cacheMutex.Lock()
-	defer randomx.cacheMutex.Unlock()
-
-	// Check if cache is already initialized with the same key
-	if randomx.cache != nil && randomx.cacheKey == key {
-		return nil
+// close drops all cached entries. Caches still in use are freed once their
+// last user releases them.
+func (m *cacheManager) close() {
+	if m == nil {
+		return
 	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
 
-	// Release old cache if exists
-	if randomx.cache != nil {
-		C.randomx_release_cache(randomx.cache)
-		randomx.cache = nil
+	for {
+		_, entry, ok := m.caches.RemoveOldest()
+		if !ok {
+			return
+		}
+		m.releaseLocked(entry)
 	}
+}
 
-	// Get optimal flags with automatic fallback
-	flags := getOptimalFlags()
+// leaseVM takes a light-mode VM bound to the cache from its verification
+// pool, creating the pool with one VM per CPU on first use.
+func (entry *epochCache) leaseVM(flags rxFlags) *rxVM {
+	entry.poolLock.Lock()
+	defer entry.poolLock.Unlock()
 
-	// Allocate and initialize cache
-	randomx.cache = C.randomx_alloc_cache(flags)
-	if randomx.cache == nil {
-		return errors.New("randomx: failed to allocate cache")
+	if entry.verifyPool == nil {
+		entry.verifyPool = NewVMPool(entry.cache, nil, flags, runtime.NumCPU())
 	}
+	return entry.verifyPool.Get()
+}
 
-	keyPtr := (*C.char)(unsafe.Pointer(&key[0]))
-	C.randomx_init_cache(randomx.cache, unsafe.Pointer(keyPtr), C.size_t(len(key)))
-	randomx.cacheKey = key
+// returnVM hands a leased VM back to the verification pool of the cache. If
+// the cache was freed in the meantime, the VM is destroyed.
+func (entry *epochCache) returnVM(vm *rxVM) {
+	entry.poolLock.Lock()
+	defer entry.poolLock.Unlock()
 
-	// CRITICAL: Update VMPool cache references when epoch changes
-	// Without this, VMs in the pool would use stale cache from previous epoch
-	if randomx.vmPool != nil {
-		randomx.vmPool.UpdateCache(randomx.cache)
-		log.Debug("Updated VMPool cache references for new epoch", "seed", key.Hex()[:16])
+	if entry.verifyPool != nil {
+		entry.verifyPool.Put(vm)
+	} else {
+		destroyVM(vm)
 	}
+}
 
-	if randomx.shouldUseDataset() {
-		if randomx.datasetDisabled.Load() {
-			if job := randomx.datasetJob; job == nil || job.seed != key {
-				// Retry on new epoch or when no build is running for this seed.
-				randomx.datasetDisabled.Store(false)
-			}
-		}
-		if !randomx.datasetDisabled.Load() {
-			if err := randomx.ensureDatasetLocked(flags); err != nil {
-				log.Warn("RandomX dataset unavailable, continuing in light mode", "err", err)
-				randomx.datasetDisabled.Store(true)
-			}
-		}
+// miningPool returns the VM pool used by the local miner, (re)creating it if
+// the dataset availability changed since it was built or if it is too small for
+// the requested number of threads.
+func (entry *epochCache) miningPool(dataset *rxDataset, flags rxFlags, threads int) *VMPool {
+	entry.poolLock.Lock()
+	defer entry.poolLock.Unlock()
+
+	if pool := entry.minePool; pool != nil && pool.dataset == dataset && pool.poolSize >= threads {
+		return pool
+	}
+	if entry.minePool != nil {
+		entry.minePool.Close()
 	}
+	entry.minePool = NewVMPool(entry.cache, dataset, flags, threads)
+	return entry.minePool
+}
 
-	return nil
+// shouldUseDataset reports whether the engine mines with the full dataset.
+func (randomx *RandomX) shouldUseDataset() bool {
+	if !haveDataset {
+		return false
+	}
+	if randomx.config == nil {
+		return true
+	}
+	if randomx.config.LightMode {
+		return false
+	}
+	return randomx.config.PowMode == ModeNormal
 }
 
-func (randomx *RandomX) startDatasetBuildLocked() {
-	dataset := randomx.dataset
-	cache := randomx.cache
-	if dataset == nil || cache == nil {
+// prepareDataset makes sure the dataset of the given epoch is available or
+// being built for mining, switching to the precomputed one if possible.
+func (randomx *RandomX) prepareDataset(entry *epochCache) {
+	if !randomx.shouldUseDataset() {
 		return
 	}
+	randomx.datasetMutex.Lock()
+	defer randomx.datasetMutex.Unlock()
 
-	seed := randomx.cacheKey
+	if randomx.datasetDisabled.Load() {
+		if job := randomx.datasetJob; job == nil || job.seed != entry.seed {
+			// Retry on new epoch or when no build is running for this seed.
+			randomx.datasetDisabled.Store(false)
+		}
+	}
+	if !randomx.datasetDisabled.Load() {
+		if err := randomx.ensureDatasetLocked(entry); err != nil {
+			log.Warn("RandomX dataset unavailable, continuing in light mode", "err", err)
+			randomx.datasetDisabled.Store(true)
+		}
+	}
+}
 
+func (randomx *RandomX) ensureDatasetLocked(entry *epochCache) error {
+	if !haveDataset {
+		return errDatasetUnsupported
+	}
+	// Switch to the precomputed dataset if it was built for this epoch
+	randomx.nextDatasetMutex.Lock()
+	if job := randomx.nextDatasetJob; job != nil && job.seed == entry.seed {
+		if current := randomx.datasetJob; current == nil || current.seed != entry.seed {
+			randomx.dataset, randomx.nextDataset = randomx.nextDataset, randomx.dataset
+			randomx.datasetJob, randomx.nextDatasetJob = randomx.nextDatasetJob, randomx.datasetJob
+			log.Info("Switched to precomputed RandomX dataset", "seed", entry.seed)
+		}
+	}
+	randomx.nextDatasetMutex.Unlock()
+	if randomx.dataset == nil {
+		log.Info("Allocating RandomX dataset (full mode)")
+		randomx.dataset = allocDataset(withFullMemory(randomx.flags()))
+		if randomx.dataset == nil {
+			return errors.New("randomx: failed to allocate dataset")
+		}
+	}
+	randomx.startDatasetBuildLocked(entry)
+	return nil
+}
+
+func (randomx *RandomX) startDatasetBuildLocked(entry *epochCache) {
 	if existing := randomx.datasetJob; existing != nil {
 		switch {
-		case existing.seed == seed && !existing.ready():
+		case existing.seed == entry.seed && !existing.ready():
 			// Build already in progress for this seed.
 			return
-		case existing.seed == seed && existing.ready() && existing.error() == nil:
+		case existing.seed == entry.seed && existing.ready() && existing.error() == nil:
 			// Dataset already initialised for this seed.
 			return
 		case !existing.ready():
@@ -481,20 +702,65 @@ func (randomx *RandomX) startDatasetBuildLocked() {
 			return
 		}
 	}
-
-	job := newDatasetBuild(seed)
+	job := newDatasetBuild(entry.seed)
 	randomx.datasetJob = job
 
-	go randomx.buildDataset(job, dataset, cache, seed)
+	randomx.caches.retain(entry)
+	go randomx.buildDataset(job, randomx.dataset, entry)
+}
+
+// precomputeDataset builds the dataset of an upcoming epoch into the spare
+// dataset buffer, unless it is already built or another build is running. The
+// buffer is not used for mining until the transition, so this doesn't have to
+// wait for the miner.
+func (randomx *RandomX) precomputeDataset(entry *epochCache) {
+	if !haveDataset {
+		return
+	}
+	randomx.nextDatasetMutex.Lock()
+	defer randomx.nextDatasetMutex.Unlock()
+
+	if job := randomx.nextDatasetJob; job != nil && (job.seed == entry.seed || !job.ready()) {
+		return
+	}
+	if randomx.nextDataset == nil {
+		randomx.nextDataset = allocDataset(withFullMemory(randomx.flags()))
+		if randomx.nextDataset == nil {
+			log.Warn("Failed to allocate RandomX dataset for the next epoch")
+			return
+		}
+	}
+	job := newDatasetBuild(entry.seed)
+	randomx.nextDatasetJob = job
+
+	randomx.caches.retain(entry)
+	go randomx.buildDataset(job, randomx.nextDataset, entry)
+}
+
+// datasetPrecomputed reports whether the spare dataset buffer holds or is
+// being filled with the dataset of the given seed.
+func (randomx *RandomX) datasetPrecomputed(seed common.Hash) bool {
+	randomx.nextDatasetMutex.Lock()
+	defer randomx.nextDatasetMutex.Unlock()
+
+	return randomx.nextDatasetJob != nil && randomx.nextDatasetJob.seed == seed
 }
 
-func (randomx *RandomX) buildDataset(job *datasetBuild, dataset *C.randomx_dataset, cache *C.randomx_cache, seed common.Hash) {
+// buildDataset initialises the dataset from the given cache, releasing the
+// reference to the cache once done.
+func (randomx *RandomX) buildDataset(job *datasetBuild, dataset *rxDataset, entry *epochCache) {
 	defer close(job.done)
 
-	if dataset == nil || cache == nil {
+	seed := entry.seed
+	if !haveDataset {
+		randomx.caches.release(entry)
+		job.setError(errDatasetUnsupported)
+		return
+	}
+	if dataset == nil || entry.cache == nil {
+		randomx.caches.release(entry)
 		err := errors.New("randomx: dataset build prerequisites missing")
 		job.setError(err)
-		randomx.datasetDisabled.Store(true)
 		log.Warn("RandomX dataset build aborted", "err", err)
 		return
 	}
@@ -507,9 +773,14 @@ func (randomx *RandomX) buildDataset(job *datasetBuild, dataset *C.randomx_datas
 			"recommendation", "Remove GOMAXPROCS=1 or set to at least 2 for stable RandomX operation")
 	}
 
-	itemCount := C.randomx_dataset_item_count()
+	itemCount := datasetItemCount()
+	data := datasetMemory(dataset, itemCount)
+	if randomx.loadStoredDataset(dataset, data, entry) {
+		randomx.caches.release(entry)
+		return
+	}
 	start := time.Now()
-	log.Info("Initializing RandomX dataset in background", "items", uint64(itemCount), "seed", seed.Hex())
+	log.Info("Initializing RandomX dataset in background", "items", itemCount, "seed", seed.Hex())
 
 	// Build dataset with timeout protection
 	// Note: We can't cancel the C call, but we can detect if it hangs
@@ -519,6 +790,8 @@ func (randomx *RandomX) buildDataset(job *datasetBuild, dataset *C.randomx_datas
 	// Initialize dataset in chunks to avoid threading conflicts with GOMAXPROCS=1
 	// This prevents segfaults when RandomX C library tries to spawn multiple threads
 	go func() {
+		// The cache must outlive the C call, even if the build timed out
+		defer randomx.caches.release(entry)
 		defer func() {
 			if r := recover(); r != nil {
 				log.Error("RandomX dataset build panic", "error", r)
@@ -530,14 +803,14 @@ func (randomx *RandomX) buildDataset(job *datasetBuild, dataset *C.randomx_datas
 		const numChunks = 1
 		chunkSize := itemCount / numChunks
 
-		for i := C.ulong(0); i < numChunks; i++ {
+		for i := uint64(0); i < numChunks; i++ {
 			startItem := i * chunkSize
 			count := chunkSize
 			if i == numChunks-1 {
 				// Last chunk gets remainder
 				count = itemCount - startItem
 			}
-			C.randomx_init_dataset(dataset, cache, startItem, count)
+			initDataset(dataset, entry.cache, startItem, count)
 		}
 		close(buildDone)
 	}()
@@ -545,21 +818,88 @@ func (randomx *RandomX) buildDataset(job *datasetBuild, dataset *C.randomx_datas
 	select {
 	case <-buildDone:
 		// Build completed successfully
-		job.setError(nil)
-		randomx.datasetDisabled.Store(false)
+		datasetBuildTimer.UpdateSince(start)
 		log.Info("RandomX dataset ready", "seed", seed.Hex(), "duration", time.Since(start))
+
+		// Store the dataset before the job is done, the buffer is not rebuilt
+		// for another epoch while being written
+		randomx.storeDataset(seed, data)
 	case <-time.After(buildTimeout):
 		// Build timed out - this indicates a serious problem
 		err := fmt.Errorf("randomx: dataset build timeout after %v", buildTimeout)
 		job.setError(err)
-		randomx.datasetDisabled.Store(true)
 		log.Error("RandomX dataset build timeout - dataset disabled", "timeout", buildTimeout, "seed", seed.Hex())
 		// Note: C call continues in background, but we mark it as failed
 		// This prevents goroutine leak of the main build goroutine
 	}
 }
 
-func (randomx *RandomX) datasetReadyLocked() *C.randomx_dataset {
+// loadStoredDataset fills the dataset from the cache directory if it holds the
+// dataset of the epoch, reporting whether it did. Unusable files are deleted,
+// the dataset is then built from scratch.
+func (randomx *RandomX) loadStoredDataset(dataset *rxDataset, data []byte, entry *epochCache) bool {
+	if randomx.config == nil || randomx.config.CacheDir == "" {
+		return false
+	}
+	var (
+		dir   = randomx.config.CacheDir
+		start = time.Now()
+	)
+	err := loadDataset(dir, entry.seed, data)
+	if err == nil && !checkDatasetItems(dataset, data, entry.cache) {
+		// Stored by a RandomX library with other parameters
+		err = fmt.Errorf("%w: items mismatch the cache", errDatasetCorrupt)
+	}
+	switch {
+	case err == nil:
+		datasetLoadTimer.UpdateSince(start)
+		log.Info("Loaded RandomX dataset from disk", "seed", entry.seed.Hex(), "duration", time.Since(start))
+		return true
+	case errors.Is(err, os.ErrNotExist):
+		return false
+	default:
+		log.Warn("Discarding unusable RandomX dataset", "seed", entry.seed.Hex(), "err", err)
+		removeDataset(datasetPath(dir, entry.seed))
+		return false
+	}
+}
+
+// checkDatasetItems recomputes a few items of a loaded dataset from the cache
+// and reports whether they match. The recomputed items are written in place.
+func checkDatasetItems(dataset *rxDataset, data []byte, cache *rxCache) bool {
+	if !haveDataset {
+		return false
+	}
+	items := uint64(len(data) / datasetItemSize)
+	for _, item := range []uint64{0, items / 2, items - 1} {
+		loaded := common.CopyBytes(data[item*datasetItemSize : (item+1)*datasetItemSize])
+		initDataset(dataset, cache, item, 1)
+		if !bytes.Equal(loaded, data[item*datasetItemSize:(item+1)*datasetItemSize]) {
+			return false
+		}
+	}
+	return true
+}
+
+// storeDataset writes a freshly built dataset to the cache directory, removing
+// the ones of older epochs.
+func (randomx *RandomX) storeDataset(seed common.Hash, data []byte) {
+	if randomx.config == nil || randomx.config.CacheDir == "" {
+		return
+	}
+	dir := randomx.config.CacheDir
+	start := time.Now()
+	if err := saveDataset(dir, seed, data); err != nil {
+		log.Warn("Failed to store RandomX dataset", "dir", dir, "err", err)
+		return
+	}
+	log.Info("Stored RandomX dataset", "path", datasetPath(dir, seed), "duration", time.Since(start))
+	gcDatasets(dir)
+}
+
+// datasetReadyLocked returns the dataset if it is fully built for the given
+// seed, or nil if mining has to fall back to light mode.
+func (randomx *RandomX) datasetReadyLocked(seed common.Hash) *rxDataset {
 	if randomx.datasetDisabled.Load() {
 		return nil
 	}
@@ -570,11 +910,7 @@ func (randomx *RandomX) datasetReadyLocked() *C.randomx_dataset {
 	}
 
 	job := randomx.datasetJob
-	if job == nil {
-		return dataset
-	}
-
-	if !job.ready() {
+	if job == nil || job.seed != seed || !job.ready() {
 		return nil
 	}
 
@@ -588,9 +924,9 @@ func (randomx *RandomX) datasetReadyLocked() *C.randomx_dataset {
 }
 
 // NewVMPool creates a new pool of RandomX VMs for parallel mining
-func NewVMPool(cache *C.randomx_cache, dataset *C.randomx_dataset, flags C.randomx_flags, size int) *VMPool {
+func NewVMPool(cache *rxCache, dataset *rxDataset, flags rxFlags, size int) *VMPool {
 	pool := &VMPool{
-		vms:      make([]*C.randomx_vm, 0, size),
+		vms:      make([]*rxVM, 0, size),
 		cache:    cache,
 		dataset:  dataset,
 		flags:    flags,
@@ -599,7 +935,7 @@ func NewVMPool(cache *C.randomx_cache, dataset *C.randomx_dataset, flags C.rando
 
 	// Pre-allocate VMs
 	for i := 0; i < size; i++ {
-		vm := C.randomx_create_vm(flags, cache, dataset)
+		vm := createVM(flags, cache, dataset)
 		if vm != nil {
 			pool.vms = append(pool.vms, vm)
 		}
@@ -609,13 +945,13 @@ func NewVMPool(cache *C.randomx_cache, dataset *C.randomx_dataset, flags C.rando
 }
 
 // Get retrieves a VM from the pool
-func (p *VMPool) Get() *C.randomx_vm {
+func (p *VMPool) Get() *rxVM {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	if len(p.vms) == 0 {
 		// Create new VM if pool is empty
-		return C.randomx_create_vm(p.flags, p.cache, p.dataset)
+		return createVM(p.flags, p.cache, p.dataset)
 	}
 
 	vm := p.vms[len(p.vms)-1]
@@ -624,7 +960,7 @@ func (p *VMPool) Get() *C.randomx_vm {
 }
 
 // Put returns a VM to the pool
-func (p *VMPool) Put(vm *C.randomx_vm) {
+func (p *VMPool) Put(vm *rxVM) {
 	if vm == nil {
 		return
 	}
@@ -632,81 +968,117 @@ func (p *VMPool) Put(vm *C.randomx_vm) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if len(p.vms) < p.poolSize {
+	if !p.closed && len(p.vms) < p.poolSize {
 		p.vms = append(p.vms, vm)
 	} else {
 		// Pool is full, destroy the VM
-		C.randomx_destroy_vm(vm)
+		destroyVM(vm)
 	}
 }
 
-// Close destroys all VMs in the pool
+// Close destroys all VMs in the pool. VMs returned after closing are destroyed.
 func (p *VMPool) Close() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	p.closed = true
 	for _, vm := range p.vms {
 		if vm != nil {
-			C.randomx_destroy_vm(vm)
+			destroyVM(vm)
 		}
 	}
 	p.vms = nil
 }
 
-// UpdateCache updates the cache for all VMs in the pool
-// CRITICAL: Must be called when epoch changes to prevent VMs from using stale cache
-func (p *VMPool) UpdateCache(newCache *C.randomx_cache) {
-	if newCache == nil {
-		return
+// verifyVM is a RandomX VM leased from the verification pool of an epoch cache
+// by a header verification worker for the duration of a batch.
+type verifyVM struct {
+	vm    *rxVM
+	entry *epochCache // Cache the VM is bound to and leased from
+}
+
+// verifyHash computes the RandomX hash of the given preimage with the leased
+// VM. If the epoch changed since its last use, the VM is handed back to the
+// previous cache and a new one is leased from the given cache, so that VMs are
+// never used with a cache other than the one they were created with.
+//
+// The caller must hold a reference to the cache.
+func (randomx *RandomX) verifyHash(vm *verifyVM, entry *epochCache, input []byte) (common.Hash, error) {
+	if vm.vm != nil && vm.entry != entry {
+		randomx.releaseVerifyVM(vm)
+	}
+	if vm.vm == nil {
+		vm.vm, vm.entry = entry.leaseVM(randomx.flags()), entry
+		if vm.vm == nil {
+			return common.Hash{}, errors.New("failed to create RandomX VM for verification")
+		}
 	}
+	return hashRandomX(vm.vm, input), nil
+}
 
-	p.mu.Lock()
-	defer p.mu.Unlock()
+// releaseVerifyVM returns a leased VM to the verification pool of its cache,
+// or destroys it if the cache was freed in the meantime.
+func (randomx *RandomX) releaseVerifyVM(vm *verifyVM) {
+	if vm.vm == nil {
+		return
+	}
+	vm.entry.returnVM(vm.vm)
+	vm.vm, vm.entry = nil, nil
+}
 
-	// Update pool's cache reference
-	p.cache = newCache
+// Threads returns the number of threads the local miner searches with.
+func (randomx *RandomX) Threads() int {
+	randomx.lock.Lock()
+	defer randomx.lock.Unlock()
 
-	// Update cache for all existing VMs in the pool
-	for _, vm := range p.vms {
-		if vm != nil {
-			C.randomx_vm_set_cache(vm, newCache)
-		}
+	if randomx.threads <= 0 {
+		return runtime.NumCPU()
 	}
+	return randomx.threads
+}
+
+// SetThreads updates the number of threads the local miner searches with. The
+// change takes effect with the next sealing request. If zero is specified, the
+// miner will use all cores of the machine.
+func (randomx *RandomX) SetThreads(threads int) {
+	randomx.lock.Lock()
+	defer randomx.lock.Unlock()
+
+	randomx.threads = threads
+}
+
+// SetDatabase sets the chain database, which persists the emission checkpoints
+// of smooth emission reward schedules across restarts.
+func (randomx *RandomX) SetDatabase(db ethdb.KeyValueStore) {
+	randomx.emissions.setDatabase(db)
 }
 
 // Close closes the RandomX engine and cleans up resources.
 func (randomx *RandomX) Close() error {
-	randomx.cacheMutex.Lock()
-	defer randomx.cacheMutex.Unlock()
+	randomx.closeOnce.Do(func() {
+		if randomx.remote != nil {
+			close(randomx.remote.requestExit)
+			<-randomx.remote.exitCh
+		}
+	})
+	randomx.datasetMutex.Lock()
+	defer randomx.datasetMutex.Unlock()
 
-	if randomx.vmPool != nil {
-		randomx.vmPool.Close()
-		randomx.vmPool = nil
-	}
+	// Drop the cached epochs, the ones in use are freed with their last user
+	randomx.caches.close()
 
-	if randomx.dataset != nil {
-		C.randomx_release_dataset(randomx.dataset)
-		randomx.dataset = nil
-	}
+	randomx.nextDatasetMutex.Lock()
+	defer randomx.nextDatasetMutex.Unlock()
 
-	if randomx.cache != nil {
-		C.randomx_release_cache(randomx.cache)
-		randomx.cache = nil
+	for _, dataset := range []**rxDataset{&randomx.dataset, &randomx.nextDataset} {
+		if *dataset != nil {
+			releaseDataset(*dataset)
+			*dataset = nil
+		}
 	}
-
 	return nil
 }
 
-// hashRandomX calculates the RandomX hash for the given input
-func hashRandomX(vm *C.randomx_vm, input []byte) common.Hash {
-	var hash common.Hash
-	inputPtr := (*C.char)(unsafe.Pointer(&input[0]))
-	hashPtr := unsafe.Pointer(&hash[0])
-
-	C.randomx_calculate_hash(vm, unsafe.Pointer(inputPtr), C.size_t(len(input)), hashPtr)
-	return hash
-}
-
 // verifyRandomX checks whether the given hash and nonce satisfy the PoW difficulty
 // CRITICAL: RandomX/Monero uses LITTLE-ENDIAN hash interpretation
 func verifyRandomX(hash common.Hash, difficulty *big.Int) bool {
@@ -728,22 +1100,43 @@ func verifyRandomX(hash common.Hash, difficulty *big.Int) bool {
 // maxUint256 is the maximum value representable by a uint256
 var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1)
 
+// maxUint64 is the upper bound of the random nonce search offset
+var maxUint64 = new(big.Int).SetUint64(math.MaxUint64)
+
 // verifyPoWWithCache verifies the proof-of-work using the provided cache
-// This function handles all C-related operations and is called from consensus.go
 // Implements rx-eth-v1 format for compatibility with xmrig RandomX mining
-func verifyPoWWithCache(cache *C.randomx_cache, dataset *C.randomx_dataset, sealHash common.Hash, header *types.Header) error {
+func verifyPoWWithCache(flags rxFlags, cache *rxCache, dataset *rxDataset, sealHash common.Hash, header *types.Header) error {
 	if cache == nil {
 		return errors.New("randomx cache not initialized")
 	}
 
-	// Create VM for verification with optimal flags (same as cache)
-	flags := flagsForDataset(dataset)
-	vm := C.randomx_create_vm(flags, cache, dataset)
+	// Create VM for verification with the given flags (same as cache)
+	vm := createVM(flags, cache, dataset)
 	if vm == nil {
 		return errors.New("failed to create RandomX VM for verification")
 	}
-	defer C.randomx_destroy_vm(vm)
+	defer destroyVM(vm)
+
+	return verifySeal(sealHash, header, func(input []byte) (common.Hash, error) {
+		return hashRandomX(vm, input), nil
+	})
+}
+
+// verifyPoWWithVM verifies the proof-of-work using a VM leased from the given
+// cache. The caller must hold a reference to the cache.
+func (randomx *RandomX) verifyPoWWithVM(vm *verifyVM, entry *epochCache, sealHash common.Hash, header *types.Header) error {
+	if entry.cache == nil {
+		return errors.New("randomx cache not initialized")
+	}
+	return verifySeal(sealHash, header, func(input []byte) (common.Hash, error) {
+		return randomx.verifyHash(vm, entry, input)
+	})
+}
 
+// verifySeal reconstructs the rx-eth-v1 preimage of the header, hashes it with
+// the given RandomX hasher and checks the result against the header's mix digest
+// and difficulty.
+func verifySeal(sealHash common.Hash, header *types.Header, hasher func(input []byte) (common.Hash, error)) error {
 	// rx-eth-v1 Format Verification
 	// ===============================
 	// The stratum-proxy sends miners a 43-byte blob:
@@ -766,22 +1159,9 @@ func verifyPoWWithCache(cache *C.randomx_cache, dataset *C.randomx_dataset, seal
 	minerNonce4 := uint32(nonce64 & 0xFFFFFFFF)
 
 	// 3. Reconstruct rx-eth-v1 preimage (43 bytes total)
-	hashInput := make([]byte, 43)
-
-	// Byte 0-31: SealHash (keccak256 of RLP-encoded header without nonce/mixdigest)
-	copy(hashInput[0:32], sealHash[:])
+	hashInput := sealPreimage(sealHash, nonce64)
 
-	// Byte 32-35: extraNonce4 (4 bytes, little-endian)
-	binary.LittleEndian.PutUint32(hashInput[32:36], extraNonce4)
-
-	// Byte 36-38: Constant padding (3 bytes of zeros)
-	// Already zero from make()
-
-	// Byte 39-42: minerNonce4 (4 bytes, little-endian)
-	binary.LittleEndian.PutUint32(hashInput[39:43], minerNonce4)
-
-	// DEBUG: Log detailed verification info
-	log.Info("RandomX verification",
+	log.Trace("RandomX verification",
 		"sealHash", sealHash.Hex(),
 		"nonce64", fmt.Sprintf("0x%016x", nonce64),
 		"extraNonce", fmt.Sprintf("0x%08x", extraNonce4),
@@ -790,9 +1170,12 @@ func verifyPoWWithCache(cache *C.randomx_cache, dataset *C.randomx_dataset, seal
 		"expectedMix", header.MixDigest.Hex())
 
 	// 4. Calculate RandomX hash using the reconstructed preimage
-	hash := hashRandomX(vm, hashInput)
+	hash, err := hasher(hashInput)
+	if err != nil {
+		return err
+	}
 
-	log.Info("RandomX hash computed", "computedHash", hash.Hex())
+	log.Trace("RandomX hash computed", "computedHash", hash.Hex())
 
 	// 5. Verify that the calculated hash matches the MixDigest
 	if hash != header.MixDigest {
@@ -809,6 +1192,40 @@ func verifyPoWWithCache(cache *C.randomx_cache, dataset *C.randomx_dataset, seal
 	return nil
 }
 
+// sealPreimage builds the 43-byte rx-eth-v1 preimage hashed for a seal hash and
+// nonce64:
+//
+//	sealHash(32) || extraNonce4(4, LE) || zero padding(3) || minerNonce4(4, LE)
+//
+// where extraNonce4 and minerNonce4 are the high and low 32 bits of the nonce.
+func sealPreimage(sealHash common.Hash, nonce64 uint64) []byte {
+	preimage := make([]byte, 43)
+	copy(preimage[0:32], sealHash[:])
+	binary.LittleEndian.PutUint32(preimage[32:36], uint32(nonce64>>32))
+	binary.LittleEndian.PutUint32(preimage[39:43], uint32(nonce64))
+	return preimage
+}
+
+// sealDigest computes the RandomX hash of a header's preimage, i.e. the mix
+// digest of the header sealed with its nonce, on the cache of its epoch. It's
+// used for solutions submitted without their hash.
+func (randomx *RandomX) sealDigest(chain consensus.ChainHeaderReader, header *types.Header) (common.Hash, error) {
+	seedHash, err := randomx.GetSeedHash(chain, header.Number)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to calculate RandomX seed: %w", err)
+	}
+	entry, err := randomx.caches.acquire(seedHash)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to initialize RandomX cache: %w", err)
+	}
+	defer randomx.caches.release(entry)
+
+	vm := new(verifyVM)
+	defer randomx.releaseVerifyVM(vm)
+
+	return randomx.verifyHash(vm, entry, sealPreimage(randomx.SealHash(header), header.Nonce.Uint64()))
+}
+
 // Seal generates a new sealing request for the given input block and pushes
 // the result into the given channel.
 //
@@ -850,6 +1267,8 @@ func (randomx *RandomX) Seal(chain consensus.ChainHeaderReader, block *types.Blo
 		case <-stop:
 			log.Info("Mining stopped before sending work to remote sealer")
 			return nil
+		case <-randomx.remote.exitCh:
+			return errRandomXStopped
 		}
 	}
 
@@ -863,187 +1282,174 @@ func (randomx *RandomX) Seal(chain consensus.ChainHeaderReader, block *types.Blo
 		return err
 	}
 
-	// Initialize RandomX cache with epoch seed
-	// Cache is reused for all blocks in the same epoch (2048 blocks)
+	// Retrieve the RandomX cache of the epoch, the miner releases it when done
 	log.Debug("Initializing RandomX cache", "seedHash", seedHash.Hex(), "blockNumber", header.Number)
-	if err := randomx.initCache(seedHash); err != nil {
+	entry, err := randomx.caches.acquire(seedHash)
+	if err != nil {
 		log.Error("Failed to initialize RandomX cache", "err", err)
 		return err
 	}
+	randomx.prepareDataset(entry)
+	randomx.precompute(chain, header.Number.Uint64())
 
 	// Create a runner and the multiple search threads it directs
-	abort := make(chan struct{})
-	found := make(chan *types.Block)
+	var (
+		abort   = make(chan struct{})
+		found   = make(chan *types.Block)
+		threads = randomx.Threads()
+	)
+	go randomx.mine(entry, block, threads, found, abort)
 
-	// Start mining goroutine
-	log.Info("Starting RandomX mining goroutine")
+	// Wait until sealing is terminated or a nonce is found, the work is then
+	// withdrawn from the remote miners too
 	go func() {
 		defer close(abort)
-		randomx.mine(block, found, abort, stop)
-	}()
-
-	// Wait for result or stop signal
-	select {
-	case result := <-found:
-		log.Info("Solution found!", "block", result.NumberU64())
 		if randomx.remote != nil {
-			randomx.remote.cancel(sealHash)
+			defer randomx.remote.cancel(sealHash)
 		}
-		// Solution found, push to results
 		select {
-		case results <- result:
-			log.Debug("Result sent to results channel")
-		default:
-			log.Warn("Results channel full, dropping result")
-		}
-	case <-stop:
-		log.Info("Mining aborted via stop channel")
-		// Mining aborted externally
-		close(abort)
-		if randomx.remote != nil {
-			randomx.remote.cancel(sealHash)
+		case result := <-found:
+			log.Info("Solution found!", "block", result.NumberU64())
+			select {
+			case results <- result:
+			case <-stop:
+				log.Warn("Sealing result is not read by miner", "sealhash", sealHash)
+			}
+		case <-stop:
+			log.Debug("RandomX sealing aborted", "block", block.NumberU64())
 		}
-	}
-
-	log.Debug("Seal function returning")
+	}()
 	return nil
 }
 
-// mine is the actual mining loop that searches for a valid nonce
-func (randomx *RandomX) mine(block *types.Block, found chan<- *types.Block, abort <-chan struct{}, stop <-chan struct{}) {
+// mine directs the local search for a valid nonce, splitting the nonce space
+// of the block across the given number of threads. Each thread leases its own
+// VM from the mining pool and the search stops as soon as the abort channel is
+// closed. The reference to the epoch cache is released once all threads exit.
+func (randomx *RandomX) mine(entry *epochCache, block *types.Block, threads int, found chan<- *types.Block, abort <-chan struct{}) {
+	defer randomx.caches.release(entry)
+
 	header := block.Header()
 	target := new(big.Int).Div(maxUint256, header.Difficulty)
 
-	log.Info("RandomX mine starting", "block", block.NumberU64(), "difficulty", header.Difficulty, "target", target.String())
+	log.Info("RandomX mine starting", "block", block.NumberU64(), "difficulty", header.Difficulty, "threads", threads)
 
-	// CRITICAL: Lock cache for entire mining duration to prevent rotation
-	// If cache is rotated (epoch change), the VM would reference freed memory
-	randomx.cacheMutex.RLock()
-	defer randomx.cacheMutex.RUnlock()
+	// CRITICAL: Lock the datasets for entire mining duration to prevent a
+	// rebuild from overwriting the one the VMs are hashing with
+	randomx.datasetMutex.RLock()
+	defer randomx.datasetMutex.RUnlock()
 
-	cache := randomx.cache
-	if cache == nil {
-		log.Error("RandomX cache is nil!")
-		return
-	}
-
-	dataset := randomx.datasetReadyLocked()
+	cache := entry.cache
+	dataset := randomx.datasetReadyLocked(entry.seed)
 	if dataset == nil {
 		log.Debug("RandomX dataset not ready, mining in light mode")
 	}
+	pool := entry.miningPool(dataset, randomx.flagsForDataset(dataset), threads)
 
-	log.Debug("Creating RandomX VM for mining")
-	// Create VM with optimal flags (JIT + hugepages if available)
-	// VM holds a reference to cache/dataset, so they must remain valid for VM lifetime
-	flags := flagsForDataset(dataset)
-	vm := C.randomx_create_vm(flags, cache, dataset)
-	if vm == nil {
-		log.Error("Failed to create RandomX VM!")
+	// Split the nonce space into equal slices starting at a random offset
+	seed, err := crand.Int(crand.Reader, maxUint64)
+	if err != nil {
+		log.Error("Failed to seed nonce search", "err", err)
 		return
 	}
-	defer C.randomx_destroy_vm(vm)
-	log.Info("RandomX VM created, starting nonce search...")
+	var (
+		sealHash = randomx.SealHash(header)
+		base     = seed.Uint64()
+		span     = math.MaxUint64/uint64(threads) + 1
+		pend     sync.WaitGroup
+	)
+	for i := 0; i < threads; i++ {
+		pend.Add(1)
+		go func(id int, nonce uint64) {
+			defer pend.Done()
+			randomx.mineThread(pool, cache, dataset, id, block, sealHash, target, nonce, found, abort)
+		}(i, base+uint64(i)*span)
+	}
+	pend.Wait()
+}
 
-	// Prepare the header for hashing (without nonce)
-	sealHash := randomx.SealHash(header)
+// mineThread is a single search thread of the local miner, hashing the rx-eth-v1
+// preimages of consecutive nonces starting at the given one.
+func (randomx *RandomX) mineThread(pool *VMPool, cache *rxCache, dataset *rxDataset, id int, block *types.Block, sealHash common.Hash, target *big.Int, nonce64 uint64, found chan<- *types.Block, abort <-chan struct{}) {
+	vm := pool.Get()
+	if vm == nil {
+		log.Error("Failed to create RandomX VM for mining", "thread", id)
+		return
+	}
+	defer pool.Put(vm)
 
-	// Start nonce search using rx-eth-v1 format
-	// For local mining, use high 32 bits as extraNonce and low 32 bits as minerNonce
+	if dataset == nil {
+		setVMCache(vm, cache)
+	}
 	var (
-		nonce64   = uint64(time.Now().UnixNano())
+		logger    = log.New("thread", id)
 		attempts  = uint64(0)
+		total     = uint64(0)
 		hashInput = make([]byte, 43) // rx-eth-v1: 32+4+3+4 bytes
+		reversed  = make([]byte, 32)
+		hashInt   = new(big.Int)
 	)
+	logger.Trace("Started RandomX search for new nonces", "seed", nonce64)
 
 	// Copy seal hash (bytes 0-31)
 	copy(hashInput[:32], sealHash[:])
 
-	// Mining loop
+	defer func() {
+		randomx.hashrate.Mark(int64(attempts))
+	}()
 	for {
 		select {
 		case <-abort:
-			log.Debug("Mining aborted", "attempts", attempts)
-			return
-		case <-stop:
-			log.Debug("Mining stopped", "attempts", attempts)
+			logger.Trace("RandomX nonce search aborted", "attempts", total+attempts)
 			return
 		default:
-			// Extract extraNonce4 (high 32 bits) and minerNonce4 (low 32 bits)
-			extraNonce4 := uint32(nonce64 >> 32)
-			minerNonce4 := uint32(nonce64 & 0xFFFFFFFF)
-
-			// Build rx-eth-v1 preimage (43 bytes)
-			// Bytes 0-31: sealHash (already copied above)
-			// Bytes 32-35: extraNonce4 (LE)
-			binary.LittleEndian.PutUint32(hashInput[32:36], extraNonce4)
-			// Bytes 36-38: const padding (already zero from make())
-			// Bytes 39-42: minerNonce4 (LE)
-			binary.LittleEndian.PutUint32(hashInput[39:43], minerNonce4)
-
-			// Calculate RandomX hash
-			hash := hashRandomX(vm, hashInput)
-
-			// Check if we found a valid solution
-			// CRITICAL: RandomX uses LITTLE-ENDIAN byte order
-			reversedHash := make([]byte, 32)
-			for i := 0; i < 32; i++ {
-				reversedHash[i] = hash[31-i]
-			}
-			hashInt := new(big.Int).SetBytes(reversedHash)
-			if hashInt.Cmp(target) <= 0 {
-				// Found valid nonce!
-				log.Info("✅ Found valid nonce!", "block", block.NumberU64(),
-					"nonce64", fmt.Sprintf("%016x", nonce64),
-					"extraNonce", fmt.Sprintf("%08x", extraNonce4),
-					"minerNonce", fmt.Sprintf("%08x", minerNonce4),
-					"attempts", attempts, "hash", hash.Hex())
-
-				newHeader := types.CopyHeader(header)
-				newHeader.Nonce = types.EncodeNonce(nonce64)
-				newHeader.MixDigest = hash
-
-				// No need to store extraNonce in header.Extra
-				// It's already encoded in the nonce (high 32 bits)
-
-				select {
-				case found <- block.WithSeal(newHeader):
-					log.Debug("Sealed block sent to found channel")
-					return
-				case <-abort:
-					log.Warn("Aborted while trying to send found block")
-					return
-				case <-stop:
-					log.Warn("Stopped while trying to send found block")
-					return
-				}
-			}
-
-			// Increment nonce
-			nonce64++
-			attempts++
+		}
+		// Build rx-eth-v1 preimage (43 bytes): extraNonce4 (high 32 bits) and
+		// minerNonce4 (low 32 bits) in little-endian around the zero padding
+		extraNonce4 := uint32(nonce64 >> 32)
+		minerNonce4 := uint32(nonce64 & 0xFFFFFFFF)
+		binary.LittleEndian.PutUint32(hashInput[32:36], extraNonce4)
+		binary.LittleEndian.PutUint32(hashInput[39:43], minerNonce4)
+
+		hash := hashRandomX(vm, hashInput)
+
+		// Feed the hashrate meter in batches to keep the overhead low
+		if attempts++; attempts == hashrateMarkInterval {
+			randomx.hashrate.Mark(int64(attempts))
+			total, attempts = total+attempts, 0
+		}
+		// CRITICAL: RandomX uses LITTLE-ENDIAN byte order
+		for i := 0; i < 32; i++ {
+			reversed[i] = hash[31-i]
+		}
+		if hashInt.SetBytes(reversed).Cmp(target) <= 0 {
+			logger.Info("✅ Found valid nonce!", "block", block.NumberU64(),
+				"nonce64", fmt.Sprintf("%016x", nonce64),
+				"extraNonce", fmt.Sprintf("%08x", extraNonce4),
+				"minerNonce", fmt.Sprintf("%08x", minerNonce4),
+				"attempts", total+attempts, "hash", hash.Hex())
 
-			// Log progress every 100000 attempts
-			if attempts%100000 == 0 {
-				log.Debug("Mining progress", "attempts", attempts, "nonce64", fmt.Sprintf("%016x", nonce64))
-			}
+			header := block.Header()
+			header.Nonce = types.EncodeNonce(nonce64)
+			header.MixDigest = hash
 
-			// Check abort every 1024 attempts
-			if attempts%1024 == 0 {
-				select {
-				case <-abort:
-					return
-				case <-stop:
-					return
-				default:
-				}
+			select {
+			case found <- block.WithSeal(header):
+				logger.Trace("RandomX nonce found and reported", "attempts", total+attempts, "nonce", nonce64)
+			case <-abort:
+				logger.Trace("RandomX nonce found but discarded", "attempts", total+attempts, "nonce", nonce64)
 			}
+			return
 		}
+		nonce64++
 	}
 }
 
 // loop is the main event loop for the remote sealer.
 func (s *remoteSealer) loop(randomx *RandomX) {
 	defer func() {
+		s.cancelNotify()
+		s.reqWG.Wait()
 		close(s.exitCh)
 	}()
 
@@ -1061,11 +1467,15 @@ func (s *remoteSealer) loop(randomx *RandomX) {
 
 			s.mutex.Lock()
 
-			if s.currentTask != nil && work.block.ParentHash() != s.currentTask.block.ParentHash() {
-				s.mutex.Unlock()
-				continue
+			// Work on a new head replaces the work of the previous one
+			parent := work.block.ParentHash()
+			if s.currentTask != nil && parent != s.currentTask.block.ParentHash() {
+				for hash, task := range s.works {
+					if task.block.ParentHash() != parent {
+						delete(s.works, hash)
+					}
+				}
 			}
-
 			if work.chain != nil {
 				s.chain = work.chain
 			}
@@ -1074,13 +1484,8 @@ func (s *remoteSealer) loop(randomx *RandomX) {
 			s.currentWork = s.makeWork(work.block)
 			s.currentWork[0] = work.sealHash.Hex()
 			s.works[work.sealHash] = work
-
-			for _, ch := range s.notifyCtx {
-				select {
-				case ch <- s.currentWork:
-				default:
-				}
-			}
+			s.pruneTemplates(parent)
+			s.notifyWork()
 			s.mutex.Unlock()
 
 		case req := <-s.fetchWorkCh:
@@ -1093,6 +1498,7 @@ func (s *remoteSealer) loop(randomx *RandomX) {
 			}
 			req.res <- s.currentWork
 			s.mutex.Unlock()
+			remoteWorkCounter.Inc(1)
 
 		case result := <-s.submitWorkCh:
 			s.mutex.Lock()
@@ -1101,12 +1507,10 @@ func (s *remoteSealer) loop(randomx *RandomX) {
 			if task == nil {
 				s.mutex.Unlock()
 				log.Warn("Work submitted but not found", "hash", result.hash)
+				remoteRejectedCounter.Inc(1)
 				result.errc <- errInvalidSealResult
 				continue
 			}
-
-			block := task.block
-			resultsCh := task.results
 			chain := task.chain
 			if chain == nil {
 				chain = s.chain
@@ -1118,29 +1522,24 @@ func (s *remoteSealer) loop(randomx *RandomX) {
 				result.errc <- errInvalidSealResult
 				continue
 			}
+			// Hashing the solution takes a while, check it without holding
+			// up the work distribution
+			s.reqWG.Add(1)
+			go s.verifyWork(chain, task, result)
 
-			header := types.CopyHeader(block.Header())
-			header.Nonce = result.nonce
-			header.MixDigest = result.mixDigest
-
-			nonce64 := header.Nonce.Uint64()
-			extraNonce4 := uint32(nonce64 >> 32)
-			minerNonce4 := uint32(nonce64 & 0xFFFFFFFF)
-
-			log.Debug("Remote work submitted", "nonce64", fmt.Sprintf("%016x", nonce64),
-				"extraNonce", fmt.Sprintf("%08x", extraNonce4),
-				"minerNonce", fmt.Sprintf("%08x", minerNonce4),
-				"hash", result.hash.Hex())
+		case sealed := <-s.sealedCh:
+			result := sealed.result
 
-			if err := randomx.verifyPoW(chain, header); err != nil {
-				log.Warn("Invalid proof-of-work submitted", "err", err)
+			s.mutex.Lock()
+			task := s.works[result.hash]
+			if task == nil {
+				// Dropped or sealed by another solution while being checked
+				s.mutex.Unlock()
+				log.Warn("Work submitted but no longer tracked", "hash", result.hash)
+				remoteRejectedCounter.Inc(1)
 				result.errc <- errInvalidSealResult
 				continue
 			}
-
-			sealed := block.WithSeal(header)
-
-			s.mutex.Lock()
 			delete(s.works, result.hash)
 			if s.currentTask != nil && s.currentTask.sealHash == result.hash {
 				s.currentTask = nil
@@ -1148,14 +1547,15 @@ func (s *remoteSealer) loop(randomx *RandomX) {
 			}
 			s.mutex.Unlock()
 
-			if resultsCh != nil {
+			if task.results != nil {
 				select {
-				case resultsCh <- sealed:
+				case task.results <- sealed.block:
 				default:
 					log.Warn("Remote result channel full, dropping sealed block", "hash", result.hash)
 				}
 			}
 
+			remoteAcceptedCounter.Inc(1)
 			result.errc <- nil
 
 		case req := <-s.submitRateCh:
@@ -1172,18 +1572,7 @@ func (s *remoteSealer) loop(randomx *RandomX) {
 
 		case req := <-s.fetchRateCh:
 			// Fetch aggregate hashrate
-			s.mutex.Lock()
-			var total uint64
-			for id, rate := range s.rates {
-				// Remove stale hashrate reports (>10s old)
-				if time.Since(rate.ping) > 10*time.Second {
-					delete(s.rates, id)
-					continue
-				}
-				total += rate.rate
-			}
-			s.mutex.Unlock()
-			req <- total
+			req <- s.remoteHashrate()
 
 		case hash := <-s.cancelCh:
 			s.mutex.Lock()
@@ -1207,16 +1596,234 @@ func (s *remoteSealer) loop(randomx *RandomX) {
 			}
 			s.mutex.Unlock()
 
+			// Refresh the hash rate metrics, which also drop to zero once
+			// mining stops
+			localHashrateGauge.Update(randomx.hashrate.Snapshot().Rate1())
+			remoteHashrateGauge.Update(float64(s.remoteHashrate()))
+
 		case <-s.requestExit:
 			return
 		}
 	}
 }
 
+// verifyWork checks the proof-of-work of a solution submitted for the given
+// task, and hands the sealed block back to the sealer loop if it is valid.
+func (s *remoteSealer) verifyWork(chain consensus.ChainHeaderReader, task *sealTask, result *mineResult) {
+	defer s.reqWG.Done()
+
+	select {
+	case s.verifySem <- struct{}{}:
+		defer func() { <-s.verifySem }()
+	case <-s.requestExit:
+		result.errc <- errRandomXStopped
+		return
+	}
+	block := task.block
+	if task.template && chain.CurrentHeader().Hash() != block.ParentHash() {
+		log.Warn("Stale work template submitted", "hash", result.hash, "number", block.NumberU64())
+		remoteRejectedCounter.Inc(1)
+		result.errc <- errInvalidSealResult
+		return
+	}
+	header := types.CopyHeader(block.Header())
+	header.Nonce = result.nonce
+	header.MixDigest = result.mixDigest
+
+	// Monero daemon clients submit the nonce only, compute the digest
+	if result.mixDigest == (common.Hash{}) {
+		digest, err := s.randomx.sealDigest(chain, header)
+		if err != nil {
+			log.Warn("Failed to compute mix digest of submitted work", "err", err)
+			remoteRejectedCounter.Inc(1)
+			result.errc <- errInvalidSealResult
+			return
+		}
+		header.MixDigest = digest
+	}
+	nonce64 := header.Nonce.Uint64()
+	log.Debug("Remote work submitted", "nonce64", fmt.Sprintf("%016x", nonce64),
+		"extraNonce", fmt.Sprintf("%08x", uint32(nonce64>>32)),
+		"minerNonce", fmt.Sprintf("%08x", uint32(nonce64)),
+		"hash", result.hash.Hex())
+
+	if err := s.randomx.verifyPoW(chain, header, nil); err != nil {
+		log.Warn("Invalid proof-of-work submitted", "err", err)
+		remoteRejectedCounter.Inc(1)
+		result.errc <- errInvalidSealResult
+		return
+	}
+	select {
+	case s.sealedCh <- &sealedWork{result: result, block: block.WithSeal(header)}:
+	case <-s.requestExit:
+		result.errc <- errRandomXStopped
+	}
+}
+
+// remoteHashrate returns the total hash rate submitted by remote miners,
+// dropping stale reports (>10s old).
+func (s *remoteSealer) remoteHashrate() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var total uint64
+	for id, rate := range s.rates {
+		if time.Since(rate.ping) > 10*time.Second {
+			delete(s.rates, id)
+			continue
+		}
+		total += rate.rate
+	}
+	return total
+}
+
+// addTemplate tracks a block built for a remote miner on top of the head of the
+// chain and returns its work package. Templates on top of older heads are
+// dropped, as are the oldest ones beyond maxWorkTemplates.
+func (s *remoteSealer) addTemplate(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block) ([4]string, error) {
+	if head := chain.CurrentHeader(); head.Hash() != block.ParentHash() {
+		return [4]string{}, errStaleTemplate
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.chain == nil {
+		s.chain = chain
+	}
+	s.pruneTemplates(block.ParentHash())
+	if len(s.templates) >= maxWorkTemplates {
+		delete(s.works, s.templates[0])
+		s.templates = s.templates[1:]
+	}
+	task := &sealTask{
+		block:    block,
+		results:  results,
+		chain:    chain,
+		sealHash: s.randomx.SealHash(block.Header()),
+		template: true,
+	}
+	s.works[task.sealHash] = task
+	s.templates = append(s.templates, task.sealHash)
+	remoteWorkCounter.Inc(1)
+
+	work := s.makeWork(block)
+	work[0] = task.sealHash.Hex()
+	return work, nil
+}
+
+// pruneTemplates drops the work templates that aren't built on top of the
+// given head, or were sealed already. The lock must be held.
+func (s *remoteSealer) pruneTemplates(head common.Hash) {
+	templates := s.templates[:0]
+	for _, hash := range s.templates {
+		if task := s.works[hash]; task != nil {
+			if task.block.ParentHash() == head {
+				templates = append(templates, hash)
+				continue
+			}
+			delete(s.works, hash)
+		}
+	}
+	s.templates = templates
+}
+
+// subscribeWork returns a channel receiving the new work packages, starting
+// with the current one if any. Subscribers that fall behind only receive the
+// latest work package.
+func (s *remoteSealer) subscribeWork() chan [4]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ch := make(chan [4]string, 1)
+	if s.currentTask != nil {
+		ch <- s.currentWork
+	}
+	s.workSubs = append(s.workSubs, ch)
+	return ch
+}
+
+// unsubscribeWork stops sending work packages to a subscriber.
+func (s *remoteSealer) unsubscribeWork(ch chan [4]string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, sub := range s.workSubs {
+		if sub == ch {
+			s.workSubs = append(s.workSubs[:i], s.workSubs[i+1:]...)
+			return
+		}
+	}
+}
+
+// notifyWork sends the current work package to the subscribers and the
+// notification URLs. The lock must be held.
+func (s *remoteSealer) notifyWork() {
+	work := s.currentWork
+	for _, ch := range s.workSubs {
+		// Replace the work package the subscriber hasn't picked up yet, it's
+		// stale. This never blocks as the sealer is the only sender.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- work
+	}
+	if len(s.notifyURLs) == 0 {
+		return
+	}
+	var blob []byte
+	if s.notifyFull {
+		blob, _ = json.Marshal(s.currentTask.block.Header())
+	} else {
+		blob, _ = json.Marshal(work)
+	}
+	s.reqWG.Add(len(s.notifyURLs))
+	for _, url := range s.notifyURLs {
+		go s.sendNotification(s.notifyCtx, url, blob, work)
+	}
+}
+
+// sendNotification posts a new work package to a notification URL.
+func (s *remoteSealer) sendNotification(ctx context.Context, url string, blob []byte, work [4]string) {
+	defer s.reqWG.Done()
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(blob))
+	if err != nil {
+		log.Warn("Can't create remote miner notification", "err", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Warn("Failed to notify remote miner", "url", url, "err", err)
+		return
+	}
+	resp.Body.Close()
+	log.Trace("Notified remote miner", "url", url, "hash", work[0], "target", work[2])
+}
+
+// submitWork hands a solution to the sealer, returning an error if it's invalid
+// or stale. A zero digest is computed by the sealer.
+func (s *remoteSealer) submitWork(nonce types.BlockNonce, hash, digest common.Hash) error {
+	errc := make(chan error, 1)
+
+	select {
+	case s.submitWorkCh <- &mineResult{nonce: nonce, mixDigest: digest, hash: hash, errc: errc}:
+	case <-s.exitCh:
+		return errRandomXStopped
+	}
+	return <-errc
+}
+
+// cancel withdraws the work of the given seal hash from the remote miners.
 func (s *remoteSealer) cancel(hash common.Hash) {
 	select {
 	case s.cancelCh <- hash:
-	default:
+	case <-s.exitCh:
 	}
 }
 
@@ -0,0 +1,178 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build cgo
+
+package randomx
+
+/*
+#cgo CFLAGS: -O3 -march=native
+#cgo LDFLAGS: -lrandomx -lm -lstdc++
+
+#include <stdlib.h>
+
+// Forward declarations for RandomX C API
+typedef struct randomx_cache randomx_cache;
+typedef struct randomx_dataset randomx_dataset;
+typedef struct randomx_vm randomx_vm;
+
+// RandomX flags
+typedef enum {
+	RANDOMX_FLAG_DEFAULT = 0,
+	RANDOMX_FLAG_LARGE_PAGES = 1,
+	RANDOMX_FLAG_HARD_AES = 2,
+	RANDOMX_FLAG_FULL_MEM = 4,
+	RANDOMX_FLAG_JIT = 8,
+	RANDOMX_FLAG_SECURE = 16,
+	RANDOMX_FLAG_ARGON2_SSSE3 = 32,
+	RANDOMX_FLAG_ARGON2_AVX2 = 64,
+	RANDOMX_FLAG_ARGON2 = 96
+} randomx_flags;
+
+// RandomX C API functions
+extern randomx_cache *randomx_alloc_cache(randomx_flags flags);
+extern void randomx_init_cache(randomx_cache *cache, const void *key, size_t keySize);
+extern void randomx_release_cache(randomx_cache* cache);
+
+extern randomx_dataset *randomx_alloc_dataset(randomx_flags flags);
+extern unsigned long randomx_dataset_item_count(void);
+extern void randomx_init_dataset(randomx_dataset *dataset, randomx_cache *cache, unsigned long startItem, unsigned long itemCount);
+extern void randomx_release_dataset(randomx_dataset *dataset);
+extern void *randomx_get_dataset_memory(randomx_dataset *dataset);
+
+extern randomx_vm *randomx_create_vm(randomx_flags flags, randomx_cache *cache, randomx_dataset *dataset);
+extern void randomx_vm_set_cache(randomx_vm *machine, randomx_cache* cache);
+extern void randomx_vm_set_dataset(randomx_vm *machine, randomx_dataset *dataset);
+extern void randomx_destroy_vm(randomx_vm *machine);
+
+extern void randomx_calculate_hash(randomx_vm *machine, const void *input, size_t inputSize, void *output);
+extern void randomx_calculate_hash_first(randomx_vm *machine, const void *input, size_t inputSize);
+extern void randomx_calculate_hash_next(randomx_vm *machine, const void *input, size_t inputSize, void *output);
+*/
+import "C"
+import (
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// The engine runs on the RandomX library if cgo is available, supporting the
+// full dataset and the JIT compiler.
+type (
+	rxFlags   = C.randomx_flags
+	rxCache   = C.randomx_cache
+	rxDataset = C.randomx_dataset
+	rxVM      = C.randomx_vm
+)
+
+// haveDataset reports whether the build can mine with the full dataset.
+const haveDataset = true
+
+// probeFlags finds the fastest supported set of RandomX flags by trying to
+// allocate a cache with them, falling back to slower options on failure.
+func probeFlags(hugePages, jit bool) rxFlags {
+	// Try optimal flags: JIT + HardAES + Large Pages (best performance)
+	if jit && hugePages {
+		optimal := flagJIT | flagHardAES | flagLargePages
+		if testCache := allocCache(optimal); testCache != nil {
+			releaseCache(testCache)
+			log.Info("RandomX using optimal flags", "jit", true, "hugepages", true, "hardAES", true)
+			return optimal
+		}
+	}
+	// Fallback 1: JIT + HardAES (no huge pages)
+	if jit {
+		fallback := flagJIT | flagHardAES
+		if testCache := allocCache(fallback); testCache != nil {
+			releaseCache(testCache)
+			if hugePages {
+				log.Warn("RandomX using JIT without huge pages (performance -30%)", "jit", true, "hugepages", false)
+			} else {
+				log.Info("RandomX using JIT, huge pages disabled", "jit", true, "hugepages", false)
+			}
+			return fallback
+		}
+	}
+	// Fallback 2: HardAES + Large Pages (JIT disabled or unavailable)
+	if hugePages {
+		fallback := flagHardAES | flagLargePages
+		if testCache := allocCache(fallback); testCache != nil {
+			releaseCache(testCache)
+			log.Warn("RandomX using interpreted mode (performance -10-15×)", "jit", false, "hugepages", true)
+			return fallback
+		}
+	}
+	// Fallback 3: HardAES only (no JIT, no huge pages) - slowest but stable
+	log.Warn("RandomX using interpreted mode (performance -10-15×)", "jit", false, "hugepages", false,
+		"hint", "Enable huge pages: sudo sysctl -w vm.nr_hugepages=1280")
+	return flagDefault | flagHardAES
+}
+
+func allocCache(flags rxFlags) *rxCache {
+	return C.randomx_alloc_cache(flags)
+}
+
+func initCache(cache *rxCache, seed []byte) {
+	C.randomx_init_cache(cache, unsafe.Pointer(&seed[0]), C.size_t(len(seed)))
+}
+
+func releaseCache(cache *rxCache) {
+	C.randomx_release_cache(cache)
+}
+
+func allocDataset(flags rxFlags) *rxDataset {
+	return C.randomx_alloc_dataset(flags)
+}
+
+func datasetItemCount() uint64 {
+	return uint64(C.randomx_dataset_item_count())
+}
+
+func initDataset(dataset *rxDataset, cache *rxCache, start, count uint64) {
+	C.randomx_init_dataset(dataset, cache, C.ulong(start), C.ulong(count))
+}
+
+func releaseDataset(dataset *rxDataset) {
+	C.randomx_release_dataset(dataset)
+}
+
+// datasetMemory returns the items of a dataset.
+func datasetMemory(dataset *rxDataset, itemCount uint64) []byte {
+	return unsafe.Slice((*byte)(C.randomx_get_dataset_memory(dataset)), itemCount*datasetItemSize)
+}
+
+func createVM(flags rxFlags, cache *rxCache, dataset *rxDataset) *rxVM {
+	return C.randomx_create_vm(flags, cache, dataset)
+}
+
+func setVMCache(vm *rxVM, cache *rxCache) {
+	C.randomx_vm_set_cache(vm, cache)
+}
+
+func destroyVM(vm *rxVM) {
+	C.randomx_destroy_vm(vm)
+}
+
+// hashRandomX calculates the RandomX hash for the given input
+func hashRandomX(vm *rxVM, input []byte) common.Hash {
+	var hash common.Hash
+	inputPtr := (*C.char)(unsafe.Pointer(&input[0]))
+	hashPtr := unsafe.Pointer(&hash[0])
+
+	C.randomx_calculate_hash(vm, unsafe.Pointer(inputPtr), C.size_t(len(input)), hashPtr)
+	return hash
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !cgo
+
+package randomx
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Without cgo the engine hashes with the Go light mode implementation. The
+// flags are ignored and no dataset can be allocated, so mining falls back to
+// light mode too.
+type (
+	rxFlags   uint32
+	rxDataset struct{}
+	rxVM      = lightVM
+)
+
+// haveDataset reports whether the build can mine with the full dataset.
+const haveDataset = false
+
+// rxCache is a cache allocated for a seed, computed once initialised.
+type rxCache struct {
+	light *lightCache
+}
+
+// probeFlags returns the default flags, there are no features to select.
+func probeFlags(hugePages, jit bool) rxFlags {
+	log.Warn("RandomX library unavailable, using the slow Go implementation in light mode",
+		"hint", "Build with CGO_ENABLED=1 and librandomx to mine")
+	return flagDefault
+}
+
+func allocCache(flags rxFlags) *rxCache {
+	return new(rxCache)
+}
+
+func initCache(cache *rxCache, seed []byte) {
+	cache.light = newLightCache(seed)
+}
+
+func releaseCache(cache *rxCache) {
+	cache.light = nil
+}
+
+func allocDataset(flags rxFlags) *rxDataset {
+	return nil
+}
+
+func datasetItemCount() uint64 {
+	return (datasetBaseSize + datasetExtraItems*datasetItemSize) / datasetItemSize
+}
+
+// initDataset does nothing, there is no dataset to initialise.
+func initDataset(dataset *rxDataset, cache *rxCache, start, count uint64) {}
+
+func releaseDataset(dataset *rxDataset) {}
+
+// datasetMemory returns nil, there is no dataset memory.
+func datasetMemory(dataset *rxDataset, itemCount uint64) []byte {
+	return nil
+}
+
+func createVM(flags rxFlags, cache *rxCache, dataset *rxDataset) *rxVM {
+	return newLightVM(cache.light)
+}
+
+func setVMCache(vm *rxVM, cache *rxCache) {
+	vm.cache = cache.light
+}
+
+func destroyVM(vm *rxVM) {}
+
+// hashRandomX calculates the RandomX hash for the given input
+func hashRandomX(vm *rxVM, input []byte) common.Hash {
+	return vm.hash(input)
+}
@@ -0,0 +1,343 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import (
+	"encoding/binary"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/holiman/uint256"
+)
+
+const (
+	// defaultUncleRewardDivisor and defaultNephewRewardDivisor are the Ethereum
+	// uncle reward ratios, used if the reward schedule doesn't set them.
+	defaultUncleRewardDivisor  = 8
+	defaultNephewRewardDivisor = 32
+
+	// emissionCheckpointInterval is the number of blocks between the cached
+	// emission totals of a smooth emission schedule.
+	emissionCheckpointInterval = 256
+)
+
+// emissionPrefix is the database key prefix of the emission checkpoints,
+// followed by the schedule hash and the big endian checkpoint index.
+var emissionPrefix = []byte("randomx-emission-")
+
+// emissionCache holds the total emission of a smooth emission schedule at
+// every emissionCheckpointInterval blocks. It tracks the schedule of a single
+// chain, and starts over if it is queried for another one. If a database is
+// set, the checkpoints are persisted so that they are not replayed from the
+// genesis after a restart.
+type emissionCache struct {
+	lock     sync.Mutex
+	db       ethdb.KeyValueStore // Database the checkpoints are persisted in, if any
+	schedule *params.RandomXRewards
+	prefix   []byte         // Database key prefix of the checkpoints of the schedule
+	totals   []*uint256.Int // totals[i] is the emission up to block i*emissionCheckpointInterval
+}
+
+// rewardSchedule returns the configured reward schedule of the chain, or nil
+// if the legacy Ethereum rewards apply.
+func rewardSchedule(config *params.ChainConfig) *params.RandomXRewards {
+	if config == nil || config.RandomX == nil {
+		return nil
+	}
+	return config.RandomX.Rewards
+}
+
+// blockReward returns the base reward of the block with the given number,
+// before the treasury share and excluding any uncle inclusion rewards.
+// The emission cache is only used by smooth emission schedules.
+func blockReward(config *params.ChainConfig, emissions *emissionCache, number uint64) *uint256.Int {
+	schedule := rewardSchedule(config)
+	switch {
+	case number == 0:
+		return new(uint256.Int)
+	case schedule == nil:
+		return legacyBlockReward(config, number)
+	case schedule.EmissionSpeedFactor != 0:
+		return smoothReward(schedule, emission(config, emissions, number-1))
+	default:
+		return halvingReward(schedule, halvingEra(schedule, number))
+	}
+}
+
+// emission returns the sum of the base rewards of all blocks up to and
+// including the given one. The genesis block pays no reward.
+func emission(config *params.ChainConfig, emissions *emissionCache, number uint64) *uint256.Int {
+	schedule := rewardSchedule(config)
+	switch {
+	case schedule == nil:
+		return legacyEmission(config, number)
+	case schedule.EmissionSpeedFactor != 0:
+		return emissions.smoothEmission(schedule, number)
+	default:
+		return halvingEmission(schedule, number)
+	}
+}
+
+// legacyBlockReward returns the Ethereum block reward at the given number.
+func legacyBlockReward(config *params.ChainConfig, number uint64) *uint256.Int {
+	num := new(big.Int).SetUint64(number)
+	switch {
+	case config.IsConstantinople(num):
+		return ConstantinopleBlockReward
+	case config.IsByzantium(num):
+		return ByzantiumBlockReward
+	default:
+		return FrontierBlockReward
+	}
+}
+
+// legacyEmission sums the Ethereum block rewards of blocks 1 to number. The
+// reward only changes at the Byzantium and Constantinople fork blocks, so the
+// range is summed in constant reward segments.
+func legacyEmission(config *params.ChainConfig, number uint64) *uint256.Int {
+	var (
+		total = new(uint256.Int)
+		next  = uint64(1)
+	)
+	for _, fork := range []*big.Int{config.ByzantiumBlock, config.ConstantinopleBlock, nil} {
+		end := number + 1
+		if fork != nil && fork.IsUint64() && fork.Uint64() < end {
+			end = fork.Uint64()
+		}
+		if end <= next {
+			continue
+		}
+		segment := new(uint256.Int).SetUint64(end - next)
+		total.Add(total, segment.Mul(segment, legacyBlockReward(config, next)))
+		next = end
+	}
+	return total
+}
+
+// tailReward returns the minimum block reward of a schedule.
+func tailReward(schedule *params.RandomXRewards) *uint256.Int {
+	if schedule.TailReward == nil {
+		return new(uint256.Int)
+	}
+	tail, _ := uint256.FromBig(schedule.TailReward)
+	return tail
+}
+
+// halvingEra returns the halving era of a block, which is zero for schedules
+// without halvings.
+func halvingEra(schedule *params.RandomXRewards, number uint64) uint64 {
+	if schedule.HalvingInterval == 0 {
+		return 0
+	}
+	return number / schedule.HalvingInterval
+}
+
+// halvingReward returns the base block reward during a halving era.
+func halvingReward(schedule *params.RandomXRewards, era uint64) *uint256.Int {
+	reward, _ := uint256.FromBig(schedule.InitialReward)
+	if era >= 256 {
+		reward.Clear()
+	} else {
+		reward.Rsh(reward, uint(era))
+	}
+	if tail := tailReward(schedule); reward.Lt(tail) {
+		return tail
+	}
+	return reward
+}
+
+// halvingEmission sums the base rewards of blocks 1 to number of a halving
+// schedule era by era, switching to a single tail segment once the reward
+// reaches its floor.
+func halvingEmission(schedule *params.RandomXRewards, number uint64) *uint256.Int {
+	total := new(uint256.Int)
+	if number == 0 {
+		return total
+	}
+	if schedule.HalvingInterval == 0 {
+		count := new(uint256.Int).SetUint64(number)
+		return total.Mul(count, halvingReward(schedule, 0))
+	}
+	var (
+		tail = tailReward(schedule)
+		next = uint64(1)
+	)
+	for era := uint64(0); ; era++ {
+		// Sum up to the end of the era, or to the end if the tail is reached
+		reward := halvingReward(schedule, era)
+		end := number
+		if reward.Gt(tail) && end-era*schedule.HalvingInterval >= schedule.HalvingInterval {
+			end = era*schedule.HalvingInterval + schedule.HalvingInterval - 1
+		}
+		count := new(uint256.Int).SetUint64(end - next + 1)
+		total.Add(total, count.Mul(count, reward))
+		if end == number {
+			return total
+		}
+		next = end + 1
+	}
+}
+
+// smoothReward returns the smooth emission block reward given the emission of
+// all previous blocks.
+func smoothReward(schedule *params.RandomXRewards, emitted *uint256.Int) *uint256.Int {
+	reward, _ := uint256.FromBig(schedule.MaxSupply)
+	if reward.Lt(emitted) {
+		reward.Clear()
+	} else {
+		reward.Sub(reward, emitted)
+	}
+	reward.Rsh(reward, uint(schedule.EmissionSpeedFactor))
+	if tail := tailReward(schedule); reward.Lt(tail) {
+		return tail
+	}
+	return reward
+}
+
+// smoothEmission sums the base rewards of blocks 1 to number of a smooth
+// emission schedule. Each reward depends on all the previous ones, so the
+// totals are cached at regular checkpoints to avoid replaying the entire
+// schedule on every call. The lock is only held to read and extend the
+// checkpoints, not while replaying the blocks between them.
+func (c *emissionCache) smoothEmission(schedule *params.RandomXRewards, number uint64) *uint256.Int {
+	index := number / emissionCheckpointInterval
+	for {
+		c.lock.Lock()
+		if c.schedule != schedule {
+			c.reset(schedule)
+		}
+		last := uint64(len(c.totals) - 1)
+		emitted := c.totals[min(last, index)]
+		c.lock.Unlock()
+
+		if last >= index {
+			return replaySmoothEmission(schedule, emitted, index*emissionCheckpointInterval, number)
+		}
+		// Extend the checkpoints by one, unless someone else did meanwhile
+		from := last * emissionCheckpointInterval
+		total := replaySmoothEmission(schedule, emitted, from, from+emissionCheckpointInterval)
+
+		c.lock.Lock()
+		if c.schedule == schedule && uint64(len(c.totals)) == last+1 {
+			c.totals = append(c.totals, total)
+			c.store(last+1, total)
+		}
+		c.lock.Unlock()
+	}
+}
+
+// setDatabase sets the database the checkpoints are persisted in, reloading
+// them on the next query.
+func (c *emissionCache) setDatabase(db ethdb.KeyValueStore) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.db, c.schedule, c.totals = db, nil, nil
+}
+
+// reset switches the cache to the given schedule, loading its persisted
+// checkpoints. The lock must be held.
+func (c *emissionCache) reset(schedule *params.RandomXRewards) {
+	c.schedule, c.totals = schedule, []*uint256.Int{new(uint256.Int)}
+	c.prefix = append(common.CopyBytes(emissionPrefix), emissionScheduleHash(schedule).Bytes()...)
+	if c.db == nil {
+		return
+	}
+	it := c.db.NewIterator(c.prefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()[len(c.prefix):]
+		if len(key) != 8 || binary.BigEndian.Uint64(key) != uint64(len(c.totals)) {
+			break
+		}
+		c.totals = append(c.totals, new(uint256.Int).SetBytes(it.Value()))
+	}
+}
+
+// store persists a checkpoint of the current schedule. The lock must be held.
+func (c *emissionCache) store(index uint64, total *uint256.Int) {
+	if c.db == nil {
+		return
+	}
+	if err := c.db.Put(binary.BigEndian.AppendUint64(common.CopyBytes(c.prefix), index), total.Bytes()); err != nil {
+		log.Warn("Failed to store emission checkpoint", "index", index, "err", err)
+	}
+}
+
+// emissionScheduleHash identifies the parameters of a smooth emission
+// schedule, the checkpoints of distinct schedules are stored apart.
+func emissionScheduleHash(schedule *params.RandomXRewards) common.Hash {
+	enc, _ := rlp.EncodeToBytes([]interface{}{
+		bigOrZero(schedule.MaxSupply), schedule.EmissionSpeedFactor, bigOrZero(schedule.TailReward),
+	})
+	return crypto.Keccak256Hash(enc)
+}
+
+// bigOrZero returns the given number, or zero if nil.
+func bigOrZero(n *big.Int) *big.Int {
+	if n == nil {
+		return new(big.Int)
+	}
+	return n
+}
+
+// replaySmoothEmission returns the emission at block to, given the emission
+// at block from.
+func replaySmoothEmission(schedule *params.RandomXRewards, emitted *uint256.Int, from, to uint64) *uint256.Int {
+	total := new(uint256.Int).Set(emitted)
+	for number := from + 1; number <= to; number++ {
+		total.Add(total, smoothReward(schedule, total))
+	}
+	return total
+}
+
+// uncleRewardDivisors returns the uncle and nephew reward divisors of the
+// chain.
+func uncleRewardDivisors(config *params.ChainConfig) (uncle uint64, nephew uint64) {
+	uncle, nephew = defaultUncleRewardDivisor, defaultNephewRewardDivisor
+	if schedule := rewardSchedule(config); schedule != nil {
+		if schedule.UncleRewardDivisor != 0 {
+			uncle = schedule.UncleRewardDivisor
+		}
+		if schedule.NephewRewardDivisor != 0 {
+			nephew = schedule.NephewRewardDivisor
+		}
+	}
+	return uncle, nephew
+}
+
+// fundShare splits the treasury share off a base block reward, returning the
+// active fund and its share. The fund is nil if no treasury share applies.
+func fundShare(config *params.ChainConfig, number uint64, reward *uint256.Int) (*params.RandomXFund, *uint256.Int) {
+	schedule := rewardSchedule(config)
+	if schedule == nil {
+		return nil, new(uint256.Int)
+	}
+	fund := schedule.FundAt(number)
+	if fund == nil {
+		return nil, new(uint256.Int)
+	}
+	share := new(uint256.Int).Mul(reward, uint256.NewInt(fund.Percent))
+	return fund, share.Div(share, uint256.NewInt(100))
+}
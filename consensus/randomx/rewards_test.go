@@ -0,0 +1,204 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/holiman/uint256"
+)
+
+// Tests that the closed form and cached emission totals match the sum of the
+// individual block rewards.
+func TestEmissionMatchesBlockRewards(t *testing.T) {
+	ether := big.NewInt(params.Ether)
+	schedules := map[string]*params.RandomXRewards{
+		"halving": {
+			InitialReward:   new(big.Int).Mul(big.NewInt(8), ether),
+			HalvingInterval: 1000,
+			TailReward:      new(big.Int).Div(ether, big.NewInt(2)),
+		},
+		"smooth": {
+			InitialReward:       new(big.Int),
+			MaxSupply:           new(big.Int).Mul(big.NewInt(1_000_000), ether),
+			EmissionSpeedFactor: 12,
+			TailReward:          new(big.Int).Div(ether, big.NewInt(10)),
+		},
+	}
+	for name, schedule := range schedules {
+		var (
+			config    = &params.ChainConfig{RandomX: &params.RandomXConfig{Rewards: schedule}}
+			emissions = new(emissionCache)
+		)
+
+		total := new(uint256.Int)
+		for number := uint64(1); number <= 3*emissionCheckpointInterval+17; number++ {
+			total.Add(total, blockReward(config, emissions, number))
+			if number%997 == 0 || number%emissionCheckpointInterval == 0 {
+				if have := emission(config, emissions, number); !have.Eq(total) {
+					t.Fatalf("%s: emission mismatch at block %d: have %v, want %v", name, number, have, total)
+				}
+			}
+		}
+	}
+}
+
+// Tests that the emission checkpoints are reloaded from the database instead of
+// being replayed, and only for the schedule they were computed for.
+func TestEmissionCheckpointsPersisted(t *testing.T) {
+	ether := big.NewInt(params.Ether)
+	newSchedule := func(factor uint64) *params.RandomXRewards {
+		return &params.RandomXRewards{
+			MaxSupply:           new(big.Int).Mul(big.NewInt(1_000_000), ether),
+			EmissionSpeedFactor: factor,
+		}
+	}
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		number = uint64(10*emissionCheckpointInterval + 5)
+		stored = new(emissionCache)
+	)
+	stored.setDatabase(db)
+	want := stored.smoothEmission(newSchedule(12), number)
+
+	// A restarted node loads the checkpoints of the same schedule
+	loaded := new(emissionCache)
+	loaded.setDatabase(db)
+	if have := loaded.smoothEmission(newSchedule(12), 0); have.Sign() != 0 {
+		t.Fatalf("genesis emission mismatch: have %v", have)
+	}
+	if len(loaded.totals) != 11 {
+		t.Fatalf("checkpoints not loaded: have %d, want 11", len(loaded.totals))
+	}
+	if have := loaded.smoothEmission(newSchedule(12), number); !have.Eq(want) {
+		t.Fatalf("emission mismatch: have %v, want %v", have, want)
+	}
+	// Another schedule doesn't reuse them
+	other := new(emissionCache)
+	other.setDatabase(db)
+	if have, want := other.smoothEmission(newSchedule(13), number), new(emissionCache).smoothEmission(newSchedule(13), number); !have.Eq(want) {
+		t.Fatalf("emission of other schedule mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that the uncle reward decreases over the uncle depth window, whatever
+// the reward divisor.
+func TestUncleRewards(t *testing.T) {
+	var (
+		reward   = new(big.Int).Mul(big.NewInt(16), big.NewInt(params.Ether))
+		schedule = &params.RandomXRewards{InitialReward: reward, UncleRewardDivisor: 16, NephewRewardDivisor: 64}
+		config   = &params.ChainConfig{RandomX: &params.RandomXConfig{Rewards: schedule}}
+		miner    = common.Address{1}
+	)
+	for depth := 1; depth <= uncleDepthWindow; depth++ {
+		statedb, _ := state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+		header := &types.Header{Number: big.NewInt(10), Coinbase: miner}
+		uncle := &types.Header{Number: big.NewInt(int64(10 - depth)), Coinbase: common.Address{2}}
+		accumulateRewards(config, new(emissionCache), statedb, header, []*types.Header{uncle})
+
+		want := big.NewInt(int64(uncleDepthWindow - depth))
+		want.Mul(want, reward).Div(want, big.NewInt(16))
+		if have := statedb.GetBalance(uncle.Coinbase).ToBig(); have.Cmp(want) != 0 {
+			t.Errorf("depth %d: uncle reward mismatch: have %v, want %v", depth, have, want)
+		}
+		want = new(big.Int).Add(reward, new(big.Int).Div(reward, big.NewInt(64)))
+		if have := statedb.GetBalance(miner).ToBig(); have.Cmp(want) != 0 {
+			t.Errorf("depth %d: miner reward mismatch: have %v, want %v", depth, have, want)
+		}
+	}
+}
+
+// Tests that the halving schedule halves the reward and stops at the tail.
+func TestHalvingReward(t *testing.T) {
+	config := &params.ChainConfig{RandomX: &params.RandomXConfig{Rewards: &params.RandomXRewards{
+		InitialReward:   big.NewInt(800),
+		HalvingInterval: 100,
+		TailReward:      big.NewInt(150),
+	}}}
+	tests := []struct {
+		number uint64
+		want   uint64
+	}{
+		{0, 0}, {1, 800}, {99, 800}, {100, 400}, {200, 200}, {300, 150}, {100000, 150},
+	}
+	for _, tt := range tests {
+		if have := blockReward(config, nil, tt.number); have.Uint64() != tt.want {
+			t.Errorf("block %d: reward mismatch: have %v, want %d", tt.number, have, tt.want)
+		}
+	}
+}
+
+// Tests that the treasury share is split off the miner's reward once the fund
+// activates.
+func TestFundShare(t *testing.T) {
+	treasury := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	config := &params.ChainConfig{RandomX: &params.RandomXConfig{Rewards: &params.RandomXRewards{
+		InitialReward: big.NewInt(1000),
+		Funds: []params.RandomXFund{
+			{Block: big.NewInt(10), Address: treasury, Percent: 5},
+			{Block: big.NewInt(20), Address: treasury, Percent: 0},
+		},
+	}}}
+	tests := []struct {
+		number uint64
+		fund   bool
+		share  uint64
+	}{
+		{9, false, 0}, {10, true, 50}, {19, true, 50}, {20, false, 0},
+	}
+	for _, tt := range tests {
+		fund, share := fundShare(config, tt.number, blockReward(config, nil, tt.number))
+		if (fund != nil) != tt.fund || share.Uint64() != tt.share {
+			t.Errorf("block %d: fund mismatch: have %v/%v, want %v/%d", tt.number, fund != nil, share, tt.fund, tt.share)
+		}
+	}
+}
+
+// Tests that the reward API only serves blocks up to a bounded distance past
+// the chain head.
+func TestRewardAPILookahead(t *testing.T) {
+	config := &params.ChainConfig{RandomX: &params.RandomXConfig{Rewards: &params.RandomXRewards{
+		InitialReward:       new(big.Int),
+		MaxSupply:           big.NewInt(1_000_000_000),
+		EmissionSpeedFactor: 12,
+	}}}
+	chain := newLWMAChainReader(config)
+	chain.addHeader(&types.Header{Number: big.NewInt(1000)})
+
+	api := &RewardAPI{randomx: NewFaker(), chain: chain}
+	if _, err := api.GetEmission(rpc.BlockNumber(1000 + maxRewardLookahead)); err != nil {
+		t.Fatalf("emission within lookahead rejected: %v", err)
+	}
+	if _, err := api.GetEmission(rpc.BlockNumber(1001 + maxRewardLookahead)); !errors.Is(err, errRewardTooFar) {
+		t.Fatalf("emission beyond lookahead: have %v, want %v", err, errRewardTooFar)
+	}
+	if _, err := api.GetBlockReward(rpc.BlockNumber(math.MaxInt64)); !errors.Is(err, errRewardTooFar) {
+		t.Fatalf("reward beyond lookahead: have %v, want %v", err, errRewardTooFar)
+	}
+	if reward, err := api.GetBlockReward(rpc.PendingBlockNumber); err != nil || uint64(reward.Number) != 1001 {
+		t.Fatalf("pending block reward: have %v/%v, want block 1001", reward, err)
+	}
+}
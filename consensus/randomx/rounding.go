@@ -0,0 +1,103 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import "math"
+
+// roundingMode is an IEEE 754 rounding mode, numbered as the CFROUND
+// instruction selects them.
+type roundingMode uint8
+
+const (
+	roundNearest roundingMode = iota
+	roundDown
+	roundUp
+	roundToZero
+)
+
+// Go only computes with round to nearest, so the other modes are emulated: the
+// result is rounded to nearest, the exact rounding error is recovered and the
+// result moved by one unit in the last place if the mode requires it. RandomX
+// keeps all values normal, the error terms below are exact in that range.
+
+// fpAdd returns a+b rounded with the given mode.
+func fpAdd(a, b float64, mode roundingMode) float64 {
+	s := a + b
+	switch {
+	case mode == roundNearest:
+		return s
+	case s == 0:
+		// Exact zero sums are positive except when rounding down
+		if mode == roundDown {
+			return -(-a - b)
+		}
+		return s
+	}
+	bb := s - a
+	err := (a - (s - bb)) + (b - bb)
+	return fpRound(s, err, mode)
+}
+
+// fpSub returns a-b rounded with the given mode.
+func fpSub(a, b float64, mode roundingMode) float64 {
+	return fpAdd(a, -b, mode)
+}
+
+// fpMul returns a*b rounded with the given mode.
+func fpMul(a, b float64, mode roundingMode) float64 {
+	p := float64(a * b)
+	if mode == roundNearest {
+		return p
+	}
+	return fpRound(p, math.FMA(a, b, -p), mode)
+}
+
+// fpDiv returns a/b rounded with the given mode.
+func fpDiv(a, b float64, mode roundingMode) float64 {
+	q := a / b
+	if mode == roundNearest {
+		return q
+	}
+	err := math.FMA(-q, b, a) // a - q*b, with the sign of the error times b
+	if b < 0 {
+		err = -err
+	}
+	return fpRound(q, err, mode)
+}
+
+// fpSqrt returns the square root of a rounded with the given mode.
+func fpSqrt(a float64, mode roundingMode) float64 {
+	s := math.Sqrt(a)
+	if mode == roundNearest {
+		return s
+	}
+	return fpRound(s, math.FMA(-s, s, a), mode)
+}
+
+// fpRound rounds a result rounded to nearest with the given mode instead, the
+// sign of err being the sign of the exact result minus the rounded one.
+func fpRound(r, err float64, mode roundingMode) float64 {
+	switch {
+	case mode == roundDown && err < 0:
+		return math.Nextafter(r, math.Inf(-1))
+	case mode == roundUp && err > 0:
+		return math.Nextafter(r, math.Inf(1))
+	case mode == roundToZero && (r > 0 && err < 0 || r < 0 && err > 0):
+		return math.Nextafter(r, 0)
+	}
+	return r
+}
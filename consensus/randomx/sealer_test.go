@@ -0,0 +1,303 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// pushWork hands a block to the remote sealer as if it was being sealed.
+func pushWork(t *testing.T, randomx *RandomX, header *types.Header) {
+	block := types.NewBlockWithHeader(header)
+	task := &sealTask{block: block, sealHash: randomx.SealHash(header)}
+	select {
+	case randomx.remote.workCh <- task:
+	case <-time.After(time.Second):
+		t.Fatalf("remote sealer not accepting work")
+	}
+}
+
+// Tests that the notification URLs receive the new work packages.
+func TestRemoteNotify(t *testing.T) {
+	sink := make(chan [4]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var work [4]string
+		if err := json.NewDecoder(req.Body).Decode(&work); err != nil {
+			t.Errorf("failed to unmarshal work package: %v", err)
+		}
+		sink <- work
+	}))
+	defer server.Close()
+
+	randomx := New(&Config{PowMode: ModeTest, Notify: []string{server.URL}})
+	defer randomx.Close()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	pushWork(t, randomx, header)
+
+	select {
+	case work := <-sink:
+		if want := randomx.SealHash(header).Hex(); work[0] != want {
+			t.Errorf("work packet hash mismatch: have %s, want %s", work[0], want)
+		}
+		if work[3] != "0x1" {
+			t.Errorf("work packet number mismatch: have %s, want 0x1", work[3])
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("notification timed out")
+	}
+}
+
+// Tests that the full pending header is sent to the notification URLs if
+// requested.
+func TestRemoteNotifyFull(t *testing.T) {
+	sink := make(chan map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var header map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&header); err != nil {
+			t.Errorf("failed to unmarshal header: %v", err)
+		}
+		sink <- header
+	}))
+	defer server.Close()
+
+	randomx := New(&Config{PowMode: ModeTest, Notify: []string{server.URL}, NotifyFull: true})
+	defer randomx.Close()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	pushWork(t, randomx, header)
+
+	select {
+	case work := <-sink:
+		if work["number"] != "0x1" || work["difficulty"] != "0x64" {
+			t.Errorf("pending header mismatch: %v", work)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("notification timed out")
+	}
+}
+
+// Tests that work subscribers get the current and the following work packages,
+// skipping the ones they didn't pick up in time.
+func TestRemoteWorkSubscription(t *testing.T) {
+	randomx := New(&Config{PowMode: ModeTest})
+	defer randomx.Close()
+
+	parent := types.EmptyRootHash
+	first := &types.Header{ParentHash: parent, Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	pushWork(t, randomx, first)
+
+	works := randomx.remote.subscribeWork()
+	defer randomx.remote.unsubscribeWork(works)
+
+	if work := <-works; work[0] != randomx.SealHash(first).Hex() {
+		t.Fatalf("current work mismatch: have %s", work[0])
+	}
+	// Only the latest of two work packages is kept for a slow subscriber
+	second := &types.Header{ParentHash: parent, Number: big.NewInt(1), Difficulty: big.NewInt(200)}
+	third := &types.Header{ParentHash: parent, Number: big.NewInt(1), Difficulty: big.NewInt(300)}
+	pushWork(t, randomx, second)
+	pushWork(t, randomx, third)
+
+	// The sealer handles requests in order, so the work is out once it answers
+	if _, err := (&API{randomx}).GetWork(); err != nil {
+		t.Fatalf("failed to get work: %v", err)
+	}
+	select {
+	case work := <-works:
+		if work[0] != randomx.SealHash(third).Hex() {
+			t.Fatalf("latest work mismatch: have %s, want %s", work[0], randomx.SealHash(third).Hex())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("work package not delivered")
+	}
+}
+
+// Tests that work on a new head replaces the work and templates of the old one.
+func TestRemoteWorkNewHead(t *testing.T) {
+	randomx := New(&Config{PowMode: ModeTest})
+	defer randomx.Close()
+
+	var (
+		genesis = &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1)}
+		chain   = &headChain{head: genesis}
+		results = make(chan *types.Block, 1)
+	)
+	old := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	pushWork(t, randomx, old)
+	template := types.NewBlockWithHeader(&types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), Difficulty: big.NewInt(200)})
+	if _, err := randomx.SealTemplate(chain, template, results); err != nil {
+		t.Fatalf("failed to hand out template: %v", err)
+	}
+	works := randomx.remote.subscribeWork()
+	defer randomx.remote.unsubscribeWork(works)
+	<-works
+
+	// A block got imported, the miner moves to the new head
+	head := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), Difficulty: big.NewInt(1), Extra: []byte{1}}
+	chain.head = head
+	next := &types.Header{ParentHash: head.Hash(), Number: big.NewInt(2), Difficulty: big.NewInt(100)}
+	pushWork(t, randomx, next)
+
+	want := randomx.SealHash(next).Hex()
+	if work, err := (&API{randomx}).GetWork(); err != nil || work[0] != want {
+		t.Fatalf("work after new head mismatch: have %v, %v, want %s", work, err, want)
+	}
+	select {
+	case work := <-works:
+		if work[0] != want {
+			t.Fatalf("pushed work mismatch: have %s, want %s", work[0], want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("work on the new head not pushed")
+	}
+	if block, _, err := randomx.remote.template(); err != nil || block.ParentHash() != head.Hash() {
+		t.Fatalf("daemon template not on the new head: %v", err)
+	}
+	for _, hash := range []common.Hash{randomx.SealHash(old), randomx.SealHash(template.Header())} {
+		if err := randomx.remote.submitWork(types.BlockNonce{}, hash, common.Hash{1}); err != errInvalidSealResult {
+			t.Fatalf("work on the old head accepted: %v", err)
+		}
+	}
+	randomx.remote.mutex.Lock()
+	defer randomx.remote.mutex.Unlock()
+	if len(randomx.remote.works) != 1 || len(randomx.remote.templates) != 0 {
+		t.Fatalf("work after new head: have %d works, %d templates", len(randomx.remote.works), len(randomx.remote.templates))
+	}
+}
+
+// Tests that work templates are tracked for their head only and bounded.
+func TestRemoteWorkTemplates(t *testing.T) {
+	randomx := New(&Config{PowMode: ModeTest})
+	defer randomx.Close()
+
+	var (
+		genesis = &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1)}
+		chain   = &headChain{head: genesis}
+		results = make(chan *types.Block, 1)
+	)
+	template := func(parent *types.Header, extra byte) *types.Block {
+		return types.NewBlockWithHeader(&types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number, big.NewInt(1)),
+			Difficulty: big.NewInt(100),
+			Extra:      []byte{extra},
+		})
+	}
+	if _, err := randomx.SealTemplate(chain, template(&types.Header{Number: big.NewInt(5)}, 0), results); err != errStaleTemplate {
+		t.Fatalf("template off the head: have %v, want %v", err, errStaleTemplate)
+	}
+	var works [][4]string
+	for i := 0; i <= maxWorkTemplates; i++ {
+		work, err := randomx.SealTemplate(chain, template(genesis, byte(i)), results)
+		if err != nil {
+			t.Fatalf("template %d: %v", i, err)
+		}
+		works = append(works, work)
+	}
+	if work := works[1]; work[0] != randomx.SealHash(template(genesis, 1).Header()).Hex() || work[3] != "0x1" {
+		t.Fatalf("work package mismatch: %v", work)
+	}
+	// The oldest template was dropped beyond the limit
+	if err := randomx.remote.submitWork(types.BlockNonce{}, common.HexToHash(works[0][0]), common.Hash{1}); err != errInvalidSealResult {
+		t.Fatalf("dropped template accepted: %v", err)
+	}
+	// Templates expire with a new head
+	head := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+	chain.head = head
+	if err := randomx.remote.submitWork(types.BlockNonce{}, common.HexToHash(works[1][0]), common.Hash{1}); err != errInvalidSealResult {
+		t.Fatalf("stale template accepted: %v", err)
+	}
+	if _, err := randomx.SealTemplate(chain, template(head, 0), results); err != nil {
+		t.Fatalf("template on the new head: %v", err)
+	}
+	randomx.remote.mutex.Lock()
+	defer randomx.remote.mutex.Unlock()
+	if len(randomx.remote.templates) != 1 || len(randomx.remote.works) != 1 {
+		t.Fatalf("templates after new head: have %d templates, %d works", len(randomx.remote.templates), len(randomx.remote.works))
+	}
+}
+
+// Tests that sealing runs in the background until a nonce is found or it is
+// aborted, withdrawing the work from the remote miners once done.
+func TestSealAsync(t *testing.T) {
+	randomx := New(&Config{PowMode: ModeTest})
+	defer randomx.Close()
+	randomx.SetThreads(1)
+
+	var (
+		genesis = &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1)}
+		chain   = &headChain{head: genesis}
+		results = make(chan *types.Block, 1)
+		stop    = make(chan struct{})
+		api     = &API{randomx}
+	)
+	// Unsealable block, sealing keeps going until aborted
+	hard := types.NewBlockWithHeader(&types.Header{
+		ParentHash: genesis.Hash(),
+		Number:     big.NewInt(1),
+		Difficulty: new(big.Int).Lsh(big.NewInt(1), 250),
+	})
+	if err := randomx.Seal(chain, hard, results, stop); err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+	if work, err := api.GetWork(); err != nil || work[0] != randomx.SealHash(hard.Header()).Hex() {
+		t.Fatalf("work not handed to remote miners: %v, %v", work, err)
+	}
+	close(stop)
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := api.GetWork(); err == errNoMiningWork {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("aborted work still handed to remote miners")
+		}
+	}
+	// Any nonce seals the block, which is delivered asynchronously even if the
+	// miner is busy
+	results <- hard
+	easy := types.NewBlockWithHeader(&types.Header{
+		ParentHash: genesis.Hash(),
+		Number:     big.NewInt(1),
+		Difficulty: big.NewInt(1),
+	})
+	if err := randomx.Seal(chain, easy, results, make(chan struct{})); err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+	time.Sleep(2 * time.Second)
+	<-results
+
+	select {
+	case block := <-results:
+		if block.ParentHash() != genesis.Hash() || block.MixDigest() == (common.Hash{}) {
+			t.Fatalf("sealed block mismatch: parent %x, mix %x", block.ParentHash(), block.MixDigest())
+		}
+		if err := randomx.verifyPoW(chain, block.Header(), nil); err != nil {
+			t.Fatalf("invalid seal: %v", err)
+		}
+	case <-time.After(time.Minute):
+		t.Fatalf("sealing result not delivered")
+	}
+}
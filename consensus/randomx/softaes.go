@@ -0,0 +1,220 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// This file implements the AES based generators and hash of RandomX on top of
+// single AES rounds, with the semantics of the x86 AESENC and AESDEC
+// instructions. A 128-bit state holds four little endian columns.
+
+// aesState is a 128-bit AES state as four little endian columns.
+type aesState [4]uint32
+
+var (
+	aesEncTable [4][256]uint32 // SubBytes and MixColumns of a byte in each row
+	aesDecTable [4][256]uint32 // InvSubBytes and InvMixColumns of a byte in each row
+)
+
+func init() {
+	// Compute the S-box from the multiplicative inverses in GF(2^8)
+	var sbox, inv [256]byte
+	for x := 1; x < 256; x++ {
+		for y := 1; y < 256; y++ {
+			if gfMul(byte(x), byte(y)) == 1 {
+				inv[x] = byte(y)
+				break
+			}
+		}
+	}
+	for x := 0; x < 256; x++ {
+		b := inv[x]
+		sbox[x] = b ^ bits.RotateLeft8(b, 1) ^ bits.RotateLeft8(b, 2) ^ bits.RotateLeft8(b, 3) ^ bits.RotateLeft8(b, 4) ^ 0x63
+	}
+	for x := 0; x < 256; x++ {
+		s := sbox[x]
+		enc := uint32(gfMul(s, 2)) | uint32(s)<<8 | uint32(s)<<16 | uint32(gfMul(s, 3))<<24
+
+		d := byte(0)
+		for y := 0; y < 256; y++ {
+			if sbox[y] == byte(x) {
+				d = byte(y)
+				break
+			}
+		}
+		dec := uint32(gfMul(d, 14)) | uint32(gfMul(d, 9))<<8 | uint32(gfMul(d, 13))<<16 | uint32(gfMul(d, 11))<<24
+
+		for row := 0; row < 4; row++ {
+			aesEncTable[row][x] = bits.RotateLeft32(enc, 8*row)
+			aesDecTable[row][x] = bits.RotateLeft32(dec, 8*row)
+		}
+	}
+}
+
+// gfMul multiplies two elements of the AES field GF(2^8).
+func gfMul(a, b byte) byte {
+	var p byte
+	for ; b != 0; b >>= 1 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+	}
+	return p
+}
+
+// aesEnc applies an AES encryption round (ShiftRows, SubBytes, MixColumns and
+// AddRoundKey) to the state.
+func aesEnc(s *aesState, key *aesState) {
+	s0, s1, s2, s3 := s[0], s[1], s[2], s[3]
+	s[0] = aesEncTable[0][byte(s0)] ^ aesEncTable[1][byte(s1>>8)] ^ aesEncTable[2][byte(s2>>16)] ^ aesEncTable[3][byte(s3>>24)] ^ key[0]
+	s[1] = aesEncTable[0][byte(s1)] ^ aesEncTable[1][byte(s2>>8)] ^ aesEncTable[2][byte(s3>>16)] ^ aesEncTable[3][byte(s0>>24)] ^ key[1]
+	s[2] = aesEncTable[0][byte(s2)] ^ aesEncTable[1][byte(s3>>8)] ^ aesEncTable[2][byte(s0>>16)] ^ aesEncTable[3][byte(s1>>24)] ^ key[2]
+	s[3] = aesEncTable[0][byte(s3)] ^ aesEncTable[1][byte(s0>>8)] ^ aesEncTable[2][byte(s1>>16)] ^ aesEncTable[3][byte(s2>>24)] ^ key[3]
+}
+
+// aesDec applies an AES decryption round (InvShiftRows, InvSubBytes,
+// InvMixColumns and AddRoundKey) to the state.
+func aesDec(s *aesState, key *aesState) {
+	s0, s1, s2, s3 := s[0], s[1], s[2], s[3]
+	s[0] = aesDecTable[0][byte(s0)] ^ aesDecTable[1][byte(s3>>8)] ^ aesDecTable[2][byte(s2>>16)] ^ aesDecTable[3][byte(s1>>24)] ^ key[0]
+	s[1] = aesDecTable[0][byte(s1)] ^ aesDecTable[1][byte(s0>>8)] ^ aesDecTable[2][byte(s3>>16)] ^ aesDecTable[3][byte(s2>>24)] ^ key[1]
+	s[2] = aesDecTable[0][byte(s2)] ^ aesDecTable[1][byte(s1>>8)] ^ aesDecTable[2][byte(s0>>16)] ^ aesDecTable[3][byte(s3>>24)] ^ key[2]
+	s[3] = aesDecTable[0][byte(s3)] ^ aesDecTable[1][byte(s2>>8)] ^ aesDecTable[2][byte(s1>>16)] ^ aesDecTable[3][byte(s0>>24)] ^ key[3]
+}
+
+// Keys and initial states of the AES generators and hash, from the highest to
+// the lowest column as listed in the reference implementation.
+var (
+	aesGen1RKeys = [4]aesState{
+		aesColumns(0xb4f44917, 0xdbb5552b, 0x62716609, 0x6daca553),
+		aesColumns(0x0da1dc4e, 0x1725d378, 0x846a710d, 0x6d7caf07),
+		aesColumns(0x3e20e345, 0xf4c0794f, 0x9f947ec6, 0x3f1262f1),
+		aesColumns(0x49169154, 0x16314c88, 0xb1ba317c, 0x6aef8135),
+	}
+	aesGen4RKeys = [8]aesState{
+		aesColumns(0x99e5d23f, 0x2f546d2b, 0xd1833ddb, 0x6421aadd),
+		aesColumns(0xa5dfcde5, 0x06f79d53, 0xb6913f55, 0xb20e3450),
+		aesColumns(0x171c02bf, 0x0aa4679f, 0x515e7baf, 0x5c3ed904),
+		aesColumns(0xd8ded291, 0xcd673785, 0xe78f5d08, 0x85623763),
+		aesColumns(0x229effb4, 0x3d518b6d, 0xe3d6a7a6, 0xb5826f73),
+		aesColumns(0xb272b7d2, 0xe9024d4e, 0x9c10b3d9, 0xc7566bf3),
+		aesColumns(0xf63befa7, 0x2ba9660a, 0xf765a38b, 0xf273c9e7),
+		aesColumns(0xc0b0762d, 0x0c06d1fd, 0x915839de, 0x7a7cd609),
+	}
+	aesHashStates = [4]aesState{
+		aesColumns(0xd7983aad, 0xcc82db47, 0x9fa856de, 0x92b52c0d),
+		aesColumns(0xace78057, 0xf59e125a, 0x15c7b798, 0x338d996e),
+		aesColumns(0xe8a07ce4, 0x5079506b, 0xae62c7d0, 0x6a770017),
+		aesColumns(0x7e994948, 0x79a10005, 0x07ad828d, 0x630a240c),
+	}
+	aesHashKeys = [2]aesState{
+		aesColumns(0x06890201, 0x90dc56bf, 0x8b24949f, 0xf6fa8389),
+		aesColumns(0xed18f99b, 0xee1043c6, 0x51f4e03c, 0x61b263d1),
+	}
+)
+
+// aesColumns creates a state from its columns, highest first.
+func aesColumns(c3, c2, c1, c0 uint32) aesState {
+	return aesState{c0, c1, c2, c3}
+}
+
+func (s *aesState) load(b []byte) {
+	for i := range s {
+		s[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+}
+
+func (s *aesState) store(b []byte) {
+	for i := range s {
+		binary.LittleEndian.PutUint32(b[4*i:], s[i])
+	}
+}
+
+// fillAes1Rx4 fills out with the AES generator of one round per 16 bytes,
+// advancing the 64 byte state.
+func fillAes1Rx4(state *[64]byte, out []byte) {
+	var s [4]aesState
+	for i := range s {
+		s[i].load(state[16*i:])
+	}
+	for ; len(out) >= 64; out = out[64:] {
+		aesDec(&s[0], &aesGen1RKeys[0])
+		aesEnc(&s[1], &aesGen1RKeys[1])
+		aesDec(&s[2], &aesGen1RKeys[2])
+		aesEnc(&s[3], &aesGen1RKeys[3])
+		for i := range s {
+			s[i].store(out[16*i:])
+		}
+	}
+	for i := range s {
+		s[i].store(state[16*i:])
+	}
+}
+
+// fillAes4Rx4 fills out with the AES generator of four rounds per 16 bytes,
+// seeded with the 64 byte state.
+func fillAes4Rx4(state *[64]byte, out []byte) {
+	var s [4]aesState
+	for i := range s {
+		s[i].load(state[16*i:])
+	}
+	for ; len(out) >= 64; out = out[64:] {
+		for r := 0; r < 4; r++ {
+			aesDec(&s[0], &aesGen4RKeys[r])
+			aesEnc(&s[1], &aesGen4RKeys[r])
+			aesDec(&s[2], &aesGen4RKeys[r+4])
+			aesEnc(&s[3], &aesGen4RKeys[r+4])
+		}
+		for i := range s {
+			s[i].store(out[16*i:])
+		}
+	}
+}
+
+// hashAes1Rx4 computes the 64 byte AES hash of the input, whose length must be
+// a multiple of 64 bytes.
+func hashAes1Rx4(input []byte, out []byte) {
+	s := aesHashStates
+	for ; len(input) >= 64; input = input[64:] {
+		var in [4]aesState
+		for i := range in {
+			in[i].load(input[16*i:])
+		}
+		aesEnc(&s[0], &in[0])
+		aesDec(&s[1], &in[1])
+		aesEnc(&s[2], &in[2])
+		aesDec(&s[3], &in[3])
+	}
+	// Two extra rounds for full diffusion
+	for _, key := range aesHashKeys {
+		aesEnc(&s[0], &key)
+		aesDec(&s[1], &key)
+		aesEnc(&s[2], &key)
+		aesDec(&s[3], &key)
+	}
+	for i := range s {
+		s[i].store(out[16*i:])
+	}
+}
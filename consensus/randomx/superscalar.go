@@ -0,0 +1,623 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package randomx
+
+import (
+	"encoding/binary"
+	"math/bits"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// This file implements the superscalar hash programs RandomX derives the
+// dataset items from. The programs are generated by simulating the scheduling
+// of their macro-ops on a reference x86 CPU, so the generator has to follow the
+// reference implementation step by step, including its quirks.
+
+const (
+	superscalarLatency    = 170 // Target latency of a program in cycles
+	superscalarMaxSize    = 512 // Maximum number of instructions in a program
+	superscalarCycleMap   = superscalarLatency + 4
+	superscalarLookahead  = 4   // Cycles to look forward for available registers
+	superscalarMaxThrowns = 256 // Instructions thrown away before aborting a decode buffer
+
+	// superscalarDisplacementReg is the register that cannot be the destination
+	// of IADD_RS, because the lea encoding it needs a displacement.
+	superscalarDisplacementReg = 5
+)
+
+// blake2Generator is the pseudo random generator the superscalar programs are
+// generated with, rehashing its 64 byte state whenever it is exhausted.
+type blake2Generator struct {
+	data  [blake2b.Size]byte
+	index int
+}
+
+// newBlake2Generator creates a generator seeded with the first 60 bytes of the
+// seed and the nonce.
+func newBlake2Generator(seed []byte, nonce uint32) *blake2Generator {
+	gen := &blake2Generator{index: blake2b.Size}
+	copy(gen.data[:60], seed)
+	binary.LittleEndian.PutUint32(gen.data[60:], nonce)
+	return gen
+}
+
+func (gen *blake2Generator) reserve(n int) {
+	if gen.index+n > len(gen.data) {
+		gen.data = blake2b.Sum512(gen.data[:])
+		gen.index = 0
+	}
+}
+
+func (gen *blake2Generator) getByte() byte {
+	gen.reserve(1)
+	gen.index++
+	return gen.data[gen.index-1]
+}
+
+func (gen *blake2Generator) getUint32() uint32 {
+	gen.reserve(4)
+	gen.index += 4
+	return binary.LittleEndian.Uint32(gen.data[gen.index-4:])
+}
+
+// ssType is the type of a superscalar instruction.
+type ssType int
+
+const (
+	ssISUB_R ssType = iota
+	ssIXOR_R
+	ssIADD_RS
+	ssIMUL_R
+	ssIROR_C
+	ssIADD_C7
+	ssIXOR_C7
+	ssIADD_C8
+	ssIXOR_C8
+	ssIADD_C9
+	ssIXOR_C9
+	ssIMULH_R
+	ssISMULH_R
+	ssIMUL_RCP
+	ssInvalid ssType = -1
+)
+
+// Execution ports of the reference CPU a micro-op can be scheduled on.
+const (
+	portNull = 0
+	portP0   = 1
+	portP1   = 2
+	portP5   = 4
+	portP01  = portP0 | portP1
+	portP05  = portP0 | portP5
+	portP015 = portP0 | portP1 | portP5
+)
+
+// ssMacroOp is an x86 macro-op of a superscalar instruction.
+type ssMacroOp struct {
+	latency   int
+	uop1      int
+	uop2      int
+	dependent bool // Depends on the previous macro-op of the instruction
+}
+
+var (
+	mopAddRR   = ssMacroOp{latency: 1, uop1: portP015}
+	mopAddRI   = ssMacroOp{latency: 1, uop1: portP015}
+	mopLeaSIB  = ssMacroOp{latency: 1, uop1: portP01}
+	mopSubRR   = ssMacroOp{latency: 1, uop1: portP015}
+	mopXorRR   = ssMacroOp{latency: 1, uop1: portP015}
+	mopXorRI   = ssMacroOp{latency: 1, uop1: portP015}
+	mopMulR    = ssMacroOp{latency: 4, uop1: portP1, uop2: portP5}
+	mopImulR   = ssMacroOp{latency: 4, uop1: portP1, uop2: portP5}
+	mopImulRR  = ssMacroOp{latency: 3, uop1: portP1}
+	mopRorRI   = ssMacroOp{latency: 1, uop1: portP05}
+	mopMovRR   = ssMacroOp{}
+	mopMovRI64 = ssMacroOp{latency: 1, uop1: portP015}
+)
+
+// ssInfo describes a superscalar instruction type: its macro-ops and which of
+// them reads the source, reads the destination and writes the result.
+type ssInfo struct {
+	typ      ssType
+	ops      []ssMacroOp
+	resultOp int
+	dstOp    int
+	srcOp    int
+}
+
+var (
+	ssInfoNOP      = &ssInfo{typ: ssInvalid}
+	ssInfoISUB_R   = &ssInfo{typ: ssISUB_R, ops: []ssMacroOp{mopSubRR}}
+	ssInfoIXOR_R   = &ssInfo{typ: ssIXOR_R, ops: []ssMacroOp{mopXorRR}}
+	ssInfoIADD_RS  = &ssInfo{typ: ssIADD_RS, ops: []ssMacroOp{mopLeaSIB}}
+	ssInfoIMUL_R   = &ssInfo{typ: ssIMUL_R, ops: []ssMacroOp{mopImulRR}}
+	ssInfoIROR_C   = &ssInfo{typ: ssIROR_C, ops: []ssMacroOp{mopRorRI}, srcOp: -1}
+	ssInfoIADD_C7  = &ssInfo{typ: ssIADD_C7, ops: []ssMacroOp{mopAddRI}, srcOp: -1}
+	ssInfoIXOR_C7  = &ssInfo{typ: ssIXOR_C7, ops: []ssMacroOp{mopXorRI}, srcOp: -1}
+	ssInfoIADD_C8  = &ssInfo{typ: ssIADD_C8, ops: []ssMacroOp{mopAddRI}, srcOp: -1}
+	ssInfoIXOR_C8  = &ssInfo{typ: ssIXOR_C8, ops: []ssMacroOp{mopXorRI}, srcOp: -1}
+	ssInfoIADD_C9  = &ssInfo{typ: ssIADD_C9, ops: []ssMacroOp{mopAddRI}, srcOp: -1}
+	ssInfoIXOR_C9  = &ssInfo{typ: ssIXOR_C9, ops: []ssMacroOp{mopXorRI}, srcOp: -1}
+	ssInfoIMULH_R  = &ssInfo{typ: ssIMULH_R, ops: []ssMacroOp{mopMovRR, mopMulR, mopMovRR}, resultOp: 1, dstOp: 0, srcOp: 1}
+	ssInfoISMULH_R = &ssInfo{typ: ssISMULH_R, ops: []ssMacroOp{mopMovRR, mopImulR, mopMovRR}, resultOp: 1, dstOp: 0, srcOp: 1}
+	ssInfoIMUL_RCP = &ssInfo{typ: ssIMUL_RCP, ops: []ssMacroOp{mopMovRI64, {latency: 3, uop1: portP1, dependent: true}}, resultOp: 1, dstOp: 1, srcOp: -1}
+
+	ssSlot3  = []*ssInfo{ssInfoISUB_R, ssInfoIXOR_R}
+	ssSlot3L = []*ssInfo{ssInfoISUB_R, ssInfoIXOR_R, ssInfoIMULH_R, ssInfoISMULH_R}
+	ssSlot4  = []*ssInfo{ssInfoIROR_C, ssInfoIADD_RS}
+	ssSlot7  = []*ssInfo{ssInfoIXOR_C7, ssInfoIADD_C7}
+	ssSlot8  = []*ssInfo{ssInfoIXOR_C8, ssInfoIADD_C8}
+	ssSlot9  = []*ssInfo{ssInfoIXOR_C9, ssInfoIADD_C9}
+)
+
+// ssDecodeBuffer is a configuration of the 16 byte decoder of the reference
+// CPU, listing the sizes of the instruction slots.
+type ssDecodeBuffer struct {
+	index int
+	slots []int
+}
+
+var (
+	ssBuffer484  = &ssDecodeBuffer{0, []int{4, 8, 4}}
+	ssBuffer7333 = &ssDecodeBuffer{1, []int{7, 3, 3, 3}}
+	ssBuffer3733 = &ssDecodeBuffer{2, []int{3, 7, 3, 3}}
+	ssBuffer493  = &ssDecodeBuffer{3, []int{4, 9, 3}}
+	ssBuffer4444 = &ssDecodeBuffer{4, []int{4, 4, 4, 4}}
+	ssBuffer3310 = &ssDecodeBuffer{5, []int{3, 3, 10}}
+
+	ssRandomBuffers = []*ssDecodeBuffer{ssBuffer484, ssBuffer7333, ssBuffer3733, ssBuffer493}
+)
+
+// nextDecodeBuffer selects the decoder configuration of the next cycle.
+func nextDecodeBuffer(typ ssType, cycle, mulCount int, gen *blake2Generator) *ssDecodeBuffer {
+	// The 128-bit multiplications decode to two micro-ops, they must be
+	// followed by a 3-3-10 configuration
+	if typ == ssIMULH_R || typ == ssISMULH_R {
+		return ssBuffer3310
+	}
+	// Saturate the multiplication port if there are fewer multiplications
+	// than cycles
+	if mulCount < cycle+1 {
+		return ssBuffer4444
+	}
+	// IMUL_RCP must be followed by a 4 byte slot for its multiplication
+	if typ == ssIMUL_RCP {
+		if gen.getByte()&1 != 0 {
+			return ssBuffer484
+		}
+		return ssBuffer493
+	}
+	return ssRandomBuffers[gen.getByte()&3]
+}
+
+// ssRegister tracks when a register is ready and the last operation applied
+// to it during program generation.
+type ssRegister struct {
+	latency     int
+	lastOpGroup ssType
+	lastOpPar   int32
+}
+
+// ssInstruction is a superscalar instruction being generated.
+type ssInstruction struct {
+	info             *ssInfo
+	src, dst         int
+	mod              byte
+	imm32            uint32
+	opGroup          ssType
+	opGroupPar       int32
+	canReuse         bool
+	groupParIsSource bool
+}
+
+// create initialises the instruction as the given type with random operands.
+func (ins *ssInstruction) create(info *ssInfo, gen *blake2Generator) {
+	*ins = ssInstruction{info: info, src: -1, dst: -1}
+
+	switch info.typ {
+	case ssISUB_R:
+		ins.opGroup, ins.groupParIsSource = ssIADD_RS, true
+	case ssIXOR_R:
+		ins.opGroup, ins.groupParIsSource = ssIXOR_R, true
+	case ssIADD_RS:
+		ins.mod = gen.getByte()
+		ins.opGroup, ins.groupParIsSource = ssIADD_RS, true
+	case ssIMUL_R:
+		ins.opGroup, ins.groupParIsSource = ssIMUL_R, true
+	case ssIROR_C:
+		for ins.imm32 == 0 {
+			ins.imm32 = uint32(gen.getByte() & 63)
+		}
+		ins.opGroup, ins.opGroupPar = ssIROR_C, -1
+	case ssIADD_C7, ssIADD_C8, ssIADD_C9:
+		ins.imm32 = gen.getUint32()
+		ins.opGroup, ins.opGroupPar = ssIADD_C7, -1
+	case ssIXOR_C7, ssIXOR_C8, ssIXOR_C9:
+		ins.imm32 = gen.getUint32()
+		ins.opGroup, ins.opGroupPar = ssIXOR_C7, -1
+	case ssIMULH_R, ssISMULH_R:
+		ins.canReuse = true
+		ins.opGroup, ins.opGroupPar = info.typ, int32(gen.getUint32())
+	case ssIMUL_RCP:
+		for {
+			ins.imm32 = gen.getUint32()
+			if ins.imm32&(ins.imm32-1) != 0 {
+				break
+			}
+		}
+		ins.opGroup, ins.opGroupPar = ssIMUL_RCP, -1
+	}
+}
+
+// createForSlot initialises the instruction with a random type fitting into the
+// given decoder slot.
+func (ins *ssInstruction) createForSlot(gen *blake2Generator, slot int, buffer int, last bool) {
+	switch slot {
+	case 3:
+		// The last slot may also hold a 128-bit multiplication
+		if last {
+			ins.create(ssSlot3L[gen.getByte()&3], gen)
+		} else {
+			ins.create(ssSlot3[gen.getByte()&1], gen)
+		}
+	case 4:
+		// The 4-4-4-4 configuration issues multiplications in its first slots
+		if buffer == ssBuffer4444.index && !last {
+			ins.create(ssInfoIMUL_R, gen)
+		} else {
+			ins.create(ssSlot4[gen.getByte()&1], gen)
+		}
+	case 7:
+		ins.create(ssSlot7[gen.getByte()&1], gen)
+	case 8:
+		ins.create(ssSlot8[gen.getByte()&1], gen)
+	case 9:
+		ins.create(ssSlot9[gen.getByte()&1], gen)
+	case 10:
+		ins.create(ssInfoIMUL_RCP, gen)
+	}
+}
+
+// selectRegister picks a random register among the available ones.
+func selectRegister(available []int, gen *blake2Generator) (int, bool) {
+	switch len(available) {
+	case 0:
+		return 0, false
+	case 1:
+		return available[0], true
+	default:
+		return available[gen.getUint32()%uint32(len(available))], true
+	}
+}
+
+// selectSource picks a source register ready at the given cycle.
+func (ins *ssInstruction) selectSource(cycle int, registers *[8]ssRegister, gen *blake2Generator) bool {
+	var available []int
+	for i := range registers {
+		if registers[i].latency <= cycle {
+			available = append(available, i)
+		}
+	}
+	// IADD_RS with r5 available as one of two registers has to read from it,
+	// because r5 cannot be its destination
+	if len(available) == 2 && ins.info.typ == ssIADD_RS {
+		if available[0] == superscalarDisplacementReg || available[1] == superscalarDisplacementReg {
+			ins.src, ins.opGroupPar = superscalarDisplacementReg, superscalarDisplacementReg
+			return true
+		}
+	}
+	src, ok := selectRegister(available, gen)
+	if !ok {
+		return false
+	}
+	ins.src = src
+	if ins.groupParIsSource {
+		ins.opGroupPar = int32(src)
+	}
+	return true
+}
+
+// selectDestination picks a destination register ready at the given cycle,
+// avoiding sequences that could be optimised away.
+func (ins *ssInstruction) selectDestination(cycle int, allowChainedMul bool, registers *[8]ssRegister, gen *blake2Generator) bool {
+	var available []int
+	for i := range registers {
+		reg := &registers[i]
+		if reg.latency <= cycle &&
+			(ins.canReuse || i != ins.src) &&
+			(allowChainedMul || ins.opGroup != ssIMUL_R || reg.lastOpGroup != ssIMUL_R) &&
+			(reg.lastOpGroup != ins.opGroup || reg.lastOpPar != ins.opGroupPar) &&
+			(ins.info.typ != ssIADD_RS || i != superscalarDisplacementReg) {
+			available = append(available, i)
+		}
+	}
+	dst, ok := selectRegister(available, gen)
+	if ok {
+		ins.dst = dst
+	}
+	return ok
+}
+
+// scheduleUop finds the first cycle from the given one where a port of the
+// micro-op is free, checking P5, P0 and P1 in this order.
+func scheduleUop(uop int, ports *[superscalarCycleMap][3]int, cycle int, commit bool) int {
+	for ; cycle < superscalarCycleMap; cycle++ {
+		switch {
+		case uop&portP5 != 0 && ports[cycle][2] == 0:
+			if commit {
+				ports[cycle][2] = uop
+			}
+			return cycle
+		case uop&portP0 != 0 && ports[cycle][0] == 0:
+			if commit {
+				ports[cycle][0] = uop
+			}
+			return cycle
+		case uop&portP1 != 0 && ports[cycle][1] == 0:
+			if commit {
+				ports[cycle][1] = uop
+			}
+			return cycle
+		}
+	}
+	return -1
+}
+
+// scheduleMop finds the first cycle a macro-op can execute at, or -1 if the
+// ports are saturated. Macro-ops of two micro-ops need both in the same cycle.
+func scheduleMop(mop ssMacroOp, ports *[superscalarCycleMap][3]int, cycle, depCycle int, commit bool) int {
+	if mop.dependent && depCycle > cycle {
+		cycle = depCycle
+	}
+	switch {
+	case mop.uop1 == portNull:
+		return cycle // eliminated move
+	case mop.uop2 == portNull:
+		return scheduleUop(mop.uop1, ports, cycle, commit)
+	}
+	for ; cycle < superscalarCycleMap; cycle++ {
+		cycle1 := scheduleUop(mop.uop1, ports, cycle, false)
+		cycle2 := scheduleUop(mop.uop2, ports, cycle, false)
+		if cycle1 >= 0 && cycle1 == cycle2 {
+			if commit {
+				scheduleUop(mop.uop1, ports, cycle1, true)
+				scheduleUop(mop.uop2, ports, cycle2, true)
+			}
+			return cycle1
+		}
+	}
+	return -1
+}
+
+// ssOp is an instruction of a generated superscalar program.
+type ssOp struct {
+	typ      ssType
+	dst, src int
+	mod      byte
+	imm32    uint32
+}
+
+// superscalarProgram is a generated superscalar hash program.
+type superscalarProgram struct {
+	ops         []ssOp
+	addressReg  int      // Register selecting the next cache line
+	reciprocals []uint64 // Reciprocals of the IMUL_RCP instructions, in order
+}
+
+// generateSuperscalar generates a superscalar program from the generator.
+func generateSuperscalar(gen *blake2Generator) *superscalarProgram {
+	var (
+		ports     [superscalarCycleMap][3]int
+		registers [8]ssRegister
+		prog      = new(superscalarProgram)
+
+		buffer         *ssDecodeBuffer
+		ins            = ssInstruction{info: ssInfoNOP}
+		macroOpIndex   int
+		cycle          int
+		depCycle       int
+		portsSaturated bool
+		mulCount       int
+		throwAwayCount int
+	)
+	for i := range registers {
+		registers[i].lastOpGroup, registers[i].lastOpPar = ssInvalid, -1
+	}
+	for decodeCycle := 0; decodeCycle < superscalarLatency && !portsSaturated && len(prog.ops) < superscalarMaxSize; decodeCycle++ {
+		buffer = nextDecodeBuffer(ins.info.typ, decodeCycle, mulCount, gen)
+
+		// Fill all instruction slots of the decode buffer
+		for slot := 0; slot < len(buffer.slots); {
+			topCycle := cycle
+
+			// Create a new instruction once all macro-ops of the current one
+			// are issued, with its first macro-op fitting into the slot
+			if macroOpIndex >= len(ins.info.ops) {
+				if portsSaturated || len(prog.ops) >= superscalarMaxSize {
+					break
+				}
+				ins.createForSlot(gen, buffer.slots[slot], buffer.index, slot == len(buffer.slots)-1)
+				macroOpIndex = 0
+			}
+			mop := ins.info.ops[macroOpIndex]
+
+			// Find the earliest cycle the macro-op can be scheduled at
+			scheduleCycle := scheduleMop(mop, &ports, cycle, depCycle, false)
+			if scheduleCycle < 0 {
+				portsSaturated = true
+				break
+			}
+			// Find the operands ready when the instruction executes, looking a
+			// few cycles forward. Instructions without any are thrown away.
+			if macroOpIndex == ins.info.srcOp {
+				forward := 0
+				for ; forward < superscalarLookahead && !ins.selectSource(scheduleCycle, &registers, gen); forward++ {
+					scheduleCycle++
+					cycle++
+				}
+				if forward == superscalarLookahead {
+					if throwAwayCount < superscalarMaxThrowns {
+						throwAwayCount++
+						macroOpIndex = len(ins.info.ops)
+						continue
+					}
+					ins = ssInstruction{info: ssInfoNOP}
+					break
+				}
+			}
+			if macroOpIndex == ins.info.dstOp {
+				forward := 0
+				for ; forward < superscalarLookahead && !ins.selectDestination(scheduleCycle, throwAwayCount > 0, &registers, gen); forward++ {
+					scheduleCycle++
+					cycle++
+				}
+				if forward == superscalarLookahead {
+					if throwAwayCount < superscalarMaxThrowns {
+						throwAwayCount++
+						macroOpIndex = len(ins.info.ops)
+						continue
+					}
+					ins = ssInstruction{info: ssInfoNOP}
+					break
+				}
+			}
+			throwAwayCount = 0
+
+			// Schedule the macro-op now that the operands are known
+			scheduleCycle = scheduleMop(mop, &ports, scheduleCycle, scheduleCycle, true)
+			if scheduleCycle < 0 {
+				portsSaturated = true
+				break
+			}
+			depCycle = scheduleCycle + mop.latency
+
+			if macroOpIndex == ins.info.resultOp {
+				reg := &registers[ins.dst]
+				reg.latency = depCycle
+				reg.lastOpGroup = ins.opGroup
+				reg.lastOpPar = ins.opGroupPar
+			}
+			slot++
+			macroOpIndex++
+
+			if scheduleCycle >= superscalarLatency {
+				portsSaturated = true
+			}
+			cycle = topCycle
+
+			// Add the instruction to the program once fully issued
+			if macroOpIndex >= len(ins.info.ops) {
+				op := ssOp{typ: ins.info.typ, dst: ins.dst, src: ins.src, mod: ins.mod, imm32: ins.imm32}
+				if op.src < 0 {
+					op.src = op.dst
+				}
+				if op.typ == ssIMUL_RCP {
+					op.imm32 = uint32(len(prog.reciprocals))
+					prog.reciprocals = append(prog.reciprocals, reciprocal(uint64(ins.imm32)))
+				}
+				prog.ops = append(prog.ops, op)
+
+				switch op.typ {
+				case ssIMUL_R, ssIMULH_R, ssISMULH_R, ssIMUL_RCP:
+					mulCount++
+				}
+			}
+		}
+		cycle++
+	}
+	// The address register is the one with the longest dependency chain,
+	// assuming single cycle operations with unlimited parallelism
+	var latencies [8]int
+	for _, op := range prog.ops {
+		latDst := latencies[op.dst] + 1
+		latSrc := 0
+		if op.dst != op.src {
+			latSrc = latencies[op.src] + 1
+		}
+		latencies[op.dst] = max(latDst, latSrc)
+	}
+	for i, latency := range latencies {
+		if latency > latencies[prog.addressReg] {
+			prog.addressReg = i
+		}
+	}
+	return prog
+}
+
+// execute runs the program on the given registers.
+func (prog *superscalarProgram) execute(r *[8]uint64) {
+	for _, op := range prog.ops {
+		switch op.typ {
+		case ssISUB_R:
+			r[op.dst] -= r[op.src]
+		case ssIXOR_R:
+			r[op.dst] ^= r[op.src]
+		case ssIADD_RS:
+			r[op.dst] += r[op.src] << ((op.mod >> 2) % 4)
+		case ssIMUL_R:
+			r[op.dst] *= r[op.src]
+		case ssIROR_C:
+			r[op.dst] = bits.RotateLeft64(r[op.dst], -int(op.imm32))
+		case ssIADD_C7, ssIADD_C8, ssIADD_C9:
+			r[op.dst] += signExtend(op.imm32)
+		case ssIXOR_C7, ssIXOR_C8, ssIXOR_C9:
+			r[op.dst] ^= signExtend(op.imm32)
+		case ssIMULH_R:
+			r[op.dst], _ = bits.Mul64(r[op.dst], r[op.src])
+		case ssISMULH_R:
+			r[op.dst] = smulh(r[op.dst], r[op.src])
+		case ssIMUL_RCP:
+			r[op.dst] *= prog.reciprocals[op.imm32]
+		}
+	}
+}
+
+// reciprocal returns 2^x / divisor for the largest x keeping the result in 64
+// bits, the fixed point reciprocal used by IMUL_RCP.
+func reciprocal(divisor uint64) uint64 {
+	const p2exp63 = uint64(1) << 63
+
+	quotient, remainder := p2exp63/divisor, p2exp63%divisor
+	for shift := bits.Len64(divisor); shift > 0; shift-- {
+		if remainder >= divisor-remainder {
+			quotient = quotient*2 + 1
+			remainder = remainder*2 - divisor
+		} else {
+			quotient = quotient * 2
+			remainder = remainder * 2
+		}
+	}
+	return quotient
+}
+
+// signExtend sign extends a 32-bit immediate to 64 bits.
+func signExtend(imm uint32) uint64 {
+	return uint64(int64(int32(imm)))
+}
+
+// smulh returns the high 64 bits of the signed 128-bit product of a and b.
+func smulh(a, b uint64) uint64 {
+	hi, _ := bits.Mul64(a, b)
+	if int64(a) < 0 {
+		hi -= b
+	}
+	if int64(b) < 0 {
+		hi -= a
+	}
+	return hi
+}
@@ -11,7 +11,7 @@ import (
 )
 
 func TestVerifySealFake(t *testing.T) {
-	engine := NewFaker()
+	engine := NewFullFaker()
 	defer engine.Close()
 
 	header := &types.Header{
@@ -26,7 +26,8 @@ func TestVerifySealFake(t *testing.T) {
 		MixDigest:  common.Hash{},
 	}
 
-	if err := engine.verifyPoW(header); err != nil {
+	_, results := engine.VerifyHeaders(nil, []*types.Header{header})
+	if err := <-results; err != nil {
 		t.Errorf("Fake engine should accept any header, got error: %v", err)
 	}
 }
@@ -85,7 +86,7 @@ func TestVerifyRandomX(t *testing.T) {
 	}{
 		{
 			name:       "Hash below target (valid)",
-			hash:       common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000001"),
+			hash:       common.HexToHash("0x0100000000000000000000000000000000000000000000000000000000000000"), // little-endian 1
 			difficulty: big.NewInt(1000000),
 			shouldPass: true,
 		},
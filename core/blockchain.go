@@ -63,6 +63,8 @@ var (
 	headFastBlockGauge      = metrics.NewRegisteredGauge("chain/head/receipt", nil)
 	headFinalizedBlockGauge = metrics.NewRegisteredGauge("chain/head/finalized", nil)
 	headSafeBlockGauge      = metrics.NewRegisteredGauge("chain/head/safe", nil)
+	headDifficultyGauge     = metrics.NewRegisteredGauge("chain/head/difficulty", nil)
+	headBaseFeeGauge        = metrics.NewRegisteredGauge("chain/head/basefee", nil)
 
 	chainInfoGauge   = metrics.NewRegisteredGaugeInfo("chain/info", nil)
 	chainMgaspsMeter = metrics.NewRegisteredResettingTimer("chain/mgasps", nil)
@@ -416,6 +418,11 @@ func NewBlockChain(db ethdb.Database, genesis *Genesis, engine consensus.Engine,
 	if bc.empty() {
 		rawdb.InitDatabaseFromFreezer(bc.db)
 	}
+	// Make sure total difficulties are available for the fork choice on
+	// proof-of-work chains.
+	if err := bc.initTd(); err != nil {
+		return nil, err
+	}
 	// Load blockchain states from disk
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
@@ -622,7 +629,7 @@ func (bc *BlockChain) loadLastState() error {
 	}
 	// Everything seems to be fine, set as the head block
 	bc.currentBlock.Store(headHeader)
-	headBlockGauge.Update(int64(headBlock.NumberU64()))
+	updateHeadBlockGauges(headHeader)
 
 	// Restore the last known head header
 	if head := rawdb.ReadHeadHeaderHash(bc.db); head != (common.Hash{}) {
@@ -1007,7 +1014,7 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, time uint64, root common.Ha
 			// last step, however the direction of SetHead is from high
 			// to low, so it's safe to update in-memory markers directly.
 			bc.currentBlock.Store(newHeadBlock)
-			headBlockGauge.Update(int64(newHeadBlock.Number.Uint64()))
+			updateHeadBlockGauges(newHeadBlock)
 
 			// The head state is missing, which is only possible in the path-based
 			// scheme. This situation occurs when the chain head is rewound below
@@ -1130,7 +1137,7 @@ func (bc *BlockChain) SnapSyncCommitHead(hash common.Hash) error {
 		return errChainStopped
 	}
 	bc.currentBlock.Store(block.Header())
-	headBlockGauge.Update(int64(block.NumberU64()))
+	updateHeadBlockGauges(block.Header())
 	bc.chainmu.Unlock()
 
 	// Destroy any existing state snapshot and regenerate it in the background,
@@ -1162,6 +1169,9 @@ func (bc *BlockChain) ResetWithGenesisBlock(genesis *types.Block) error {
 	// Prepare the genesis block and reinitialise the chain
 	batch := bc.db.NewBatch()
 	rawdb.WriteBlock(batch, genesis)
+	if bc.hc.tracksTd() {
+		rawdb.WriteTd(batch, genesis.Hash(), genesis.NumberU64(), genesis.Difficulty())
+	}
 	if err := batch.Write(); err != nil {
 		log.Crit("Failed to write genesis block", "err", err)
 	}
@@ -1170,7 +1180,7 @@ func (bc *BlockChain) ResetWithGenesisBlock(genesis *types.Block) error {
 	// Last update all in-memory chain markers
 	bc.genesisBlock = genesis
 	bc.currentBlock.Store(bc.genesisBlock.Header())
-	headBlockGauge.Update(int64(bc.genesisBlock.NumberU64()))
+	updateHeadBlockGauges(bc.genesisBlock.Header())
 	bc.hc.SetGenesis(bc.genesisBlock.Header())
 	bc.hc.SetCurrentHeader(bc.genesisBlock.Header())
 	bc.currentSnapBlock.Store(bc.genesisBlock.Header())
@@ -1243,7 +1253,18 @@ func (bc *BlockChain) writeHeadBlock(block *types.Block) {
 	headFastBlockGauge.Update(int64(block.NumberU64()))
 
 	bc.currentBlock.Store(block.Header())
-	headBlockGauge.Update(int64(block.NumberU64()))
+	updateHeadBlockGauges(block.Header())
+}
+
+// updateHeadBlockGauges reports a new head block in the metrics.
+func updateHeadBlockGauges(header *types.Header) {
+	headBlockGauge.Update(int64(header.Number.Uint64()))
+	if header.Difficulty != nil {
+		headDifficultyGauge.Update(header.Difficulty.Int64())
+	}
+	if header.BaseFee != nil {
+		headBaseFeeGauge.Update(header.BaseFee.Int64())
+	}
 }
 
 // stopWithoutSaving stops the blockchain service. If any imports are currently in progress
@@ -1566,6 +1587,9 @@ func (bc *BlockChain) writeBlockWithoutState(block *types.Block) (err error) {
 		return errInsertionInterrupted
 	}
 	batch := bc.db.NewBatch()
+	if err := bc.writeTd(batch, block.Header()); err != nil {
+		return err
+	}
 	rawdb.WriteBlock(batch, block)
 	if err := batch.Write(); err != nil {
 		log.Crit("Failed to write block into disk", "err", err)
@@ -1577,6 +1601,9 @@ func (bc *BlockChain) writeBlockWithoutState(block *types.Block) (err error) {
 // and introduces chain reorg if necessary.
 func (bc *BlockChain) writeKnownBlock(block *types.Block) error {
 	current := bc.CurrentBlock()
+	if reorg, err := bc.reorgNeeded(current, block.Header()); err != nil || !reorg {
+		return err
+	}
 	if block.ParentHash() != current.Hash() {
 		if err := bc.reorg(current, block.Header()); err != nil {
 			return err
@@ -1597,6 +1624,9 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	// Note all the components of block(hash->number map, header, body, receipts)
 	// should be written atomically. BlockBatch is used for containing all components.
 	blockBatch := bc.db.NewBatch()
+	if err := bc.writeTd(blockBatch, block.Header()); err != nil {
+		return err
+	}
 	rawdb.WriteBlock(blockBatch, block)
 	rawdb.WriteReceipts(blockBatch, block.Hash(), block.NumberU64(), receipts)
 	rawdb.WritePreimages(blockBatch, statedb.Preimages())
@@ -1680,6 +1710,15 @@ func (bc *BlockChain) writeBlockAndSetHead(block *types.Block, receipts []*types
 	}
 	currentBlock := bc.CurrentBlock()
 
+	// On proof-of-work chains, keep the block as a side fork unless it makes
+	// the chain heavier than the current one.
+	reorg, err := bc.reorgNeeded(currentBlock, block.Header())
+	if err != nil {
+		return NonStatTy, err
+	}
+	if !reorg {
+		return SideStatTy, nil
+	}
 	// Reorganise the chain if the parent is not the head block
 	if block.ParentHash() != currentBlock.Hash() {
 		if err := bc.reorg(currentBlock, block.Header()); err != nil {
@@ -2236,6 +2275,23 @@ func (bc *BlockChain) insertSideChain(block *types.Block, it *insertIterator, ma
 				"root", block.Root())
 		}
 	}
+	// At this point, we've written all sidechain blocks to database. Loop ended
+	// either on some other error or all were processed. If there was some other
+	// error, we can ignore the rest of those blocks.
+	//
+	// If the sidechain is heavier than our local chain, we now need to reimport
+	// the previous blocks to regenerate the required state.
+	if last := it.previous(); last != nil && bc.hc.tracksTd() {
+		reorg, err := bc.reorgNeeded(current, last)
+		if err != nil {
+			return nil, it.index, err
+		}
+		if !reorg {
+			log.Info("Sidechain written to disk", "start", it.first().NumberU64(), "end", last.Number,
+				"sidetd", bc.GetTd(last.Hash(), last.Number.Uint64()), "localtd", bc.GetTd(current.Hash(), current.Number.Uint64()))
+			return nil, it.index, err
+		}
+	}
 	// Gather all the sidechain hashes (full blocks may be memory heavy)
 	var (
 		hashes  []common.Hash
@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/beacon"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/randomx"
 	"github.com/ethereum/go-ethereum/core/history"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
@@ -4097,6 +4098,95 @@ func TestPragueRequests(t *testing.T) {
 	}
 }
 
+// TestRandomXPostMergeForks checks that proof-of-work RandomX chains run the
+// Shanghai, Cancun and Prague execution rules without the beacon chain and blob
+// header fields of these forks.
+func TestRandomXPostMergeForks(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		aa      = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		config  = *params.AllEthashProtocolChanges
+		engine  = randomx.New(&randomx.Config{PowMode: randomx.ModeFake})
+		signer  = types.LatestSigner(&config)
+		genesis *Genesis
+	)
+	defer engine.Close()
+
+	config.TerminalTotalDifficulty = nil
+	config.Ethash = nil
+	config.RandomX = &params.RandomXConfig{DisableBlobs: true}
+	config.ShanghaiTime = u64(0)
+	config.CancunTime = u64(0)
+	config.PragueTime = u64(0)
+	config.BlobScheduleConfig = params.DefaultBlobSchedule
+
+	code := []byte{
+		// sstore(0, tload(0)) after tstore(0, 42)
+		byte(vm.PUSH1), 0x2a, byte(vm.PUSH0), byte(vm.TSTORE),
+		byte(vm.PUSH0), byte(vm.TLOAD), byte(vm.PUSH0), byte(vm.SSTORE),
+		// sstore(1, difficulty)
+		byte(vm.PREVRANDAO), byte(vm.PUSH1), 0x1, byte(vm.SSTORE),
+		// sstore(2, blobbasefee)
+		byte(vm.BLOBBASEFEE), byte(vm.PUSH1), 0x2, byte(vm.SSTORE),
+		// sstore(3, mload(32)) after mstore(0, 42) and mcopy(32, 0, 32)
+		byte(vm.PUSH1), 0x2a, byte(vm.PUSH0), byte(vm.MSTORE),
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH0), byte(vm.PUSH1), 0x20, byte(vm.MCOPY),
+		byte(vm.PUSH1), 0x20, byte(vm.MLOAD), byte(vm.PUSH1), 0x3, byte(vm.SSTORE),
+		byte(vm.STOP),
+	}
+	genesis = &Genesis{
+		Config: &config,
+		Alloc: types.GenesisAlloc{
+			addr: {Balance: big.NewInt(params.Ether)},
+			aa:   {Code: code},
+		},
+	}
+	_, blocks, _ := GenerateChainWithGenesis(genesis, engine, 4, func(i int, b *BlockGen) {
+		if i != 0 {
+			return
+		}
+		b.AddTx(types.MustSignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   config.ChainID,
+			Nonce:     0,
+			To:        &aa,
+			Gas:       500_000,
+			GasFeeCap: newGwei(5),
+			GasTipCap: big.NewInt(2),
+		}))
+	})
+	for _, block := range blocks {
+		header := block.Header()
+		if header.WithdrawalsHash != nil || header.BlobGasUsed != nil || header.ExcessBlobGas != nil || header.ParentBeaconRoot != nil || header.RequestsHash != nil {
+			t.Fatalf("block %d: post-merge header fields set: %+v", block.Number(), header)
+		}
+		if engine.SealHash(header) == header.Hash() {
+			t.Fatalf("block %d: seal hash covers the seal", block.Number())
+		}
+	}
+	header := blocks[0].Header()
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), genesis, engine, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	if receipts := chain.GetReceiptsByHash(blocks[0].Hash()); receipts[0].Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("transaction failed")
+	}
+	state, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+	for slot, want := range []*big.Int{big.NewInt(42), header.Difficulty, big.NewInt(params.BlobTxMinBlobGasprice), big.NewInt(42)} {
+		if have := state.GetState(aa, common.BigToHash(big.NewInt(int64(slot)))).Big(); have.Cmp(want) != 0 {
+			t.Errorf("slot %d: have %v, want %v", slot, have, want)
+		}
+	}
+}
+
 // TestEIP7702 deploys two delegation designations and calls them. It writes one
 // value to storage which is verified after.
 func TestEIP7702(t *testing.T) {
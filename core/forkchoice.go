@@ -0,0 +1,141 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// errMissingTd is returned if the total difficulty of a block that the fork
+// choice rule depends on is not available in the database.
+var errMissingTd = errors.New("missing total difficulty")
+
+// reorgNeeded returns whether the chain head should be switched from current to
+// extern. On chains without proof-of-work fork choice (i.e. anything but RandomX)
+// the external header always wins, which is the post-merge behaviour where the
+// head is driven by the caller.
+//
+// On RandomX chains the heaviest chain rule is applied: the new head is adopted
+// only if its total difficulty is strictly higher than the local one. If both
+// branches carry the same amount of work, the lower block wins (reducing the
+// incentive for selfish mining) and among blocks of equal height the one with
+// the numerically smaller hash wins, so that every node resolves the tie in the
+// same way irrespective of arrival order.
+func (bc *BlockChain) reorgNeeded(current *types.Header, extern *types.Header) (bool, error) {
+	if !bc.hc.tracksTd() {
+		return true, nil
+	}
+	localTd := bc.GetTd(current.Hash(), current.Number.Uint64())
+	if localTd == nil {
+		return false, errMissingTd
+	}
+	externTd := bc.GetTd(extern.Hash(), extern.Number.Uint64())
+	if externTd == nil {
+		return false, errMissingTd
+	}
+	return heavierChain(current, localTd, extern, externTd), nil
+}
+
+// heavierChain implements the proof-of-work fork choice rule, reporting whether
+// the chain ending in extern is preferred over the one ending in current.
+func heavierChain(current *types.Header, localTd *big.Int, extern *types.Header, externTd *big.Int) bool {
+	if diff := externTd.Cmp(localTd); diff != 0 {
+		return diff > 0
+	}
+	if diff := extern.Number.Cmp(current.Number); diff != 0 {
+		return diff < 0
+	}
+	externHash, currentHash := extern.Hash(), current.Hash()
+	return bytes.Compare(externHash[:], currentHash[:]) < 0
+}
+
+// writeTd computes and stores the total difficulty of the given block into the
+// batch, if the chain tracks it. The parent's total difficulty must already be
+// known.
+func (bc *BlockChain) writeTd(db ethdb.KeyValueWriter, header *types.Header) error {
+	if !bc.hc.tracksTd() {
+		return nil
+	}
+	ptd := bc.GetTd(header.ParentHash, header.Number.Uint64()-1)
+	if ptd == nil {
+		return errMissingTd
+	}
+	rawdb.WriteTd(db, header.Hash(), header.Number.Uint64(), new(big.Int).Add(ptd, header.Difficulty))
+	return nil
+}
+
+// initTd makes sure the total difficulty of every canonical header is present
+// in the database on chains that track it. Databases created by releases that
+// did not maintain total difficulty are backfilled once, walking the canonical
+// header chain from genesis to its head.
+func (bc *BlockChain) initTd() error {
+	if !bc.hc.tracksTd() {
+		return nil
+	}
+	genesis := bc.genesisBlock.Header()
+	if rawdb.ReadTd(bc.db, genesis.Hash(), 0) != nil {
+		return nil
+	}
+	var (
+		head   uint64
+		start  = time.Now()
+		logged = time.Now()
+		td     = new(big.Int).Set(genesis.Difficulty)
+		batch  = bc.db.NewBatch()
+	)
+	if hash := rawdb.ReadHeadHeaderHash(bc.db); hash != (common.Hash{}) {
+		if number, ok := rawdb.ReadHeaderNumber(bc.db, hash); ok {
+			head = number
+		}
+	}
+	log.Info("Backfilling total difficulty", "head", head)
+
+	rawdb.WriteTd(batch, genesis.Hash(), 0, td)
+	for number := uint64(1); number <= head; number++ {
+		header := rawdb.ReadHeader(bc.db, rawdb.ReadCanonicalHash(bc.db, number), number)
+		if header == nil {
+			break
+		}
+		td.Add(td, header.Difficulty)
+		rawdb.WriteTd(batch, header.Hash(), number, td)
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Backfilling total difficulty", "number", number, "head", head, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Backfilled total difficulty", "head", head, "td", td, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
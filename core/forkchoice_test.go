@@ -0,0 +1,179 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newTdTestChain creates a blockchain with a RandomX chain config (so total
+// difficulty is tracked) backed by a fake engine that accepts any difficulty.
+func newTdTestChain(t *testing.T) (*Genesis, *BlockChain) {
+	config := *params.AllEthashProtocolChanges
+	config.Ethash = nil
+	config.RandomX = new(params.RandomXConfig)
+
+	gspec := &Genesis{
+		Config:     &config,
+		Difficulty: big.NewInt(1000),
+		BaseFee:    big.NewInt(params.InitialBaseFee),
+	}
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), gspec, ethash.NewFullFaker(), DefaultConfig().WithStateScheme(rawdb.HashScheme))
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	t.Cleanup(chain.Stop)
+	return gspec, chain
+}
+
+// makeTdChain generates n blocks on top of the genesis with the given per-block
+// difficulty. The seed is mixed into the extra data to make forks distinct.
+func makeTdChain(gspec *Genesis, n int, diff int64, seed byte) []*types.Block {
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFullFaker(), n, func(i int, b *BlockGen) {
+		b.SetDifficulty(big.NewInt(diff))
+		b.SetExtra([]byte{seed})
+	})
+	return blocks
+}
+
+// Tests that total difficulty is persisted for imported blocks.
+func TestTdTracking(t *testing.T) {
+	gspec, chain := newTdTestChain(t)
+
+	blocks := makeTdChain(gspec, 4, 100, 0)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if td := chain.GetTd(chain.Genesis().Hash(), 0); td == nil || td.Int64() != 1000 {
+		t.Fatalf("genesis td mismatch: have %v, want %v", td, 1000)
+	}
+	for i, block := range blocks {
+		want := int64(1000 + 100*(i+1))
+		if td := chain.GetTd(block.Hash(), block.NumberU64()); td == nil || td.Int64() != want {
+			t.Fatalf("block %d td mismatch: have %v, want %v", block.NumberU64(), td, want)
+		}
+	}
+}
+
+// Tests that total difficulty survives the migration of blocks into the
+// ancient store, which has no total difficulty table.
+func TestTdFrozen(t *testing.T) {
+	config := *params.AllEthashProtocolChanges
+	config.Ethash = nil
+	config.RandomX = new(params.RandomXConfig)
+
+	db, err := rawdb.Open(rawdb.NewMemoryDatabase(), rawdb.OpenOptions{Ancient: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	gspec := &Genesis{
+		Config:     &config,
+		Difficulty: big.NewInt(1000),
+		BaseFee:    big.NewInt(params.InitialBaseFee),
+	}
+	chain, err := NewBlockChain(db, gspec, ethash.NewFullFaker(), DefaultConfig().WithStateScheme(rawdb.HashScheme))
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	blocks := makeTdChain(gspec, 16, 100, 0)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.SetFinalized(blocks[9].Header())
+	if err := db.(interface{ Freeze() error }).Freeze(); err != nil {
+		t.Fatalf("failed to freeze blocks: %v", err)
+	}
+	if frozen, _ := db.Ancients(); frozen < 10 {
+		t.Fatalf("blocks not frozen: have %d ancients, want at least 10", frozen)
+	}
+	for i, block := range blocks {
+		want := int64(1000 + 100*(i+1))
+		if td := rawdb.ReadTd(db, block.Hash(), block.NumberU64()); td == nil || td.Int64() != want {
+			t.Fatalf("block %d td mismatch: have %v, want %v", block.NumberU64(), td, want)
+		}
+	}
+}
+
+// Tests that a longer but lighter fork does not replace a shorter but heavier
+// canonical chain, and that the head switches as soon as a fork becomes heavier.
+func TestTdForkChoice(t *testing.T) {
+	gspec, chain := newTdTestChain(t)
+
+	heavy := makeTdChain(gspec, 2, 500, 1) // td = 2000
+	light := makeTdChain(gspec, 4, 200, 2) // td = 1800
+
+	if _, err := chain.InsertChain(heavy); err != nil {
+		t.Fatalf("failed to insert heavy chain: %v", err)
+	}
+	if _, err := chain.InsertChain(light); err != nil {
+		t.Fatalf("failed to insert light chain: %v", err)
+	}
+	if head := chain.CurrentBlock().Hash(); head != heavy[len(heavy)-1].Hash() {
+		t.Fatalf("head mismatch: have %x, want heavy chain head %x", head, heavy[len(heavy)-1].Hash())
+	}
+	// Extend the light chain so it overtakes the heavy one
+	_, extended, _ := GenerateChainWithGenesis(gspec, ethash.NewFullFaker(), 6, func(i int, b *BlockGen) {
+		b.SetDifficulty(big.NewInt(200))
+		b.SetExtra([]byte{2})
+	}) // td = 2200
+	if _, err := chain.InsertChain(extended[len(light):]); err != nil {
+		t.Fatalf("failed to extend light chain: %v", err)
+	}
+	if head := chain.CurrentBlock().Hash(); head != extended[len(extended)-1].Hash() {
+		t.Fatalf("head mismatch: have %x, want extended chain head %x", head, extended[len(extended)-1].Hash())
+	}
+}
+
+// Tests that forks with identical total difficulty are resolved in the same
+// way regardless of the order in which they are imported.
+func TestTdForkChoiceTieBreak(t *testing.T) {
+	var (
+		gspec, _ = newTdTestChain(t)
+		a        = makeTdChain(gspec, 2, 300, 1)
+		b        = makeTdChain(gspec, 2, 300, 2)
+		c        = makeTdChain(gspec, 3, 200, 3)
+		want     = a[len(a)-1].Hash()
+		last     = b[len(b)-1].Hash()
+	)
+	// Among equal-height forks the smaller hash wins
+	if bytes.Compare(last[:], want[:]) < 0 {
+		want = last
+	}
+	for i, order := range [][][]*types.Block{{a, b, c}, {b, a, c}, {c, b, a}, {c, a, b}} {
+		_, chain := newTdTestChain(t)
+		for _, blocks := range order {
+			if _, err := chain.InsertChain(blocks); err != nil {
+				t.Fatalf("order %d: failed to insert chain: %v", i, err)
+			}
+		}
+		// The shorter forks carry the same work as the longer one, so they win
+		if head := chain.CurrentBlock().Hash(); head != want {
+			t.Fatalf("order %d: head mismatch: have %x, want %x", i, head, want)
+		}
+	}
+}
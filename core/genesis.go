@@ -502,7 +502,9 @@ func (g *Genesis) toBlockWithRoot(root common.Hash) *types.Block {
 	var (
 		withdrawals []*types.Withdrawal
 	)
-	if conf := g.Config; conf != nil {
+	// RandomX chains run the post-merge forks without their beacon chain and
+	// blob header fields.
+	if conf := g.Config; conf != nil && conf.RandomX == nil {
 		num := big.NewInt(int64(g.Number))
 		if conf.IsShanghai(num, g.Timestamp) {
 			head.WithdrawalsHash = &types.EmptyWithdrawalsHash
@@ -566,6 +568,9 @@ func (g *Genesis) Commit(db ethdb.Database, triedb *triedb.Database) (*types.Blo
 	batch := db.NewBatch()
 	rawdb.WriteGenesisStateSpec(batch, block.Hash(), blob)
 	rawdb.WriteBlock(batch, block)
+	if config.RandomX != nil {
+		rawdb.WriteTd(batch, block.Hash(), block.NumberU64(), block.Difficulty())
+	}
 	rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), nil)
 	rawdb.WriteCanonicalHash(batch, block.Hash(), block.NumberU64())
 	rawdb.WriteHeadBlockHash(batch, block.Hash())
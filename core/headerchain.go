@@ -19,6 +19,7 @@ package core
 import (
 	"errors"
 	"fmt"
+	"math/big"
 	"sync/atomic"
 	"time"
 
@@ -35,6 +36,7 @@ import (
 
 const (
 	headerCacheLimit = 512
+	tdCacheLimit     = 1024
 	numberCacheLimit = 2048
 )
 
@@ -63,7 +65,8 @@ type HeaderChain struct {
 	currentHeaderHash common.Hash                  // Hash of the current head of the header chain (prevent recomputing all the time)
 
 	headerCache *lru.Cache[common.Hash, *types.Header]
-	numberCache *lru.Cache[common.Hash, uint64] // most recent block numbers
+	tdCache     *lru.Cache[common.Hash, *big.Int] // most recent total difficulties (proof-of-work only)
+	numberCache *lru.Cache[common.Hash, uint64]   // most recent block numbers
 
 	procInterrupt func() bool
 	engine        consensus.Engine
@@ -76,6 +79,7 @@ func NewHeaderChain(chainDb ethdb.Database, config *params.ChainConfig, engine c
 		config:        config,
 		chainDb:       chainDb,
 		headerCache:   lru.NewCache[common.Hash, *types.Header](headerCacheLimit),
+		tdCache:       lru.NewCache[common.Hash, *big.Int](tdCacheLimit),
 		numberCache:   lru.NewCache[common.Hash, uint64](numberCacheLimit),
 		procInterrupt: procInterrupt,
 		engine:        engine,
@@ -200,7 +204,14 @@ func (hc *HeaderChain) WriteHeaders(headers []*types.Header) (int, error) {
 		inserted    []rawdb.NumberHash // Ephemeral lookup of number/hash for the chain
 		parentKnown = true             // Set to true to force hc.HasHeader check the first iteration
 		batch       = hc.chainDb.NewBatch()
+		td          *big.Int // Running total difficulty, only tracked on proof-of-work chains
 	)
+	if hc.tracksTd() {
+		if td = hc.GetTd(headers[0].ParentHash, headers[0].Number.Uint64()-1); td == nil {
+			return 0, consensus.ErrUnknownAncestor
+		}
+		td = new(big.Int).Set(td)
+	}
 	for i, header := range headers {
 		var hash common.Hash
 		// The headers have already been validated at this point, so we already
@@ -212,12 +223,18 @@ func (hc *HeaderChain) WriteHeaders(headers []*types.Header) (int, error) {
 			hash = header.Hash()
 		}
 		number := header.Number.Uint64()
-
+		if td != nil {
+			td.Add(td, header.Difficulty)
+		}
 		// If the parent was not present, store it
 		// If the header is already known, skip it, otherwise store
 		alreadyKnown := parentKnown && hc.HasHeader(hash, number)
 		if !alreadyKnown {
 			rawdb.WriteHeader(batch, header)
+			if td != nil {
+				rawdb.WriteTd(batch, hash, number, td)
+				hc.tdCache.Add(hash, new(big.Int).Set(td))
+			}
 			inserted = append(inserted, rawdb.NumberHash{Number: number, Hash: hash})
 			hc.headerCache.Add(hash, header)
 			hc.numberCache.Add(hash, number)
@@ -265,6 +282,19 @@ func (hc *HeaderChain) writeHeadersAndSetHead(headers []*types.Header) (*headerW
 	if hc.GetCanonicalHash(lastHeader.Number.Uint64()) == lastHash && lastHeader.Number.Uint64() <= hc.CurrentHeader().Number.Uint64() {
 		return result, nil
 	}
+	// On proof-of-work chains, only switch over if the new headers make up
+	// a heavier chain than the current one.
+	if hc.tracksTd() {
+		current := hc.CurrentHeader()
+		localTd, externTd := hc.GetTd(current.Hash(), current.Number.Uint64()), hc.GetTd(lastHash, lastHeader.Number.Uint64())
+		if localTd == nil || externTd == nil {
+			return nil, errMissingTd
+		}
+		if !heavierChain(current, localTd, lastHeader, externTd) {
+			result.status = SideStatTy
+			return result, nil
+		}
+	}
 	// Apply the reorg operation
 	if err := hc.Reorg(headers); err != nil {
 		return nil, err
@@ -399,6 +429,29 @@ func (hc *HeaderChain) GetHeader(hash common.Hash, number uint64) *types.Header
 	return header
 }
 
+// tracksTd reports whether the chain maintains per-block total difficulty.
+// Only proof-of-work (RandomX) chains do, as it is what their fork choice
+// rule is based on.
+func (hc *HeaderChain) tracksTd() bool {
+	return hc.config.RandomX != nil
+}
+
+// GetTd retrieves a block's total difficulty from the database by hash and
+// number, caching it if found. Nil is returned if the
+// chain does not track total difficulty or the block is unknown.
+func (hc *HeaderChain) GetTd(hash common.Hash, number uint64) *big.Int {
+	if cached, ok := hc.tdCache.Get(hash); ok {
+		return cached
+	}
+	td := rawdb.ReadTd(hc.chainDb, hash, number)
+	if td == nil {
+		return nil
+	}
+	// Cache the found total difficulty for next time and return
+	hc.tdCache.Add(hash, td)
+	return td
+}
+
 // GetHeaderByHash retrieves a block header from the database by hash, caching it if
 // found.
 func (hc *HeaderChain) GetHeaderByHash(hash common.Hash) *types.Header {
@@ -600,6 +653,7 @@ func (hc *HeaderChain) setHead(headBlock uint64, headTime uint64, updateFn Updat
 				}
 				// Remove the hash->number mapping along with the header itself
 				rawdb.DeleteHeader(batch, hash, num)
+				rawdb.DeleteTd(batch, hash, num)
 			}
 			// Remove the number->hash mapping
 			rawdb.DeleteCanonicalHash(batch, num)
@@ -638,6 +692,7 @@ func (hc *HeaderChain) setHead(headBlock uint64, headTime uint64, updateFn Updat
 	}
 	// Clear out any stale content from the caches
 	hc.headerCache.Purge()
+	hc.tdCache.Purge()
 	hc.numberCache.Purge()
 }
 
@@ -108,9 +108,10 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		receipts = append(receipts, receipt)
 		allLogs = append(allLogs, receipt.Logs...)
 	}
-	// Read requests if Prague is enabled.
+	// Read requests if Prague is enabled. They are consensus layer requests,
+	// which RandomX chains do not have.
 	var requests [][]byte
-	if config.IsPrague(block.Number(), block.Time()) {
+	if config.IsPrague(block.Number(), block.Time()) && config.RandomX == nil {
 		requests = [][]byte{}
 		// EIP-6110
 		if err := ParseDepositLogs(&requests, allLogs, config); err != nil {
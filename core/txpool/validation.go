@@ -82,6 +82,9 @@ func ValidateTransaction(tx *types.Transaction, head *types.Header, signer types
 	if !rules.IsCancun && tx.Type() == types.BlobTxType {
 		return fmt.Errorf("%w: type %d rejected, pool not yet in Cancun", core.ErrTxTypeNotSupported, tx.Type())
 	}
+	if opts.Config.RandomX != nil && tx.Type() == types.BlobTxType {
+		return fmt.Errorf("%w: type %d rejected, blobs disabled on randomx", core.ErrTxTypeNotSupported, tx.Type())
+	}
 	if !rules.IsPrague && tx.Type() == types.SetCodeTxType {
 		return fmt.Errorf("%w: type %d rejected, pool not yet in Prague", core.ErrTxTypeNotSupported, tx.Type())
 	}
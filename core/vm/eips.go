@@ -289,6 +289,12 @@ func opBlobHash(pc *uint64, evm *EVM, scope *ScopeContext) ([]byte, error) {
 
 // opBlobBaseFee implements BLOBBASEFEE opcode
 func opBlobBaseFee(pc *uint64, evm *EVM, scope *ScopeContext) ([]byte, error) {
+	// Proof-of-work chains run Cancun without blobs, the blob base fee never
+	// leaves its floor there.
+	if evm.Context.BlobBaseFee == nil {
+		scope.Stack.push(uint256.NewInt(params.BlobTxMinBlobGasprice))
+		return nil, nil
+	}
 	blobBaseFee, _ := uint256.FromBig(evm.Context.BlobBaseFee)
 	scope.Stack.push(blobBaseFee)
 	return nil, nil
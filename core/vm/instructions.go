@@ -475,6 +475,11 @@ func opDifficulty(pc *uint64, evm *EVM, scope *ScopeContext) ([]byte, error) {
 }
 
 func opRandom(pc *uint64, evm *EVM, scope *ScopeContext) ([]byte, error) {
+	// Proof-of-work chains running the post-merge forks have no beacon chain
+	// randomness, the opcode keeps returning the block difficulty there.
+	if evm.Context.Random == nil {
+		return opDifficulty(pc, evm, scope)
+	}
 	v := new(uint256.Int).SetBytes(evm.Context.Random.Bytes())
 	scope.Stack.push(v)
 	return nil, nil
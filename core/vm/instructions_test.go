@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
@@ -760,6 +761,52 @@ func TestBlobHash(t *testing.T) {
 	}
 }
 
+// Tests that the post-merge block opcodes keep working on proof-of-work chains
+// running the post-merge forks: PREVRANDAO returns the difficulty, since there
+// is no beacon randomness, and BLOBBASEFEE its floor, since there are no blobs.
+func TestRandomXBlockOps(t *testing.T) {
+	config := *params.AllEthashProtocolChanges
+	config.TerminalTotalDifficulty = nil
+	config.Ethash = nil
+	config.RandomX = &params.RandomXConfig{DisableBlobs: true}
+	config.ShanghaiTime = new(uint64)
+	config.CancunTime = new(uint64)
+	config.PragueTime = new(uint64)
+
+	var (
+		address    = common.BytesToAddress([]byte("contract"))
+		difficulty = big.NewInt(131072)
+		statedb, _ = state.New(types.EmptyRootHash, state.NewDatabaseForTesting())
+		evm        = NewEVM(BlockContext{
+			Transfer:    func(StateDB, common.Address, common.Address, *uint256.Int) {},
+			BlockNumber: big.NewInt(1),
+			Difficulty:  difficulty,
+		}, statedb, &config, Config{})
+	)
+	if evm.table != &pragueInstructionSet {
+		t.Fatalf("post-merge forks not active on randomx chain")
+	}
+	// mstore(0, prevrandao), mstore(32, blobbasefee), return(0, 64)
+	code := []byte{
+		byte(PREVRANDAO), byte(PUSH0), byte(MSTORE),
+		byte(BLOBBASEFEE), byte(PUSH1), 0x20, byte(MSTORE),
+		byte(PUSH1), 0x40, byte(PUSH0), byte(RETURN),
+	}
+	statedb.CreateAccount(address)
+	statedb.SetCode(address, code, tracing.CodeChangeUnspecified)
+
+	ret, _, err := evm.Call(common.Address{}, address, nil, 100_000, new(uint256.Int))
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if have := new(big.Int).SetBytes(ret[:32]); have.Cmp(difficulty) != 0 {
+		t.Errorf("prevrandao mismatch: have %v, want difficulty %v", have, difficulty)
+	}
+	if have := new(big.Int).SetBytes(ret[32:]); have.Uint64() != params.BlobTxMinBlobGasprice {
+		t.Errorf("blobbasefee mismatch: have %v, want %d", have, params.BlobTxMinBlobGasprice)
+	}
+}
+
 func TestOpMCopy(t *testing.T) {
 	// Test cases from https://eips.ethereum.org/EIPS/eip-5656#test-cases
 	for i, tc := range []struct {
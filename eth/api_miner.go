@@ -19,6 +19,7 @@ package eth
 import (
 	"math/big"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
@@ -81,3 +82,19 @@ func (api *MinerAPI) Mining() bool {
 func (api *MinerAPI) HashRate() uint64 {
 	return api.e.Miner().HashRate()
 }
+
+// GetWorkFor returns a work package, in the format of GetWork, for a block on
+// top of the current head that pays the given coinbase and carries the given
+// extra data, or the configured one if omitted. Proxies use it to offer solo
+// mining, each miner's blocks paying its own address. Solutions are submitted
+// with SubmitWork until the next chain head.
+//
+// Every call builds and executes a full block, so the method is only available
+// in the miner namespace, not to the public.
+func (api *MinerAPI) GetWorkFor(coinbase common.Address, extra *hexutil.Bytes) ([4]string, error) {
+	var data []byte
+	if extra != nil {
+		data = *extra
+	}
+	return api.e.Miner().WorkFor(coinbase, data)
+}
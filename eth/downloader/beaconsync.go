@@ -107,7 +107,7 @@ func (b *beaconBackfiller) resume() {
 		}()
 		// If the downloader fails, report an error as in beacon chain mode there
 		// should be no errors as long as the chain we're syncing to is valid.
-		if err := b.downloader.synchronise(mode, b.started); err != nil {
+		if err := b.downloader.synchronise(mode, b.started, b.downloader.syncToHead); err != nil {
 			log.Error("Beacon backfilling failed", "err", err)
 			return
 		}
@@ -134,6 +134,9 @@ type Downloader struct {
 	// Skeleton sync
 	skeleton *skeleton // Header skeleton to backfill the chain with (eth2 mode)
 
+	// Proof-of-work sync
+	powSync atomic.Bool // Whether the running sync cycle is driven by a proof-of-work peer
+
 	// State sync
 	pivotHeader *types.Header // Pivot block header to dynamically push the syncing state root
 	pivotLock   sync.RWMutex  // Lock protecting pivot header reads from updates
@@ -192,6 +195,10 @@ type BlockChain interface {
 	// SnapSyncCommitHead directly commits the head block to a certain entity.
 	SnapSyncCommitHead(common.Hash) error
 
+	// InsertHeaderChain verifies and inserts a batch of headers into the local
+	// header chain.
+	InsertHeaderChain([]*types.Header) (int, error)
+
 	// InsertHeadersBeforeCutoff inserts a batch of headers before the configured
 	// chain cutoff into the ancient store.
 	InsertHeadersBeforeCutoff([]*types.Header) (int, error)
@@ -328,10 +335,10 @@ func (d *Downloader) UnregisterPeer(id string) error {
 	return nil
 }
 
-// synchronise will select the peer and use it for synchronising. If an empty string is given
-// it will use the best peer possible and synchronize if its TD is higher than our own. If any of the
-// checks fail an error will be returned. This method is synchronous
-func (d *Downloader) synchronise(mode SyncMode, beaconPing chan struct{}) error {
+// synchronise prepares the downloader for a new sync cycle in the given mode and
+// runs the provided sync strategy (beacon or proof-of-work) to completion. If any
+// of the checks fail an error will be returned. This method is synchronous
+func (d *Downloader) synchronise(mode SyncMode, beaconPing chan struct{}, run func() error) error {
 	// The beacon header syncer is async. It will start this synchronization and
 	// will continue doing other tasks. However, if synchronization needs to be
 	// cancelled, the syncer needs to know if we reached the startup point (and
@@ -403,7 +410,7 @@ func (d *Downloader) synchronise(mode SyncMode, beaconPing chan struct{}) error
 	if beaconPing != nil {
 		close(beaconPing)
 	}
-	return d.syncToHead()
+	return run()
 }
 
 func (d *Downloader) getMode() SyncMode {
@@ -481,6 +488,16 @@ func (d *Downloader) syncToHead() (err error) {
 	d.syncStatsChainHeight = height
 	d.syncStatsLock.Unlock()
 
+	return d.syncFrom(origin, height, pivot, final, d.fetchHeaders)
+}
+
+// syncFrom runs the content retrieval and import phase of a sync cycle, above
+// the given common ancestor. The final header is only known in beacon mode and
+// may be nil. Headers are fed into the pipeline by fetchHeaders, starting at
+// the given block number.
+func (d *Downloader) syncFrom(origin uint64, height uint64, pivot *types.Header, final *types.Header, fetchHeaders func(from uint64) error) error {
+	mode := d.getMode()
+
 	// Ensure our origin point is below any snap sync pivot point
 	if mode == ethconfig.SnapSync {
 		if height <= uint64(fsMinFullBlocks) {
@@ -518,6 +535,8 @@ func (d *Downloader) syncToHead() (err error) {
 		//
 		// Beacon sync, use the latest finalized block as the ancient limit
 		// or a reasonable height if no finalized block is yet announced.
+		// Proof-of-work sync never has a finalized block and always takes
+		// the latter route.
 		if final != nil {
 			d.ancientLimit = final.Number.Uint64()
 		} else if height > fullMaxForkAncestry+1 {
@@ -567,9 +586,10 @@ func (d *Downloader) syncToHead() (err error) {
 	// Initiate the sync using a concurrent header and content retrieval algorithm
 	d.queue.Prepare(chainOffset, mode)
 
-	// In beacon mode, headers are served by the skeleton syncer
+	// Headers are served by the skeleton syncer in beacon mode, or by the
+	// sync peer in proof-of-work mode
 	fetchers := []func() error{
-		func() error { return d.fetchHeaders(origin + 1) },   // Headers are always retrieved
+		func() error { return fetchHeaders(origin + 1) },     // Headers are always retrieved
 		func() error { return d.fetchBodies(chainOffset) },   // Bodies are retrieved during normal and snap sync
 		func() error { return d.fetchReceipts(chainOffset) }, // Receipts are retrieved during snap sync
 		func() error { return d.processHeaders(origin + 1) },
@@ -762,6 +782,16 @@ func (d *Downloader) processHeaders(origin uint64) error {
 					chunkHeaders = chunkHeaders[cutoff:]
 					chunkHashes = chunkHashes[cutoff:]
 				}
+				// Proof-of-work headers are not anchored to a trusted beacon head,
+				// so in snap sync (where blocks are not executed) verify and import
+				// them into the header chain before retrieving any content. Full
+				// sync verifies them upon block import.
+				if mode == ethconfig.SnapSync && d.powSync.Load() && len(chunkHeaders) > 0 {
+					if n, err := d.blockchain.InsertHeaderChain(chunkHeaders); err != nil {
+						log.Warn("Invalid header encountered", "number", chunkHeaders[n].Number, "hash", chunkHashes[n], "parent", chunkHeaders[n].ParentHash, "err", err)
+						return fmt.Errorf("%w: %v", errInvalidChain, err)
+					}
+				}
 				if len(chunkHeaders) > 0 {
 					scheduled = true
 					if d.queue.Schedule(chunkHeaders, chunkHashes, origin+uint64(cutoff)) != len(chunkHeaders) {
@@ -0,0 +1,432 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	errUnknownPeer     = errors.New("peer is unknown or unhealthy")
+	errEmptyHeaderSet  = errors.New("empty header set by peer")
+	errStallingPeer    = errors.New("peer is stalling")
+	errInvalidAncestor = errors.New("retrieved ancestor is invalid")
+)
+
+// PoWSync synchronises the local chain with the chain advertised by the given
+// remote peer. It is the proof-of-work counterpart of the beacon sync: there is
+// no consensus client on proof-of-work networks, so the sync target is the head
+// of the peer with the most total difficulty (as announced in the eth handshake
+// or in block propagations).
+//
+// Headers are retrieved from the selected peer, while bodies and receipts are
+// downloaded concurrently from all available peers. Snap sync is used for the
+// state if requested, full sync otherwise.
+func (d *Downloader) PoWSync(id string, head common.Hash, td *big.Int, mode SyncMode) error {
+	err := d.synchronise(mode, nil, func() error {
+		p := d.peers.Peer(id)
+		if p == nil {
+			return errUnknownPeer
+		}
+		d.powSync.Store(true)
+		defer d.powSync.Store(false)
+
+		return d.syncWithPeer(p, head, td)
+	})
+	switch {
+	case err == nil, errors.Is(err, errBusy), errors.Is(err, errCanceled):
+		return err
+
+	case errors.Is(err, errInvalidChain), errors.Is(err, errBadPeer), errors.Is(err, errTimeout),
+		errors.Is(err, errStallingPeer), errors.Is(err, errEmptyHeaderSet), errors.Is(err, errInvalidAncestor),
+		errors.Is(err, errInvalidBody), errors.Is(err, errInvalidReceipt):
+		log.Warn("Synchronisation failed, dropping peer", "peer", id, "err", err)
+		if d.dropPeer == nil {
+			// The dropPeer method is nil when `--copydb` is used for a local copy.
+			// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
+			log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", id)
+		} else {
+			d.dropPeer(id)
+		}
+		return err
+	}
+	log.Warn("Synchronisation failed, retrying", "peer", id, "err", err)
+	return err
+}
+
+// syncWithPeer starts a block synchronization based on the hash chain from the
+// specified peer and head hash.
+func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.Int) (err error) {
+	d.mux.Post(StartEvent{})
+	defer func() {
+		// reset on error
+		if err != nil {
+			d.mux.Post(FailedEvent{err})
+		} else {
+			latest := d.blockchain.CurrentHeader()
+			d.mux.Post(DoneEvent{latest})
+		}
+	}()
+	mode := d.getMode()
+
+	log.Debug("Synchronising with the network", "peer", p.id, "eth", p.version, "head", hash, "td", td, "mode", mode)
+	defer func(start time.Time) {
+		log.Debug("Synchronisation terminated", "elapsed", common.PrettyDuration(time.Since(start)))
+	}(time.Now())
+
+	// Look up the sync boundaries: the common ancestor and the target block
+	latest, pivot, err := d.fetchHead(p, hash)
+	if err != nil {
+		return err
+	}
+	if mode == ethconfig.SnapSync && pivot == nil {
+		// If no pivot block was returned, the head is below the min full block
+		// threshold (i.e. new chain). In that case we won't really snap sync
+		// anyway, but still need a valid pivot block to avoid some code hitting
+		// nil panics on an access.
+		pivot = d.blockchain.CurrentBlock()
+	}
+	height := latest.Number.Uint64()
+
+	origin, err := d.findAncestor(p, latest)
+	if err != nil {
+		return err
+	}
+	d.syncStatsLock.Lock()
+	if d.syncStatsChainHeight <= origin || d.syncStatsChainOrigin > origin {
+		d.syncStatsChainOrigin = origin
+	}
+	d.syncStatsChainHeight = height
+	d.syncStatsLock.Unlock()
+
+	return d.syncFrom(origin, height, pivot, nil, func(from uint64) error {
+		return d.fetchPeerHeaders(p, from, height)
+	})
+}
+
+// fetchHead retrieves the head header and prior pivot block (if available) from
+// a remote peer.
+func (d *Downloader) fetchHead(p *peerConnection, hash common.Hash) (head *types.Header, pivot *types.Header, err error) {
+	p.log.Debug("Retrieving remote chain head")
+	mode := d.getMode()
+
+	// Request the advertised remote head block and wait for the response
+	fetch := 1
+	if mode == ethconfig.SnapSync {
+		fetch = 2 // head + pivot headers
+	}
+	headers, hashes, err := d.fetchHeadersByHash(p, hash, fetch, fsMinFullBlocks-1, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Make sure the peer gave us at least one and at most the requested headers
+	if len(headers) == 0 || len(headers) > fetch {
+		return nil, nil, fmt.Errorf("%w: returned headers %d != requested %d", errBadPeer, len(headers), fetch)
+	}
+	// The first header needs to be the head, validate against the request. If
+	// only 1 header was returned, make sure there's no pivot or there was not
+	// one requested.
+	head = headers[0]
+	if hashes[0] != hash {
+		return nil, nil, fmt.Errorf("%w: remote head %x != requested %x", errBadPeer, hashes[0], hash)
+	}
+	if len(headers) == 1 {
+		if mode == ethconfig.SnapSync && head.Number.Uint64() > uint64(fsMinFullBlocks) {
+			return nil, nil, fmt.Errorf("%w: no pivot included along head header", errBadPeer)
+		}
+		p.log.Debug("Remote head identified, no pivot", "number", head.Number, "hash", hashes[0])
+		return head, nil, nil
+	}
+	// At this point we have 2 headers in total and the first is the
+	// validated head of the chain. Check the pivot number and return,
+	pivot = headers[1]
+	if pivot.Number.Uint64() != head.Number.Uint64()-uint64(fsMinFullBlocks) {
+		return nil, nil, fmt.Errorf("%w: remote pivot %d != requested %d", errInvalidChain, pivot.Number, head.Number.Uint64()-uint64(fsMinFullBlocks))
+	}
+	return head, pivot, nil
+}
+
+// calculateRequestSpan calculates what headers to request from a peer when trying to determine the
+// common ancestor.
+// It returns parameters to be used for peer.RequestHeadersByNumber:
+//
+//	from  - starting block number
+//	count - number of headers to request
+//	skip  - number of headers to skip
+//
+// and also returns 'max', the last block which is expected to be returned by the remote peers,
+// given the (from,count,skip)
+func calculateRequestSpan(remoteHeight, localHeight uint64) (int64, int, int, uint64) {
+	var (
+		from     int
+		count    int
+		MaxCount = MaxHeaderFetch / 16
+	)
+	// requestHead is the highest block that we will ask for. If requestHead is not offset,
+	// the highest block that we will get is 16 blocks back from head, which means we
+	// will fetch 14 or 15 blocks unnecessarily in the case the height difference
+	// between us and the peer is 1-2 blocks, which is most common
+	requestHead := int(remoteHeight) - 1
+	if requestHead < 0 {
+		requestHead = 0
+	}
+	// requestBottom is the lowest block we want included in the query
+	// Ideally, we want to include the one just below our own head
+	requestBottom := int(localHeight) - 1
+	if requestBottom < 0 {
+		requestBottom = 0
+	}
+	totalSpan := requestHead - requestBottom
+	span := 1 + totalSpan/MaxCount
+	if span < 2 {
+		span = 2
+	}
+	if span > 16 {
+		span = 16
+	}
+
+	count = 1 + totalSpan/span
+	if count > MaxCount {
+		count = MaxCount
+	}
+	if count < 2 {
+		count = 2
+	}
+	from = requestHead - (count-1)*span
+	if from < 0 {
+		from = 0
+	}
+	max := from + (count-1)*span
+	return int64(from), count, span - 1, uint64(max)
+}
+
+// findAncestor tries to locate the common ancestor link of the local chain and
+// a remote peers blockchain. In the general case when our node was in sync and
+// on the correct chain, checking the top N links should already get us a match.
+// In the rare scenario when we ended up on a long reorganisation (i.e. none of
+// the head links match), we do a binary search to find the common ancestor.
+func (d *Downloader) findAncestor(p *peerConnection, remoteHeader *types.Header) (uint64, error) {
+	// Figure out the valid ancestor range to prevent rewrite attacks
+	var (
+		floor        = int64(-1)
+		localHeight  uint64
+		remoteHeight = remoteHeader.Number.Uint64()
+	)
+	mode := d.getMode()
+	switch mode {
+	case ethconfig.FullSync:
+		localHeight = d.blockchain.CurrentBlock().Number.Uint64()
+	case ethconfig.SnapSync:
+		localHeight = d.blockchain.CurrentSnapBlock().Number.Uint64()
+	}
+	p.log.Debug("Looking for common ancestor", "local", localHeight, "remote", remoteHeight)
+
+	// Recap floor value for binary search
+	if localHeight >= fullMaxForkAncestry {
+		// We're above the max reorg threshold, find the earliest fork point
+		floor = int64(localHeight - fullMaxForkAncestry)
+	}
+	ancestor, err := d.findAncestorSpanSearch(p, mode, remoteHeight, localHeight, floor)
+	if err != nil {
+		return 0, err
+	}
+	if ancestor != 0 {
+		return ancestor, nil
+	}
+	// Ancestor not found, we need to binary search over our chain
+	start, end := uint64(0), remoteHeight
+	if floor > 0 {
+		start = uint64(floor)
+	}
+	p.log.Trace("Binary searching for common ancestor", "start", start, "end", end)
+
+	for start+1 < end {
+		// Split our chain interval in two, and request the hash to cross check
+		check := (start + end) / 2
+
+		headers, hashes, err := d.fetchHeadersByNumber(p, check, 1, 0, false)
+		if err != nil {
+			return 0, err
+		}
+		// Make sure the peer actually gave something valid
+		if len(headers) != 1 {
+			p.log.Warn("Multiple headers for single request", "headers", len(headers))
+			return 0, fmt.Errorf("%w: multiple headers (%d) for single request", errBadPeer, len(headers))
+		}
+		if n := headers[0].Number.Uint64(); n != check {
+			p.log.Warn("Received non requested header", "number", n, "hash", hashes[0], "request", check)
+			return 0, fmt.Errorf("%w: non-requested header (%d)", errBadPeer, n)
+		}
+		// Modify the search interval based on the response
+		if !d.knownAncestor(mode, hashes[0], check) {
+			end = check
+			continue
+		}
+		start = check
+	}
+	// Ensure valid ancestry and return
+	if int64(start) <= floor {
+		p.log.Warn("Ancestor below allowance", "number", start, "allowance", floor)
+		return 0, errInvalidAncestor
+	}
+	p.log.Debug("Found common ancestor", "number", start)
+	return start, nil
+}
+
+// findAncestorSpanSearch checks a spread of the remote peer's recent headers
+// against the local chain, returning the highest match (or 0 if none found).
+func (d *Downloader) findAncestorSpanSearch(p *peerConnection, mode SyncMode, remoteHeight, localHeight uint64, floor int64) (uint64, error) {
+	from, count, skip, max := calculateRequestSpan(remoteHeight, localHeight)
+
+	p.log.Trace("Span searching for common ancestor", "count", count, "from", from, "skip", skip)
+	headers, hashes, err := d.fetchHeadersByNumber(p, uint64(from), count, skip, false)
+	if err != nil {
+		return 0, err
+	}
+	// Make sure the peer actually gave something valid
+	if len(headers) == 0 {
+		p.log.Warn("Empty head header set")
+		return 0, errEmptyHeaderSet
+	}
+	// Make sure the peer's reply conforms to the request
+	for i, header := range headers {
+		expectNumber := from + int64(i)*int64(skip+1)
+		if number := header.Number.Int64(); number != expectNumber {
+			p.log.Warn("Head headers broke chain ordering", "index", i, "requested", expectNumber, "received", number)
+			return 0, fmt.Errorf("%w: %v", errInvalidChain, errors.New("head headers broke chain ordering"))
+		}
+	}
+	// Check if a common ancestor was found
+	for i := len(headers) - 1; i >= 0; i-- {
+		// Skip any headers that underflow/overflow our requested set
+		number := headers[i].Number.Uint64()
+		if int64(number) < from || number > max {
+			continue
+		}
+		// Otherwise check if we already know the header or not
+		if !d.knownAncestor(mode, hashes[i], number) {
+			continue
+		}
+		if int64(number) <= floor {
+			p.log.Warn("Ancestor below allowance", "number", number, "hash", hashes[i], "allowance", floor)
+			return 0, errInvalidAncestor
+		}
+		p.log.Debug("Found common ancestor", "number", number, "hash", hashes[i])
+		return number, nil
+	}
+	return 0, nil
+}
+
+// knownAncestor reports whether the given block is present locally to the
+// extent required by the sync mode.
+func (d *Downloader) knownAncestor(mode SyncMode, hash common.Hash, number uint64) bool {
+	if mode == ethconfig.SnapSync {
+		return d.blockchain.HasFastBlock(hash, number)
+	}
+	return d.blockchain.HasBlock(hash, number)
+}
+
+// fetchPeerHeaders keeps retrieving headers from the sync peer, starting at the
+// given block number, and feeds them to the header processor until the remote
+// chain is exhausted.
+//
+// The advertised head height is used to detect peers withholding headers. In
+// snap sync, headers keep being polled for until the pivot block is committed,
+// allowing the pivot to move along with the remote chain.
+func (d *Downloader) fetchPeerHeaders(p *peerConnection, from uint64, height uint64) error {
+	p.log.Debug("Directing header downloads", "origin", from)
+	defer p.log.Debug("Header download terminated")
+
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	var parent common.Hash // Hash of the last delivered header to ensure linkage
+	for {
+		select {
+		case <-d.cancelCh:
+			return errCanceled
+		default:
+		}
+		headers, hashes, err := d.fetchHeadersByNumber(p, from, MaxHeaderFetch, 0, false)
+		if err != nil {
+			return err
+		}
+		if len(headers) == 0 {
+			// A peer not delivering the headers it advertised is stalling us
+			if from <= height {
+				p.log.Debug("No headers delivered below advertised head", "from", from, "head", height)
+				return errStallingPeer
+			}
+			// Otherwise the remote chain is exhausted. Finish header retrieval,
+			// unless the snap sync pivot is still not committed, in which case
+			// wait a bit for new headers and retry.
+			if d.getMode() != ethconfig.SnapSync || d.committed.Load() {
+				select {
+				case d.headerProcCh <- nil:
+					return nil
+				case <-d.cancelCh:
+					return errCanceled
+				}
+			}
+			p.log.Trace("Pivot not yet committed, waiting for headers")
+			timer.Reset(fsHeaderContCheck)
+			select {
+			case <-timer.C:
+				continue
+			case <-d.cancelCh:
+				return errCanceled
+			}
+		}
+		// Make sure the delivered headers form a contiguous chain
+		for i, header := range headers {
+			if header.Number.Uint64() != from+uint64(i) {
+				p.log.Warn("Headers broke chain ordering", "index", i, "requested", from+uint64(i), "received", header.Number)
+				return fmt.Errorf("%w: headers broke chain ordering", errInvalidChain)
+			}
+			if (i > 0 || parent != common.Hash{}) {
+				want := parent
+				if i > 0 {
+					want = hashes[i-1]
+				}
+				if header.ParentHash != want {
+					p.log.Warn("Headers broke chain ancestry", "number", header.Number, "parent", header.ParentHash, "want", want)
+					return fmt.Errorf("%w: headers broke chain ancestry", errInvalidChain)
+				}
+			}
+		}
+		p.log.Trace("Scheduling new headers", "count", len(headers), "from", from)
+		select {
+		case d.headerProcCh <- &headerTask{
+			headers: headers,
+			hashes:  hashes,
+		}:
+		case <-d.cancelCh:
+			return errCanceled
+		}
+		from += uint64(len(headers))
+		parent = hashes[len(hashes)-1]
+	}
+}
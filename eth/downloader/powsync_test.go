@@ -0,0 +1,138 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/randomx"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newPoWTestGenesis returns the genesis of a RandomX test chain. LWMA starts
+// past its window, so that it averages varied difficulties and the blocks fail
+// verification if their ancestors are not found.
+func newPoWTestGenesis() *core.Genesis {
+	config := *params.AllEthashProtocolChanges
+	config.TerminalTotalDifficulty = nil
+	config.Ethash = nil
+	config.RandomX = &params.RandomXConfig{
+		LWMAActivationBlock: big.NewInt(2 * randomx.LWMAWindowSize),
+	}
+	return &core.Genesis{
+		Config:  &config,
+		Alloc:   types.GenesisAlloc{testAddress: {Balance: big.NewInt(1000000000000000)}},
+		BaseFee: big.NewInt(params.InitialBaseFee),
+	}
+}
+
+// newPoWTestEngine creates a RandomX engine checking everything but the seal.
+func newPoWTestEngine(t *testing.T) *randomx.RandomX {
+	engine := randomx.New(&randomx.Config{PowMode: randomx.ModeFake})
+	t.Cleanup(func() { engine.Close() })
+	return engine
+}
+
+// newPoWTester creates a downloader tester on the given RandomX chain, with
+// the given blocks already imported.
+func newPoWTester(t *testing.T, gspec *core.Genesis, blocks []*types.Block) *downloadTester {
+	db, err := rawdb.Open(rawdb.NewMemoryDatabase(), rawdb.OpenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	chain, err := core.NewBlockChain(db, gspec, newPoWTestEngine(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to import: %v", n, err)
+	}
+	tester := &downloadTester{
+		chain: chain,
+		peers: make(map[string]*downloadTesterPeer),
+	}
+	tester.downloader = New(db, new(event.TypeMux), tester.chain, tester.dropPeer, nil)
+	return tester
+}
+
+// newPoWPeer registers a peer serving the given RandomX chain.
+func (dl *downloadTester) newPoWPeer(t *testing.T, id string, gspec *core.Genesis, blocks []*types.Block) *downloadTesterPeer {
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), gspec, newPoWTestEngine(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(chain.Stop)
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to import peer chain: %v", n, err)
+	}
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	peer := &downloadTesterPeer{
+		dl:             dl,
+		id:             id,
+		chain:          chain,
+		withholdBodies: make(map[common.Hash]struct{}),
+	}
+	dl.peers[id] = peer
+
+	if err := dl.downloader.RegisterPeer(id, eth.ETH68, peer); err != nil {
+		t.Fatal(err)
+	}
+	if err := dl.downloader.SnapSyncer.Register(peer); err != nil {
+		t.Fatal(err)
+	}
+	return peer
+}
+
+// Tests that a RandomX chain is synchronised from a peer with a longer chain,
+// importing header batches longer than the LWMA window.
+func TestPoWSync68Full(t *testing.T) { testPoWSync(t, FullSync) }
+func TestPoWSync68Snap(t *testing.T) { testPoWSync(t, SnapSync) }
+
+func testPoWSync(t *testing.T, mode SyncMode) {
+	gspec := newPoWTestGenesis()
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, newPoWTestEngine(t), 3*MaxHeaderFetch, func(i int, block *core.BlockGen) {
+		// Alternate fast and slow blocks for the difficulty to move
+		if i%3 == 0 {
+			block.OffsetTime(20)
+		}
+	})
+	tester := newPoWTester(t, gspec, blocks[:10])
+	defer tester.terminate()
+
+	peer := tester.newPoWPeer(t, "peer", gspec, blocks)
+	head := peer.chain.CurrentBlock()
+	td := peer.chain.GetTd(head.Hash(), head.Number.Uint64())
+
+	if err := tester.downloader.PoWSync("peer", head.Hash(), td, mode); err != nil {
+		t.Fatalf("failed to synchronise: %v", err)
+	}
+	assertOwnChain(t, tester, len(blocks)+1)
+	if have := tester.chain.CurrentBlock().Hash(); have != head.Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", have, head.Hash())
+	}
+}
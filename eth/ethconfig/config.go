@@ -72,6 +72,11 @@ var Defaults = Config{
 	RPCTxFeeCap:          1, // 1 ether
 	TxSyncDefaultTimeout: 20 * time.Second,
 	TxSyncMaxTimeout:     1 * time.Minute,
+	RandomX: randomx.Config{
+		CacheDir:  "randomx",
+		HugePages: true,
+		JIT:       true,
+	},
 }
 
 //go:generate go run github.com/fjl/gencodec -type Config -formats toml -out gen_config.go
@@ -141,6 +146,9 @@ type Config struct {
 	// Mining options
 	Miner miner.Config
 
+	// RandomX proof-of-work engine options
+	RandomX randomx.Config
+
 	// Transaction pool options
 	TxPool   legacypool.Config
 	BlobPool blobpool.Config
@@ -193,14 +201,17 @@ type Config struct {
 
 // CreateConsensusEngine creates a consensus engine for the given chain config.
 // Supports RandomX (PoW), Clique (PoA), and Beacon (PoS) consensus engines.
-func CreateConsensusEngine(config *params.ChainConfig, db ethdb.Database) (consensus.Engine, error) {
+// The RandomX settings are only used if the chain runs on RandomX.
+func CreateConsensusEngine(config *params.ChainConfig, randomxConfig *randomx.Config, db ethdb.Database) (consensus.Engine, error) {
 	log.Info("Creating consensus engine", "randomx", config.RandomX != nil, "clique", config.Clique != nil, "ethash", config.Ethash != nil, "ttd", config.TerminalTotalDifficulty != nil)
 
 	// RandomX PoW consensus (CPU-friendly mining)
 	if config.RandomX != nil {
 		log.Info("Using RandomX PoW consensus engine")
 		// Real RandomX engine with C bindings
-		return randomx.New(nil), nil
+		engine := randomx.New(randomxConfig)
+		engine.SetDatabase(db)
+		return engine, nil
 	}
 
 	// Legacy PoS check (commented out to allow PoW chains)
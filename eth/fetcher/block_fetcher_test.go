@@ -0,0 +1,418 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	genesis      = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1)})
+	unknownBlock = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Extra: []byte("unknown"), Difficulty: big.NewInt(1)})
+)
+
+// makeChain creates a chain of n blocks starting at and including parent.
+// The returned hash chain is ordered head->parent. Every third block carries
+// a transaction so that both header-only and body retrievals are exercised.
+func makeChain(n int, parent *types.Block) ([]common.Hash, map[common.Hash]*types.Block) {
+	hashes := make([]common.Hash, n+1)
+	hashes[len(hashes)-1] = parent.Hash()
+	blockm := make(map[common.Hash]*types.Block, n+1)
+	blockm[parent.Hash()] = parent
+
+	for i := 0; i < n; i++ {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number(), common.Big1),
+			Difficulty: big.NewInt(1),
+			Time:       parent.Time() + 1,
+		}
+		var body *types.Body
+		if i%3 == 0 {
+			body = &types.Body{Transactions: []*types.Transaction{testTxs[i%len(testTxs)]}}
+		}
+		block := types.NewBlock(header, body, nil, trie.NewStackTrie(nil))
+		hashes[len(hashes)-i-2] = block.Hash()
+		blockm[block.Hash()] = block
+		parent = block
+	}
+	return hashes, blockm
+}
+
+// fetcherTester is a test simulator for mocking out local block chain.
+type fetcherTester struct {
+	fetcher *BlockFetcher
+
+	hashes []common.Hash                // Hash chain belonging to the tester
+	blocks map[common.Hash]*types.Block // Blocks belonging to the tester
+	drops  map[string]bool              // Map of peers dropped by the fetcher
+
+	lock sync.RWMutex
+}
+
+// newTester creates a new fetcher test mocker.
+func newTester() *fetcherTester {
+	tester := &fetcherTester{
+		hashes: []common.Hash{genesis.Hash()},
+		blocks: map[common.Hash]*types.Block{genesis.Hash(): genesis},
+		drops:  make(map[string]bool),
+	}
+	tester.fetcher = NewBlockFetcher(tester.getBlock, tester.verifyHeader, tester.broadcastBlock, tester.chainHeight, tester.insertChain, tester.dropPeer)
+	tester.fetcher.Start()
+
+	return tester
+}
+
+// getBlock retrieves a block from the tester's block chain.
+func (f *fetcherTester) getBlock(hash common.Hash) *types.Block {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.blocks[hash]
+}
+
+// verifyHeader is a nop placeholder for the block header verification.
+func (f *fetcherTester) verifyHeader(header *types.Header) error {
+	return nil
+}
+
+// broadcastBlock is a nop placeholder for the block broadcasting.
+func (f *fetcherTester) broadcastBlock(block *types.Block, propagate bool) {
+}
+
+// chainHeight retrieves the current height (block number) of the chain.
+func (f *fetcherTester) chainHeight() uint64 {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.blocks[f.hashes[len(f.hashes)-1]].NumberU64()
+}
+
+// insertChain injects a new blocks into the simulated chain.
+func (f *fetcherTester) insertChain(blocks types.Blocks) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for i, block := range blocks {
+		// Make sure the parent in known
+		if _, ok := f.blocks[block.ParentHash()]; !ok {
+			return i, errors.New("unknown parent")
+		}
+		// Discard any new blocks if the same height already exists
+		if block.NumberU64() <= f.blocks[f.hashes[len(f.hashes)-1]].NumberU64() {
+			return i, nil
+		}
+		// Otherwise build our current chain
+		f.hashes = append(f.hashes, block.Hash())
+		f.blocks[block.Hash()] = block
+	}
+	return 0, nil
+}
+
+// dropPeer is an emulator for the peer removal, simply accumulating the various
+// peers dropped by the fetcher.
+func (f *fetcherTester) dropPeer(peer string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.drops[peer] = true
+}
+
+// dropped returns whether the given peer was dropped by the fetcher.
+func (f *fetcherTester) dropped(peer string) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.drops[peer]
+}
+
+// makeHeaderFetcher retrieves a block header fetcher associated with a simulated peer.
+func (f *fetcherTester) makeHeaderFetcher(blocks map[common.Hash]*types.Block, drift time.Duration) headerRequesterFn {
+	closure := make(map[common.Hash]*types.Block)
+	for hash, block := range blocks {
+		closure[hash] = block
+	}
+	// Create a function that return a header from the closure
+	return func(hash common.Hash, sink chan *eth.Response) (*eth.Request, error) {
+		// Gather the blocks to return
+		headers := make([]*types.Header, 0, 1)
+		if block, ok := closure[hash]; ok {
+			headers = append(headers, block.Header())
+		}
+		// Return on a new thread
+		req := &eth.Request{
+			Peer: "peer",
+		}
+		res := &eth.Response{
+			Req:  req,
+			Res:  (*eth.BlockHeadersRequest)(&headers),
+			Time: drift,
+			Done: make(chan error, 1), // Ignore the returned status
+		}
+		go func() {
+			sink <- res
+		}()
+		return req, nil
+	}
+}
+
+// makeBodyFetcher retrieves a block body fetcher associated with a simulated peer.
+func (f *fetcherTester) makeBodyFetcher(blocks map[common.Hash]*types.Block, drift time.Duration) bodyRequesterFn {
+	closure := make(map[common.Hash]*types.Block)
+	for hash, block := range blocks {
+		closure[hash] = block
+	}
+	// Create a function that returns blocks from the closure
+	return func(hashes []common.Hash, sink chan *eth.Response) (*eth.Request, error) {
+		// Gather the block bodies to return
+		bodies := make(eth.BlockBodiesResponse, 0, len(hashes))
+		for _, hash := range hashes {
+			if block, ok := closure[hash]; ok {
+				bodies = append(bodies, &eth.BlockBody{
+					Transactions: block.Transactions(),
+					Uncles:       block.Uncles(),
+				})
+			}
+		}
+		// Return on a new thread
+		req := &eth.Request{
+			Peer: "peer",
+		}
+		res := &eth.Response{
+			Req:  req,
+			Res:  &bodies,
+			Time: drift,
+			Done: make(chan error, 1), // Ignore the returned status
+		}
+		go func() {
+			sink <- res
+		}()
+		return req, nil
+	}
+}
+
+// verifyImportEvent verifies that one single event arrive on an import channel.
+func verifyImportEvent(t *testing.T, imported chan *types.Block, arrive bool) {
+	t.Helper()
+
+	if arrive {
+		select {
+		case <-imported:
+		case <-time.After(time.Second):
+			t.Fatalf("import timeout")
+		}
+	} else {
+		select {
+		case <-imported:
+			t.Fatalf("import invoked")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+// verifyImportCount verifies that exactly count number of events arrive on an
+// import hook channel.
+func verifyImportCount(t *testing.T, imported chan *types.Block, count int) {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		select {
+		case <-imported:
+		case <-time.After(time.Second):
+			t.Fatalf("block %d: import timeout", i+1)
+		}
+	}
+	verifyImportDone(t, imported)
+}
+
+// verifyImportDone verifies that no more events are arriving on an import channel.
+func verifyImportDone(t *testing.T, imported chan *types.Block) {
+	t.Helper()
+
+	select {
+	case <-imported:
+		t.Fatalf("extra block imported")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Tests that a fetcher accepts block announcements and initiates retrievals for
+// them, successfully importing into the local chain.
+func TestBlockFetcherSequentialAnnouncements(t *testing.T) {
+	// Create a chain of blocks to import
+	targetBlocks := 4 * hashLimit / 16
+	hashes, blocks := makeChain(targetBlocks, genesis)
+
+	tester := newTester()
+	defer tester.fetcher.Stop()
+
+	headerFetcher := tester.makeHeaderFetcher(blocks, -gatherSlack)
+	bodyFetcher := tester.makeBodyFetcher(blocks, 0)
+
+	// Iteratively announce blocks until all are imported
+	imported := make(chan *types.Block)
+	tester.fetcher.importedHook = func(block *types.Block) { imported <- block }
+
+	for i := len(hashes) - 2; i >= 0; i-- {
+		tester.fetcher.Notify("valid", hashes[i], uint64(len(hashes)-i-1), time.Now().Add(-arriveTimeout), headerFetcher, bodyFetcher)
+		verifyImportEvent(t, imported, true)
+	}
+	verifyImportDone(t, imported)
+}
+
+// Tests that blocks arriving from various sources (i.e. direct propagation)
+// get imported in order, even if they arrive out of order.
+func TestBlockFetcherRandomArrivalImport(t *testing.T) {
+	// Create a chain of blocks to import, and choose one to delay
+	targetBlocks := maxQueueDist
+	hashes, blocks := makeChain(targetBlocks, genesis)
+	skip := targetBlocks / 2
+
+	tester := newTester()
+	defer tester.fetcher.Stop()
+
+	// Iteratively enqueue blocks until all are imported
+	imported := make(chan *types.Block, len(hashes)-1)
+	tester.fetcher.importedHook = func(block *types.Block) { imported <- block }
+
+	for i := len(hashes) - 1; i >= 0; i-- {
+		if i != skip {
+			tester.fetcher.Enqueue("valid", blocks[hashes[i]])
+			time.Sleep(time.Millisecond)
+		}
+	}
+	// Finally deliver the gap and wait for all the blocks to be imported
+	tester.fetcher.Enqueue("valid", blocks[hashes[skip]])
+	verifyImportCount(t, imported, len(hashes)-1)
+}
+
+// Tests that announcements for blocks too far from the chain head are discarded.
+func TestBlockFetcherDistantAnnouncementDiscarding(t *testing.T) {
+	// Create a long chain to import and define the discard boundaries
+	hashes, blocks := makeChain(3*maxQueueDist, genesis)
+	head := hashes[len(hashes)/2]
+
+	low, high := len(hashes)/2+maxUncleDist+1, len(hashes)/2-maxQueueDist-1
+
+	// Create a tester with a simulated head block
+	tester := newTester()
+	defer tester.fetcher.Stop()
+
+	tester.lock.Lock()
+	tester.hashes = []common.Hash{head}
+	tester.blocks = map[common.Hash]*types.Block{head: blocks[head]}
+	tester.lock.Unlock()
+
+	headerFetcher := tester.makeHeaderFetcher(blocks, -gatherSlack)
+	bodyFetcher := tester.makeBodyFetcher(blocks, 0)
+
+	fetching := make(chan struct{}, 2)
+	tester.fetcher.fetchingHook = func(hashes []common.Hash) { fetching <- struct{}{} }
+
+	// Ensure that a block with a lower number than the threshold is discarded
+	tester.fetcher.Notify("lower", hashes[low], blocks[hashes[low]].NumberU64(), time.Now().Add(-arriveTimeout), headerFetcher, bodyFetcher)
+	select {
+	case <-time.After(50 * time.Millisecond):
+	case <-fetching:
+		t.Fatalf("fetcher requested stale header")
+	}
+	// Ensure that a block with a higher number than the threshold is discarded
+	tester.fetcher.Notify("higher", hashes[high], blocks[hashes[high]].NumberU64(), time.Now().Add(-arriveTimeout), headerFetcher, bodyFetcher)
+	select {
+	case <-time.After(50 * time.Millisecond):
+	case <-fetching:
+		t.Fatalf("fetcher requested future header")
+	}
+}
+
+// Tests that a peer announcing a block with a number different from the one it
+// delivers gets dropped.
+func TestBlockFetcherInvalidNumberAnnouncement(t *testing.T) {
+	// Create a single block to import and check numbers against
+	hashes, blocks := makeChain(1, genesis)
+
+	tester := newTester()
+	defer tester.fetcher.Stop()
+
+	badHeaderFetcher := tester.makeHeaderFetcher(blocks, -gatherSlack)
+	badBodyFetcher := tester.makeBodyFetcher(blocks, 0)
+
+	imported := make(chan *types.Block)
+	announced := make(chan interface{})
+	tester.fetcher.importedHook = func(block *types.Block) { imported <- block }
+
+	// Announce a block with a bad number, check for immediate drop
+	tester.fetcher.announceChangeHook = func(hash common.Hash, b bool) {
+		announced <- nil
+	}
+	tester.fetcher.Notify("bad", hashes[0], 2, time.Now().Add(-arriveTimeout), badHeaderFetcher, badBodyFetcher)
+	verifyAnnounce := func() {
+		for i := 0; i < 2; i++ {
+			select {
+			case <-announced:
+				continue
+			case <-time.After(1 * time.Second):
+				t.Fatal("announce timeout")
+				return
+			}
+		}
+	}
+	verifyAnnounce()
+	verifyImportEvent(t, imported, false)
+
+	// Wait for the header filter to process the delivery and drop the peer
+	for i := 0; i < 100 && !tester.dropped("bad"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !tester.dropped("bad") {
+		t.Fatalf("peer with invalid numbered announcement not dropped")
+	}
+	goodHeaderFetcher := tester.makeHeaderFetcher(blocks, -gatherSlack)
+	goodBodyFetcher := tester.makeBodyFetcher(blocks, 0)
+
+	// Make sure a good announcement passes without a drop
+	tester.fetcher.Notify("good", hashes[0], 1, time.Now().Add(-arriveTimeout), goodHeaderFetcher, goodBodyFetcher)
+	verifyAnnounce()
+	verifyImportEvent(t, imported, true)
+
+	if tester.dropped("good") {
+		t.Fatalf("peer with valid numbered announcement dropped")
+	}
+	verifyImportDone(t, imported)
+}
+
+// Tests that blocks whose parent is unknown are not imported.
+func TestBlockFetcherUnknownParent(t *testing.T) {
+	tester := newTester()
+	defer tester.fetcher.Stop()
+
+	imported := make(chan *types.Block)
+	tester.fetcher.importedHook = func(block *types.Block) { imported <- block }
+
+	orphan := types.NewBlockWithHeader(&types.Header{ParentHash: unknownBlock.Hash(), Number: big.NewInt(1), Difficulty: big.NewInt(1)})
+	tester.fetcher.Enqueue("valid", orphan)
+	verifyImportEvent(t, imported, false)
+}
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"maps"
 	"math"
+	"math/big"
 	"slices"
 	"sync"
 	"sync/atomic"
@@ -94,6 +95,14 @@ type txPool interface {
 	SubscribeTransactions(ch chan<- core.NewTxsEvent, reorgs bool) event.Subscription
 }
 
+// minedBlockSource is the subset of the miner needed to propagate locally sealed
+// blocks to the network.
+type minedBlockSource interface {
+	// SubscribeNewMinedBlockEvent subscribes to blocks sealed and imported by
+	// the local miner.
+	SubscribeNewMinedBlockEvent(ch chan<- core.NewMinedBlockEvent) event.Subscription
+}
+
 // handlerConfig is the collection of initialization parameters to create a full
 // node network handler.
 type handlerConfig struct {
@@ -106,6 +115,7 @@ type handlerConfig struct {
 	BloomCache     uint64                 // Megabytes to alloc for snap sync bloom
 	EventMux       *event.TypeMux         // Legacy event mux, deprecate for `feed`
 	RequiredBlocks map[uint64]common.Hash // Hard coded map of required block hashes for sync challenges
+	Miner          minedBlockSource       // Local miner to propagate sealed blocks from (proof-of-work only)
 }
 
 type handler struct {
@@ -121,14 +131,19 @@ type handler struct {
 	maxPeers int
 
 	downloader     *downloader.Downloader
+	blockFetcher   *fetcher.BlockFetcher
 	txFetcher      *fetcher.TxFetcher
 	peers          *peerSet
+	chainSync      *chainSyncer
 	txBroadcastKey [16]byte
 
-	eventMux   *event.TypeMux
-	txsCh      chan core.NewTxsEvent
-	txsSub     event.Subscription
-	blockRange *blockRangeState
+	eventMux      *event.TypeMux
+	txsCh         chan core.NewTxsEvent
+	txsSub        event.Subscription
+	miner         minedBlockSource
+	minedBlockCh  chan core.NewMinedBlockEvent
+	minedBlockSub event.Subscription
+	blockRange    *blockRangeState
 
 	requiredBlocks map[uint64]common.Hash
 
@@ -157,6 +172,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		peers:          newPeerSet(),
 		txBroadcastKey: newBroadcastChoiceKey(),
 		requiredBlocks: config.RequiredBlocks,
+		miner:          config.Miner,
 		quitSync:       make(chan struct{}),
 		handlerDoneCh:  make(chan struct{}),
 		handlerStartCh: make(chan struct{}),
@@ -195,6 +211,13 @@ func newHandler(config *handlerConfig) (*handler, error) {
 	// Construct the downloader (long sync)
 	h.downloader = downloader.New(config.Database, h.eventMux, h.chain, h.removePeer, h.enableSyncedFeatures)
 
+	// Proof-of-work networks have no consensus client feeding them new heads,
+	// so freshly sealed blocks need to be propagated between the peers.
+	if h.chain.Config().RandomX != nil {
+		h.blockFetcher = h.newBlockFetcher()
+		h.chainSync = newChainSyncer(h)
+	}
+
 	fetchTx := func(peer string, hashes []common.Hash) error {
 		p := h.peers.peer(peer)
 		if p == nil {
@@ -209,6 +232,34 @@ func newHandler(config *handlerConfig) (*handler, error) {
 	return h, nil
 }
 
+// newBlockFetcher constructs the announcement based block fetcher used to
+// import blocks propagated by remote peers.
+func (h *handler) newBlockFetcher() *fetcher.BlockFetcher {
+	validator := func(header *types.Header) error {
+		return h.chain.Engine().VerifyHeader(h.chain, header)
+	}
+	heighter := func() uint64 {
+		return h.chain.CurrentBlock().Number.Uint64()
+	}
+	inserter := func(blocks types.Blocks) (int, error) {
+		// If snap sync is running, deny importing weird blocks. This is a problematic
+		// clause when starting up a new network, because snap-syncing miners might not
+		// accept each others' blocks until a restart. Unfortunately we haven't figured
+		// out a way yet where nodes can decide unilaterally whether the network is new
+		// or not. This should be fixed if we figure out a solution.
+		if h.snapSync.Load() {
+			log.Warn("Snap syncing, discarded propagated block", "number", blocks[0].Number(), "hash", blocks[0].Hash())
+			return 0, nil
+		}
+		n, err := h.chain.InsertChain(blocks)
+		if err == nil {
+			h.enableSyncedFeatures() // Mark initial sync done on any fetcher import
+		}
+		return n, err
+	}
+	return fetcher.NewBlockFetcher(h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, inserter, h.removePeer)
+}
+
 // protoTracker tracks the number of active protocol handlers.
 func (h *handler) protoTracker() {
 	defer h.wg.Done()
@@ -261,8 +312,11 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 		return err
 	}
 
-	// Execute the Ethereum handshake
-	if err := peer.Handshake(h.networkID, h.chain, h.blockRange.currentRange()); err != nil {
+	// Execute the Ethereum handshake, advertising our total difficulty on
+	// proof-of-work networks
+	head := h.chain.CurrentHeader()
+	td := h.chain.GetTd(head.Hash(), head.Number.Uint64())
+	if err := peer.Handshake(h.networkID, h.chain, td, h.blockRange.currentRange()); err != nil {
 		peer.Log().Debug("Ethereum handshake failed", "err", err)
 		return err
 	}
@@ -307,6 +361,8 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 			return err
 		}
 	}
+	h.chainSync.handlePeerEvent()
+
 	// Propagate existing transactions. new transactions appearing
 	// after this will be sent via broadcasts.
 	h.syncTransactions(peer)
@@ -440,6 +496,21 @@ func (h *handler) Start(maxPeers int) {
 	h.blockRange = newBlockRangeState(h.chain, h.eventMux)
 	go h.blockRangeLoop(h.blockRange)
 
+	// broadcast mined blocks and start the block fetcher on proof-of-work networks
+	if h.blockFetcher != nil {
+		if h.miner != nil {
+			h.wg.Add(1)
+			h.minedBlockCh = make(chan core.NewMinedBlockEvent, chainHeadChanSize)
+			h.minedBlockSub = h.miner.SubscribeNewMinedBlockEvent(h.minedBlockCh)
+			go h.minedBroadcastLoop()
+		}
+		h.blockFetcher.Start()
+
+		// start the total difficulty driven chain sync
+		h.wg.Add(1)
+		go h.chainSync.loop()
+	}
+
 	// start sync handlers
 	h.txFetcher.Start()
 
@@ -450,7 +521,13 @@ func (h *handler) Start(maxPeers int) {
 
 func (h *handler) Stop() {
 	h.txsSub.Unsubscribe() // quits txBroadcastLoop
+	if h.minedBlockSub != nil {
+		h.minedBlockSub.Unsubscribe() // quits minedBroadcastLoop
+	}
 	h.blockRange.stop()
+	if h.blockFetcher != nil {
+		h.blockFetcher.Stop()
+	}
 	h.txFetcher.Stop()
 	h.downloader.Terminate()
 
@@ -468,6 +545,40 @@ func (h *handler) Stop() {
 	log.Info("Ethereum protocol stopped")
 }
 
+// BroadcastBlock will either propagate a block to a subset of its peers, or
+// will only announce its availability (depending what's requested).
+func (h *handler) BroadcastBlock(block *types.Block, propagate bool) {
+	hash := block.Hash()
+	peers := h.peers.peersWithoutBlock(hash)
+
+	// If propagation is requested, send to a subset of the peer
+	if propagate {
+		// Calculate the TD of the block (it's not imported yet, so block.Td is not valid)
+		var td *big.Int
+		if parent := h.chain.GetTd(block.ParentHash(), block.NumberU64()-1); parent != nil {
+			td = new(big.Int).Add(block.Difficulty(), parent)
+		} else {
+			log.Error("Propagating dangling block", "number", block.Number(), "hash", hash)
+			return
+		}
+
+		// Send the block to a subset of our peers
+		transfer := peers[:int(math.Sqrt(float64(len(peers))))]
+		for _, peer := range transfer {
+			peer.AsyncSendNewBlock(block, td)
+		}
+		log.Trace("Propagated block", "hash", hash, "recipients", len(transfer), "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
+		return
+	}
+	// Otherwise if the block is indeed in our own chain, announce it
+	if h.chain.HasBlock(hash, block.NumberU64()) {
+		for _, peer := range peers {
+			peer.AsyncSendNewBlockHash(block)
+		}
+		log.Trace("Announced block", "hash", hash, "recipients", len(peers), "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
+	}
+}
+
 // BroadcastTransactions will propagate a batch of transactions
 // - To a square root of all peers for non-blob transactions
 // - And, separately, as announcements to all peers which are not known to
@@ -541,6 +652,20 @@ func (h *handler) txBroadcastLoop() {
 	}
 }
 
+// minedBroadcastLoop sends mined blocks to connected peers.
+func (h *handler) minedBroadcastLoop() {
+	defer h.wg.Done()
+	for {
+		select {
+		case ev := <-h.minedBlockCh:
+			h.BroadcastBlock(ev.Block, true)  // First propagate block to peers
+			h.BroadcastBlock(ev.Block, false) // Only then announce to the rest
+		case <-h.minedBlockSub.Err():
+			return
+		}
+	}
+}
+
 // enableSyncedFeatures enables the post-sync functionalities when the initial
 // sync is finished.
 func (h *handler) enableSyncedFeatures() {
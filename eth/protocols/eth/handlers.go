@@ -343,11 +343,51 @@ func serviceGetReceiptsQuery69(chain *core.BlockChain, query GetReceiptsRequest)
 }
 
 func handleNewBlockhashes(backend Backend, msg Decoder, peer *Peer) error {
-	return errors.New("block announcements disallowed") // We dropped support for non-merge networks
+	// Block announcements are only meaningful on proof-of-work networks, where
+	// there is no consensus client handing us the chain head
+	if backend.Chain().Config().RandomX == nil {
+		return errors.New("block announcements disallowed") // We dropped support for non-merge networks
+	}
+	// A batch of new block announcements just arrived
+	ann := new(NewBlockHashesPacket)
+	if err := msg.Decode(ann); err != nil {
+		return err
+	}
+	// Mark the hashes as present at the remote node
+	for _, block := range *ann {
+		peer.markBlock(block.Hash)
+	}
+	// Deliver them all to the backend for queuing
+	return backend.Handle(peer, ann)
 }
 
 func handleNewBlock(backend Backend, msg Decoder, peer *Peer) error {
-	return errors.New("block broadcasts disallowed") // We dropped support for non-merge networks
+	// Block propagation is only meaningful on proof-of-work networks, where
+	// there is no consensus client handing us the chain head
+	if backend.Chain().Config().RandomX == nil {
+		return errors.New("block broadcasts disallowed") // We dropped support for non-merge networks
+	}
+	// Retrieve and decode the propagated block
+	ann := new(NewBlockPacket)
+	if err := msg.Decode(ann); err != nil {
+		return err
+	}
+	if err := ann.sanityCheck(); err != nil {
+		return err
+	}
+	if hash := types.CalcUncleHash(ann.Block.Uncles()); hash != ann.Block.UncleHash() {
+		return fmt.Errorf("NewBlock: invalid uncle hash: have %x, want %x", hash, ann.Block.UncleHash())
+	}
+	if hash := types.DeriveSha(ann.Block.Transactions(), trie.NewStackTrie(nil)); hash != ann.Block.TxHash() {
+		return fmt.Errorf("NewBlock: invalid transaction root: have %x, want %x", hash, ann.Block.TxHash())
+	}
+	ann.Block.ReceivedAt = msg.Time()
+	ann.Block.ReceivedFrom = peer
+
+	// Mark the peer as owning the block
+	peer.markBlock(ann.Block.Hash())
+
+	return backend.Handle(peer, ann)
 }
 
 func handleBlockHeaders(backend Backend, msg Decoder, peer *Peer) error {
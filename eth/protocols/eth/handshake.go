@@ -19,6 +19,7 @@ package eth
 import (
 	"errors"
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -35,18 +36,22 @@ const (
 
 // Handshake executes the eth protocol handshake, negotiating version number,
 // network IDs, difficulties, head and genesis blocks.
-func (p *Peer) Handshake(networkID uint64, chain forkid.Blockchain, rangeMsg BlockRangeUpdatePacket) error {
+//
+// The total difficulty of the local head is only advertised on proof-of-work
+// networks, where it drives peer selection for chain synchronisation. It must
+// be nil otherwise.
+func (p *Peer) Handshake(networkID uint64, chain forkid.Blockchain, td *big.Int, rangeMsg BlockRangeUpdatePacket) error {
 	switch p.version {
 	case ETH69:
-		return p.handshake69(networkID, chain, rangeMsg)
+		return p.handshake69(networkID, chain, td, rangeMsg)
 	case ETH68:
-		return p.handshake68(networkID, chain)
+		return p.handshake68(networkID, chain, td)
 	default:
 		return errors.New("unsupported protocol version")
 	}
 }
 
-func (p *Peer) handshake68(networkID uint64, chain forkid.Blockchain) error {
+func (p *Peer) handshake68(networkID uint64, chain forkid.Blockchain, td *big.Int) error {
 	var (
 		genesis    = chain.Genesis()
 		latest     = chain.CurrentHeader()
@@ -58,6 +63,7 @@ func (p *Peer) handshake68(networkID uint64, chain forkid.Blockchain) error {
 		pkt := &StatusPacket68{
 			ProtocolVersion: uint32(p.version),
 			NetworkID:       networkID,
+			TD:              td,
 			Head:            latest.Hash(),
 			Genesis:         genesis.Hash(),
 			ForkID:          forkID,
@@ -88,10 +94,14 @@ func (p *Peer) readStatus68(networkID uint64, status *StatusPacket68, genesis co
 	if err := forkFilter(status.ForkID); err != nil {
 		return fmt.Errorf("%w: %v", errForkIDRejected, err)
 	}
+	if status.TD != nil && status.TD.BitLen() > 100 {
+		return fmt.Errorf("%w: td %v too large", errInvalidTD, status.TD)
+	}
+	p.SetHead(status.Head, status.TD)
 	return nil
 }
 
-func (p *Peer) handshake69(networkID uint64, chain forkid.Blockchain, rangeMsg BlockRangeUpdatePacket) error {
+func (p *Peer) handshake69(networkID uint64, chain forkid.Blockchain, td *big.Int, rangeMsg BlockRangeUpdatePacket) error {
 	var (
 		genesis    = chain.Genesis()
 		latest     = chain.CurrentHeader()
@@ -109,6 +119,7 @@ func (p *Peer) handshake69(networkID uint64, chain forkid.Blockchain, rangeMsg B
 			EarliestBlock:   rangeMsg.EarliestBlock,
 			LatestBlock:     rangeMsg.LatestBlock,
 			LatestBlockHash: rangeMsg.LatestBlockHash,
+			TD:              td,
 		}
 		errc <- p2p.Send(p.rw, StatusMsg, pkt)
 	}()
@@ -145,7 +156,11 @@ func (p *Peer) readStatus69(networkID uint64, status *StatusPacket69, genesis co
 	if err := initRange.Validate(); err != nil {
 		return fmt.Errorf("%w: %v", errInvalidBlockRange, err)
 	}
+	if status.TD != nil && status.TD.BitLen() > 100 {
+		return fmt.Errorf("%w: td %v too large", errInvalidTD, status.TD)
+	}
 	p.lastRange.Store(initRange)
+	p.SetHead(status.LatestBlockHash, status.TD)
 	return nil
 }
 
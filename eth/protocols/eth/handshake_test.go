@@ -80,7 +80,7 @@ func testHandshake(t *testing.T, protocol uint) {
 		// Send the junk test with one peer, check the handshake failure
 		go p2p.Send(app, test.code, test.data)
 
-		err := peer.Handshake(1, backend.chain, BlockRangeUpdatePacket{})
+		err := peer.Handshake(1, backend.chain, nil, BlockRangeUpdatePacket{})
 		if err == nil {
 			t.Errorf("test %d: protocol returned nil error, want %q", i, test.want)
 		} else if !errors.Is(err, test.want) {
@@ -88,3 +88,42 @@ func testHandshake(t *testing.T, protocol uint) {
 		}
 	}
 }
+
+// Tests that the head total difficulty is exchanged during the handshake.
+func TestHandshakeTD68(t *testing.T) { testHandshakeTD(t, ETH68) }
+func TestHandshakeTD69(t *testing.T) { testHandshakeTD(t, ETH69) }
+
+func testHandshakeTD(t *testing.T, protocol uint) {
+	t.Parallel()
+
+	backend := newTestBackend(3)
+	defer backend.close()
+
+	app, net := p2p.MsgPipe()
+	defer app.Close()
+	defer net.Close()
+
+	peer1 := NewPeer(protocol, p2p.NewPeer(enode.ID{1}, "peer1", nil), app, nil)
+	defer peer1.Close()
+	peer2 := NewPeer(protocol, p2p.NewPeer(enode.ID{2}, "peer2", nil), net, nil)
+	defer peer2.Close()
+
+	var (
+		head    = backend.chain.CurrentBlock()
+		headMsg = BlockRangeUpdatePacket{LatestBlock: head.Number.Uint64(), LatestBlockHash: head.Hash()}
+		errc    = make(chan error, 2)
+	)
+	go func() { errc <- peer1.Handshake(1, backend.chain, big.NewInt(100), headMsg) }()
+	go func() { errc <- peer2.Handshake(1, backend.chain, nil, headMsg) }()
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != nil {
+			t.Fatalf("handshake failed: %v", err)
+		}
+	}
+	if hash, td := peer2.Head(); hash != head.Hash() || td == nil || td.Int64() != 100 {
+		t.Errorf("remote head mismatch: have %x/%v, want %x/%v", hash, td, head.Hash(), 100)
+	}
+	if hash, td := peer1.Head(); hash != head.Hash() || (td != nil && td.Sign() != 0) {
+		t.Errorf("remote head mismatch: have %x/%v, want %x/<nil>", hash, td, head.Hash())
+	}
+}
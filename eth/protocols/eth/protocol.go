@@ -76,6 +76,7 @@ var (
 	errGenesisMismatch   = errors.New("genesis mismatch")
 	errForkIDRejected    = errors.New("fork ID rejected")
 	errInvalidBlockRange = errors.New("invalid block range in status")
+	errInvalidTD         = errors.New("invalid total difficulty in status")
 )
 
 // Packet represents a p2p message in the `eth` protocol.
@@ -104,6 +105,8 @@ type StatusPacket69 struct {
 	EarliestBlock   uint64
 	LatestBlock     uint64
 	LatestBlockHash common.Hash
+	// total difficulty of the latest block, only sent on proof-of-work networks
+	TD *big.Int `rlp:"optional"`
 }
 
 // NewBlockHashesPacket is the network packet for the block announcements.
@@ -204,6 +207,19 @@ type NewBlockPacket struct {
 	TD    *big.Int
 }
 
+// sanityCheck verifies that the values are reasonable, as a DoS protection
+func (request *NewBlockPacket) sanityCheck() error {
+	if err := request.Block.SanityCheck(); err != nil {
+		return err
+	}
+	// TD at mainnet block #7753254 is 76 bits. If it becomes 100 million times
+	// larger, it will still fit within 100 bits
+	if tdlen := request.TD.BitLen(); tdlen > 100 {
+		return fmt.Errorf("too large block TD: bitlen %d", tdlen)
+	}
+	return nil
+}
+
 // GetBlockBodiesRequest represents a block body query.
 type GetBlockBodiesRequest []common.Hash
 
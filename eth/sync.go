@@ -17,9 +17,20 @@
 package eth
 
 import (
+	"math/big"
+	"time"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	forceSyncCycle      = 10 * time.Second // Time interval to force syncs, even if few peers are available
+	defaultMinSyncPeers = 5                // Amount of peers desired to start syncing
 )
 
 // syncTransactions starts sending all currently pending transactions to the given peer.
@@ -35,3 +46,176 @@ func (h *handler) syncTransactions(p *eth.Peer) {
 	}
 	p.AsyncSendPooledTransactionHashes(hashes)
 }
+
+// chainSyncer coordinates blockchain sync components on proof-of-work networks,
+// where there is no consensus client to drive the sync. The sync target is the
+// head of the peer advertising the highest total difficulty.
+type chainSyncer struct {
+	handler     *handler
+	force       *time.Timer
+	forced      bool // true when force timer fired
+	peerEventCh chan struct{}
+	doneCh      chan error // non-nil when sync is running
+}
+
+// chainSyncOp is a scheduled sync operation.
+type chainSyncOp struct {
+	mode ethconfig.SyncMode
+	peer *eth.Peer
+	td   *big.Int
+	head common.Hash
+}
+
+// newChainSyncer creates a chainSyncer.
+func newChainSyncer(handler *handler) *chainSyncer {
+	return &chainSyncer{
+		handler:     handler,
+		peerEventCh: make(chan struct{}),
+	}
+}
+
+// handlePeerEvent notifies the syncer about a change in the peer set.
+// This is called for new peers and every time a peer announces a new
+// chain head.
+func (cs *chainSyncer) handlePeerEvent() bool {
+	if cs == nil {
+		return false // not a proof-of-work network
+	}
+	select {
+	case cs.peerEventCh <- struct{}{}:
+		return true
+	case <-cs.handler.quitSync:
+		return false
+	}
+}
+
+// loop runs in its own goroutine and launches the sync when necessary.
+func (cs *chainSyncer) loop() {
+	defer cs.handler.wg.Done()
+
+	// The force timer lowers the peer count threshold down to one when it fires.
+	// This ensures we'll always start sync even if there aren't enough peers.
+	cs.force = time.NewTimer(forceSyncCycle)
+	defer cs.force.Stop()
+
+	for {
+		if op := cs.nextSyncOp(); op != nil {
+			cs.startSync(op)
+		}
+		select {
+		case <-cs.peerEventCh:
+			// Peer information changed, recheck.
+		case <-cs.doneCh:
+			cs.doneCh = nil
+			cs.force.Reset(forceSyncCycle)
+			cs.forced = false
+
+		case <-cs.force.C:
+			cs.forced = true
+
+		case <-cs.handler.quitSync:
+			// Abort any running sync cycle and wait for it to return
+			cs.handler.downloader.Terminate()
+			if cs.doneCh != nil {
+				<-cs.doneCh
+			}
+			return
+		}
+	}
+}
+
+// nextSyncOp determines whether sync is required at this time.
+func (cs *chainSyncer) nextSyncOp() *chainSyncOp {
+	if cs.doneCh != nil {
+		return nil // Sync already running
+	}
+	// Ensure we're at minimum peer count.
+	minPeers := defaultMinSyncPeers
+	if cs.forced {
+		minPeers = 1
+	} else if minPeers > cs.handler.maxPeers {
+		minPeers = cs.handler.maxPeers
+	}
+	if cs.handler.peers.len() < minPeers {
+		return nil
+	}
+	// We have enough peers, pick the one with the highest TD
+	peer := cs.handler.peers.peerWithHighestTD()
+	if peer == nil {
+		return nil
+	}
+	mode, ourTD := cs.modeAndLocalHead()
+	op := peerToSyncOp(mode, peer)
+	if ourTD != nil && op.td.Cmp(ourTD) <= 0 {
+		return nil // We're in sync
+	}
+	return op
+}
+
+func peerToSyncOp(mode ethconfig.SyncMode, p *eth.Peer) *chainSyncOp {
+	peerHead, peerTD := p.Head()
+	return &chainSyncOp{mode: mode, peer: p, td: peerTD, head: peerHead}
+}
+
+// modeAndLocalHead returns the sync mode to use and the total difficulty of
+// the local head it would sync from.
+func (cs *chainSyncer) modeAndLocalHead() (ethconfig.SyncMode, *big.Int) {
+	// If we're in snap sync mode, return that directly
+	if cs.handler.snapSync.Load() {
+		block := cs.handler.chain.CurrentSnapBlock()
+		td := cs.handler.chain.GetTd(block.Hash(), block.Number.Uint64())
+		return ethconfig.SnapSync, td
+	}
+	// We are probably in full sync, but we might have rewound to before the
+	// snap sync pivot, check if we should re-enable snap sync.
+	head := cs.handler.chain.CurrentBlock()
+	if pivot := rawdb.ReadLastPivotNumber(cs.handler.database); pivot != nil {
+		if head.Number.Uint64() < *pivot {
+			block := cs.handler.chain.CurrentSnapBlock()
+			td := cs.handler.chain.GetTd(block.Hash(), block.Number.Uint64())
+			return ethconfig.SnapSync, td
+		}
+	}
+	// We are in a full sync, but the associated head state is missing. To complete
+	// the head state, forcefully rerun the snap sync. Note it doesn't mean the
+	// persistent state is corrupted, just mismatch with the head block.
+	if !cs.handler.chain.HasState(head.Root) {
+		block := cs.handler.chain.CurrentSnapBlock()
+		td := cs.handler.chain.GetTd(block.Hash(), block.Number.Uint64())
+		log.Info("Reenabled snap sync as chain is stateless")
+		return ethconfig.SnapSync, td
+	}
+	// Nope, we're really full syncing
+	td := cs.handler.chain.GetTd(head.Hash(), head.Number.Uint64())
+	return ethconfig.FullSync, td
+}
+
+// startSync launches doSync in a new goroutine.
+func (cs *chainSyncer) startSync(op *chainSyncOp) {
+	cs.doneCh = make(chan error, 1)
+	go func() { cs.doneCh <- cs.handler.doSync(op) }()
+}
+
+// doSync synchronizes the local blockchain with a remote peer.
+func (h *handler) doSync(op *chainSyncOp) error {
+	// Run the sync cycle, and disable snap sync if we're past the pivot block
+	err := h.downloader.PoWSync(op.peer.ID(), op.head, op.td, op.mode)
+	if err != nil {
+		return err
+	}
+	h.enableSyncedFeatures()
+
+	head := h.chain.CurrentBlock()
+	if head.Number.Uint64() > 0 {
+		// We've completed a sync cycle, notify all peers of new state. This path is
+		// essential in star-topology networks where a gateway node needs to notify
+		// all its out-of-date peers of the availability of a new block. This failure
+		// scenario will most often crop up in private and hackathon networks with
+		// degenerate connectivity, but it should be healthy for the mainnet too to
+		// more reliably update peers or the local TD state.
+		if block := h.chain.GetBlock(head.Hash(), head.Number.Uint64()); block != nil {
+			h.BroadcastBlock(block, false)
+		}
+	}
+	return nil
+}
@@ -17,14 +17,20 @@
 package eth
 
 import (
+	"math/big"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/consensus/randomx"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 // Tests that snap sync is disabled after a successful sync cycle.
@@ -102,3 +108,115 @@ func testSnapSyncDisabling(t *testing.T, ethVer uint, snapVer uint) {
 		}
 	}
 }
+
+// newTestPoWHandler creates a full syncing handler on a RandomX chain, with the
+// given blocks already imported.
+func newTestPoWHandler(t *testing.T, gspec *core.Genesis, blocks []*types.Block, maxPeers int) *testHandler {
+	engine := randomx.New(&randomx.Config{PowMode: randomx.ModeFake})
+	t.Cleanup(func() { engine.Close() })
+
+	db := rawdb.NewMemoryDatabase()
+	chain, err := core.NewBlockChain(db, gspec, engine, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to import: %v", n, err)
+	}
+	txpool := newTestTxPool()
+
+	handler, err := newHandler(&handlerConfig{
+		Database:   db,
+		Chain:      chain,
+		TxPool:     txpool,
+		Network:    1,
+		Sync:       ethconfig.FullSync,
+		BloomCache: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.Start(maxPeers)
+
+	return &testHandler{
+		db:      db,
+		chain:   chain,
+		txpool:  txpool,
+		handler: handler,
+	}
+}
+
+// Tests that proof-of-work chains are synchronised from the peer announcing the
+// highest total difficulty, not from the one with the highest block number.
+func TestPoWSyncHighestTD68(t *testing.T) { testPoWSyncHighestTD(t, eth.ETH68) }
+
+func testPoWSyncHighestTD(t *testing.T, ethVer uint) {
+	t.Parallel()
+
+	config := *params.AllEthashProtocolChanges
+	config.TerminalTotalDifficulty = nil
+	config.Ethash = nil
+	config.RandomX = &params.RandomXConfig{LWMAActivationBlock: big.NewInt(1 << 20)}
+	gspec := &core.Genesis{
+		Config:     &config,
+		Alloc:      types.GenesisAlloc{testAddr: {Balance: big.NewInt(1000000)}},
+		Difficulty: big.NewInt(131072),
+		BaseFee:    big.NewInt(params.InitialBaseFee),
+	}
+	// Create a long chain of slow blocks and a short chain of fast ones: the
+	// difficulty drops along the former, so the latter ends up heavier.
+	engine := randomx.New(&randomx.Config{PowMode: randomx.ModeFake})
+	defer engine.Close()
+
+	_, long, _ := core.GenerateChainWithGenesis(gspec, engine, 60, func(i int, block *core.BlockGen) {
+		block.OffsetTime(1000)
+	})
+	_, short, _ := core.GenerateChainWithGenesis(gspec, engine, 30, nil)
+
+	local := newTestPoWHandler(t, gspec, nil, 2)
+	defer local.close()
+	light := newTestPoWHandler(t, gspec, long, 1000)
+	defer light.close()
+	heavy := newTestPoWHandler(t, gspec, short, 1000)
+	defer heavy.close()
+
+	var (
+		lightHead = light.chain.CurrentBlock()
+		heavyHead = heavy.chain.CurrentBlock()
+		lightTd   = light.chain.GetTd(lightHead.Hash(), lightHead.Number.Uint64())
+		heavyTd   = heavy.chain.GetTd(heavyHead.Hash(), heavyHead.Number.Uint64())
+	)
+	if lightHead.Number.Cmp(heavyHead.Number) <= 0 || lightTd.Cmp(heavyTd) >= 0 {
+		t.Fatalf("invalid fixture: light chain #%d td %v, heavy chain #%d td %v", lightHead.Number, lightTd, heavyHead.Number, heavyTd)
+	}
+	// Connect both remote peers, the sync only starts once both are known
+	caps := []p2p.Cap{{Name: "eth", Version: ethVer}}
+	for i, remote := range []*testHandler{light, heavy} {
+		localPipe, remotePipe := p2p.MsgPipe()
+		defer localPipe.Close()
+		defer remotePipe.Close()
+
+		localPeer := eth.NewPeer(ethVer, p2p.NewPeer(enode.ID{byte(i + 1)}, "", caps), localPipe, local.txpool)
+		remotePeer := eth.NewPeer(ethVer, p2p.NewPeer(enode.ID{0xff}, "", caps), remotePipe, remote.txpool)
+		defer localPeer.Close()
+		defer remotePeer.Close()
+
+		go local.handler.runEthPeer(localPeer, func(peer *eth.Peer) error {
+			return eth.Handle((*ethHandler)(local.handler), peer)
+		})
+		go remote.handler.runEthPeer(remotePeer, func(peer *eth.Peer) error {
+			return eth.Handle((*ethHandler)(remote.handler), peer)
+		})
+	}
+	for timeout := time.After(5 * time.Second); ; {
+		select {
+		case <-timeout:
+			head := local.chain.CurrentBlock()
+			t.Fatalf("local chain not synced to heaviest peer: head #%d %x, want #%d %x", head.Number, head.Hash(), heavyHead.Number, heavyHead.Hash())
+		case <-time.After(100 * time.Millisecond):
+			if local.chain.CurrentBlock().Hash() == heavyHead.Hash() {
+				return
+			}
+		}
+	}
+}
@@ -478,7 +478,7 @@ func decodeHash(s string) (h common.Hash, inputLength int, err error) {
 func (api *BlockChainAPI) GetHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (map[string]interface{}, error) {
 	header, err := api.b.HeaderByNumber(ctx, number)
 	if header != nil && err == nil {
-		response := RPCMarshalHeader(header)
+		response := api.rpcMarshalHeader(ctx, header)
 		if number == rpc.PendingBlockNumber {
 			// Pending header need to nil out a few fields
 			for _, field := range []string{"hash", "nonce", "miner"} {
@@ -494,7 +494,7 @@ func (api *BlockChainAPI) GetHeaderByNumber(ctx context.Context, number rpc.Bloc
 func (api *BlockChainAPI) GetHeaderByHash(ctx context.Context, hash common.Hash) map[string]interface{} {
 	header, _ := api.b.HeaderByHash(ctx, hash)
 	if header != nil {
-		return RPCMarshalHeader(header)
+		return api.rpcMarshalHeader(ctx, header)
 	}
 	return nil
 }
@@ -509,7 +509,7 @@ func (api *BlockChainAPI) GetHeaderByHash(ctx context.Context, hash common.Hash)
 func (api *BlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
 	block, err := api.b.BlockByNumber(ctx, number)
 	if block != nil && err == nil {
-		response := RPCMarshalBlock(block, true, fullTx, api.b.ChainConfig())
+		response := api.rpcMarshalBlock(ctx, block, true, fullTx)
 		if number == rpc.PendingBlockNumber {
 			// Pending blocks need to nil out a few fields
 			for _, field := range []string{"hash", "nonce", "miner"} {
@@ -526,7 +526,7 @@ func (api *BlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.Block
 func (api *BlockChainAPI) GetBlockByHash(ctx context.Context, hash common.Hash, fullTx bool) (map[string]interface{}, error) {
 	block, err := api.b.BlockByHash(ctx, hash)
 	if block != nil {
-		return RPCMarshalBlock(block, true, fullTx, api.b.ChainConfig()), nil
+		return api.rpcMarshalBlock(ctx, block, true, fullTx), nil
 	}
 	return nil, err
 }
@@ -988,6 +988,26 @@ func RPCMarshalBlock(block *types.Block, inclTx bool, fullTx bool, config *param
 	return fields
 }
 
+// rpcMarshalHeader uses the generalized output filler, then adds the total
+// difficulty field on chains that track it (proof-of-work chains).
+func (api *BlockChainAPI) rpcMarshalHeader(ctx context.Context, header *types.Header) map[string]interface{} {
+	fields := RPCMarshalHeader(header)
+	if td := api.b.GetTd(ctx, header.Hash()); td != nil {
+		fields["totalDifficulty"] = (*hexutil.Big)(td)
+	}
+	return fields
+}
+
+// rpcMarshalBlock uses the generalized output filler, then adds the total
+// difficulty field on chains that track it (proof-of-work chains).
+func (api *BlockChainAPI) rpcMarshalBlock(ctx context.Context, b *types.Block, inclTx bool, fullTx bool) map[string]interface{} {
+	fields := RPCMarshalBlock(b, inclTx, fullTx, api.b.ChainConfig())
+	if td := api.b.GetTd(ctx, b.Hash()); td != nil {
+		fields["totalDifficulty"] = (*hexutil.Big)(td)
+	}
+	return fields
+}
+
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
 type RPCTransaction struct {
 	BlockHash           *common.Hash                 `json:"blockHash"`
@@ -525,7 +525,13 @@ func (b testBackend) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc
 }
 
 func (b testBackend) CurrentHeader() *types.Header { return b.chain.CurrentHeader() }
-func (b testBackend) CurrentBlock() *types.Header  { return b.chain.CurrentBlock() }
+func (b testBackend) GetTd(ctx context.Context, hash common.Hash) *big.Int {
+	if header := b.chain.GetHeaderByHash(hash); header != nil {
+		return b.chain.GetTd(hash, header.Number.Uint64())
+	}
+	return nil
+}
+func (b testBackend) CurrentBlock() *types.Header { return b.chain.CurrentBlock() }
 func (b testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
 	if number == rpc.LatestBlockNumber {
 		head := b.chain.CurrentBlock()
@@ -319,8 +319,9 @@ func (b *backendMock) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
 }
 func (b *backendMock) BlobBaseFee(ctx context.Context) *big.Int { return big.NewInt(42) }
 
-func (b *backendMock) CurrentHeader() *types.Header     { return b.current }
-func (b *backendMock) ChainConfig() *params.ChainConfig { return b.config }
+func (b *backendMock) CurrentHeader() *types.Header                         { return b.current }
+func (b *backendMock) GetTd(ctx context.Context, hash common.Hash) *big.Int { return nil }
+func (b *backendMock) ChainConfig() *params.ChainConfig                     { return b.config }
 
 // Other methods needed to implement Backend interface.
 func (b *backendMock) SyncProgress(ctx context.Context) ethereum.SyncProgress {
@@ -18,6 +18,7 @@
 package miner
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 	"sync"
@@ -30,10 +31,42 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
 )
 
+var (
+	miningGauge        = metrics.NewRegisteredGauge("miner/mining", nil)
+	hashrateGauge      = metrics.NewRegisteredGauge("miner/hashrate", nil)
+	minedBlocksCounter = metrics.NewRegisteredCounter("miner/blocks/mined", nil)
+	lostBlocksCounter  = metrics.NewRegisteredCounter("miner/blocks/lost", nil) // Sealed but failed to import
+)
+
+const (
+	// hashrateRefreshInterval is how often the hash rate metric is refreshed
+	// while mining.
+	hashrateRefreshInterval = 5 * time.Second
+
+	// templateResultsSize is the number of blocks sealed from work templates
+	// that can be queued for import.
+	templateResultsSize = 16
+
+	// sealResultsSize is the number of locally sealed blocks that can be queued
+	// for import.
+	sealResultsSize = 10
+
+	// chainHeadChanSize is the size of the channel listening to new heads.
+	chainHeadChanSize = 10
+
+	// sealRefreshInterval is how often the block being sealed is rebuilt to
+	// pick up new transactions and a fresh timestamp.
+	sealRefreshInterval = 30 * time.Second
+)
+
+var errTemplatesUnsupported = errors.New("consensus engine doesn't support work templates")
+
 // Backend wraps all methods required for mining. Only full node is capable
 // to offer all the functions here.
 type Backend interface {
@@ -63,6 +96,24 @@ var DefaultConfig = Config{
 	Recommit: 2 * time.Second,
 }
 
+// threaded is implemented by consensus engines that can seal a single block
+// using multiple threads.
+type threaded interface {
+	SetThreads(threads int)
+}
+
+// hashrater is implemented by consensus engines that measure their hash rate.
+type hashrater interface {
+	Hashrate() float64
+}
+
+// templateSealer is implemented by consensus engines that can track blocks
+// handed out to remote miners besides the one being mined locally, returning
+// their work package and delivering them on the results channel once sealed.
+type templateSealer interface {
+	SealTemplate(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block) ([4]string, error)
+}
+
 // Miner is the main object which takes care of submitting new work to consensus
 // engine and gathering the sealing result.
 type Miner struct {
@@ -77,10 +128,15 @@ type Miner struct {
 	pendingMu   sync.Mutex // Lock protects the pending block
 
 	// PoW mining fields
-	mining    bool           // Mining status
-	miningMu  sync.RWMutex   // Lock for mining status
-	mineStop  chan struct{}  // Stop channel for mining
-	threads   int            // Number of mining threads
+	mining    bool          // Mining status
+	miningMu  sync.RWMutex  // Lock for mining status
+	mineStop  chan struct{} // Stop channel for mining
+	threads   int           // Number of mining threads
+	minedFeed event.Feed    // Feed of locally sealed and imported blocks
+
+	// Remote work templates
+	templateResults chan *types.Block // Blocks sealed from work templates
+	templateOnce    sync.Once         // Starts the import of sealed templates on first use
 }
 
 // New creates a new miner with provided config.
@@ -159,7 +215,7 @@ func (miner *Miner) getPending() *newPayloadResult {
 		timestamp  = uint64(time.Now().Unix())
 		withdrawal types.Withdrawals
 	)
-	if miner.chainConfig.IsShanghai(new(big.Int).Add(header.Number, big.NewInt(1)), timestamp) {
+	if miner.chainConfig.IsShanghai(new(big.Int).Add(header.Number, big.NewInt(1)), timestamp) && miner.chainConfig.RandomX == nil {
 		withdrawal = []*types.Withdrawal{}
 	}
 	ret := miner.generateWork(&generateParams{
@@ -197,10 +253,13 @@ func (miner *Miner) Start(threads int) error {
 	miner.threads = threads
 	miner.mineStop = make(chan struct{})
 
-	// Start mining goroutines
-	for i := 0; i < threads; i++ {
-		go miner.mineLoop(miner.mineStop)
+	// All threads work on the same block, the engine splits the nonce space
+	if th, ok := miner.engine.(threaded); ok {
+		th.SetThreads(threads)
 	}
+	go miner.mineLoop(miner.mineStop)
+	go miner.hashrateLoop(miner.mineStop)
+	miningGauge.Update(1)
 
 	return nil
 }
@@ -216,9 +275,17 @@ func (miner *Miner) Stop() error {
 
 	close(miner.mineStop)
 	miner.mining = false
+	miningGauge.Update(0)
+	hashrateGauge.Update(0)
 	return nil
 }
 
+// SubscribeNewMinedBlockEvent registers a subscription for blocks sealed by
+// this miner once they have been imported into the local chain.
+func (miner *Miner) SubscribeNewMinedBlockEvent(ch chan<- core.NewMinedBlockEvent) event.Subscription {
+	return miner.minedFeed.Subscribe(ch)
+}
+
 // Mining returns whether the miner is currently mining.
 func (miner *Miner) Mining() bool {
 	miner.miningMu.RLock()
@@ -226,100 +293,168 @@ func (miner *Miner) Mining() bool {
 	return miner.mining
 }
 
-// HashRate returns the current hash rate (always returns 0 for now, can be improved later).
+// HashRate returns the current hash rate of the local and remote miners, as
+// measured by the consensus engine.
 func (miner *Miner) HashRate() uint64 {
-	// TODO: Implement actual hashrate calculation
+	if hr, ok := miner.engine.(hashrater); ok {
+		return uint64(hr.Hashrate())
+	}
 	return 0
 }
 
-// mineLoop is the main mining loop that continuously tries to mine blocks.
-func (miner *Miner) mineLoop(stop <-chan struct{}) {
-	log.Info("Mining loop started")
+// WorkFor builds a block on top of the current head that pays the given coinbase
+// and carries the given extra data, or the configured one if nil. The block is
+// handed to the consensus engine for remote sealing and its work package is
+// returned. The block is imported once a remote miner seals it.
+func (miner *Miner) WorkFor(coinbase common.Address, extra []byte) ([4]string, error) {
+	sealer, ok := miner.engine.(templateSealer)
+	if !ok {
+		return [4]string{}, errTemplatesUnsupported
+	}
+	if uint64(len(extra)) > params.MaximumExtraDataSize {
+		return [4]string{}, fmt.Errorf("extra exceeds max length. %d > %v", len(extra), params.MaximumExtraDataSize)
+	}
+	result := miner.generateWork(&generateParams{
+		timestamp:  uint64(time.Now().Unix()),
+		parentHash: miner.chain.CurrentBlock().Hash(),
+		coinbase:   coinbase,
+		extra:      extra,
+	}, false)
+	if result.err != nil {
+		return [4]string{}, result.err
+	}
+	miner.templateOnce.Do(func() {
+		miner.templateResults = make(chan *types.Block, templateResultsSize)
+		go miner.templateLoop(miner.templateResults)
+	})
+	return sealer.SealTemplate(miner.chain, result.block, miner.templateResults)
+}
+
+// templateLoop imports the blocks sealed from work templates. It runs for the
+// lifetime of the process once the first template was handed out.
+func (miner *Miner) templateLoop(results <-chan *types.Block) {
+	for block := range results {
+		log.Info("Work template sealed", "number", block.NumberU64(), "hash", block.Hash(), "coinbase", block.Coinbase())
+		miner.commitSealed(block)
+	}
+}
+
+// commitSealed imports a sealed block into the chain and announces it.
+func (miner *Miner) commitSealed(block *types.Block) {
+	if _, err := miner.chain.InsertChain([]*types.Block{block}); err != nil {
+		log.Error("Failed to insert block", "err", err)
+		lostBlocksCounter.Inc(1)
+		return
+	}
+	log.Info("🎉 Successfully mined block!", "number", block.NumberU64(), "hash", block.Hash().Hex())
+	minedBlocksCounter.Inc(1)
+	miner.minedFeed.Send(core.NewMinedBlockEvent{Block: block})
+}
+
+// hashrateLoop keeps the hash rate metrics up to date while mining.
+func (miner *Miner) hashrateLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(hashrateRefreshInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
+		case <-ticker.C:
+			hashrateGauge.Update(int64(miner.HashRate()))
 		case <-stop:
-			log.Info("Mining loop stopped")
 			return
-		default:
-			// Get the current head block
-			header := miner.chain.CurrentBlock()
-			if header == nil {
-				log.Warn("Current block header is nil, waiting...")
-				time.Sleep(time.Second)
-				continue
-			}
+		}
+	}
+}
 
-			log.Info("Mining new block", "parent", header.Number.Uint64(), "difficulty", header.Difficulty)
+// mineLoop is the main mining loop, sealing blocks on top of the chain head.
+// Each sealing attempt is aborted and restarted when the head changes, when a
+// block got sealed or when the work gets stale.
+func (miner *Miner) mineLoop(stop <-chan struct{}) {
+	log.Info("Mining loop started")
+	defer log.Info("Mining loop stopped")
 
-			// Create a new block to mine
-			timestamp := uint64(time.Now().Unix())
-			parent := miner.chain.GetBlock(header.Hash(), header.Number.Uint64())
-			if parent == nil {
-				log.Warn("Parent block is nil, waiting...")
-				time.Sleep(time.Second)
-				continue
-			}
+	chainHeadCh := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	sub := miner.chain.SubscribeChainHeadEvent(chainHeadCh)
+	defer sub.Unsubscribe()
 
-			// Build a new block
-			coinbase := miner.config.PendingFeeRecipient
-			if coinbase == (common.Address{}) {
-				coinbase = miner.config.Etherbase
-			}
+	var (
+		results = make(chan *types.Block, sealResultsSize) // Shared by all attempts
+		abort   chan struct{}                              // Aborts the current sealing attempt
+		parent  common.Hash                                // Parent of the block being sealed
+		timer   = time.NewTimer(0)
+	)
+	defer timer.Stop()
+	defer func() {
+		if abort != nil {
+			close(abort)
+		}
+	}()
+	commit := func() {
+		if abort != nil {
+			close(abort)
+		}
+		abort = make(chan struct{})
 
-			log.Debug("Generating work", "coinbase", coinbase, "timestamp", timestamp)
-
-			// Generate work
-			result := miner.generateWork(&generateParams{
-				timestamp:   timestamp,
-				forceTime:   true,
-				parentHash:  parent.Hash(),
-				coinbase:    coinbase,
-				random:      common.Hash{},
-				withdrawals: nil,
-				beaconRoot:  nil,
-				noTxs:       false,
-			}, false)
-
-			if result.err != nil {
-				log.Error("Failed to generate work", "err", result.err)
-				time.Sleep(time.Second)
-				continue
+		head, err := miner.commitWork(results, abort)
+		if err != nil {
+			log.Error("Failed to start sealing", "err", err)
+			parent = common.Hash{}
+			timer.Reset(time.Second)
+			return
+		}
+		parent = head
+		timer.Reset(sealRefreshInterval)
+	}
+	for {
+		select {
+		case <-timer.C:
+			commit()
+
+		case head := <-chainHeadCh:
+			if head.Header.Hash() != parent {
+				commit()
 			}
 
-			log.Info("Starting to seal block", "number", result.block.NumberU64(), "difficulty", result.block.Difficulty())
+		case block := <-results:
+			log.Info("Block sealed successfully!", "number", block.NumberU64(), "hash", block.Hash().Hex())
 
-			// Try to seal the block using the consensus engine
-			resultCh := make(chan *types.Block, 1)
-			if err := miner.engine.Seal(miner.chain, result.block, resultCh, stop); err != nil {
-				log.Error("Seal failed", "err", err)
-				time.Sleep(time.Second)
-				continue
-			}
+			// Mining continues on top of the sealed block, or on the old head
+			// if it failed to import
+			miner.commitSealed(block)
+			commit()
 
-			// Wait for the sealing result
-			select {
-			case block := <-resultCh:
-				if block != nil {
-					log.Info("Block sealed successfully!", "number", block.NumberU64(), "hash", block.Hash().Hex())
-					// Insert the sealed block into the blockchain
-					_, err := miner.chain.InsertChain([]*types.Block{block})
-					if err != nil {
-						log.Error("Failed to insert block", "err", err)
-						// Block insertion failed, continue mining
-						continue
-					}
-					log.Info("🎉 Successfully mined block!", "number", block.NumberU64(), "hash", block.Hash().Hex())
-				} else {
-					log.Warn("Received nil block from seal")
-				}
-			case <-stop:
-				log.Info("Mining stopped during sealing")
-				return
-			case <-time.After(30 * time.Second):
-				log.Warn("Sealing timeout after 30 seconds")
-				// Timeout, try again
-				continue
-			}
+		case <-stop:
+			return
 		}
 	}
 }
+
+// commitWork builds a block on top of the chain head and hands it to the
+// consensus engine for sealing, until the abort channel is closed. The hash
+// of the parent block is returned.
+func (miner *Miner) commitWork(results chan<- *types.Block, abort <-chan struct{}) (common.Hash, error) {
+	parent := miner.chain.CurrentBlock()
+
+	miner.confMu.RLock()
+	coinbase := miner.config.PendingFeeRecipient
+	if coinbase == (common.Address{}) {
+		coinbase = miner.config.Etherbase
+	}
+	miner.confMu.RUnlock()
+
+	result := miner.generateWork(&generateParams{
+		timestamp:  uint64(time.Now().Unix()),
+		forceTime:  true,
+		parentHash: parent.Hash(),
+		coinbase:   coinbase,
+	}, false)
+	if result.err != nil {
+		return common.Hash{}, fmt.Errorf("failed to generate work: %w", result.err)
+	}
+	log.Info("Starting to seal block", "number", result.block.NumberU64(), "difficulty", result.block.Difficulty())
+
+	if err := miner.engine.Seal(miner.chain, result.block, results, abort); err != nil {
+		return common.Hash{}, err
+	}
+	return parent.Hash(), nil
+}
@@ -21,9 +21,13 @@ import (
 	"math/big"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/clique"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/randomx"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
@@ -166,3 +170,94 @@ func createMiner(t *testing.T) *Miner {
 	miner := New(backend, config, engine)
 	return miner
 }
+
+// templateEngine wraps a consensus engine, recording the work templates it is
+// handed.
+type templateEngine struct {
+	consensus.Engine
+	blocks  []*types.Block
+	results chan<- *types.Block
+}
+
+func (e *templateEngine) SealTemplate(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block) ([4]string, error) {
+	e.blocks, e.results = append(e.blocks, block), results
+	return [4]string{block.Hash().Hex()}, nil
+}
+
+func TestWorkFor(t *testing.T) {
+	miner := createMiner(t)
+	if _, err := miner.WorkFor(common.Address{1}, nil); err != errTemplatesUnsupported {
+		t.Fatalf("work template without support: have %v, want %v", err, errTemplatesUnsupported)
+	}
+	engine := &templateEngine{Engine: ethash.NewFaker()}
+	miner.engine = engine
+
+	coinbase := common.HexToAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	if _, err := miner.WorkFor(coinbase, []byte("solo")); err != nil {
+		t.Fatalf("failed to build work template: %v", err)
+	}
+	if _, err := miner.WorkFor(coinbase, make([]byte, params.MaximumExtraDataSize+1)); err == nil {
+		t.Fatalf("work template with oversized extra data built")
+	}
+	if len(engine.blocks) != 1 || engine.results == nil {
+		t.Fatalf("work templates handed to the engine: have %d", len(engine.blocks))
+	}
+	block := engine.blocks[0]
+	if block.Coinbase() != coinbase || string(block.Extra()) != "solo" {
+		t.Errorf("work template mismatch: coinbase %v, extra %q", block.Coinbase(), block.Extra())
+	}
+	if block.ParentHash() != miner.chain.CurrentBlock().Hash() {
+		t.Errorf("work template not built on the head")
+	}
+}
+
+// Tests that the mining loop keeps sealing blocks on top of the new heads.
+func TestMineLoop(t *testing.T) {
+	chainConfig := *params.AllEthashProtocolChanges
+	chainConfig.TerminalTotalDifficulty = nil
+	chainConfig.Ethash = nil
+	chainConfig.RandomX = &params.RandomXConfig{}
+
+	engine := randomx.New(&randomx.Config{PowMode: randomx.ModeFake})
+	defer engine.Close()
+
+	genesis := &core.Genesis{
+		Config:     &chainConfig,
+		GasLimit:   11_500_000,
+		BaseFee:    big.NewInt(params.InitialBaseFee),
+		Difficulty: big.NewInt(1),
+	}
+	chainDB := rawdb.NewMemoryDatabase()
+	bc, err := core.NewBlockChain(chainDB, genesis, engine, nil)
+	if err != nil {
+		t.Fatalf("can't create new chain %v", err)
+	}
+	defer bc.Stop()
+
+	statedb, _ := state.New(bc.Genesis().Root(), bc.StateCache())
+	blockchain := &testBlockChain{bc.Genesis().Root(), &chainConfig, statedb, 10000000, new(event.Feed)}
+	pool := legacypool.New(testTxPoolConfig, blockchain)
+	txpool, _ := txpool.New(testTxPoolConfig.PriceLimit, blockchain, []txpool.SubPool{pool})
+
+	miner := New(NewMockBackend(bc, txpool), Config{PendingFeeRecipient: common.HexToAddress("123456789")}, engine)
+
+	mined := make(chan core.NewMinedBlockEvent, 16)
+	sub := miner.SubscribeNewMinedBlockEvent(mined)
+	defer sub.Unsubscribe()
+
+	if err := miner.Start(1); err != nil {
+		t.Fatalf("failed to start mining: %v", err)
+	}
+	defer miner.Stop()
+
+	for i := uint64(1); i <= 3; i++ {
+		select {
+		case ev := <-mined:
+			if ev.Block.NumberU64() != i {
+				t.Fatalf("mined block number mismatch: have %d, want %d", ev.Block.NumberU64(), i)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("block %d not mined", i)
+		}
+	}
+}
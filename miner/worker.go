@@ -102,6 +102,7 @@ type generateParams struct {
 	withdrawals types.Withdrawals // List of withdrawals to include in block (shanghai field)
 	beaconRoot  *common.Hash      // The beacon root (cancun field).
 	noTxs       bool              // Flag whether an empty block without any transaction is expected
+	extra       []byte            // The extra data of the block, the configured one if nil
 }
 
 // generateWork generates a sealing block based on the given parameters.
@@ -142,7 +143,7 @@ func (miner *Miner) generateWork(genParam *generateParams, witness bool) *newPay
 
 	// Collect consensus-layer requests if Prague is enabled.
 	var requests [][]byte
-	if miner.chainConfig.IsPrague(work.header.Number, work.header.Time) {
+	if miner.chainConfig.IsPrague(work.header.Number, work.header.Time) && miner.chainConfig.RandomX == nil {
 		requests = [][]byte{}
 		// EIP-6110 deposits
 		if err := core.ParseDepositLogs(&requests, allLogs, miner.chainConfig); err != nil {
@@ -211,7 +212,9 @@ func (miner *Miner) prepareWork(genParams *generateParams, witness bool) (*envir
 		Coinbase:   genParams.coinbase,
 	}
 	// Set the extra field.
-	if len(miner.config.ExtraData) != 0 {
+	if genParams.extra != nil {
+		header.Extra = genParams.extra
+	} else if len(miner.config.ExtraData) != 0 {
 		header.Extra = miner.config.ExtraData
 	}
 	// Set the randomness field from the beacon chain if it's available.
@@ -232,8 +235,9 @@ func (miner *Miner) prepareWork(genParams *generateParams, witness bool) (*envir
 		log.Error("Failed to prepare header for sealing", "err", err)
 		return nil, err
 	}
-	// Apply EIP-4844, EIP-4788.
-	if miner.chainConfig.IsCancun(header.Number, header.Time) {
+	// Apply EIP-4844, EIP-4788. RandomX chains run Cancun without blobs and
+	// beacon roots.
+	if miner.chainConfig.IsCancun(header.Number, header.Time) && miner.chainConfig.RandomX == nil {
 		var excessBlobGas uint64
 		if miner.chainConfig.IsCancun(parent.Number, parent.Time) {
 			excessBlobGas = eip4844.CalcExcessBlobGas(miner.chainConfig, parent, timestamp)
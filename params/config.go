@@ -509,6 +509,258 @@ type RandomXConfig struct {
 	// LWMA (Linearly Weighted Moving Average) is better suited for CPU mining
 	// than Ethereum's original difficulty algorithm
 	LWMAActivationBlock *big.Int `json:"lwmaActivationBlock,omitempty"`
+
+	// RandomXParams are the consensus parameters active from genesis. Fields
+	// left at zero fall back to the engine defaults.
+	RandomXParams
+
+	// Forks schedules changes to the consensus parameters at specific block
+	// heights. Entries must be sorted by block number and only the non-zero
+	// fields of an entry override the previously active values.
+	Forks []RandomXFork `json:"forks,omitempty"`
+
+	// Rewards is the block reward schedule of the chain. If nil, the Ethereum
+	// Frontier/Byzantium/Constantinople rewards are paid.
+	Rewards *RandomXRewards `json:"rewards,omitempty"`
+
+	// DisableBlobs acknowledges that blob transactions (EIP-4844) are not
+	// available after Cancun. RandomX headers carry no withdrawals root, which
+	// the blob gas fields follow in the header encoding, so the chain runs
+	// Cancun without blobs. It must be set to schedule Cancun.
+	DisableBlobs bool `json:"disableBlobs,omitempty"`
+}
+
+// RandomXRewards is the block reward emission schedule of a RandomX chain.
+//
+// The base reward follows either a halving schedule, where the initial reward
+// is halved every HalvingInterval blocks, or Monero's smooth emission, where
+// every block pays (MaxSupply - emitted) >> EmissionSpeedFactor. In both cases
+// the reward never drops below TailReward.
+type RandomXRewards struct {
+	InitialReward       *big.Int `json:"initialReward"`                 // Base block reward of the first halving era, in wei
+	HalvingInterval     uint64   `json:"halvingInterval,omitempty"`     // Blocks between reward halvings (0 = no halving)
+	MaxSupply           *big.Int `json:"maxSupply,omitempty"`           // Emission target of the smooth emission curve, in wei
+	EmissionSpeedFactor uint64   `json:"emissionSpeedFactor,omitempty"` // Smooth emission shift (0 = smooth emission disabled)
+	TailReward          *big.Int `json:"tailReward,omitempty"`          // Minimum base block reward, in wei
+
+	UncleRewardDivisor  uint64 `json:"uncleRewardDivisor,omitempty"`  // Uncle gets (8 - depth) / divisor of the reward (default 8)
+	NephewRewardDivisor uint64 `json:"nephewRewardDivisor,omitempty"` // Includer gets 1 / divisor of the reward per uncle (default 32)
+
+	// Funds schedules a share of every base block reward to be paid to a
+	// treasury address instead of the miner. The entry with the highest
+	// activation block not above the current block applies.
+	Funds []RandomXFund `json:"funds,omitempty"`
+}
+
+// RandomXFund is a scheduled treasury share of the block reward.
+type RandomXFund struct {
+	Block   *big.Int       `json:"block"`   // Block number from which the share applies
+	Address common.Address `json:"address"` // Recipient of the share
+	Percent uint64         `json:"percent"` // Share of the base block reward, 0 disables the fund
+}
+
+// FundAt returns the treasury fund active at the given block number, or nil
+// if none is.
+func (r *RandomXRewards) FundAt(number uint64) *RandomXFund {
+	var active *RandomXFund
+	for i := range r.Funds {
+		if r.Funds[i].Block == nil || !r.Funds[i].Block.IsUint64() || r.Funds[i].Block.Uint64() > number {
+			break
+		}
+		active = &r.Funds[i]
+	}
+	if active == nil || active.Percent == 0 {
+		return nil
+	}
+	return active
+}
+
+// validate checks the reward schedule for settings the engine cannot use.
+func (r *RandomXRewards) validate() error {
+	if r.InitialReward == nil || r.InitialReward.Sign() < 0 {
+		return errors.New("rewards: initialReward must be set")
+	}
+	if r.HalvingInterval != 0 && r.EmissionSpeedFactor != 0 {
+		return errors.New("rewards: halvingInterval and emissionSpeedFactor are mutually exclusive")
+	}
+	if r.EmissionSpeedFactor != 0 && (r.MaxSupply == nil || r.MaxSupply.Sign() <= 0) {
+		return errors.New("rewards: smooth emission requires maxSupply")
+	}
+	if r.TailReward != nil && r.TailReward.Sign() < 0 {
+		return errors.New("rewards: negative tailReward")
+	}
+	var last *big.Int
+	for i, fund := range r.Funds {
+		if fund.Block == nil {
+			return fmt.Errorf("rewards: fund %d: missing block number", i)
+		}
+		if last != nil && fund.Block.Cmp(last) <= 0 {
+			return fmt.Errorf("rewards: fund %d: block %v not after previous fund block %v", i, fund.Block, last)
+		}
+		if fund.Percent > 100 {
+			return fmt.Errorf("rewards: fund %d: percent %d exceeds 100", i, fund.Percent)
+		}
+		last = fund.Block
+	}
+	return nil
+}
+
+// RandomXParams are the tunable consensus parameters of the RandomX engine.
+// A zero value means the parameter is not set.
+type RandomXParams struct {
+	EpochLength               uint64 `json:"epochLength,omitempty"`                   // Blocks between RandomX seed changes
+	EpochLag                  uint64 `json:"epochLag,omitempty"`                      // Blocks the seed block lags behind the epoch boundary
+	LWMAWindowSize            uint64 `json:"lwmaWindowSize,omitempty"`                // Number of blocks averaged by LWMA
+	LWMATargetBlockTime       uint64 `json:"lwmaTargetBlockTime,omitempty"`           // Target block time in seconds
+	LWMABurstDetectionWindow  uint64 `json:"lwmaBurstDetectionWindow,omitempty"`      // Recent blocks inspected for hashrate bursts
+	LWMABurstSustainedPercent uint64 `json:"lwmaBurstSustainedPercent,omitempty"`     // Share of fast blocks (in %) flagged as a sustained burst
+	AllowedFutureBlockTime    uint64 `json:"allowedFutureBlockTimeSeconds,omitempty"` // Max seconds a block may be ahead of local time
+}
+
+// RandomXFork is a scheduled change of the RandomX consensus parameters.
+type RandomXFork struct {
+	Block *big.Int `json:"block"` // Block number from which the parameters apply
+	RandomXParams
+}
+
+// ParamsAt returns the RandomX parameters active at the given block number.
+// Parameters that are not configured are returned as zero.
+func (c *RandomXConfig) ParamsAt(number uint64) RandomXParams {
+	if c == nil {
+		return RandomXParams{}
+	}
+	active := c.RandomXParams
+	for _, fork := range c.Forks {
+		if fork.Block == nil || !fork.Block.IsUint64() || fork.Block.Uint64() > number {
+			break
+		}
+		active.override(&fork.RandomXParams)
+	}
+	return active
+}
+
+// override replaces the parameters of p with all the ones set in o.
+func (p *RandomXParams) override(o *RandomXParams) {
+	if o.EpochLength != 0 {
+		p.EpochLength = o.EpochLength
+	}
+	if o.EpochLag != 0 {
+		p.EpochLag = o.EpochLag
+	}
+	if o.LWMAWindowSize != 0 {
+		p.LWMAWindowSize = o.LWMAWindowSize
+	}
+	if o.LWMATargetBlockTime != 0 {
+		p.LWMATargetBlockTime = o.LWMATargetBlockTime
+	}
+	if o.LWMABurstDetectionWindow != 0 {
+		p.LWMABurstDetectionWindow = o.LWMABurstDetectionWindow
+	}
+	if o.LWMABurstSustainedPercent != 0 {
+		p.LWMABurstSustainedPercent = o.LWMABurstSustainedPercent
+	}
+	if o.AllowedFutureBlockTime != 0 {
+		p.AllowedFutureBlockTime = o.AllowedFutureBlockTime
+	}
+}
+
+// validate checks the parameters for values the engine cannot operate with.
+func (p *RandomXParams) validate() error {
+	if p.LWMAWindowSize == 1 {
+		return errors.New("lwmaWindowSize must be at least 2")
+	}
+	if p.LWMABurstSustainedPercent > 100 {
+		return errors.New("lwmaBurstSustainedPercent must not exceed 100")
+	}
+	return nil
+}
+
+// validate checks the genesis parameters and the ordering of the scheduled
+// parameter forks.
+func (c *RandomXConfig) validate() error {
+	if err := c.RandomXParams.validate(); err != nil {
+		return err
+	}
+	var last *big.Int
+	for i, fork := range c.Forks {
+		if fork.Block == nil {
+			return fmt.Errorf("fork %d: missing block number", i)
+		}
+		if last != nil && fork.Block.Cmp(last) <= 0 {
+			return fmt.Errorf("fork %d: block %v not after previous fork block %v", i, fork.Block, last)
+		}
+		if err := fork.RandomXParams.validate(); err != nil {
+			return fmt.Errorf("fork %d: %v", i, err)
+		}
+		last = fork.Block
+	}
+	if c.Rewards != nil {
+		return c.Rewards.validate()
+	}
+	return nil
+}
+
+// checkCompatible returns the first block up to head at which the parameters
+// of the two configurations differ, or nil if they agree on all past blocks.
+func (c *RandomXConfig) checkCompatible(newcfg *RandomXConfig, head *big.Int) *big.Int {
+	blocks := []*big.Int{common.Big0}
+	if c != nil {
+		for _, fork := range c.Forks {
+			blocks = append(blocks, fork.Block)
+		}
+	}
+	if newcfg != nil {
+		for _, fork := range newcfg.Forks {
+			blocks = append(blocks, fork.Block)
+		}
+	}
+	var first *big.Int
+	for _, block := range blocks {
+		if !isBlockForked(block, head) || !block.IsUint64() {
+			continue
+		}
+		if c.ParamsAt(block.Uint64()) != newcfg.ParamsAt(block.Uint64()) {
+			if first == nil || block.Cmp(first) < 0 {
+				first = block
+			}
+		}
+	}
+	return first
+}
+
+// checkCompatible returns the first block up to head at which the two reward
+// schedules pay differently, or nil if they agree on all past blocks. Each
+// reward of a smooth emission depends on all previous ones, so any change of
+// the base schedule applies from the first rewarded block.
+func (r *RandomXRewards) checkCompatible(newcfg *RandomXRewards, head *big.Int) *big.Int {
+	if !isBlockForked(common.Big1, head) {
+		return nil // the genesis block pays no reward
+	}
+	if r == nil || newcfg == nil {
+		if r != newcfg {
+			return big.NewInt(1)
+		}
+		return nil
+	}
+	if !configBlockEqual(r.InitialReward, newcfg.InitialReward) || r.HalvingInterval != newcfg.HalvingInterval ||
+		!configBlockEqual(r.MaxSupply, newcfg.MaxSupply) || r.EmissionSpeedFactor != newcfg.EmissionSpeedFactor ||
+		!configBlockEqual(r.TailReward, newcfg.TailReward) ||
+		r.UncleRewardDivisor != newcfg.UncleRewardDivisor || r.NephewRewardDivisor != newcfg.NephewRewardDivisor {
+		return big.NewInt(1)
+	}
+	var first *big.Int
+	for _, fund := range append(append([]RandomXFund{}, r.Funds...), newcfg.Funds...) {
+		if !isBlockForked(fund.Block, head) || !fund.Block.IsUint64() {
+			continue
+		}
+		have, want := r.FundAt(fund.Block.Uint64()), newcfg.FundAt(fund.Block.Uint64())
+		if (have == nil) != (want == nil) || (have != nil && (have.Address != want.Address || have.Percent != want.Percent)) {
+			if first == nil || fund.Block.Cmp(first) < 0 {
+				first = fund.Block
+			}
+		}
+	}
+	return first
 }
 
 // String implements the stringer interface, returning the consensus engine details.
@@ -1003,6 +1255,15 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 		}
 	}
 
+	// Check that the RandomX parameter schedule is usable.
+	if c.RandomX != nil {
+		if err := c.RandomX.validate(); err != nil {
+			return fmt.Errorf("invalid randomx configuration: %v", err)
+		}
+		if c.CancunTime != nil && !c.RandomX.DisableBlobs {
+			return errors.New("invalid randomx configuration: blob transactions are not supported, disableBlobs must be set to schedule cancun")
+		}
+	}
 	// Check that all forks with blobs explicitly define the blob schedule configuration.
 	bsc := c.BlobScheduleConfig
 	if bsc == nil {
@@ -1140,6 +1401,14 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, headNumber *big.Int,
 	if isForkTimestampIncompatible(c.AmsterdamTime, newcfg.AmsterdamTime, headTimestamp) {
 		return newTimestampCompatError("Amsterdam fork timestamp", c.AmsterdamTime, newcfg.AmsterdamTime)
 	}
+	if c.RandomX != nil && newcfg.RandomX != nil {
+		if block := c.RandomX.checkCompatible(newcfg.RandomX, headNumber); block != nil {
+			return newBlockCompatError("RandomX parameters", block, block)
+		}
+		if block := c.RandomX.Rewards.checkCompatible(newcfg.RandomX.Rewards, headNumber); block != nil {
+			return newBlockCompatError("RandomX rewards", block, block)
+		}
+	}
 	return nil
 }
 
@@ -1408,6 +1677,9 @@ func (c *ChainConfig) Rules(num *big.Int, isMerge bool, timestamp uint64) Rules
 	// disallow setting Merge out of order
 	isMerge = isMerge && c.IsLondon(num)
 	isVerkle := isMerge && c.IsVerkle(num, timestamp)
+	// RandomX chains stay on proof-of-work, but run the execution rules of the
+	// post-merge forks
+	postMerge := isMerge || (c.RandomX != nil && c.IsLondon(num))
 	return Rules{
 		ChainID:          new(big.Int).Set(chainID),
 		IsHomestead:      c.IsHomestead(num),
@@ -1422,11 +1694,11 @@ func (c *ChainConfig) Rules(num *big.Int, isMerge bool, timestamp uint64) Rules
 		IsEIP2929:        c.IsBerlin(num) && !isVerkle,
 		IsLondon:         c.IsLondon(num),
 		IsMerge:          isMerge,
-		IsShanghai:       isMerge && c.IsShanghai(num, timestamp),
-		IsCancun:         isMerge && c.IsCancun(num, timestamp),
-		IsPrague:         isMerge && c.IsPrague(num, timestamp),
-		IsOsaka:          isMerge && c.IsOsaka(num, timestamp),
-		IsAmsterdam:      isMerge && c.IsAmsterdam(num, timestamp),
+		IsShanghai:       postMerge && c.IsShanghai(num, timestamp),
+		IsCancun:         postMerge && c.IsCancun(num, timestamp),
+		IsPrague:         postMerge && c.IsPrague(num, timestamp),
+		IsOsaka:          postMerge && c.IsOsaka(num, timestamp),
+		IsAmsterdam:      postMerge && c.IsAmsterdam(num, timestamp),
 		IsVerkle:         isVerkle,
 		IsEIP4762:        isVerkle,
 	}
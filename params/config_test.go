@@ -23,6 +23,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/require"
 )
 
@@ -139,6 +140,29 @@ func TestConfigRules(t *testing.T) {
 	}
 }
 
+func TestRandomXPostMergeRules(t *testing.T) {
+	c := *AllEthashProtocolChanges
+	c.TerminalTotalDifficulty, c.Ethash = nil, nil
+	c.ShanghaiTime, c.CancunTime, c.PragueTime = newUint64(0), newUint64(500), newUint64(500)
+	c.BlobScheduleConfig = DefaultBlobSchedule
+	c.RandomX = &RandomXConfig{}
+
+	// Proof-of-work headers are never merged, the forks still apply
+	if r := c.Rules(big.NewInt(0), false, 0); r.IsMerge || !r.IsShanghai || r.IsCancun {
+		t.Errorf("before cancun: have merge %v, shanghai %v, cancun %v", r.IsMerge, r.IsShanghai, r.IsCancun)
+	}
+	if r := c.Rules(big.NewInt(0), false, 500); !r.IsCancun || !r.IsPrague {
+		t.Errorf("after prague: have cancun %v, prague %v", r.IsCancun, r.IsPrague)
+	}
+	if err := c.CheckConfigForkOrder(); err == nil {
+		t.Error("cancun scheduled without disabling blobs")
+	}
+	c.RandomX.DisableBlobs = true
+	if err := c.CheckConfigForkOrder(); err != nil {
+		t.Errorf("valid config rejected: %v", err)
+	}
+}
+
 func TestTimestampCompatError(t *testing.T) {
 	require.Equal(t, new(ConfigCompatError).Error(), "")
 
@@ -155,3 +179,76 @@ func TestTimestampCompatError(t *testing.T) {
 	require.Equal(t, newTimestampCompatError(errWhat, newUint64(0), newUint64(1681338455)).Error(),
 		"mismatching Shanghai fork timestamp in database (have timestamp 0, want timestamp 1681338455, rewindto timestamp 0)")
 }
+
+func TestRandomXParamsSchedule(t *testing.T) {
+	config := &RandomXConfig{
+		RandomXParams: RandomXParams{EpochLength: 1024, LWMATargetBlockTime: 10},
+		Forks: []RandomXFork{
+			{Block: big.NewInt(100), RandomXParams: RandomXParams{LWMATargetBlockTime: 15}},
+			{Block: big.NewInt(200), RandomXParams: RandomXParams{EpochLength: 2048, AllowedFutureBlockTime: 30}},
+		},
+	}
+	tests := []struct {
+		number uint64
+		want   RandomXParams
+	}{
+		{0, RandomXParams{EpochLength: 1024, LWMATargetBlockTime: 10}},
+		{99, RandomXParams{EpochLength: 1024, LWMATargetBlockTime: 10}},
+		{100, RandomXParams{EpochLength: 1024, LWMATargetBlockTime: 15}},
+		{200, RandomXParams{EpochLength: 2048, LWMATargetBlockTime: 15, AllowedFutureBlockTime: 30}},
+	}
+	for _, tt := range tests {
+		if have := config.ParamsAt(tt.number); have != tt.want {
+			t.Errorf("block %d: params mismatch: have %+v, want %+v", tt.number, have, tt.want)
+		}
+	}
+	if err := config.validate(); err != nil {
+		t.Fatalf("valid schedule rejected: %v", err)
+	}
+	unordered := &RandomXConfig{Forks: []RandomXFork{{Block: big.NewInt(200)}, {Block: big.NewInt(100)}}}
+	if err := unordered.validate(); err == nil {
+		t.Fatal("unordered schedule accepted")
+	}
+	// Rescheduling a fork that already passed must be rejected, a future one not
+	stored := &ChainConfig{RandomX: config}
+	moved := *config
+	moved.Forks = []RandomXFork{config.Forks[0], {Block: big.NewInt(300), RandomXParams: config.Forks[1].RandomXParams}}
+	if err := stored.CheckCompatible(&ChainConfig{RandomX: &moved}, 150, 0); err != nil {
+		t.Errorf("future fork change rejected: %v", err)
+	}
+	err := stored.CheckCompatible(&ChainConfig{RandomX: &moved}, 250, 0)
+	if err == nil || err.RewindToBlock != 199 {
+		t.Errorf("past fork change: have %v, want rewind to 199", err)
+	}
+}
+
+// Tests that changes of the reward schedule are only accepted before the
+// rewards they alter are paid.
+func TestRandomXRewardsCompatible(t *testing.T) {
+	treasury := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	rewards := &RandomXRewards{
+		InitialReward: big.NewInt(1000),
+		Funds:         []RandomXFund{{Block: big.NewInt(100), Address: treasury, Percent: 5}},
+	}
+	stored := &ChainConfig{RandomX: &RandomXConfig{Rewards: rewards}}
+
+	halved := *rewards
+	halved.InitialReward = big.NewInt(500)
+	if err := stored.CheckCompatible(&ChainConfig{RandomX: &RandomXConfig{Rewards: &halved}}, 0, 0); err != nil {
+		t.Errorf("reward change at genesis rejected: %v", err)
+	}
+	if err := stored.CheckCompatible(&ChainConfig{RandomX: &RandomXConfig{Rewards: &halved}}, 10, 0); err == nil || err.RewindToBlock != 0 {
+		t.Errorf("past reward change: have %v, want rewind to 0", err)
+	}
+	if err := stored.CheckCompatible(&ChainConfig{RandomX: &RandomXConfig{}}, 10, 0); err == nil || err.RewindToBlock != 0 {
+		t.Errorf("dropped reward schedule: have %v, want rewind to 0", err)
+	}
+	moved := *rewards
+	moved.Funds = []RandomXFund{{Block: big.NewInt(200), Address: treasury, Percent: 5}}
+	if err := stored.CheckCompatible(&ChainConfig{RandomX: &RandomXConfig{Rewards: &moved}}, 50, 0); err != nil {
+		t.Errorf("future fund change rejected: %v", err)
+	}
+	if err := stored.CheckCompatible(&ChainConfig{RandomX: &RandomXConfig{Rewards: &moved}}, 150, 0); err == nil || err.RewindToBlock != 99 {
+		t.Errorf("past fund change: have %v, want rewind to 99", err)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// activeMinerWindow is how recently a miner must have been seen to count as
+// active.
+const activeMinerWindow = 2 * time.Minute
+
+// apiBlockLimit is the number of found blocks returned by the API.
+const apiBlockLimit = 50
+
+// PoolStats is the API view of the proxy totals.
+type PoolStats struct {
+	Miners       int        `json:"miners"`
+	ActiveMiners int        `json:"activeMiners"`
+	Addresses    int        `json:"addresses"`
+	Hashrate     float64    `json:"hashrate"`
+	Shares       ShareStats `json:"shares"`
+	BlocksFound  uint64     `json:"blocksFound"`
+	Uptime       float64    `json:"uptime"` // Seconds
+	Job          *JobStats  `json:"job,omitempty"`
+	Pool         *PoolInfo  `json:"pool,omitempty"`
+}
+
+// ShareStats are the share counters of the proxy.
+type ShareStats struct {
+	Total    uint64            `json:"total"`
+	Valid    uint64            `json:"valid"`
+	Invalid  uint64            `json:"invalid"`
+	Stale    uint64            `json:"stale"`
+	Rejected map[string]uint64 `json:"rejected"` // Invalid shares per reason
+}
+
+// PoolInfo describes the pool settings, if running as a pool.
+type PoolInfo struct {
+	Address string   `json:"address"`
+	Fee     float64  `json:"fee"`
+	Scheme  string   `json:"scheme,omitempty"`
+	Revenue *big.Int `json:"revenue,omitempty"`
+}
+
+// JobStats is the API view of the current job.
+type JobStats struct {
+	JobID             string    `json:"jobId"`
+	Height            uint64    `json:"height"`
+	SeedHash          string    `json:"seedHash"`
+	NetworkDifficulty uint64    `json:"networkDifficulty"`
+	Created           time.Time `json:"created"`
+}
+
+// WorkerStats is the API view of a connected miner.
+type WorkerStats struct {
+	ID            string    `json:"id"`
+	Worker        string    `json:"worker"`
+	Address       string    `json:"address"`
+	Agent         string    `json:"agent,omitempty"`
+	Difficulty    uint64    `json:"difficulty"`
+	Hashrate      float64   `json:"hashrate"`
+	SharesValid   uint64    `json:"sharesValid"`
+	SharesInvalid uint64    `json:"sharesInvalid"`
+	SharesStale   uint64    `json:"sharesStale"`
+	BlocksFound   uint64    `json:"blocksFound"`
+	LastShare     time.Time `json:"lastShare"`
+	Active        bool      `json:"active"`
+	Banned        bool      `json:"banned,omitempty"`
+}
+
+// AddressStats aggregates the workers mining to an address.
+type AddressStats struct {
+	Address       string        `json:"address"`
+	Workers       int           `json:"workers"`
+	Hashrate      float64       `json:"hashrate"`
+	SharesValid   uint64        `json:"sharesValid"`
+	SharesInvalid uint64        `json:"sharesInvalid"`
+	SharesStale   uint64        `json:"sharesStale"`
+	BlocksFound   uint64        `json:"blocksFound"`
+	Balance       *big.Int      `json:"balance,omitempty"` // Unpaid balance (pool mode)
+	Paid          *big.Int      `json:"paid,omitempty"`    // Total paid out (pool mode)
+	WorkerList    []WorkerStats `json:"workerList,omitempty"`
+}
+
+// BlockStats is the API view of a found block.
+type BlockStats struct {
+	Height uint64    `json:"height"`
+	Nonce  uint64    `json:"nonce"`
+	Hash   string    `json:"hash,omitempty"`
+	Finder string    `json:"finder"`
+	Worker string    `json:"worker,omitempty"`
+	Found  time.Time `json:"found"`
+	Status string    `json:"status,omitempty"` // Ledger status (pool mode)
+	Reward *big.Int  `json:"reward,omitempty"`
+}
+
+// startAPI starts the HTTP stats API and Prometheus metrics listener.
+func (s *Server) startAPI() error {
+	listener, err := net.Listen("tcp", s.cfg().APIAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for API: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/stats", s.handleAPIStats)
+	mux.HandleFunc("/api/miners", s.handleAPIMiners)
+	mux.HandleFunc("/api/miners/", s.handleAPIMiner)
+	mux.HandleFunc("/api/blocks", s.handleAPIBlocks)
+	mux.HandleFunc("/api/job", s.handleAPIJob)
+	mux.HandleFunc("/api/upstreams", s.handleAPIUpstreams)
+	mux.HandleFunc("/api/bans", s.handleAPIBans)
+	mux.HandleFunc("/api/bans/", s.handleAPIBan)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+
+	s.apiServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.apiServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("❌ API server error: %v", err)
+		}
+	}()
+	log.Printf("📈 HTTP API and metrics on %s", listener.Addr())
+	return nil
+}
+
+// writeJSON writes an API response.
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("⚠️  API write error: %v", err)
+	}
+}
+
+// workerStats returns the stats of all connected miners, ordered by address
+// and worker name.
+func (s *Server) workerStats() []WorkerStats {
+	s.minersMu.RLock()
+	workers := make([]WorkerStats, 0, len(s.miners))
+	for _, miner := range s.miners {
+		miner.mu.RLock()
+		worker := WorkerStats{
+			ID:            miner.ID,
+			Worker:        miner.WorkerName,
+			Address:       miner.Address,
+			Agent:         miner.Agent,
+			Difficulty:    miner.Difficulty,
+			SharesValid:   miner.SharesValid,
+			SharesInvalid: miner.SharesInvalid,
+			SharesStale:   miner.SharesStale,
+			BlocksFound:   miner.BlocksFound,
+			LastShare:     miner.LastShareTime,
+			Active:        time.Since(miner.LastActivity) < activeMinerWindow,
+			Banned:        miner.Banned,
+		}
+		// Idle miners don't contribute to the hashrate anymore
+		if worker.Active {
+			worker.Hashrate = miner.Hashrate
+		}
+		miner.mu.RUnlock()
+
+		workers = append(workers, worker)
+	}
+	s.minersMu.RUnlock()
+
+	sort.Slice(workers, func(i, j int) bool {
+		if workers[i].Address != workers[j].Address {
+			return workers[i].Address < workers[j].Address
+		}
+		if workers[i].Worker != workers[j].Worker {
+			return workers[i].Worker < workers[j].Worker
+		}
+		return workers[i].ID < workers[j].ID
+	})
+	return workers
+}
+
+// addressStats aggregates worker stats per address, keeping the order.
+func addressStats(workers []WorkerStats) []*AddressStats {
+	var addresses []*AddressStats
+	for _, worker := range workers {
+		if len(addresses) == 0 || addresses[len(addresses)-1].Address != worker.Address {
+			addresses = append(addresses, &AddressStats{Address: worker.Address})
+		}
+		stats := addresses[len(addresses)-1]
+		stats.Workers++
+		stats.Hashrate += worker.Hashrate
+		stats.SharesValid += worker.SharesValid
+		stats.SharesInvalid += worker.SharesInvalid
+		stats.SharesStale += worker.SharesStale
+		stats.BlocksFound += worker.BlocksFound
+	}
+	return addresses
+}
+
+// jobStats returns the current job, or nil if there is no work yet.
+func (s *Server) jobStats() *JobStats {
+	s.workMu.RLock()
+	defer s.workMu.RUnlock()
+
+	if s.currentJob == nil {
+		return nil
+	}
+	return &JobStats{
+		JobID:             s.currentJob.JobID,
+		Height:            s.currentJob.Height,
+		SeedHash:          s.currentJob.SeedHash,
+		NetworkDifficulty: s.currentJob.Difficulty,
+		Created:           s.currentJob.CreatedAt,
+	}
+}
+
+// handleAPIStats serves the proxy totals.
+func (s *Server) handleAPIStats(w http.ResponseWriter, r *http.Request) {
+	workers := s.workerStats()
+
+	stats := PoolStats{
+		Miners:    len(workers),
+		Addresses: len(addressStats(workers)),
+		Job:       s.jobStats(),
+	}
+	for _, worker := range workers {
+		if worker.Active {
+			stats.ActiveMiners++
+			stats.Hashrate += worker.Hashrate
+		}
+	}
+	s.stats.mu.RLock()
+	stats.Shares = ShareStats{
+		Total:    s.stats.TotalShares,
+		Valid:    s.stats.ValidShares,
+		Invalid:  s.stats.InvalidShares,
+		Stale:    s.stats.StaleShares,
+		Rejected: make(map[string]uint64, len(s.stats.Rejected)),
+	}
+	for reason, count := range s.stats.Rejected {
+		stats.Shares.Rejected[reason] = count
+	}
+	stats.BlocksFound = s.stats.BlocksFound
+	stats.Uptime = time.Since(s.stats.StartTime).Seconds()
+	s.stats.mu.RUnlock()
+
+	if s.cfg().PoolAddress != "" {
+		stats.Pool = &PoolInfo{Address: s.cfg().PoolAddress, Fee: s.cfg().PoolFee}
+		if s.ledger != nil {
+			stats.Pool.Scheme = s.cfg().PayoutScheme
+			stats.Pool.Revenue = s.ledger.Revenue()
+		}
+	}
+	writeJSON(w, stats)
+}
+
+// handleAPIMiners serves the per-address stats.
+func (s *Server) handleAPIMiners(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, addressStats(s.workerStats()))
+}
+
+// handleAPIMiner serves the stats of one address with its workers, at
+// /api/miners/{address}.
+func (s *Server) handleAPIMiner(w http.ResponseWriter, r *http.Request) {
+	address := strings.TrimPrefix(r.URL.Path, "/api/miners/")
+
+	var workers []WorkerStats
+	for _, worker := range s.workerStats() {
+		if worker.Address == address {
+			workers = append(workers, worker)
+		}
+	}
+	stats := &AddressStats{Address: address}
+	if len(workers) > 0 {
+		stats = addressStats(workers)[0]
+		stats.WorkerList = workers
+	}
+	if s.ledger != nil {
+		stats.Balance, stats.Paid = s.ledger.Account(address)
+	}
+	if stats.Workers == 0 && (stats.Balance == nil || (stats.Balance.Sign() == 0 && stats.Paid.Sign() == 0)) {
+		http.Error(w, "unknown miner", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, stats)
+}
+
+// handleAPIBlocks serves the last found blocks, newest first. In pool mode
+// they come from the ledger, with their maturity status and reward.
+func (s *Server) handleAPIBlocks(w http.ResponseWriter, r *http.Request) {
+	blocks := []BlockStats{}
+	if s.ledger != nil {
+		for _, block := range s.ledger.RecentBlocks(apiBlockLimit) {
+			blocks = append(blocks, BlockStats{
+				Height: block.Height,
+				Nonce:  block.Nonce,
+				Hash:   block.Hash,
+				Finder: block.Finder,
+				Found:  block.Found,
+				Status: block.Status,
+				Reward: block.Reward,
+			})
+		}
+	} else {
+		for _, block := range s.stats.GetRecentBlocks() {
+			if len(blocks) == apiBlockLimit {
+				break
+			}
+			blocks = append(blocks, BlockStats{
+				Height: block.Height,
+				Nonce:  block.Nonce,
+				Finder: block.Finder,
+				Worker: block.Worker,
+				Found:  block.Found,
+			})
+		}
+	}
+	writeJSON(w, blocks)
+}
+
+// handleAPIJob serves the current job.
+func (s *Server) handleAPIJob(w http.ResponseWriter, r *http.Request) {
+	job := s.jobStats()
+	if job == nil {
+		http.Error(w, "no work available", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, job)
+}
+
+// handleAPIUpstreams serves the health of the Geth upstreams.
+func (s *Server) handleAPIUpstreams(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.upstreams.Status())
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newAPITestServer creates a server with two addresses, one of them mining
+// with two workers.
+func newAPITestServer() *Server {
+	srv := &Server{
+		config: &ServerConfig{InitialDiff: 1000, VarDiffTarget: 30},
+		miners: make(map[string]*Miner),
+		stats:  NewStats(),
+		currentJob: &Job{
+			JobID:      "7",
+			Height:     42,
+			SeedHash:   "0xabcd",
+			Difficulty: 5000,
+			CreatedAt:  time.Unix(1700000000, 0),
+		},
+	}
+	now := time.Now()
+	for _, miner := range []*Miner{
+		{ID: "1.1.1.1:1", Address: "0xa", WorkerName: "rig1", Difficulty: 1000, Hashrate: 100, SharesValid: 3, LastActivity: now},
+		{ID: "1.1.1.1:2", Address: "0xa", WorkerName: "rig2", Difficulty: 3000, Hashrate: 50, SharesValid: 2, SharesStale: 1, LastActivity: now},
+		{ID: "2.2.2.2:1", Address: "0xb", WorkerName: "x", Difficulty: 2000, Hashrate: 10, LastActivity: now.Add(-time.Hour)},
+	} {
+		srv.miners[miner.ID] = miner
+	}
+	return srv
+}
+
+func TestAPIStats(t *testing.T) {
+	srv := newAPITestServer()
+	srv.stats.RecordShare(true)
+	srv.stats.RecordRejectedShare(RejectDuplicate)
+	srv.stats.RecordBlock(FoundBlock{Height: 41, Finder: "0xa", Worker: "rig1"})
+
+	rec := httptest.NewRecorder()
+	srv.handleAPIStats(rec, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+
+	var stats PoolStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode stats: %v", err)
+	}
+	if stats.Miners != 3 || stats.ActiveMiners != 2 || stats.Addresses != 2 || stats.Hashrate != 150 {
+		t.Errorf("miners: have %d/%d on %d addresses at %v H/s, want 2/3 on 2 at 150 H/s",
+			stats.ActiveMiners, stats.Miners, stats.Addresses, stats.Hashrate)
+	}
+	if stats.Shares.Total != 2 || stats.Shares.Rejected[RejectDuplicate] != 1 || stats.BlocksFound != 1 {
+		t.Errorf("shares: have %+v, %d blocks", stats.Shares, stats.BlocksFound)
+	}
+	if stats.Job == nil || stats.Job.Height != 42 || stats.Job.NetworkDifficulty != 5000 {
+		t.Errorf("job: have %+v", stats.Job)
+	}
+}
+
+func TestAPIMiners(t *testing.T) {
+	srv := newAPITestServer()
+
+	rec := httptest.NewRecorder()
+	srv.handleAPIMiner(rec, httptest.NewRequest(http.MethodGet, "/api/miners/0xa", nil))
+
+	var miner AddressStats
+	if err := json.NewDecoder(rec.Body).Decode(&miner); err != nil {
+		t.Fatalf("decode miner: %v", err)
+	}
+	if miner.Workers != 2 || miner.Hashrate != 150 || miner.SharesValid != 5 || miner.SharesStale != 1 {
+		t.Errorf("address stats: have %+v", miner)
+	}
+	if len(miner.WorkerList) != 2 || miner.WorkerList[0].Worker != "rig1" || miner.WorkerList[1].Worker != "rig2" {
+		t.Errorf("workers: have %+v", miner.WorkerList)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.handleAPIMiner(rec, httptest.NewRequest(http.MethodGet, "/api/miners/0xc", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown miner: have status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	srv := newAPITestServer()
+	srv.stats.RecordShare(true)
+	srv.stats.RecordRejectedShare(RejectLowDifficulty)
+	srv.stats.RecordStaleShare()
+	srv.stats.RecordBan(BanForgedShare)
+	srv.stats.RecordVarDiff(1000, 2000)
+	srv.stats.ObserveSubmit(3 * time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	srv.handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, line := range []string{
+		"stratum_miners_connected 3",
+		"stratum_miners_active 2",
+		"stratum_hashrate 150",
+		"stratum_miner_difficulty_avg 2000",
+		"stratum_job_height 42",
+		"stratum_shares_accepted_total 1",
+		`stratum_shares_rejected_total{reason="duplicate"} 0`,
+		`stratum_shares_rejected_total{reason="low_difficulty"} 1`,
+		"stratum_shares_stale_total 1",
+		`stratum_bans_total{reason="forged_share"} 1`,
+		`stratum_vardiff_adjustments_total{direction="up"} 1`,
+		`stratum_share_submit_duration_seconds_bucket{le="0.0025"} 0`,
+		`stratum_share_submit_duration_seconds_bucket{le="0.005"} 1`,
+		`stratum_share_submit_duration_seconds_bucket{le="+Inf"} 1`,
+		"stratum_share_submit_duration_seconds_count 1",
+		"# TYPE stratum_share_submit_duration_seconds histogram",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics missing %q", line)
+		}
+	}
+}
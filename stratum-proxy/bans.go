@@ -0,0 +1,371 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ban list
+//
+// Bans are kept by IP address, subnet or payout address, optionally with an
+// expiry time, and saved to a JSON file so that they survive restarts. IP and
+// subnet bans are enforced when a connection is accepted, before anything is
+// read from it, address bans on login. Automatic bans for invalid or forged
+// shares are recorded against the IP of the miner, never its address, as
+// anyone can log in with any address.
+
+// Kinds of ban targets.
+const (
+	BanKindIP      = "ip"
+	BanKindSubnet  = "subnet"
+	BanKindAddress = "address"
+)
+
+var errUnknownBan = errors.New("unknown ban")
+
+// BanRecord is a banned IP address, subnet or payout address.
+type BanRecord struct {
+	Target  string     `json:"target"` // Canonical IP, CIDR or checksummed address
+	Kind    string     `json:"kind"`
+	Reason  string     `json:"reason"`
+	Created time.Time  `json:"created"`
+	Expires *time.Time `json:"expires,omitempty"` // Nil for permanent bans
+
+	network *net.IPNet // Parsed subnet
+}
+
+// expired reports whether the ban no longer applies.
+func (b *BanRecord) expired(now time.Time) bool {
+	return b.Expires != nil && !now.Before(*b.Expires)
+}
+
+// parseBanTarget parses an IP address, a CIDR subnet or a payout address into
+// its canonical form.
+func parseBanTarget(target string) (string, string, *net.IPNet, error) {
+	target = strings.TrimSpace(target)
+	if strings.Contains(target, "/") {
+		_, network, err := net.ParseCIDR(target)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("invalid subnet %q", target)
+		}
+		return network.String(), BanKindSubnet, network, nil
+	}
+	if ip := net.ParseIP(target); ip != nil {
+		return ip.String(), BanKindIP, nil, nil
+	}
+	address, err := parseAddress(target)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid ban target %q: not an IP, subnet or address", target)
+	}
+	return address, BanKindAddress, nil, nil
+}
+
+// remoteIP returns the IP of a remote address, or the address itself if it
+// has no port.
+func remoteIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// BanList keeps the bans and saves them to disk on every change.
+type BanList struct {
+	path string
+	bans map[string]*BanRecord // By target
+	mu   sync.RWMutex
+}
+
+// OpenBanList loads the ban list from a file, which is created on the first
+// ban if missing.
+func OpenBanList(path string) (*BanList, error) {
+	list := &BanList{path: path, bans: make(map[string]*BanRecord)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ban list: %w", err)
+	}
+	var records []*BanRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("corrupt ban list: %w", err)
+	}
+	now := time.Now()
+	for _, record := range records {
+		target, kind, network, err := parseBanTarget(record.Target)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt ban list: %w", err)
+		}
+		if record.expired(now) {
+			continue
+		}
+		record.Target, record.Kind, record.network = target, kind, network
+		list.bans[target] = record
+	}
+	return list, nil
+}
+
+// save writes the unexpired bans to disk, replacing the file atomically. The
+// lock must be held.
+func (l *BanList) save() error {
+	now := time.Now()
+	records := make([]*BanRecord, 0, len(l.bans))
+	for target, record := range l.bans {
+		if record.expired(now) {
+			delete(l.bans, target)
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Target < records[j].Target
+	})
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		return fmt.Errorf("failed to write ban list: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to replace ban list: %w", err)
+	}
+	return nil
+}
+
+// Add bans an IP address, subnet or payout address for the given duration, or
+// permanently if zero. Banning a target again replaces its previous ban.
+func (l *BanList) Add(target, reason string, duration time.Duration) (*BanRecord, error) {
+	target, kind, network, err := parseBanTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	record := &BanRecord{
+		Target:  target,
+		Kind:    kind,
+		Reason:  reason,
+		Created: time.Now(),
+		network: network,
+	}
+	if duration > 0 {
+		expires := record.Created.Add(duration)
+		record.Expires = &expires
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.bans[target] = record
+	return record, l.save()
+}
+
+// Remove lifts the ban of a target.
+func (l *BanList) Remove(target string) error {
+	target, _, _, err := parseBanTarget(target)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if record, ok := l.bans[target]; !ok || record.expired(time.Now()) {
+		return errUnknownBan
+	}
+	delete(l.bans, target)
+	return l.save()
+}
+
+// List returns the bans in effect, ordered by target.
+func (l *BanList) List() []BanRecord {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	now := time.Now()
+	records := make([]BanRecord, 0, len(l.bans))
+	for _, record := range l.bans {
+		if !record.expired(now) {
+			records = append(records, *record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Target < records[j].Target
+	})
+	return records
+}
+
+// CheckIP returns the ban of an IP address or of a subnet containing it, or
+// nil if it isn't banned.
+func (l *BanList) CheckIP(ip string) *BanRecord {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	now := time.Now()
+	if record := l.bans[parsed.String()]; record != nil && !record.expired(now) {
+		return record
+	}
+	for _, record := range l.bans {
+		if record.network != nil && record.network.Contains(parsed) && !record.expired(now) {
+			return record
+		}
+	}
+	return nil
+}
+
+// CheckAddress returns the ban of a checksummed payout address, or nil if it
+// isn't banned.
+func (l *BanList) CheckAddress(address string) *BanRecord {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if record := l.bans[address]; record != nil && record.Kind == BanKindAddress && !record.expired(time.Now()) {
+		return record
+	}
+	return nil
+}
+
+// banMinerIP bans the IP of a miner for the configured duration, so that it
+// can't start over by reconnecting.
+func (s *Server) banMinerIP(miner *Miner, reason string) {
+	duration := s.cfg().BanDuration
+	if s.bans == nil || duration <= 0 {
+		return
+	}
+	ip := remoteIP(miner.RemoteAddr)
+	if _, err := s.bans.Add(ip, reason, duration); err != nil {
+		log.Printf("❌ Failed to ban %s: %v", ip, err)
+		return
+	}
+	log.Printf("🚫 Banned IP %s for %s: %s", ip, duration, reason)
+}
+
+// disconnectBanned closes the connections of the miners matching a ban.
+func (s *Server) disconnectBanned() {
+	s.minersMu.RLock()
+	defer s.minersMu.RUnlock()
+
+	for _, miner := range s.miners {
+		miner.mu.RLock()
+		address := miner.Address
+		miner.mu.RUnlock()
+
+		ban := s.bans.CheckIP(remoteIP(miner.RemoteAddr))
+		if ban == nil && address != "" {
+			ban = s.bans.CheckAddress(address)
+		}
+		if ban != nil && miner.Conn != nil {
+			log.Printf("🚫 Disconnecting %s (%s): %s", miner.ID, miner.RemoteAddr, ban.Reason)
+			miner.Conn.Close()
+		}
+	}
+}
+
+// BanRequest adds a ban through the admin API.
+type BanRequest struct {
+	Target   string `json:"target"`
+	Reason   string `json:"reason"`
+	Duration string `json:"duration,omitempty"` // Go duration, permanent if empty
+}
+
+// authorizeAdmin checks the admin token of an API request, writing the error
+// response if it is missing or wrong.
+func (s *Server) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := s.cfg().AdminToken
+	if token == "" {
+		http.Error(w, "admin API disabled", http.StatusForbidden)
+		return false
+	}
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// handleAPIBans lists the bans on GET and adds one on POST.
+func (s *Server) handleAPIBans(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.bans == nil {
+		http.Error(w, "ban list disabled", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.bans.List())
+
+	case http.MethodPost:
+		var req BanRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			http.Error(w, "invalid ban: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		var duration time.Duration
+		if req.Duration != "" {
+			var err error
+			if duration, err = time.ParseDuration(req.Duration); err != nil || duration < 0 {
+				http.Error(w, "invalid ban duration", http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Reason == "" {
+			req.Reason = "Banned by operator"
+		}
+		record, err := s.bans.Add(req.Target, req.Reason, duration)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.stats.RecordBan(BanAdmin)
+		log.Printf("🚫 Operator banned %s %s: %s", record.Kind, record.Target, record.Reason)
+
+		s.disconnectBanned()
+		writeJSON(w, record)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAPIBan lifts a ban on DELETE /api/bans/{target}.
+func (s *Server) handleAPIBan(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.bans == nil {
+		http.Error(w, "ban list disabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	target := strings.TrimPrefix(r.URL.Path, "/api/bans/")
+	switch err := s.bans.Remove(target); {
+	case errors.Is(err, errUnknownBan):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("✅ Operator lifted the ban of %s", target)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBanList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	list, err := OpenBanList(path)
+	if err != nil {
+		t.Fatalf("open ban list: %v", err)
+	}
+	for _, ban := range []struct {
+		target   string
+		duration time.Duration
+	}{
+		{"203.0.113.7", 0},
+		{"198.51.100.0/24", time.Hour},
+		{"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", 0},
+		{"192.0.2.1", time.Nanosecond},
+	} {
+		if _, err := list.Add(ban.target, "test", ban.duration); err != nil {
+			t.Fatalf("ban %s: %v", ban.target, err)
+		}
+	}
+	if _, err := list.Add("not a target", "test", 0); err == nil {
+		t.Fatalf("invalid target banned")
+	}
+	time.Sleep(time.Millisecond)
+
+	// Bans survive a restart, expired ones are dropped
+	if list, err = OpenBanList(path); err != nil {
+		t.Fatalf("reopen ban list: %v", err)
+	}
+	if bans := list.List(); len(bans) != 3 {
+		t.Fatalf("bans after reopen: have %+v, want 3", bans)
+	}
+	for ip, banned := range map[string]bool{
+		"203.0.113.7":         true,
+		"198.51.100.42":       true,
+		"198.51.101.1":        false,
+		"192.0.2.1":           false, // Expired
+		"::ffff:198.51.100.9": true,  // IPv4 mapped to IPv6
+		"garbage":             false,
+	} {
+		if have := list.CheckIP(ip) != nil; have != banned {
+			t.Errorf("CheckIP(%s): have %v, want %v", ip, have, banned)
+		}
+	}
+	if list.CheckAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed") == nil {
+		t.Errorf("banned address not found")
+	}
+	if err := list.Remove("198.51.100.0/24"); err != nil {
+		t.Fatalf("remove subnet ban: %v", err)
+	}
+	if list.CheckIP("198.51.100.42") != nil {
+		t.Errorf("subnet still banned after removal")
+	}
+	if err := list.Remove("198.51.100.0/24"); !errors.Is(err, errUnknownBan) {
+		t.Errorf("remove twice: have %v, want %v", err, errUnknownBan)
+	}
+}
+
+func TestConnectionBansAndLimits(t *testing.T) {
+	bans, err := OpenBanList(filepath.Join(t.TempDir(), "bans.json"))
+	if err != nil {
+		t.Fatalf("open ban list: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &Server{
+		config:   &ServerConfig{InitialDiff: 1000, MaxConnectionsPerIP: 1},
+		miners:   make(map[string]*Miner),
+		stats:    NewStats(),
+		bans:     bans,
+		stopCh:   make(chan struct{}),
+		listener: listener,
+	}
+	srv.wg.Add(1)
+	go srv.acceptConnections(listener, 1000)
+	defer func() {
+		close(srv.stopCh)
+		srv.closeListeners()
+		srv.wg.Wait()
+	}()
+
+	// closed reports whether the proxy closed a connection without reading
+	closed := func(conn net.Conn) bool {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		_, err := conn.Read(make([]byte, 1))
+
+		var netErr net.Error
+		return err != nil && !(errors.As(err, &netErr) && netErr.Timeout())
+	}
+	first, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer first.Close()
+	if closed(first) {
+		t.Fatalf("first connection closed")
+	}
+	second, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer second.Close()
+	if !closed(second) {
+		t.Fatalf("connection over the per-IP limit accepted")
+	}
+
+	// Banning the IP kicks the connected miner and refuses new connections
+	if _, err := bans.Add("127.0.0.0/8", "test", time.Hour); err != nil {
+		t.Fatalf("ban: %v", err)
+	}
+	srv.disconnectBanned()
+	if !closed(first) {
+		t.Fatalf("banned miner not disconnected")
+	}
+	time.Sleep(50 * time.Millisecond) // Let the connection count drop
+	third, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer third.Close()
+	if !closed(third) {
+		t.Fatalf("connection from banned subnet accepted")
+	}
+	srv.stats.mu.RLock()
+	defer srv.stats.mu.RUnlock()
+	if have := srv.stats.ConnectionsRejected; have[ConnRejectIPLimit] != 1 || have[ConnRejectBanned] != 1 {
+		t.Fatalf("rejected connections: have %v", have)
+	}
+}
+
+func TestAPIBans(t *testing.T) {
+	bans, err := OpenBanList(filepath.Join(t.TempDir(), "bans.json"))
+	if err != nil {
+		t.Fatalf("open ban list: %v", err)
+	}
+	srv := newAPITestServer()
+	srv.bans = bans
+	srv.config.AdminToken = "secret"
+
+	request := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		if path == "/api/bans" {
+			srv.handleAPIBans(rec, req)
+		} else {
+			srv.handleAPIBan(rec, req)
+		}
+		return rec
+	}
+	if rec := request(http.MethodGet, "/api/bans", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("list without token: have %d", rec.Code)
+	}
+	if rec := request(http.MethodGet, "/api/bans", "", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("list with wrong token: have %d", rec.Code)
+	}
+	if rec := request(http.MethodPost, "/api/bans", `{"target":"10.0.0.0/8","reason":"abuse","duration":"24h"}`, "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("add ban: have %d: %s", rec.Code, rec.Body)
+	}
+	if rec := request(http.MethodPost, "/api/bans", `{"target":"10.0.0.1","duration":"soon"}`, "secret"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("add ban with invalid duration: have %d", rec.Code)
+	}
+	rec := request(http.MethodGet, "/api/bans", "", "secret")
+	if !strings.Contains(rec.Body.String(), `"target":"10.0.0.0/8"`) || !strings.Contains(rec.Body.String(), `"reason":"abuse"`) {
+		t.Fatalf("ban list: %s", rec.Body)
+	}
+	if rec := request(http.MethodDelete, "/api/bans/10.0.0.0/8", "", "secret"); rec.Code != http.StatusNoContent {
+		t.Fatalf("remove ban: have %d: %s", rec.Code, rec.Body)
+	}
+	if rec := request(http.MethodDelete, "/api/bans/10.0.0.0/8", "", "secret"); rec.Code != http.StatusNotFound {
+		t.Fatalf("remove missing ban: have %d", rec.Code)
+	}
+	if srv.stats.Bans[BanAdmin] != 1 {
+		t.Fatalf("admin bans: have %d, want 1", srv.stats.Bans[BanAdmin])
+	}
+
+	srv.config.AdminToken = ""
+	if rec := request(http.MethodGet, "/api/bans", "", "secret"); rec.Code != http.StatusForbidden {
+		t.Fatalf("list with admin API disabled: have %d", rec.Code)
+	}
+}
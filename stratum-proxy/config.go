@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// configFlag is the flag naming the configuration file, which can't be set
+// from the file itself.
+const configFlag = "config"
+
+// upstreamsSetting is the configuration file key listing Geth nodes with
+// explicit priorities, as an alternative to the comma separated "geth" flag.
+const upstreamsSetting = "upstreams"
+
+// fileSettings are the flags set from the configuration file on the last
+// load, reset to their default if removed from the file.
+var fileSettings map[string]bool
+
+// configSetting is a ServerConfig setting, named after its flag.
+type configSetting struct {
+	name  string
+	value func(*ServerConfig) interface{}
+}
+
+// liveSettings are the settings applied to a running server on reload. They
+// are read on every share, login, health check or payout.
+var liveSettings = []configSetting{
+	{"pool-fee", func(c *ServerConfig) interface{} { return c.PoolFee }},
+	{"vardiff-target", func(c *ServerConfig) interface{} { return c.VarDiffTarget }},
+	{"vardiff-window", func(c *ServerConfig) interface{} { return c.VarDiffWindow }},
+	{"max-invalid-streak", func(c *ServerConfig) interface{} { return c.MaxInvalidStreak }},
+	{"max-connections", func(c *ServerConfig) interface{} { return c.MaxConnections }},
+	{"max-connections-per-ip", func(c *ServerConfig) interface{} { return c.MaxConnectionsPerIP }},
+	{"ban-duration", func(c *ServerConfig) interface{} { return c.BanDuration }},
+	{"admin-token", func(c *ServerConfig) interface{} { return c.AdminToken }},
+	{"share-rate-limit", func(c *ServerConfig) interface{} { return c.ShareRateLimit }},
+	{"spot-check-rate", func(c *ServerConfig) interface{} { return c.SpotCheckRate }},
+	{"trust-after", func(c *ServerConfig) interface{} { return c.TrustThreshold }},
+	{"pplns-window", func(c *ServerConfig) interface{} { return c.PPLNSWindow }},
+	{"confirmations", func(c *ServerConfig) interface{} { return c.BlockConfirmations }},
+	{"payout-threshold", func(c *ServerConfig) interface{} { return fmt.Sprint(c.PayoutThreshold) }},
+	{"worker-grace", func(c *ServerConfig) interface{} { return c.WorkerGracePeriod }},
+	{"max-lag", func(c *ServerConfig) interface{} { return c.MaxUpstreamLag }},
+	{"v", func(c *ServerConfig) interface{} { return c.Verbose }},
+}
+
+// restartSettings are the settings only read at startup: listeners, upstream
+// connections, the ledger and the RandomX verifier.
+var restartSettings = []configSetting{
+	{"stratum", func(c *ServerConfig) interface{} { return c.ListenAddr }},
+	{"diff", func(c *ServerConfig) interface{} { return c.InitialDiff }},
+	{"ports", func(c *ServerConfig) interface{} { return fmt.Sprint(c.Ports) }},
+	{"tls-cert", func(c *ServerConfig) interface{} { return c.TLSCertFile }},
+	{"tls-key", func(c *ServerConfig) interface{} { return c.TLSKeyFile }},
+	{"api", func(c *ServerConfig) interface{} { return c.APIAddr }},
+	{"geth", func(c *ServerConfig) interface{} { return fmt.Sprint(c.GethRPC, c.Upstreams) }},
+	{"health-interval", func(c *ServerConfig) interface{} { return c.HealthCheckInterval }},
+	{"pool-addr", func(c *ServerConfig) interface{} { return c.PoolAddress }},
+	{"ledger", func(c *ServerConfig) interface{} { return c.LedgerDir }},
+	{"bans", func(c *ServerConfig) interface{} { return c.BanFile }},
+	{"payout-scheme", func(c *ServerConfig) interface{} { return c.PayoutScheme }},
+	{"payout-interval", func(c *ServerConfig) interface{} { return c.PayoutInterval }},
+	{"verify-shares", func(c *ServerConfig) interface{} { return c.VerifyShares }},
+	{"verify-full", func(c *ServerConfig) interface{} { return c.VerifyFullMode }},
+	{"algo", func(c *ServerConfig) interface{} { return c.Algorithm }},
+}
+
+// changedSettings returns the names of the settings that differ between two
+// configurations.
+func changedSettings(current, next *ServerConfig, settings []configSetting) []string {
+	var changed []string
+	for _, setting := range settings {
+		if setting.value(current) != setting.value(next) {
+			changed = append(changed, setting.name)
+		}
+	}
+	return changed
+}
+
+// validate checks the settings that would otherwise break the server.
+func (c *ServerConfig) validate() error {
+	switch {
+	case c.PoolFee < 0 || c.PoolFee > 100:
+		return fmt.Errorf("pool fee out of range: %v", c.PoolFee)
+	case c.VarDiffTarget < 0:
+		return fmt.Errorf("negative vardiff target: %v", c.VarDiffTarget)
+	case c.MaxConnections < 0 || c.MaxConnectionsPerIP < 0:
+		return errors.New("negative connection limit")
+	case c.BanDuration < 0:
+		return fmt.Errorf("negative ban duration: %v", c.BanDuration)
+	case c.ShareRateLimit < 0:
+		return fmt.Errorf("negative share rate limit: %v", c.ShareRateLimit)
+	case c.SpotCheckRate < 0 || c.SpotCheckRate > 1:
+		return fmt.Errorf("spot check rate out of range: %v", c.SpotCheckRate)
+	}
+	if c.PoolAddress != "" && c.LedgerDir != "" {
+		if c.PayoutScheme != SchemePPLNS && c.PayoutScheme != SchemePPS {
+			return fmt.Errorf("unknown payout scheme: %q", c.PayoutScheme)
+		}
+		if c.PayoutThreshold == nil || c.PayoutInterval <= 0 {
+			return errors.New("payout threshold and interval required in pool mode")
+		}
+		if c.PPLNSWindow <= 0 {
+			return fmt.Errorf("invalid PPLNS window: %v", c.PPLNSWindow)
+		}
+	}
+	return nil
+}
+
+// cfg returns the current configuration. It is replaced as a whole on reload,
+// never modified.
+func (s *Server) cfg() *ServerConfig {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+
+	return s.config
+}
+
+// Reload applies a new configuration to the running server without
+// disconnecting miners. Settings only read at startup keep their current
+// value until the next restart.
+func (s *Server) Reload(config *ServerConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	current := s.cfg()
+
+	for _, name := range changedSettings(current, config, restartSettings) {
+		log.Printf("⚠️  Setting %s changed, restart the proxy to apply it", name)
+	}
+	next := *current
+	next.PoolFee = config.PoolFee
+	next.VarDiffTarget = config.VarDiffTarget
+	next.VarDiffWindow = config.VarDiffWindow
+	next.MaxInvalidStreak = config.MaxInvalidStreak
+	next.MaxConnections = config.MaxConnections
+	next.MaxConnectionsPerIP = config.MaxConnectionsPerIP
+	next.BanDuration = config.BanDuration
+	next.AdminToken = config.AdminToken
+	next.ShareRateLimit = config.ShareRateLimit
+	next.SpotCheckRate = config.SpotCheckRate
+	next.TrustThreshold = config.TrustThreshold
+	next.PPLNSWindow = config.PPLNSWindow
+	next.BlockConfirmations = config.BlockConfirmations
+	next.PayoutThreshold = config.PayoutThreshold
+	next.WorkerGracePeriod = config.WorkerGracePeriod
+	next.MaxUpstreamLag = config.MaxUpstreamLag
+	next.Verbose = config.Verbose
+
+	if s.verifier != nil {
+		s.verifier.SetPolicy(next.SpotCheckRate, next.TrustThreshold)
+	}
+	if s.upstreams != nil {
+		s.upstreams.SetMaxLag(next.MaxUpstreamLag)
+	}
+	s.configMu.Lock()
+	s.config = &next
+	s.configMu.Unlock()
+
+	if changed := changedSettings(current, &next, liveSettings); len(changed) > 0 {
+		log.Printf("🔄 Configuration reloaded, applied %s", strings.Join(changed, ", "))
+	} else {
+		log.Printf("🔄 Configuration reloaded, no live setting changed")
+	}
+	return nil
+}
+
+// loadConfig builds the server configuration from the configuration file, if
+// any, and the flags set on the command line, which take precedence. Settings
+// missing from both get their flag default. On error, the flags are left as
+// they were.
+func loadConfig(path string, overrides map[string]bool) (config *ServerConfig, err error) {
+	previous := make(map[*flag.Flag]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		previous[f] = f.Value.String()
+	})
+	defer func() {
+		if err != nil {
+			for f, value := range previous {
+				f.Value.Set(value)
+			}
+		}
+	}()
+
+	var (
+		upstreams []UpstreamConfig
+		settings  map[string]bool
+	)
+	if path != "" {
+		if upstreams, settings, err = applyConfigFile(path, overrides); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+	if config, err = newConfig(upstreams); err != nil {
+		return nil, err
+	}
+	if err = config.validate(); err != nil {
+		return nil, err
+	}
+	fileSettings = settings
+	return config, nil
+}
+
+// applyConfigFile sets the flags from a JSON configuration file, except those
+// set on the command line. Keys are flag names, values strings, numbers or
+// booleans, durations are strings like "10m". The "ports" and "geth" flags
+// also take lists. It returns the upstreams listed with explicit priorities
+// and the flags set in the file.
+func applyConfigFile(path string, overrides map[string]bool) ([]UpstreamConfig, map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var raws map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, nil, err
+	}
+	values := make(map[string]string)
+	for name, raw := range raws {
+		if name == upstreamsSetting {
+			continue
+		}
+		if flag.Lookup(name) == nil || name == configFlag {
+			return nil, nil, fmt.Errorf("unknown setting %q", name)
+		}
+		value, err := configValue(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("setting %q: %w", name, err)
+		}
+		values[name] = value
+	}
+	var upstreams []UpstreamConfig
+	if raw, ok := raws[upstreamsSetting]; ok && !overrides["geth"] {
+		if _, ok := values["geth"]; ok {
+			return nil, nil, fmt.Errorf("both %q and \"geth\" set", upstreamsSetting)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&upstreams); err != nil {
+			return nil, nil, fmt.Errorf("setting %q: %w", upstreamsSetting, err)
+		}
+		for _, upstream := range upstreams {
+			if upstream.URL == "" {
+				return nil, nil, fmt.Errorf("setting %q: upstream without url", upstreamsSetting)
+			}
+		}
+	}
+
+	// Settings removed from the file since the last load get their default
+	settings := make(map[string]bool)
+	for name := range values {
+		settings[name] = true
+	}
+	for name := range fileSettings {
+		if _, ok := values[name]; !ok {
+			values[name] = flag.Lookup(name).DefValue
+		}
+	}
+	for name, value := range values {
+		if overrides[name] {
+			continue
+		}
+		if err := flag.Lookup(name).Value.Set(value); err != nil {
+			return nil, nil, fmt.Errorf("setting %q: %w", name, err)
+		}
+	}
+	return upstreams, settings, nil
+}
+
+// configValue converts a JSON setting to its flag syntax.
+func configValue(raw json.RawMessage) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("list item %v is not a string", item)
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %s", raw)
+}
+
+// commandLineFlags returns the names of the flags set on the command line.
+func commandLineFlags() map[string]bool {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// resetFlags restores the flags changed by a test.
+func resetFlags(t *testing.T) {
+	previous := make(map[*flag.Flag]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		previous[f] = f.Value.String()
+	})
+	settings := fileSettings
+	t.Cleanup(func() {
+		fileSettings = settings
+		for f, value := range previous {
+			f.Value.Set(value)
+		}
+	})
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	resetFlags(t)
+	path := filepath.Join(t.TempDir(), "proxy.json")
+
+	writeConfig(t, path, `{
+		"stratum": "0.0.0.0:4444",
+		"pool-fee": 2.5,
+		"vardiff-window": 20,
+		"worker-grace": "1h",
+		"verify-shares": false,
+		"ports": ["tls://0.0.0.0:3443", "0.0.0.0:3334/100000"],
+		"upstreams": [
+			{"url": "http://backup:8545", "priority": 2},
+			{"url": "http://primary:8545", "priority": 1}
+		]
+	}`)
+	// The share rate limit is set on the command line and wins over the file
+	*shareRateLimit = 5
+	config, err := loadConfig(path, map[string]bool{"share-rate-limit": true})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if config.ListenAddr != "0.0.0.0:4444" || config.PoolFee != 2.5 || config.VarDiffWindow != 20 ||
+		config.WorkerGracePeriod != time.Hour || config.VerifyShares || config.ShareRateLimit != 5 {
+		t.Fatalf("settings not applied: %+v", config)
+	}
+	if len(config.Ports) != 2 || !config.Ports[0].TLS || config.Ports[1].InitialDiff != 100000 {
+		t.Fatalf("ports: have %+v", config.Ports)
+	}
+	want := []UpstreamConfig{{URL: "http://backup:8545", Priority: 2}, {URL: "http://primary:8545", Priority: 1}}
+	if !reflect.DeepEqual(config.Upstreams, want) {
+		t.Fatalf("upstreams: have %+v, want %+v", config.Upstreams, want)
+	}
+
+	// Settings removed from the file return to their defaults
+	writeConfig(t, path, `{"pool-fee": 3}`)
+	if config, err = loadConfig(path, map[string]bool{"share-rate-limit": true}); err != nil {
+		t.Fatalf("reload config: %v", err)
+	}
+	if config.ListenAddr != "0.0.0.0:3333" || config.PoolFee != 3 || config.ShareRateLimit != 5 {
+		t.Fatalf("settings after reload: %+v", config)
+	}
+
+	// Invalid files are rejected without touching the flags
+	for _, content := range []string{
+		`{"pool-fee": 3`,
+		`{"no-such-setting": 1}`,
+		`{"config": "other.json"}`,
+		`{"vardiff-window": "often"}`,
+		`{"pool-fee": 150}`,
+		`{"geth": "http://a:8545", "upstreams": [{"url": "http://b:8545"}]}`,
+		`{"upstreams": [{"host": "b"}]}`,
+	} {
+		writeConfig(t, path, content)
+		if _, err := loadConfig(path, nil); err == nil {
+			t.Errorf("invalid config %s accepted", content)
+		}
+		if *poolFee != 3 {
+			t.Fatalf("flags changed by invalid config %s", content)
+		}
+	}
+}
+
+func TestServerReload(t *testing.T) {
+	hasher := &fakeHasher{}
+	srv := &Server{
+		config: &ServerConfig{
+			ListenAddr:    "0.0.0.0:3333",
+			PoolFee:       1,
+			VarDiffTarget: 30,
+			SpotCheckRate: 1,
+		},
+		upstreams: NewUpstreamPool(nil, 2),
+		verifier:  NewShareVerifier(hasher, 1, 100),
+	}
+	next := *srv.cfg()
+	next.ListenAddr = "0.0.0.0:4444"
+	next.PoolFee = 2
+	next.VarDiffTarget = 10
+	next.SpotCheckRate = 0
+	next.TrustThreshold = 0
+	next.MaxUpstreamLag = 5
+
+	if err := srv.Reload(&next); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	config := srv.cfg()
+	if config.PoolFee != 2 || config.VarDiffTarget != 10 {
+		t.Fatalf("live settings not applied: %+v", config)
+	}
+	if config.ListenAddr != "0.0.0.0:3333" {
+		t.Fatalf("listen address changed without restart: %s", config.ListenAddr)
+	}
+	if srv.verifier.ShouldVerify(0, false) {
+		t.Fatalf("spot check policy not applied")
+	}
+	if srv.upstreams.maxLag != 5 {
+		t.Fatalf("upstream lag: have %d, want 5", srv.upstreams.maxLag)
+	}
+
+	next.PoolFee = -1
+	if err := srv.Reload(&next); err == nil {
+		t.Fatalf("invalid config applied")
+	}
+	if srv.cfg().PoolFee != 2 {
+		t.Fatalf("invalid config partially applied")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+// maxJobHistory is the number of recent jobs per miner that shares are still
+// accepted for. Geth hands out new work on every recommit, so a miner usually
+// has shares in flight for the previous job when the next one arrives.
+const maxJobHistory = 8
+
+// minerJob is a job sent to a miner, along with the nonces submitted for it.
+type minerJob struct {
+	job    *Job
+	nonces map[uint32]struct{} // Miner nonces already submitted for this job
+}
+
+// submit records a nonce submitted for the job, returning false if it was
+// submitted before.
+func (j *minerJob) submit(nonce uint32) bool {
+	if _, ok := j.nonces[nonce]; ok {
+		return false
+	}
+	j.nonces[nonce] = struct{}{}
+	return true
+}
+
+// jobHistory is a bounded list of the most recent jobs sent to a miner. It is
+// protected by the miner lock.
+type jobHistory struct {
+	jobs []*minerJob // Oldest first
+}
+
+// add records a job sent to the miner, evicting the oldest one if the history
+// is full. Resending a known job (e.g. with a new target) keeps its nonces.
+func (h *jobHistory) add(job *Job) {
+	for i, known := range h.jobs {
+		if known.job.JobID == job.JobID {
+			h.jobs = append(append(h.jobs[:i:i], h.jobs[i+1:]...), known)
+			return
+		}
+	}
+	h.jobs = append(h.jobs, &minerJob{job: job, nonces: make(map[uint32]struct{})})
+	if len(h.jobs) > maxJobHistory {
+		h.jobs = h.jobs[len(h.jobs)-maxJobHistory:]
+	}
+}
+
+// get returns the job with the given ID, or nil if it's unknown or expired.
+func (h *jobHistory) get(jobID string) *minerJob {
+	for _, known := range h.jobs {
+		if known.job.JobID == jobID {
+			return known
+		}
+	}
+	return nil
+}
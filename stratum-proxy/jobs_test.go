@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestJobHistory(t *testing.T) {
+	var history jobHistory
+	for i := 0; i < maxJobHistory+2; i++ {
+		history.add(&Job{JobID: fmt.Sprint(i)})
+	}
+	// The oldest jobs are evicted
+	for i := 0; i < 2; i++ {
+		if history.get(fmt.Sprint(i)) != nil {
+			t.Errorf("job %d not evicted", i)
+		}
+	}
+	for i := 2; i < maxJobHistory+2; i++ {
+		if history.get(fmt.Sprint(i)) == nil {
+			t.Errorf("job %d missing", i)
+		}
+	}
+	// Resending a job keeps its nonces and makes it the most recent
+	entry := history.get("2")
+	if !entry.submit(1) {
+		t.Fatalf("first submission rejected")
+	}
+	if entry.submit(1) {
+		t.Fatalf("duplicate submission accepted")
+	}
+	history.add(&Job{JobID: "2"})
+	history.add(&Job{JobID: "100"})
+
+	if history.get("3") != nil {
+		t.Errorf("job 3 not evicted after resending job 2")
+	}
+	if entry := history.get("2"); entry == nil || entry.submit(1) {
+		t.Errorf("resent job lost its nonces")
+	}
+}
+
+func TestSubmitJobHistory(t *testing.T) {
+	srv := &Server{
+		config: &ServerConfig{InitialDiff: 1, VarDiffTarget: 30},
+		stats:  NewStats(),
+	}
+	miner := &Miner{ID: "test", Difficulty: 1}
+
+	submit := func(jobID string, nonce string) *StratumResponse {
+		params, _ := json.Marshal(map[string]interface{}{
+			"job_id": jobID,
+			"nonce":  nonce,
+			"result": "deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+		})
+		return srv.handleSubmit(miner, &StratumRequest{ID: 1, Method: "submit", Params: params})
+	}
+	header := "0x0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	// Two jobs for the same height, e.g. after a recommit
+	for _, job := range []*Job{{JobID: "1", Height: 10, HeaderHash: header}, {JobID: "2", Height: 10, HeaderHash: header}} {
+		srv.currentJob = job
+		miner.Jobs.add(job)
+	}
+	if resp := submit("1", "00000001"); resp.Error != nil {
+		t.Fatalf("share for previous job of same height rejected: %v", resp.Error)
+	}
+	if resp := submit("2", "00000001"); resp.Error != nil {
+		t.Fatalf("share for current job rejected: %v", resp.Error)
+	}
+	if resp := submit("2", "00000001"); resp.Error == nil {
+		t.Fatalf("duplicate share accepted")
+	}
+	// A new block makes the older jobs stale
+	srv.currentJob = &Job{JobID: "3", Height: 11, HeaderHash: header}
+	miner.Jobs.add(srv.currentJob)
+
+	if resp := submit("2", "00000002"); resp.Error == nil {
+		t.Fatalf("share for outdated height accepted")
+	}
+	if resp := submit("unknown", "00000002"); resp.Error == nil {
+		t.Fatalf("share for unknown job accepted")
+	}
+	if miner.SharesValid != 2 || miner.SharesInvalid != 1 || miner.SharesStale != 2 {
+		t.Fatalf("share counters: have valid %d, invalid %d, stale %d, want 2, 1, 2",
+			miner.SharesValid, miner.SharesInvalid, miner.SharesStale)
+	}
+	_, _, shares, valid, invalid, stale, _, _, _ := srv.stats.GetStats()
+	if shares != 5 || valid != 2 || invalid != 1 || stale != 2 {
+		t.Fatalf("server stats: have %d shares (%d valid, %d invalid, %d stale), want 5 (2, 1, 2)",
+			shares, valid, invalid, stale)
+	}
+}
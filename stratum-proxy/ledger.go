@@ -0,0 +1,619 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Share ledger
+//
+// The ledger keeps the pool accounting on disk: shares, found blocks, miner
+// balances and payouts. Every change is appended to a journal as a JSON line
+// before it is applied in memory, and the journal is replayed on startup. The
+// journal is periodically folded into a snapshot to keep restarts fast.
+
+const (
+	ledgerSnapshotFile = "ledger.json"
+	ledgerJournalFile  = "journal.jsonl"
+
+	// ledgerCompactInterval is the number of journal entries after which the
+	// journal is folded into the snapshot.
+	ledgerCompactInterval = 100000
+)
+
+// Block states tracked by the ledger.
+const (
+	BlockPending  = "pending"  // Found, waiting for confirmations
+	BlockMatured  = "matured"  // Confirmed in the canonical chain, rewards credited
+	BlockOrphaned = "orphaned" // Not in the canonical chain
+)
+
+// Payout states tracked by the ledger.
+const (
+	PayoutCreated   = "created"   // Balance deducted, transaction not signed yet
+	PayoutSigned    = "signed"    // Transaction signed, possibly broadcast
+	PayoutConfirmed = "confirmed" // Transaction included successfully
+	PayoutFailed    = "failed"    // Transaction reverted, balance restored
+)
+
+var errUnknownPayout = errors.New("unknown payout")
+
+// BlockRecord is a block found by the pool.
+type BlockRecord struct {
+	Height  uint64              `json:"height"`
+	Nonce   uint64              `json:"nonce"`
+	Hash    string              `json:"hash,omitempty"`
+	Finder  string              `json:"finder"`
+	Found   time.Time           `json:"found"`
+	Status  string              `json:"status"`
+	Reward  *big.Int            `json:"reward,omitempty"`
+	Shares  map[string]uint64   `json:"shares"` // Difficulty per address the reward is split by (PPLNS)
+	Credits map[string]*big.Int `json:"credits,omitempty"`
+}
+
+// PayoutRecord is a payment of a miner balance.
+type PayoutRecord struct {
+	ID      uint64    `json:"id"`
+	Address string    `json:"address"`
+	Amount  *big.Int  `json:"amount"`
+	Nonce   uint64    `json:"nonce"` // Account nonce of the payout transaction
+	TxHash  string    `json:"txHash,omitempty"`
+	RawTx   string    `json:"rawTx,omitempty"`
+	Status  string    `json:"status"`
+	Created time.Time `json:"created"`
+}
+
+// windowShare is a share in the PPLNS window.
+type windowShare struct {
+	Address    string `json:"address"`
+	Difficulty uint64 `json:"difficulty"`
+}
+
+// ledgerState is the accounting state, as stored in the snapshot.
+type ledgerState struct {
+	Seq uint64 `json:"seq"` // Sequence number of the last applied journal entry
+
+	Window     []windowShare     `json:"window"`     // Last shares, up to the PPLNS window
+	WindowDiff uint64            `json:"windowDiff"` // Total difficulty of the window
+	Round      map[string]uint64 `json:"round"`      // Difficulty per address since the last block
+
+	Blocks   []*BlockRecord      `json:"blocks"`
+	Balances map[string]*big.Int `json:"balances"` // Unpaid balances
+	Paid     map[string]*big.Int `json:"paid"`     // Total paid out
+	Revenue  *big.Int            `json:"revenue"`  // Pool fees collected
+
+	Payouts    []*PayoutRecord `json:"payouts"` // Unfinished payouts
+	NextPayout uint64          `json:"nextPayout"`
+}
+
+// ledgerEntry is a journal entry, one change of the ledger state.
+type ledgerEntry struct {
+	Seq  uint64    `json:"seq"`
+	Kind string    `json:"kind"`
+	Time time.Time `json:"time"`
+
+	Address    string              `json:"address,omitempty"`
+	Difficulty uint64              `json:"difficulty,omitempty"`
+	Window     uint64              `json:"window,omitempty"` // PPLNS window in difficulty
+	Credit     *big.Int            `json:"credit,omitempty"` // PPS credit of a share
+	Block      *BlockRecord        `json:"block,omitempty"`
+	Credits    map[string]*big.Int `json:"credits,omitempty"`
+	Fee        *big.Int            `json:"fee,omitempty"`
+	Payout     *PayoutRecord       `json:"payout,omitempty"`
+}
+
+// Journal entry kinds.
+const (
+	entryShare        = "share"
+	entryBlock        = "block"
+	entryBlockMatured = "block-matured"
+	entryBlockOrphan  = "block-orphaned"
+	entryPayout       = "payout"
+	entryPayoutSigned = "payout-signed"
+	entryPayoutDone   = "payout-done"
+)
+
+// Ledger is the persistent pool accounting.
+type Ledger struct {
+	dir     string
+	state   *ledgerState
+	journal *os.File
+	writer  *bufio.Writer
+	entries int // Journal entries since the last snapshot
+	mu      sync.Mutex
+}
+
+// newLedgerState creates an empty ledger state.
+func newLedgerState() *ledgerState {
+	return &ledgerState{
+		Round:    make(map[string]uint64),
+		Balances: make(map[string]*big.Int),
+		Paid:     make(map[string]*big.Int),
+		Revenue:  new(big.Int),
+	}
+}
+
+// OpenLedger opens the ledger stored in dir, creating it if needed, and
+// replays its journal.
+func OpenLedger(dir string) (*Ledger, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+	l := &Ledger{dir: dir, state: newLedgerState()}
+
+	// Load the last snapshot and replay the journal on top
+	if data, err := os.ReadFile(filepath.Join(dir, ledgerSnapshotFile)); err == nil {
+		if err := json.Unmarshal(data, l.state); err != nil {
+			return nil, fmt.Errorf("corrupt ledger snapshot: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read ledger snapshot: %w", err)
+	}
+	replayed, err := l.replay()
+	if err != nil {
+		return nil, err
+	}
+	// Fold the replayed journal into a fresh snapshot and start a new journal
+	if err := l.compact(); err != nil {
+		return nil, err
+	}
+	if replayed > 0 {
+		log.Printf("📒 Ledger: replayed %d journal entries", replayed)
+	}
+	return l, nil
+}
+
+// replay applies the journal entries on top of the loaded snapshot. A torn
+// last line, left by a crash during a write, is ignored.
+func (l *Ledger) replay() (int, error) {
+	file, err := os.Open(filepath.Join(l.dir, ledgerJournalFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open ledger journal: %w", err)
+	}
+	defer file.Close()
+
+	var (
+		scanner = bufio.NewScanner(file)
+		count   int
+		torn    bool
+	)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if torn {
+			return count, errors.New("corrupt ledger journal: invalid entry before the last one")
+		}
+		var entry ledgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			torn = true
+			continue
+		}
+		if entry.Seq <= l.state.Seq {
+			continue // already in the snapshot
+		}
+		l.apply(&entry)
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("failed to read ledger journal: %w", err)
+	}
+	return count, nil
+}
+
+// compact writes the current state to the snapshot and truncates the journal.
+// The snapshot is replaced atomically, entries of the old journal still there
+// after a crash are skipped on replay by their sequence number.
+func (l *Ledger) compact() error {
+	if l.journal != nil {
+		if err := l.writer.Flush(); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(l.state)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(l.dir, ledgerSnapshotFile+".tmp")
+	if err := writeFileSync(tmp, data); err != nil {
+		return fmt.Errorf("failed to write ledger snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(l.dir, ledgerSnapshotFile)); err != nil {
+		return fmt.Errorf("failed to replace ledger snapshot: %w", err)
+	}
+	if l.journal != nil {
+		l.journal.Close()
+		l.journal = nil
+	}
+	journal, err := os.OpenFile(filepath.Join(l.dir, ledgerJournalFile), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger journal: %w", err)
+	}
+	l.journal, l.writer, l.entries = journal, bufio.NewWriter(journal), 0
+	return nil
+}
+
+// writeFileSync writes a file and flushes it to disk.
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// commit journals an entry and applies it. Entries moving funds are synced to
+// disk before returning, shares are only flushed to the OS. The ledger lock
+// must be held.
+func (l *Ledger) commit(entry *ledgerEntry, sync bool) error {
+	if l.journal == nil {
+		return errors.New("ledger closed")
+	}
+	entry.Seq, entry.Time = l.state.Seq+1, time.Now()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := l.writer.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write ledger journal: %w", err)
+	}
+	if err := l.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write ledger journal: %w", err)
+	}
+	if sync {
+		if err := l.journal.Sync(); err != nil {
+			return fmt.Errorf("failed to sync ledger journal: %w", err)
+		}
+	}
+	l.apply(entry)
+
+	if l.entries++; l.entries >= ledgerCompactInterval {
+		if err := l.compact(); err != nil {
+			log.Printf("⚠️  Ledger compaction failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// apply applies a journal entry to the state. It must be deterministic, as it
+// is used for both live changes and replays.
+func (l *Ledger) apply(entry *ledgerEntry) {
+	state := l.state
+	state.Seq = entry.Seq
+
+	switch entry.Kind {
+	case entryShare:
+		state.Round[entry.Address] += entry.Difficulty
+
+		state.Window = append(state.Window, windowShare{Address: entry.Address, Difficulty: entry.Difficulty})
+		state.WindowDiff += entry.Difficulty
+		for entry.Window > 0 && len(state.Window) > 1 && state.WindowDiff-state.Window[0].Difficulty >= entry.Window {
+			state.WindowDiff -= state.Window[0].Difficulty
+			state.Window = state.Window[1:]
+		}
+		if entry.Credit != nil {
+			addBalance(state.Balances, entry.Address, entry.Credit)
+		}
+
+	case entryBlock:
+		state.Blocks = append(state.Blocks, entry.Block)
+		state.Round = make(map[string]uint64)
+
+	case entryBlockMatured, entryBlockOrphan:
+		block := state.block(entry.Block.Height, entry.Block.Nonce)
+		if block == nil || block.Status != BlockPending {
+			return
+		}
+		block.Hash = entry.Block.Hash
+		if entry.Kind == entryBlockOrphan {
+			block.Status = BlockOrphaned
+			return
+		}
+		block.Status = BlockMatured
+		block.Reward = entry.Block.Reward
+		block.Credits = entry.Credits
+		for address, credit := range entry.Credits {
+			addBalance(state.Balances, address, credit)
+		}
+		if entry.Fee != nil {
+			state.Revenue.Add(state.Revenue, entry.Fee)
+		}
+
+	case entryPayout:
+		payout := *entry.Payout
+		subBalance(state.Balances, payout.Address, payout.Amount)
+		state.Payouts = append(state.Payouts, &payout)
+		state.NextPayout = payout.ID + 1
+
+	case entryPayoutSigned:
+		if payout := state.payout(entry.Payout.ID); payout != nil {
+			payout.TxHash, payout.RawTx, payout.Status = entry.Payout.TxHash, entry.Payout.RawTx, PayoutSigned
+		}
+
+	case entryPayoutDone:
+		payout := state.payout(entry.Payout.ID)
+		if payout == nil {
+			return
+		}
+		if entry.Payout.Status == PayoutConfirmed {
+			addBalance(state.Paid, payout.Address, payout.Amount)
+		} else {
+			addBalance(state.Balances, payout.Address, payout.Amount)
+		}
+		for i, p := range state.Payouts {
+			if p == payout {
+				state.Payouts = append(state.Payouts[:i], state.Payouts[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// addBalance adds an amount to the balance of an address.
+func addBalance(balances map[string]*big.Int, address string, amount *big.Int) {
+	if balances[address] == nil {
+		balances[address] = new(big.Int)
+	}
+	balances[address].Add(balances[address], amount)
+}
+
+// subBalance subtracts an amount from the balance of an address, dropping
+// empty balances.
+func subBalance(balances map[string]*big.Int, address string, amount *big.Int) {
+	balance := balances[address]
+	if balance == nil {
+		balance = new(big.Int)
+	}
+	balance.Sub(balance, amount)
+	if balance.Sign() == 0 {
+		delete(balances, address)
+	} else {
+		balances[address] = balance
+	}
+}
+
+// block returns the block found at the given height with the given nonce.
+func (state *ledgerState) block(height, nonce uint64) *BlockRecord {
+	for _, block := range state.Blocks {
+		if block.Height == height && block.Nonce == nonce {
+			return block
+		}
+	}
+	return nil
+}
+
+// payout returns the unfinished payout with the given ID.
+func (state *ledgerState) payout(id uint64) *PayoutRecord {
+	for _, payout := range state.Payouts {
+		if payout.ID == id {
+			return payout
+		}
+	}
+	return nil
+}
+
+// RecordShare records a valid share of an address. The PPLNS window is kept at
+// window difficulty (unbounded if zero), credit is the immediate reward of the
+// share (PPS) or nil.
+func (l *Ledger) RecordShare(address string, difficulty uint64, window uint64, credit *big.Int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.commit(&ledgerEntry{
+		Kind:       entryShare,
+		Address:    address,
+		Difficulty: difficulty,
+		Window:     window,
+		Credit:     credit,
+	}, false)
+}
+
+// RecordBlock records a block found by the pool, along with the shares of the
+// PPLNS window its reward will be split by, and starts a new round.
+func (l *Ledger) RecordBlock(height, nonce uint64, finder string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	shares := make(map[string]uint64)
+	for _, share := range l.state.Window {
+		shares[share.Address] += share.Difficulty
+	}
+	return l.commit(&ledgerEntry{
+		Kind: entryBlock,
+		Block: &BlockRecord{
+			Height: height,
+			Nonce:  nonce,
+			Finder: finder,
+			Found:  time.Now(),
+			Status: BlockPending,
+			Shares: shares,
+		},
+	}, true)
+}
+
+// MatureBlock marks a found block as confirmed and credits the rewards.
+func (l *Ledger) MatureBlock(height, nonce uint64, hash string, reward *big.Int, credits map[string]*big.Int, fee *big.Int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.commit(&ledgerEntry{
+		Kind:    entryBlockMatured,
+		Block:   &BlockRecord{Height: height, Nonce: nonce, Hash: hash, Reward: reward},
+		Credits: credits,
+		Fee:     fee,
+	}, true)
+}
+
+// OrphanBlock marks a found block as not part of the canonical chain.
+func (l *Ledger) OrphanBlock(height, nonce uint64, hash string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.commit(&ledgerEntry{
+		Kind:  entryBlockOrphan,
+		Block: &BlockRecord{Height: height, Nonce: nonce, Hash: hash},
+	}, true)
+}
+
+// PendingBlocks returns the found blocks waiting for confirmations.
+func (l *Ledger) PendingBlocks() []BlockRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var blocks []BlockRecord
+	for _, block := range l.state.Blocks {
+		if block.Status == BlockPending {
+			blocks = append(blocks, *block)
+		}
+	}
+	return blocks
+}
+
+// RecentBlocks returns up to limit found blocks, newest first.
+func (l *Ledger) RecentBlocks(limit int) []BlockRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var blocks []BlockRecord
+	for i := len(l.state.Blocks) - 1; i >= 0 && len(blocks) < limit; i-- {
+		blocks = append(blocks, *l.state.Blocks[i])
+	}
+	return blocks
+}
+
+// Revenue returns the pool fees collected.
+func (l *Ledger) Revenue() *big.Int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return new(big.Int).Set(l.state.Revenue)
+}
+
+// Account returns the unpaid balance and the total paid out of an address.
+func (l *Ledger) Account(address string) (balance *big.Int, paid *big.Int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	balance, paid = new(big.Int), new(big.Int)
+	if amount := l.state.Balances[address]; amount != nil {
+		balance.Set(amount)
+	}
+	if amount := l.state.Paid[address]; amount != nil {
+		paid.Set(amount)
+	}
+	return balance, paid
+}
+
+// Balances returns the unpaid balances, ordered by address.
+func (l *Ledger) Balances() ([]string, []*big.Int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	addresses := make([]string, 0, len(l.state.Balances))
+	for address := range l.state.Balances {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	amounts := make([]*big.Int, len(addresses))
+	for i, address := range addresses {
+		amounts[i] = new(big.Int).Set(l.state.Balances[address])
+	}
+	return addresses, amounts
+}
+
+// CreatePayout deducts an amount from a balance for a payout transaction with
+// the given account nonce.
+func (l *Ledger) CreatePayout(address string, amount *big.Int, nonce uint64) (*PayoutRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	payout := &PayoutRecord{
+		ID:      l.state.NextPayout,
+		Address: address,
+		Amount:  new(big.Int).Set(amount),
+		Nonce:   nonce,
+		Status:  PayoutCreated,
+		Created: time.Now(),
+	}
+	if err := l.commit(&ledgerEntry{Kind: entryPayout, Payout: payout}, true); err != nil {
+		return nil, err
+	}
+	copy := *payout
+	return &copy, nil
+}
+
+// SignPayout records the signed transaction of a payout. It must be called
+// before broadcasting, so that a restart rebroadcasts the same transaction
+// instead of signing a new one.
+func (l *Ledger) SignPayout(id uint64, txHash string, rawTx string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.state.payout(id) == nil {
+		return errUnknownPayout
+	}
+	return l.commit(&ledgerEntry{Kind: entryPayoutSigned, Payout: &PayoutRecord{ID: id, TxHash: txHash, RawTx: rawTx}}, true)
+}
+
+// FinishPayout completes a payout, restoring the balance if it failed.
+func (l *Ledger) FinishPayout(id uint64, confirmed bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.state.payout(id) == nil {
+		return errUnknownPayout
+	}
+	status := PayoutConfirmed
+	if !confirmed {
+		status = PayoutFailed
+	}
+	return l.commit(&ledgerEntry{Kind: entryPayoutDone, Payout: &PayoutRecord{ID: id, Status: status}}, true)
+}
+
+// PendingPayouts returns the payouts not confirmed yet.
+func (l *Ledger) PendingPayouts() []PayoutRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	payouts := make([]PayoutRecord, len(l.state.Payouts))
+	for i, payout := range l.state.Payouts {
+		payouts[i] = *payout
+	}
+	return payouts
+}
+
+// Close writes a final snapshot and closes the journal.
+func (l *Ledger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.journal == nil {
+		return nil
+	}
+	err := l.compact()
+	if l.journal != nil {
+		l.journal.Close()
+		l.journal = nil
+	}
+	return err
+}
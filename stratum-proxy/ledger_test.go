@@ -0,0 +1,143 @@
+package main
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLedgerReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	ledger, err := OpenLedger(dir)
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	// Fill a PPLNS window of 300 difficulty, the oldest share falls out
+	for _, share := range []windowShare{{"0xa", 100}, {"0xb", 100}, {"0xa", 100}, {"0xb", 100}} {
+		if err := ledger.RecordShare(share.Address, share.Difficulty, 300, nil); err != nil {
+			t.Fatalf("record share: %v", err)
+		}
+	}
+	if err := ledger.RecordBlock(10, 42, "0xb"); err != nil {
+		t.Fatalf("record block: %v", err)
+	}
+	if err := ledger.RecordShare("0xa", 100, 300, big.NewInt(5)); err != nil {
+		t.Fatalf("record share: %v", err)
+	}
+	// Simulate a crash: drop the ledger without writing a snapshot
+	ledger.writer.Flush()
+	ledger.journal.Close()
+
+	ledger, err = OpenLedger(dir)
+	if err != nil {
+		t.Fatalf("reopen ledger: %v", err)
+	}
+	defer ledger.Close()
+
+	blocks := ledger.PendingBlocks()
+	if len(blocks) != 1 || blocks[0].Height != 10 || blocks[0].Nonce != 42 {
+		t.Fatalf("pending blocks after replay: %+v", blocks)
+	}
+	if have := blocks[0].Shares; have["0xa"] != 100 || have["0xb"] != 200 {
+		t.Fatalf("block shares: have %v, want 0xa:100 0xb:200", have)
+	}
+	if have := ledger.state.Round; len(have) != 1 || have["0xa"] != 100 {
+		t.Fatalf("round after block: have %v, want 0xa:100", have)
+	}
+	addresses, amounts := ledger.Balances()
+	if len(addresses) != 1 || addresses[0] != "0xa" || amounts[0].Int64() != 5 {
+		t.Fatalf("balances: have %v %v, want 0xa:5", addresses, amounts)
+	}
+}
+
+func TestLedgerCompactionCrash(t *testing.T) {
+	dir := t.TempDir()
+
+	ledger, err := OpenLedger(dir)
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	if err := ledger.RecordShare("0xa", 100, 0, big.NewInt(7)); err != nil {
+		t.Fatalf("record share: %v", err)
+	}
+	journal, err := os.ReadFile(filepath.Join(dir, ledgerJournalFile))
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	if err := ledger.Close(); err != nil {
+		t.Fatalf("close ledger: %v", err)
+	}
+	// Simulate a crash between writing the snapshot and truncating the journal,
+	// plus a torn write at the end
+	journal = append(journal, []byte(`{"seq":2,"kind":"sha`)...)
+	if err := os.WriteFile(filepath.Join(dir, ledgerJournalFile), journal, 0600); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	ledger, err = OpenLedger(dir)
+	if err != nil {
+		t.Fatalf("reopen ledger: %v", err)
+	}
+	defer ledger.Close()
+
+	if _, amounts := ledger.Balances(); len(amounts) != 1 || amounts[0].Int64() != 7 {
+		t.Fatalf("balance after replay: have %v, want 7", amounts)
+	}
+}
+
+func TestLedgerPayouts(t *testing.T) {
+	dir := t.TempDir()
+
+	ledger, err := OpenLedger(dir)
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	if err := ledger.RecordBlock(10, 42, "0xa"); err != nil {
+		t.Fatalf("record block: %v", err)
+	}
+	credits := map[string]*big.Int{"0xa": big.NewInt(90), "0xb": big.NewInt(10)}
+	if err := ledger.MatureBlock(10, 42, "0xhash", big.NewInt(100), credits, big.NewInt(0)); err != nil {
+		t.Fatalf("mature block: %v", err)
+	}
+	payout, err := ledger.CreatePayout("0xa", big.NewInt(90), 3)
+	if err != nil {
+		t.Fatalf("create payout: %v", err)
+	}
+	if err := ledger.SignPayout(payout.ID, "0xtx", "0xraw"); err != nil {
+		t.Fatalf("sign payout: %v", err)
+	}
+	ledger.Close()
+
+	// The signed payout survives a restart with its transaction
+	ledger, err = OpenLedger(dir)
+	if err != nil {
+		t.Fatalf("reopen ledger: %v", err)
+	}
+	defer ledger.Close()
+
+	pending := ledger.PendingPayouts()
+	if len(pending) != 1 || pending[0].Status != PayoutSigned || pending[0].RawTx != "0xraw" || pending[0].Nonce != 3 {
+		t.Fatalf("pending payouts after restart: %+v", pending)
+	}
+	if addresses, _ := ledger.Balances(); len(addresses) != 1 || addresses[0] != "0xb" {
+		t.Fatalf("balances during payout: have %v, want only 0xb", addresses)
+	}
+	// A failed payout restores the balance, a confirmed one is paid
+	if err := ledger.FinishPayout(payout.ID, false); err != nil {
+		t.Fatalf("finish payout: %v", err)
+	}
+	if _, amounts := ledger.Balances(); len(amounts) != 2 || amounts[0].Int64() != 90 {
+		t.Fatalf("balances after failed payout: %v", amounts)
+	}
+	payout, _ = ledger.CreatePayout("0xa", big.NewInt(90), 4)
+	if err := ledger.FinishPayout(payout.ID, true); err != nil {
+		t.Fatalf("finish payout: %v", err)
+	}
+	if have := ledger.state.Paid["0xa"]; have == nil || have.Int64() != 90 {
+		t.Fatalf("paid after confirmed payout: have %v, want 90", have)
+	}
+	if err := ledger.FinishPayout(payout.ID, true); err != errUnknownPayout {
+		t.Fatalf("finishing payout twice: have %v, want %v", err, errUnknownPayout)
+	}
+}
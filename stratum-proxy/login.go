@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultWorkerName is the worker name of miners that don't set one.
+const defaultWorkerName = "default"
+
+// defaultWorkerGracePeriod is how long the state of a disconnected worker is
+// kept for it to reconnect, if not configured.
+const defaultWorkerGracePeriod = 10 * time.Minute
+
+// workerNameRegexp matches valid worker names.
+var workerNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+var (
+	errInvalidAddress  = errors.New("invalid address")
+	errAddressChecksum = errors.New("invalid address checksum")
+)
+
+// MinerLogin is a parsed stratum login.
+type MinerLogin struct {
+	Address   string // Checksummed payout address
+	Worker    string
+	FixedDiff uint64 // Difficulty requested by the miner, 0 for vardiff
+}
+
+// Key identifies the worker across connections.
+func (l *MinerLogin) Key() string {
+	return l.Address + "." + l.Worker
+}
+
+// checksumAddress returns the EIP-55 form of a 40 character hex address.
+func checksumAddress(address string) string {
+	address = strings.ToLower(address)
+	hash := keccak256([]byte(address))
+
+	checksummed := []byte(address)
+	for i, c := range checksummed {
+		// Letters are uppercased if the matching hash nibble is 8 or more
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+		if c >= 'a' && c <= 'f' && nibble >= 8 {
+			checksummed[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(checksummed)
+}
+
+// parseAddress validates an Ethereum address and returns its checksummed
+// form. Mixed case addresses must have a valid EIP-55 checksum, all lowercase
+// or all uppercase ones carry no checksum.
+func parseAddress(address string) (string, error) {
+	raw, ok := strings.CutPrefix(address, "0x")
+	if !ok || len(raw) != 40 {
+		return "", errInvalidAddress
+	}
+	if _, err := hex.DecodeString(raw); err != nil {
+		return "", errInvalidAddress
+	}
+	checksummed := checksumAddress(raw)
+	if raw != strings.ToLower(raw) && raw != strings.ToUpper(raw) && "0x"+raw != checksummed {
+		return "", errAddressChecksum
+	}
+	return checksummed, nil
+}
+
+// parseLogin parses the xmrig login conventions: the login is the payout
+// address, optionally followed by ".worker" and "+difficulty". Without a
+// worker in the login, the password names the worker, unless it is xmrig's
+// default "x" or not a valid name.
+func parseLogin(login, pass string) (*MinerLogin, error) {
+	result := &MinerLogin{}
+
+	if rest, diff, ok := strings.Cut(login, "+"); ok {
+		value, err := strconv.ParseUint(diff, 10, 64)
+		if err != nil || value == 0 {
+			return nil, fmt.Errorf("invalid fixed difficulty %q", diff)
+		}
+		result.FixedDiff, login = value, rest
+	}
+	address, worker, _ := strings.Cut(login, ".")
+	if worker != "" && !workerNameRegexp.MatchString(worker) {
+		return nil, fmt.Errorf("invalid worker name %q", worker)
+	}
+	// Passwords are often used for other settings, only take valid names
+	if worker == "" && pass != "x" && workerNameRegexp.MatchString(pass) {
+		worker = pass
+	}
+	if worker == "" {
+		worker = defaultWorkerName
+	}
+	checksummed, err := parseAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", err, address)
+	}
+	result.Address, result.Worker = checksummed, worker
+	return result, nil
+}
+
+// workerState is the state of a worker kept across reconnects.
+type workerState struct {
+	Difficulty      uint64
+	SharesValid     uint64
+	SharesInvalid   uint64
+	SharesStale     uint64
+	SharesVerified  uint64
+	TotalDifficulty uint64
+	BlocksFound     uint64
+	ShareTimes      []time.Time
+	Hashrate        float64
+	Banned          bool
+	BanReason       string
+	disconnected    time.Time
+}
+
+// workerRegistry keeps the state of disconnected workers for a grace period,
+// so that reconnects don't reset their difficulty and stats.
+type workerRegistry struct {
+	states map[string]*workerState // By worker key
+	mu     sync.Mutex
+}
+
+// save stores the state of a disconnected worker.
+func (r *workerRegistry) save(key string, state *workerState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.states == nil {
+		r.states = make(map[string]*workerState)
+	}
+	state.disconnected = time.Now()
+	r.states[key] = state
+}
+
+// restore takes the state of a worker that disconnected within the grace
+// period, or returns nil.
+func (r *workerRegistry) restore(key string, grace time.Duration) *workerState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.states[key]
+	if state == nil {
+		return nil
+	}
+	delete(r.states, key)
+	if time.Since(state.disconnected) > grace {
+		return nil
+	}
+	return state
+}
+
+// prune drops the states of workers gone for longer than the grace period.
+func (r *workerRegistry) prune(grace time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, state := range r.states {
+		if time.Since(state.disconnected) > grace {
+			delete(r.states, key)
+		}
+	}
+}
+
+// saveState captures the state of a miner to keep across reconnects. The
+// miner lock must be held.
+func (m *Miner) saveState() *workerState {
+	return &workerState{
+		Difficulty:      m.Difficulty,
+		SharesValid:     m.SharesValid,
+		SharesInvalid:   m.SharesInvalid,
+		SharesStale:     m.SharesStale,
+		SharesVerified:  m.SharesVerified,
+		TotalDifficulty: m.TotalDifficulty,
+		BlocksFound:     m.BlocksFound,
+		ShareTimes:      m.ShareTimes,
+		Hashrate:        m.Hashrate,
+		Banned:          m.Banned,
+		BanReason:       m.BanReason,
+	}
+}
+
+// restoreState resumes a miner from the state of its previous connection.
+// The miner lock must be held.
+func (m *Miner) restoreState(state *workerState) {
+	m.Difficulty = state.Difficulty
+	m.SharesValid = state.SharesValid
+	m.SharesInvalid = state.SharesInvalid
+	m.SharesStale = state.SharesStale
+	m.SharesVerified = state.SharesVerified
+	m.TotalDifficulty = state.TotalDifficulty
+	m.BlocksFound = state.BlocksFound
+	m.ShareTimes = state.ShareTimes
+	m.Hashrate = state.Hashrate
+	m.Banned = state.Banned
+	m.BanReason = state.BanReason
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestKeccak256(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{"abc", "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45"},
+		// Exactly one block and more than one, to cover absorbing full blocks
+		{string(make([]byte, 136)), "3a5912a7c5faa06ee4fe906253e339467a9ce87d533c65be3c15cb231cdb25f9"},
+		{string(make([]byte, 200)), "e1bb54e1bc3af48d01e5dbfc81015c98152a574f6428c6948aa4837c9c0baad9"},
+	}
+	for _, tt := range tests {
+		hash := keccak256([]byte(tt.input))
+		if have := hex.EncodeToString(hash[:]); have != tt.want {
+			t.Errorf("keccak256(%q): have %s, want %s", tt.input, have, tt.want)
+		}
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	// EIP-55 test vectors
+	for _, address := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	} {
+		if have, err := parseAddress(address); err != nil || have != address {
+			t.Errorf("parseAddress(%s): have %s, %v", address, have, err)
+		}
+	}
+	// Single case addresses carry no checksum and are normalized
+	if have, err := parseAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"); err != nil || have != "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed" {
+		t.Errorf("lowercase address: have %s, %v", have, err)
+	}
+	if _, err := parseAddress("0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"); !errors.Is(err, errAddressChecksum) {
+		t.Errorf("bad checksum: have %v, want %v", err, errAddressChecksum)
+	}
+	for _, address := range []string{"", "0xabc", "5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeZ"} {
+		if _, err := parseAddress(address); !errors.Is(err, errInvalidAddress) {
+			t.Errorf("parseAddress(%q): have %v, want %v", address, err, errInvalidAddress)
+		}
+	}
+}
+
+func TestParseLogin(t *testing.T) {
+	const address = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+	tests := []struct {
+		login, pass string
+		want        MinerLogin
+	}{
+		{address, "x", MinerLogin{Address: address, Worker: defaultWorkerName}},
+		{address, "", MinerLogin{Address: address, Worker: defaultWorkerName}},
+		{address, "rig1", MinerLogin{Address: address, Worker: "rig1"}},
+		{address, "rig1,d=1000", MinerLogin{Address: address, Worker: defaultWorkerName}},
+		{address + ".rig2", "rig1", MinerLogin{Address: address, Worker: "rig2"}},
+		{address + "+50000", "rig1", MinerLogin{Address: address, Worker: "rig1", FixedDiff: 50000}},
+		{address + ".rig2+50000", "x", MinerLogin{Address: address, Worker: "rig2", FixedDiff: 50000}},
+	}
+	for _, tt := range tests {
+		have, err := parseLogin(tt.login, tt.pass)
+		if err != nil {
+			t.Errorf("parseLogin(%q, %q): %v", tt.login, tt.pass, err)
+			continue
+		}
+		if *have != tt.want {
+			t.Errorf("parseLogin(%q, %q): have %+v, want %+v", tt.login, tt.pass, *have, tt.want)
+		}
+	}
+	for _, login := range []string{"", "garbage", address + "+0", address + "+abc", address + ".bad name"} {
+		if _, err := parseLogin(login, "x"); err == nil {
+			t.Errorf("invalid login %q accepted", login)
+		}
+	}
+}
+
+func TestWorkerReconnect(t *testing.T) {
+	srv := &Server{
+		config: &ServerConfig{InitialDiff: 1000, VarDiffTarget: 30},
+		stats:  NewStats(),
+		currentJob: &Job{
+			JobID:      "1",
+			Height:     1,
+			HeaderHash: "0x0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			SeedHash:   "0xabcd",
+		},
+	}
+	login := func(miner *Miner, params string) *StratumResponse {
+		return srv.handleLogin(miner, &StratumRequest{ID: 1, Method: "login", Params: json.RawMessage(params)})
+	}
+	params := `{"login":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed.rig1","pass":"x"}`
+
+	miner := &Miner{ID: "1.2.3.4:1000", Difficulty: 1000}
+	if resp := login(miner, params); resp.Error != nil {
+		t.Fatalf("login: %v", resp.Error)
+	}
+	if miner.ID != "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed.rig1" {
+		t.Fatalf("miner ID: have %s", miner.ID)
+	}
+	if resp := login(miner, params); resp.Error == nil {
+		t.Fatalf("second login on the same connection accepted")
+	}
+	// The worker disconnects after vardiff raised its difficulty
+	miner.Difficulty, miner.SharesValid = 8000, 42
+	srv.workers.save(miner.ID, miner.saveState())
+
+	reconnected := &Miner{ID: "1.2.3.4:2000", Difficulty: 1000}
+	if resp := login(reconnected, params); resp.Error != nil {
+		t.Fatalf("login after reconnect: %v", resp.Error)
+	}
+	if reconnected.Difficulty != 8000 || reconnected.SharesValid != 42 {
+		t.Fatalf("state after reconnect: have diff %d, %d shares, want 8000, 42", reconnected.Difficulty, reconnected.SharesValid)
+	}
+	// Another worker of the same address starts fresh, a fixed difficulty wins
+	other := &Miner{ID: "1.2.3.4:3000", Difficulty: 1000}
+	if resp := login(other, `{"login":"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed+20000","pass":"rig2"}`); resp.Error != nil {
+		t.Fatalf("login of second worker: %v", resp.Error)
+	}
+	if other.SharesValid != 0 || other.Difficulty != 20000 || other.FixedDiff != 20000 {
+		t.Fatalf("second worker: have diff %d (fixed %d), %d shares", other.Difficulty, other.FixedDiff, other.SharesValid)
+	}
+	if resp := login(&Miner{}, `{"login":"0xnotanaddress","pass":"x"}`); resp.Error == nil {
+		t.Fatalf("invalid address accepted")
+	}
+}
@@ -5,19 +5,30 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"math/big"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 var (
+	// Configuration file
+	configFile   = flag.String(configFlag, "", "JSON configuration file, reloaded on SIGHUP (command-line flags override it)")
+
 	// Stratum server config
 	stratumAddr  = flag.String("stratum", "0.0.0.0:3333", "Stratum server listen address")
 	stratumDiff  = flag.Float64("diff", 10000, "Initial difficulty for miners")
+	extraPorts   = flag.String("ports", "", "Additional stratum ports, comma separated as [tls://]host:port[/difficulty]")
+	tlsCert      = flag.String("tls-cert", "stratum-tls.crt", "TLS certificate of the tls:// ports (self-signed one generated if missing)")
+	tlsKey       = flag.String("tls-key", "stratum-tls.key", "TLS private key of the tls:// ports")
 
 	// Geth RPC config
-	gethRPC      = flag.String("geth", "http://localhost:8545", "Geth JSON-RPC endpoint")
+	gethRPC      = flag.String("geth", "http://localhost:8545", "Geth JSON-RPC endpoints, comma separated in order of priority")
+	maxLag       = flag.Uint64("max-lag", 2, "Blocks an upstream may lag behind the best one before failing over")
+	healthInterval = flag.Duration("health-interval", defaultHealthCheckInterval, "Time between upstream health checks")
 
 	// Pool config
 	poolAddr     = flag.String("pool-addr", "", "Pool payout address (miner etherbase)")
@@ -30,9 +41,35 @@ var (
 	// Ban system config
 	maxInvalidStreak = flag.Uint64("max-invalid-streak", 10, "Max consecutive invalid shares before ban (0 = disabled)")
 
+	// Share verification config
+	verifyShares  = flag.Bool("verify-shares", haveRandomX, "Recompute share hashes with RandomX (requires a build with -tags randomx)")
+	verifyFull    = flag.Bool("verify-full", false, "Verify shares with the full RandomX dataset (2 GB) instead of light mode")
+	spotCheckRate = flag.Float64("spot-check-rate", 1.0, "Fraction of shares verified for trusted miners (1.0 = all)")
+	trustAfter    = flag.Uint64("trust-after", 100, "Verified shares after which a miner is only spot-checked")
+
+	// Payout config
+	ledgerDir       = flag.String("ledger", "pool-ledger", "Share ledger directory (pool mode)")
+	payoutScheme    = flag.String("payout-scheme", SchemePPLNS, "Payout scheme: pplns or pps")
+	pplnsWindow     = flag.Float64("pplns-window", 2.0, "PPLNS window in multiples of the network difficulty")
+	confirmations   = flag.Uint64("confirmations", 60, "Confirmations before block rewards are credited")
+	payoutThreshold = flag.String("payout-threshold", "0.1", "Minimum balance paid out (in coins)")
+	payoutInterval  = flag.Duration("payout-interval", 10*time.Minute, "Time between payout batches")
+
+	// Worker config
+	workerGrace = flag.Duration("worker-grace", defaultWorkerGracePeriod, "How long disconnected workers keep their difficulty and stats")
+
 	// DoS protection config
 	maxConnections = flag.Int("max-connections", 1000, "Max concurrent connections (0 = unlimited)")
 	shareRateLimit = flag.Float64("share-rate-limit", 100.0, "Max shares per second per miner (0 = unlimited)")
+	maxConnectionsPerIP = flag.Int("max-connections-per-ip", 0, "Max concurrent connections per IP (0 = unlimited)")
+
+	// Ban list config
+	banFile     = flag.String("bans", "bans.json", "Ban list file, kept across restarts (empty = bans only last for the connection)")
+	banDuration = flag.Duration("ban-duration", time.Hour, "How long IPs are banned for invalid or forged shares (0 = connection only)")
+	adminToken  = flag.String("admin-token", "", "Bearer token of the admin API managing bans (empty = disabled)")
+
+	// Monitoring
+	apiAddr = flag.String("api", "", "HTTP stats API and Prometheus metrics listen address (empty = disabled)")
 
 	// Logging
 	verbose      = flag.Bool("v", false, "Verbose logging")
@@ -49,27 +86,16 @@ func main() {
 	// ASCII banner
 	printBanner()
 
-	// Validate config
+	// Load and validate config
+	overrides := commandLineFlags()
+	config, err := loadConfig(*configFile, overrides)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 	if *poolAddr == "" {
 		log.Println("⚠️  WARNING: No pool address specified, using miner addresses directly")
 	}
 
-	// Create proxy server
-	config := &ServerConfig{
-		ListenAddr:         *stratumAddr,
-		GethRPC:            *gethRPC,
-		InitialDiff:        *stratumDiff,
-		PoolAddress:        *poolAddr,
-		PoolFee:            *poolFee,
-		Verbose:            *verbose,
-		Algorithm:          *algo,
-		VarDiffTarget:      *varDiffTarget,
-		VarDiffWindow:      *varDiffWindow,
-		MaxInvalidStreak:   *maxInvalidStreak,
-		MaxConnections:     *maxConnections,
-		ShareRateLimit:     *shareRateLimit,
-	}
-
 	server, err := NewServer(config)
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
@@ -77,13 +103,16 @@ func main() {
 
 	// Start server
 	log.Printf("🚀 Starting Stratum proxy on %s", *stratumAddr)
-	log.Printf("🔗 Connected to Geth: %s", *gethRPC)
+	for _, upstream := range config.Upstreams {
+		log.Printf("🔗 Connected to Geth: %s (priority %d)", upstreamName(upstream.URL), upstream.Priority)
+	}
 	log.Printf("⛏️  Algorithm: %s", *algo)
 	log.Printf("💎 Initial difficulty: %.0f", *stratumDiff)
 
 	if *poolAddr != "" {
 		log.Printf("💰 Pool address: %s", *poolAddr)
 		log.Printf("💵 Pool fee: %.2f%%", *poolFee)
+		log.Printf("📒 Ledger: %s (%s, payouts above %s every %s)", *ledgerDir, *payoutScheme, *payoutThreshold, *payoutInterval)
 	}
 
 	log.Printf("⚙️  VarDiff: target %.1fs, window %d shares", *varDiffTarget, *varDiffWindow)
@@ -93,22 +122,109 @@ func main() {
 		log.Printf("🛡️  Ban system: disabled")
 	}
 
+	if *verifyShares {
+		mode := "light"
+		if *verifyFull {
+			mode = "full"
+		}
+		log.Printf("🔍 Share verification: RandomX %s mode, spot-check %.0f%% after %d shares", mode, *spotCheckRate*100, *trustAfter)
+	} else {
+		log.Printf("⚠️  Share verification disabled, miner results are trusted")
+	}
+
 	if err := server.Start(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 
-	// Wait for interrupt
+	// Wait for interrupt, reload the config file on SIGHUP
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
 	log.Println("✅ Stratum proxy running. Press Ctrl+C to stop.")
-	<-sigCh
+	for sig := range sigCh {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		if *configFile == "" {
+			log.Println("⚠️  SIGHUP ignored, no config file given")
+			continue
+		}
+		log.Printf("🔄 Reloading %s", *configFile)
+		config, err := loadConfig(*configFile, overrides)
+		if err == nil {
+			err = server.Reload(config)
+		}
+		if err != nil {
+			log.Printf("❌ Config reload failed, keeping the current settings: %v", err)
+		}
+	}
 
 	log.Println("🛑 Shutting down...")
 	server.Stop()
 	log.Println("👋 Goodbye!")
 }
 
+// newConfig builds the server configuration from the flags. Upstreams with
+// explicit priorities replace the -geth list if given.
+func newConfig(upstreams []UpstreamConfig) (*ServerConfig, error) {
+	threshold, err := parseCoins(*payoutThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payout threshold: %w", err)
+	}
+	ports, err := parsePorts(*extraPorts, *stratumDiff)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ports: %w", err)
+	}
+	if len(upstreams) == 0 {
+		upstreams = parseUpstreams(*gethRPC)
+	}
+	return &ServerConfig{
+		ListenAddr:          *stratumAddr,
+		Upstreams:           upstreams,
+		MaxUpstreamLag:      *maxLag,
+		HealthCheckInterval: *healthInterval,
+		WorkerGracePeriod:   *workerGrace,
+		InitialDiff:         *stratumDiff,
+		PoolAddress:         *poolAddr,
+		PoolFee:             *poolFee,
+		Verbose:             *verbose,
+		Algorithm:           *algo,
+		VarDiffTarget:       *varDiffTarget,
+		VarDiffWindow:       *varDiffWindow,
+		MaxInvalidStreak:    *maxInvalidStreak,
+		MaxConnections:      *maxConnections,
+		MaxConnectionsPerIP: *maxConnectionsPerIP,
+		BanFile:             *banFile,
+		BanDuration:         *banDuration,
+		AdminToken:          *adminToken,
+		ShareRateLimit:      *shareRateLimit,
+		VerifyShares:        *verifyShares,
+		VerifyFullMode:      *verifyFull,
+		SpotCheckRate:       *spotCheckRate,
+		TrustThreshold:      *trustAfter,
+		LedgerDir:           *ledgerDir,
+		PayoutScheme:        *payoutScheme,
+		PPLNSWindow:         *pplnsWindow,
+		BlockConfirmations:  *confirmations,
+		PayoutThreshold:     threshold,
+		PayoutInterval:      *payoutInterval,
+		APIAddr:             *apiAddr,
+		Ports:               ports,
+		TLSCertFile:         *tlsCert,
+		TLSKeyFile:          *tlsKey,
+	}, nil
+}
+
+// parseCoins parses a decimal coin amount into wei.
+func parseCoins(amount string) (*big.Int, error) {
+	coins, ok := new(big.Rat).SetString(amount)
+	if !ok || coins.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+	wei := coins.Mul(coins, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)))
+	return new(big.Int).Quo(wei.Num(), wei.Denom()), nil
+}
+
 func printBanner() {
 	banner := `
 ╔═══════════════════════════════════════════════════════════╗
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// submitLatencyBuckets are the upper bounds, in seconds, of the share
+// submission latency histogram.
+var submitLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// histogram is a Prometheus-style histogram. It isn't thread-safe, the owner
+// must protect it.
+type histogram struct {
+	bounds []float64 // Bucket upper bounds, ascending
+	counts []uint64  // Observations per bucket, the last one is +Inf
+	sum    float64
+	count  uint64
+}
+
+// newHistogram creates a histogram with the given bucket upper bounds.
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// observe adds a value to the histogram.
+func (h *histogram) observe(value float64) {
+	h.counts[sort.SearchFloat64s(h.bounds, value)]++
+	h.sum += value
+	h.count++
+}
+
+// metricsWriter writes metrics in the Prometheus text exposition format.
+type metricsWriter struct {
+	w io.Writer
+}
+
+// formatValue formats a sample value.
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// metric writes a single unlabeled counter or gauge.
+func (m metricsWriter) metric(name, kind, help string, value float64) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, formatValue(value))
+}
+
+// labeled writes a counter or gauge with one label, ordered by label value.
+func (m metricsWriter) labeled(name, kind, help, label string, values map[string]uint64) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(m.w, "%s{%s=%q} %d\n", name, label, key, values[key])
+	}
+}
+
+// histogram writes a histogram with cumulative buckets.
+func (m metricsWriter) histogram(name, help string, h *histogram) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(m.w, "%s_bucket{le=\"%s\"} %d\n", name, formatValue(bound), cumulative)
+	}
+	fmt.Fprintf(m.w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(m.w, "%s_sum %s\n%s_count %d\n", name, formatValue(h.sum), name, h.count)
+}
+
+// writeMetrics writes the share, ban, vardiff and block counters.
+func (s *Stats) writeMetrics(m metricsWriter) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m.metric("stratum_shares_accepted_total", "counter", "Shares accepted", float64(s.ValidShares))
+	m.labeled("stratum_shares_rejected_total", "counter", "Shares rejected, by reason", "reason", s.Rejected)
+	m.metric("stratum_shares_stale_total", "counter", "Shares submitted for outdated jobs", float64(s.StaleShares))
+	m.labeled("stratum_bans_total", "counter", "Miners banned, by reason", "reason", s.Bans)
+	m.labeled("stratum_connections_rejected_total", "counter", "Connections and logins refused, by reason", "reason", s.ConnectionsRejected)
+	m.labeled("stratum_vardiff_adjustments_total", "counter", "Miner difficulty adjustments, by direction", "direction",
+		map[string]uint64{"up": s.VarDiffUp, "down": s.VarDiffDown})
+	m.metric("stratum_blocks_found_total", "counter", "Blocks found and accepted by Geth", float64(s.BlocksFound))
+	m.metric("stratum_blocks_rejected_total", "counter", "Block candidates rejected by Geth", float64(s.BlocksRejected))
+	m.histogram("stratum_share_submit_duration_seconds", "Time to process a share submission", s.SubmitLatency)
+	m.metric("stratum_uptime_seconds", "gauge", "Time since the proxy started", time.Since(s.StartTime).Seconds())
+}
+
+// handleMetrics serves the Prometheus metrics.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m := metricsWriter{w: w}
+
+	workers := s.workerStats()
+	var (
+		active, banned int
+		hashrate       float64
+		difficulty     uint64
+	)
+	for _, worker := range workers {
+		if worker.Active {
+			active++
+			hashrate += worker.Hashrate
+		}
+		if worker.Banned {
+			banned++
+		}
+		difficulty += worker.Difficulty
+	}
+	avgDifficulty := 0.0
+	if len(workers) > 0 {
+		avgDifficulty = float64(difficulty) / float64(len(workers))
+	}
+	m.metric("stratum_miners_connected", "gauge", "Connected miners", float64(len(workers)))
+	m.metric("stratum_miners_active", "gauge", "Miners that were active in the last minutes", float64(active))
+	m.metric("stratum_miners_banned", "gauge", "Connected miners that are banned", float64(banned))
+	m.metric("stratum_hashrate", "gauge", "Estimated hashrate of the active miners (H/s)", hashrate)
+	m.metric("stratum_miner_difficulty_avg", "gauge", "Average share difficulty of the connected miners", avgDifficulty)
+
+	if job := s.jobStats(); job != nil {
+		m.metric("stratum_job_height", "gauge", "Block height of the current job", float64(job.Height))
+		m.metric("stratum_network_difficulty", "gauge", "Network difficulty of the current job", float64(job.NetworkDifficulty))
+		m.metric("stratum_job_timestamp_seconds", "gauge", "Time the current job was received from Geth", float64(job.Created.Unix()))
+	}
+	s.stats.writeMetrics(m)
+
+	if s.upstreams != nil {
+		healthy := make(map[string]uint64)
+		active := make(map[string]uint64)
+		height := make(map[string]uint64)
+		for _, status := range s.upstreams.Status() {
+			healthy[status.Name], active[status.Name], height[status.Name] = 0, 0, status.Height
+			if status.Healthy {
+				healthy[status.Name] = 1
+			}
+			if status.Active {
+				active[status.Name] = 1
+			}
+		}
+		m.labeled("stratum_upstream_healthy", "gauge", "Whether a Geth upstream passes the health checks", "upstream", healthy)
+		m.labeled("stratum_upstream_active", "gauge", "Whether a Geth upstream is the one work is fetched from", "upstream", active)
+		m.labeled("stratum_upstream_height", "gauge", "Head block number of a Geth upstream", "upstream", height)
+		m.metric("stratum_upstream_failovers_total", "counter", "Changes of the active Geth upstream", float64(s.upstreams.Failovers()))
+	}
+
+	if s.bans != nil {
+		m.metric("stratum_bans_active", "gauge", "Bans in effect", float64(len(s.bans.List())))
+	}
+
+	if s.ledger != nil {
+		m.metric("stratum_pool_pending_blocks", "gauge", "Found blocks waiting for confirmations", float64(len(s.ledger.PendingBlocks())))
+		m.metric("stratum_pool_pending_payouts", "gauge", "Payouts not confirmed yet", float64(len(s.ledger.PendingPayouts())))
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// blockUnlockInterval is how often found blocks are checked for maturity.
+const blockUnlockInterval = time.Minute
+
+// addressRegexp matches the payout addresses transactions can be sent to.
+var addressRegexp = regexp.MustCompile("^0x[0-9a-fA-F]{40}$")
+
+// recordShare credits a valid share to the ledger.
+func (s *Server) recordShare(address string, difficulty, networkDifficulty uint64) {
+	window := uint64(s.cfg().PPLNSWindow * float64(networkDifficulty))
+
+	var credit *big.Int
+	if s.cfg().PayoutScheme == SchemePPS {
+		s.workMu.RLock()
+		reward := s.blockReward
+		s.workMu.RUnlock()
+
+		if reward != nil {
+			credit = CalculatePPS(reward, s.cfg().PoolFee, difficulty, networkDifficulty)
+		}
+	}
+	if err := s.ledger.RecordShare(address, difficulty, window, credit); err != nil {
+		log.Printf("❌ Failed to record share of %s: %v", address, err)
+	}
+}
+
+// blockUnlocker periodically checks found blocks for maturity.
+func (s *Server) blockUnlocker() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(blockUnlockInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.unlockBlocks()
+		}
+	}
+}
+
+// unlockBlocks credits the rewards of found blocks that have enough
+// confirmations, or marks them as orphans if the canonical block at their
+// height isn't theirs.
+func (s *Server) unlockBlocks() {
+	pending := s.ledger.PendingBlocks()
+	if len(pending) == 0 {
+		return
+	}
+	head, err := s.upstreams.Client().GetBlockNumber()
+	if err != nil {
+		log.Printf("⚠️  Block unlocker: %v", err)
+		return
+	}
+	for _, block := range pending {
+		if head < block.Height+s.cfg().BlockConfirmations {
+			continue
+		}
+		info, err := s.upstreams.Client().GetBlockByNumber(block.Height)
+		if err != nil {
+			log.Printf("⚠️  Block unlocker: %v", err)
+			return
+		}
+		if info == nil {
+			continue
+		}
+		// The nonce identifies our solution, any other block at this height won
+		var nonce uint64
+		fmt.Sscanf(info.Nonce, "0x%x", &nonce)
+		if nonce != block.Nonce {
+			log.Printf("💀 Block %d orphaned (canonical block %s)", block.Height, info.Hash)
+			if err := s.ledger.OrphanBlock(block.Height, block.Nonce, info.Hash); err != nil {
+				log.Printf("❌ Failed to record orphaned block %d: %v", block.Height, err)
+			}
+			continue
+		}
+		reward, err := s.upstreams.Client().GetBlockReward(block.Height)
+		if err != nil {
+			log.Printf("⚠️  Block unlocker: %v", err)
+			return
+		}
+		// With PPS, miners were already paid per share and the pool keeps the
+		// block reward. With PPLNS, the reward is split by the shares of the
+		// window when the block was found.
+		var (
+			credits map[string]*big.Int
+			fee     = reward
+		)
+		if s.cfg().PayoutScheme != SchemePPS {
+			credits, fee = CalculatePPLNS(reward, s.cfg().PoolFee, block.Shares)
+		}
+		if err := s.ledger.MatureBlock(block.Height, block.Nonce, info.Hash, reward, credits, fee); err != nil {
+			log.Printf("❌ Failed to record matured block %d: %v", block.Height, err)
+			continue
+		}
+		log.Printf("💰 Block %d matured: reward %s wei credited to %d miners (pool: %s wei)",
+			block.Height, reward, len(credits), fee)
+	}
+}
+
+// payoutWorker periodically pays out balances above the threshold.
+func (s *Server) payoutWorker() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg().PayoutInterval)
+	defer ticker.Stop()
+
+	// Finish the payouts interrupted by a restart right away
+	s.processPayouts()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.processPayouts()
+		}
+	}
+}
+
+// processPayouts settles the unfinished payouts, then starts a new batch of
+// payouts for all balances above the threshold once the previous batch is
+// done, so that transaction nonces are never reused.
+func (s *Server) processPayouts() {
+	pending := s.ledger.PendingPayouts()
+	for _, payout := range pending {
+		s.settlePayout(payout)
+	}
+	if len(s.ledger.PendingPayouts()) > 0 {
+		return
+	}
+	addresses, amounts := s.ledger.Balances()
+
+	var batch []int
+	for i, amount := range amounts {
+		if amount.Cmp(s.cfg().PayoutThreshold) < 0 {
+			continue
+		}
+		if !addressRegexp.MatchString(addresses[i]) {
+			if s.cfg().Verbose {
+				log.Printf("⚠️  Skipping payout to invalid address %q", addresses[i])
+			}
+			continue
+		}
+		batch = append(batch, i)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	nonce, err := s.upstreams.Client().GetTransactionCount(s.cfg().PoolAddress, "pending")
+	if err != nil {
+		log.Printf("⚠️  Payouts: %v", err)
+		return
+	}
+	log.Printf("💸 Paying out %d balances", len(batch))
+
+	for _, i := range batch {
+		payout, err := s.ledger.CreatePayout(addresses[i], amounts[i], nonce)
+		if err != nil {
+			log.Printf("❌ Failed to create payout to %s: %v", addresses[i], err)
+			return
+		}
+		nonce++
+		s.settlePayout(*payout)
+	}
+}
+
+// settlePayout moves a payout forward: it signs the transaction if not done
+// yet, completes the payout if the transaction was included, and otherwise
+// (re)broadcasts it. The signed transaction is journaled before broadcasting,
+// so a restart resends the very same transaction and can't pay twice.
+func (s *Server) settlePayout(payout PayoutRecord) {
+	if payout.Status == PayoutCreated {
+		gasPrice, err := s.upstreams.Client().GasPrice()
+		if err != nil {
+			log.Printf("⚠️  Payout %d: %v", payout.ID, err)
+			return
+		}
+		raw, hash, err := s.upstreams.Client().SignTransaction(s.cfg().PoolAddress, payout.Address, payout.Amount, payout.Nonce, gasPrice)
+		if err != nil {
+			log.Printf("⚠️  Payout %d: %v", payout.ID, err)
+			return
+		}
+		if err := s.ledger.SignPayout(payout.ID, hash, raw); err != nil {
+			log.Printf("❌ Failed to record signed payout %d: %v", payout.ID, err)
+			return
+		}
+		payout.TxHash, payout.RawTx, payout.Status = hash, raw, PayoutSigned
+	}
+	status, _, found, err := s.upstreams.Client().GetTransactionReceipt(payout.TxHash)
+	if err != nil {
+		log.Printf("⚠️  Payout %d: %v", payout.ID, err)
+		return
+	}
+	if found {
+		if err := s.ledger.FinishPayout(payout.ID, status == 1); err != nil {
+			log.Printf("❌ Failed to record finished payout %d: %v", payout.ID, err)
+			return
+		}
+		if status == 1 {
+			log.Printf("💸 Paid %s wei to %s (tx %s)", payout.Amount, payout.Address, payout.TxHash)
+		} else {
+			log.Printf("❌ Payout to %s failed (tx %s), balance restored", payout.Address, payout.TxHash)
+		}
+		return
+	}
+	if _, err := s.upstreams.Client().SendRawTransaction(payout.RawTx); err != nil && !isKnownTxError(err) {
+		log.Printf("⚠️  Payout %d broadcast: %v", payout.ID, err)
+	}
+}
+
+// isKnownTxError reports whether a broadcast failed only because the
+// transaction was already sent.
+func isKnownTxError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// portListener is the listener of an additional stratum port.
+type portListener struct {
+	listener net.Listener
+	port     PortConfig
+}
+
+// parsePorts parses a comma separated list of additional stratum ports, each
+// as [tls://]host:port[/difficulty]. Ports without a difficulty start miners
+// at defaultDiff.
+func parsePorts(spec string, defaultDiff float64) ([]PortConfig, error) {
+	var ports []PortConfig
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		port := PortConfig{InitialDiff: defaultDiff}
+		if rest, ok := strings.CutPrefix(field, "tls://"); ok {
+			port.TLS, field = true, rest
+		}
+		if addr, diff, ok := strings.Cut(field, "/"); ok {
+			value, err := strconv.ParseFloat(diff, 64)
+			if err != nil || value < 1 {
+				return nil, fmt.Errorf("invalid difficulty in port %q", field)
+			}
+			port.InitialDiff, field = value, addr
+		}
+		if _, _, err := net.SplitHostPort(field); err != nil {
+			return nil, fmt.Errorf("invalid port %q: %w", field, err)
+		}
+		port.Addr = field
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// listenPorts opens the listeners of the additional stratum ports. The TLS
+// certificate is only loaded if a port needs it.
+func (s *Server) listenPorts() error {
+	var tlsConfig *tls.Config
+	for _, port := range s.cfg().Ports {
+		if port.TLS && tlsConfig == nil {
+			cert, err := loadOrCreateCertificate(s.cfg().TLSCertFile, s.cfg().TLSKeyFile)
+			if err != nil {
+				return err
+			}
+			log.Printf("🔐 TLS certificate fingerprint (SHA-256): %s", certificateFingerprint(cert))
+			tlsConfig = &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS12,
+			}
+		}
+	}
+	for _, port := range s.cfg().Ports {
+		listener, err := net.Listen("tcp", port.Addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", port.Addr, err)
+		}
+		scheme := "stratum+tcp"
+		if port.TLS {
+			listener = tls.NewListener(listener, tlsConfig)
+			scheme = "stratum+ssl"
+		}
+		s.portListeners = append(s.portListeners, portListener{listener: listener, port: port})
+		log.Printf("🔌 Listening on %s://%s (initial difficulty %.0f)", scheme, listener.Addr(), port.InitialDiff)
+	}
+	return nil
+}
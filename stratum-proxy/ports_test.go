@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	ports, err := parsePorts("0.0.0.0:3334/100000, tls://0.0.0.0:3443,tls://:443/5000", 1000)
+	if err != nil {
+		t.Fatalf("parse ports: %v", err)
+	}
+	want := []PortConfig{
+		{Addr: "0.0.0.0:3334", InitialDiff: 100000},
+		{Addr: "0.0.0.0:3443", InitialDiff: 1000, TLS: true},
+		{Addr: ":443", InitialDiff: 5000, TLS: true},
+	}
+	if !reflect.DeepEqual(ports, want) {
+		t.Fatalf("ports: have %+v, want %+v", ports, want)
+	}
+	for _, spec := range []string{"3334", "0.0.0.0:3334/0", "0.0.0.0:3334/abc", "udp://0.0.0.0:3334"} {
+		if _, err := parsePorts(spec, 1000); err == nil {
+			t.Errorf("invalid port %q accepted", spec)
+		}
+	}
+}
+
+func TestCertificatePersisted(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "proxy.crt"), filepath.Join(dir, "proxy.key")
+
+	cert, err := loadOrCreateCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	reloaded, err := loadOrCreateCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("load certificate: %v", err)
+	}
+	if have, want := certificateFingerprint(reloaded), certificateFingerprint(cert); have != want {
+		t.Fatalf("fingerprint changed after reload: have %s, want %s", have, want)
+	}
+	if _, err := loadOrCreateCertificate(certFile, filepath.Join(dir, "missing.key")); err == nil {
+		t.Fatalf("certificate without key accepted")
+	}
+}
+
+func TestTLSPort(t *testing.T) {
+	dir := t.TempDir()
+	srv := &Server{
+		config: &ServerConfig{
+			Ports:       []PortConfig{{Addr: "127.0.0.1:0", InitialDiff: 50000, TLS: true}},
+			TLSCertFile: filepath.Join(dir, "proxy.crt"),
+			TLSKeyFile:  filepath.Join(dir, "proxy.key"),
+		},
+		miners: make(map[string]*Miner),
+		stats:  NewStats(),
+		stopCh: make(chan struct{}),
+		currentJob: &Job{
+			JobID:      "1",
+			Height:     1,
+			HeaderHash: "0x0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			SeedHash:   "0xabcd",
+		},
+	}
+	if err := srv.listenPorts(); err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := srv.portListeners[0]
+	srv.wg.Add(1)
+	go srv.acceptConnections(port.listener, port.port.InitialDiff)
+	defer func() {
+		close(srv.stopCh)
+		srv.closeListeners()
+		srv.wg.Wait()
+	}()
+
+	cert, err := loadOrCreateCertificate(srv.config.TLSCertFile, srv.config.TLSKeyFile)
+	if err != nil {
+		t.Fatalf("load certificate: %v", err)
+	}
+	fingerprint := certificateFingerprint(cert)
+
+	// Connect like xmrig with a pinned fingerprint
+	conn, err := tls.Dial("tcp", port.listener.Addr().String(), &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			sum := sha256.Sum256(state.PeerCertificates[0].Raw)
+			if hex.EncodeToString(sum[:]) != fingerprint {
+				return errors.New("fingerprint mismatch")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	login := `{"id":1,"jsonrpc":"2.0","method":"login","params":{"login":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed","pass":"x","agent":"test"}}` + "\n"
+	if _, err := conn.Write([]byte(login)); err != nil {
+		t.Fatalf("write login: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("read login response: %v", err)
+	}
+	var resp struct {
+		Result struct {
+			Job JobResponse `json:"job"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(line, &resp); err != nil {
+		t.Fatalf("decode login response: %v", err)
+	}
+	if want := DifficultyToStratumTarget(50000); resp.Result.Job.Target != want {
+		t.Fatalf("job target: have %s, want %s for the port difficulty", resp.Result.Job.Target, want)
+	}
+}
@@ -0,0 +1,181 @@
+//go:build randomx && cgo
+
+package main
+
+/*
+#cgo LDFLAGS: -lrandomx -lm -lstdc++
+#include <randomx.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// haveRandomX reports whether the proxy is linked against the RandomX library
+// and can verify shares.
+const haveRandomX = true
+
+// rxEpochs is the number of seeds kept initialised, so that shares for the
+// previous epoch can still be verified right after a seed change.
+const rxEpochs = 2
+
+// rxEpoch holds the RandomX cache, optional dataset and VMs of one seed.
+type rxEpoch struct {
+	seed    [32]byte
+	cache   *C.randomx_cache
+	dataset *C.randomx_dataset
+	vms     chan *C.randomx_vm // Idle VMs, one per CPU
+
+	lock sync.RWMutex // Held for reading while hashing, for writing when freeing
+}
+
+// randomxHasher verifies shares with the RandomX library, in light mode (256 MB
+// cache per seed) unless configured to use the full 2 GB dataset.
+type randomxHasher struct {
+	full   bool
+	flags  C.randomx_flags
+	epochs []*rxEpoch // Initialised seeds, most recently used last
+	lock   sync.Mutex
+}
+
+// newRandomXHasher creates a RandomX hasher, using the full dataset if full is
+// set and light mode otherwise.
+func newRandomXHasher(full bool) (Hasher, error) {
+	flags := C.randomx_get_flags()
+	if full {
+		flags |= C.RANDOMX_FLAG_FULL_MEM
+	}
+	return &randomxHasher{full: full, flags: flags}, nil
+}
+
+// Hash returns the RandomX hash of input under the given seed, initialising
+// the seed first if needed.
+func (h *randomxHasher) Hash(seed []byte, input []byte) ([32]byte, error) {
+	var hash [32]byte
+
+	epoch, err := h.epoch(seed)
+	if err != nil {
+		return hash, err
+	}
+	defer epoch.lock.RUnlock()
+
+	vm := <-epoch.vms
+	C.randomx_calculate_hash(vm, unsafe.Pointer(&input[0]), C.size_t(len(input)), unsafe.Pointer(&hash[0]))
+	epoch.vms <- vm
+
+	return hash, nil
+}
+
+// epoch returns the initialised epoch of a seed, read locked. Seeds falling out
+// of the most recently used ones are freed once no longer hashing.
+func (h *randomxHasher) epoch(seed []byte) (*rxEpoch, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for i, epoch := range h.epochs {
+		if string(epoch.seed[:]) == string(seed) {
+			h.epochs = append(append(h.epochs[:i:i], h.epochs[i+1:]...), epoch)
+			epoch.lock.RLock()
+			return epoch, nil
+		}
+	}
+	epoch, err := h.initEpoch(seed)
+	if err != nil {
+		return nil, err
+	}
+	h.epochs = append(h.epochs, epoch)
+	if len(h.epochs) > rxEpochs {
+		go h.epochs[0].free()
+		h.epochs = h.epochs[1:]
+	}
+	epoch.lock.RLock()
+	return epoch, nil
+}
+
+// initEpoch allocates and initialises the cache, dataset and VMs of a seed.
+func (h *randomxHasher) initEpoch(seed []byte) (*rxEpoch, error) {
+	start := time.Now()
+
+	epoch := &rxEpoch{vms: make(chan *C.randomx_vm, runtime.NumCPU())}
+	copy(epoch.seed[:], seed)
+
+	epoch.cache = C.randomx_alloc_cache(h.flags)
+	if epoch.cache == nil {
+		return nil, errors.New("failed to allocate RandomX cache")
+	}
+	C.randomx_init_cache(epoch.cache, unsafe.Pointer(&epoch.seed[0]), C.size_t(len(epoch.seed)))
+
+	if h.full {
+		epoch.dataset = C.randomx_alloc_dataset(h.flags)
+		if epoch.dataset == nil {
+			epoch.free()
+			return nil, errors.New("failed to allocate RandomX dataset")
+		}
+		initDataset(epoch.dataset, epoch.cache)
+	}
+	for i := 0; i < cap(epoch.vms); i++ {
+		vm := C.randomx_create_vm(h.flags, epoch.cache, epoch.dataset)
+		if vm == nil {
+			epoch.free()
+			return nil, errors.New("failed to create RandomX VM")
+		}
+		epoch.vms <- vm
+	}
+	log.Printf("🔑 Initialised RandomX seed %x for share verification (full: %v, took %s)",
+		epoch.seed[:8], h.full, time.Since(start).Round(time.Millisecond))
+
+	return epoch, nil
+}
+
+// initDataset initialises a dataset from a cache using all CPUs.
+func initDataset(dataset *C.randomx_dataset, cache *C.randomx_cache) {
+	var (
+		items   = uint64(C.randomx_dataset_item_count())
+		threads = uint64(runtime.NumCPU())
+		wg      sync.WaitGroup
+	)
+	for i := uint64(0); i < threads; i++ {
+		start, end := items*i/threads, items*(i+1)/threads
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			C.randomx_init_dataset(dataset, cache, C.ulong(start), C.ulong(end-start))
+		}()
+	}
+	wg.Wait()
+}
+
+// free waits for running hashes to finish and releases the epoch.
+func (e *rxEpoch) free() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for len(e.vms) > 0 {
+		C.randomx_destroy_vm(<-e.vms)
+	}
+	if e.dataset != nil {
+		C.randomx_release_dataset(e.dataset)
+		e.dataset = nil
+	}
+	if e.cache != nil {
+		C.randomx_release_cache(e.cache)
+		e.cache = nil
+	}
+}
+
+// Close releases all initialised seeds.
+func (h *randomxHasher) Close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for _, epoch := range h.epochs {
+		epoch.free()
+	}
+	h.epochs = nil
+}
@@ -0,0 +1,13 @@
+//go:build !randomx || !cgo
+
+package main
+
+// haveRandomX reports whether the proxy is linked against the RandomX library
+// and can verify shares.
+const haveRandomX = false
+
+// newRandomXHasher is unavailable without the RandomX library, the proxy has to
+// run without share verification.
+func newRandomXHasher(full bool) (Hasher, error) {
+	return nil, errNoRandomX
+}
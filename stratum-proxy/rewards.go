@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math/big"
+	"sort"
+)
+
+// Payout schemes supported by the ledger.
+const (
+	SchemePPLNS = "pplns" // Block rewards split by the last N shares once matured
+	SchemePPS   = "pps"   // Every share paid immediately at its expected value
+)
+
+// feeBasisPoints converts a fee percentage to basis points, clamped to 0-100%.
+func feeBasisPoints(feePercent float64) int64 {
+	bps := int64(feePercent*100 + 0.5)
+	if bps < 0 {
+		return 0
+	}
+	if bps > 10000 {
+		return 10000
+	}
+	return bps
+}
+
+// applyFee splits the pool fee off an amount, returning the remainder and the fee.
+func applyFee(amount *big.Int, feePercent float64) (*big.Int, *big.Int) {
+	fee := new(big.Int).Mul(amount, big.NewInt(feeBasisPoints(feePercent)))
+	fee.Div(fee, big.NewInt(10000))
+	return new(big.Int).Sub(amount, fee), fee
+}
+
+// CalculatePPLNS splits a block reward between the addresses of the PPLNS window
+// proportionally to their share difficulty, after the pool fee. Rounding dust
+// goes to the pool along with the fee.
+func CalculatePPLNS(reward *big.Int, feePercent float64, shares map[string]uint64) (map[string]*big.Int, *big.Int) {
+	net, fee := applyFee(reward, feePercent)
+
+	total := new(big.Int)
+	for _, difficulty := range shares {
+		total.Add(total, new(big.Int).SetUint64(difficulty))
+	}
+	if total.Sign() == 0 {
+		return map[string]*big.Int{}, new(big.Int).Set(reward)
+	}
+	addresses := make([]string, 0, len(shares))
+	for address := range shares {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	var (
+		credits = make(map[string]*big.Int, len(shares))
+		paid    = new(big.Int)
+	)
+	for _, address := range addresses {
+		credit := new(big.Int).Mul(net, new(big.Int).SetUint64(shares[address]))
+		credit.Div(credit, total)
+		if credit.Sign() > 0 {
+			credits[address] = credit
+			paid.Add(paid, credit)
+		}
+	}
+	return credits, fee.Add(fee, net.Sub(net, paid))
+}
+
+// CalculatePPS returns the pay-per-share credit of a share: the block reward
+// weighted by the probability of the share being a block, after the pool fee.
+func CalculatePPS(reward *big.Int, feePercent float64, shareDiff, networkDiff uint64) *big.Int {
+	if networkDiff == 0 {
+		return new(big.Int)
+	}
+	credit := new(big.Int).Mul(reward, new(big.Int).SetUint64(shareDiff))
+	credit.Div(credit, new(big.Int).SetUint64(networkDiff))
+
+	credit, _ = applyFee(credit, feePercent)
+	return credit
+}
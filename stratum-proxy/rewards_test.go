@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCalculatePPLNS(t *testing.T) {
+	credits, fee := CalculatePPLNS(big.NewInt(1000), 1.0, map[string]uint64{"0xa": 2, "0xb": 1})
+
+	// 1% fee leaves 990, split 2:1 as 660 and 330
+	if credits["0xa"].Int64() != 660 || credits["0xb"].Int64() != 330 || fee.Int64() != 10 {
+		t.Fatalf("credits %v, fee %v: want 660, 330 and 10", credits, fee)
+	}
+	// Rounding dust goes to the pool, nothing is lost
+	credits, fee = CalculatePPLNS(big.NewInt(100), 0, map[string]uint64{"0xa": 1, "0xb": 1, "0xc": 1})
+	total := new(big.Int).Set(fee)
+	for _, credit := range credits {
+		total.Add(total, credit)
+	}
+	if total.Int64() != 100 || fee.Int64() != 1 {
+		t.Fatalf("split of 100 in thirds: total %v, fee %v", total, fee)
+	}
+}
+
+func TestCalculatePPS(t *testing.T) {
+	// A share of a tenth of the network difficulty earns a tenth of the reward
+	if have := CalculatePPS(big.NewInt(1000), 2.0, 100, 1000); have.Int64() != 98 {
+		t.Fatalf("PPS credit: have %v, want 98", have)
+	}
+	if have := CalculatePPS(big.NewInt(1000), 2.0, 100, 0); have.Sign() != 0 {
+		t.Fatalf("PPS credit without network difficulty: have %v, want 0", have)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math/big"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -161,6 +163,154 @@ func (c *RPCClient) GetBlockNumber() (uint64, error) {
 	return num, nil
 }
 
+// BlockInfo holds the fields of a chain block the proxy needs
+type BlockInfo struct {
+	Number string `json:"number"`
+	Hash   string `json:"hash"`
+	Nonce  string `json:"nonce"`
+	Miner  string `json:"miner"`
+}
+
+// GetBlockByNumber gets the canonical block at the given height, or nil if
+// there is none yet
+func (c *RPCClient) GetBlockByNumber(number uint64) (*BlockInfo, error) {
+	result, err := c.call("eth_getBlockByNumber", []interface{}{fmt.Sprintf("0x%x", number), false})
+	if err != nil {
+		return nil, fmt.Errorf("getBlockByNumber failed: %w", err)
+	}
+	if isNull(result) {
+		return nil, nil
+	}
+	var block BlockInfo
+	if err := json.Unmarshal(result, &block); err != nil {
+		return nil, fmt.Errorf("failed to parse block: %w", err)
+	}
+	return &block, nil
+}
+
+// GetBlockReward gets the base reward paid to the miner of the block at the
+// given height, excluding transaction fees and any treasury share
+func (c *RPCClient) GetBlockReward(number uint64) (*big.Int, error) {
+	result, err := c.call("randomx_getBlockReward", []interface{}{fmt.Sprintf("0x%x", number)})
+	if err != nil {
+		return nil, fmt.Errorf("getBlockReward failed: %w", err)
+	}
+	var reward struct {
+		MinerReward string `json:"minerReward"`
+	}
+	if err := json.Unmarshal(result, &reward); err != nil {
+		return nil, fmt.Errorf("failed to parse block reward: %w", err)
+	}
+	return parseHexBig(reward.MinerReward)
+}
+
+// GetTransactionCount gets the nonce of an account at the given block tag
+// ("latest" or "pending")
+func (c *RPCClient) GetTransactionCount(address, tag string) (uint64, error) {
+	result, err := c.call("eth_getTransactionCount", []interface{}{address, tag})
+	if err != nil {
+		return 0, fmt.Errorf("getTransactionCount failed: %w", err)
+	}
+	var count string
+	if err := json.Unmarshal(result, &count); err != nil {
+		return 0, fmt.Errorf("failed to parse transaction count: %w", err)
+	}
+	n, err := parseHexBig(count)
+	if err != nil {
+		return 0, err
+	}
+	return n.Uint64(), nil
+}
+
+// GasPrice gets the suggested gas price
+func (c *RPCClient) GasPrice() (*big.Int, error) {
+	result, err := c.call("eth_gasPrice", []interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("gasPrice failed: %w", err)
+	}
+	var price string
+	if err := json.Unmarshal(result, &price); err != nil {
+		return nil, fmt.Errorf("failed to parse gas price: %w", err)
+	}
+	return parseHexBig(price)
+}
+
+// SignTransaction signs a value transfer with an account unlocked in Geth (or
+// its external signer), returning the raw transaction and its hash
+func (c *RPCClient) SignTransaction(from, to string, value *big.Int, nonce uint64, gasPrice *big.Int) (string, string, error) {
+	tx := map[string]interface{}{
+		"from":     from,
+		"to":       to,
+		"value":    fmt.Sprintf("0x%x", value),
+		"nonce":    fmt.Sprintf("0x%x", nonce),
+		"gas":      "0x5208", // 21000, plain transfer
+		"gasPrice": fmt.Sprintf("0x%x", gasPrice),
+	}
+	result, err := c.call("eth_signTransaction", []interface{}{tx})
+	if err != nil {
+		return "", "", fmt.Errorf("signTransaction failed: %w", err)
+	}
+	var signed struct {
+		Raw string `json:"raw"`
+		Tx  struct {
+			Hash string `json:"hash"`
+		} `json:"tx"`
+	}
+	if err := json.Unmarshal(result, &signed); err != nil {
+		return "", "", fmt.Errorf("failed to parse signed transaction: %w", err)
+	}
+	return signed.Raw, signed.Tx.Hash, nil
+}
+
+// SendRawTransaction broadcasts a signed transaction
+func (c *RPCClient) SendRawTransaction(raw string) (string, error) {
+	result, err := c.call("eth_sendRawTransaction", []interface{}{raw})
+	if err != nil {
+		return "", fmt.Errorf("sendRawTransaction failed: %w", err)
+	}
+	var hash string
+	if err := json.Unmarshal(result, &hash); err != nil {
+		return "", fmt.Errorf("failed to parse transaction hash: %w", err)
+	}
+	return hash, nil
+}
+
+// GetTransactionReceipt gets the receipt status (1 = success) and block of an
+// included transaction, found is false while it's pending
+func (c *RPCClient) GetTransactionReceipt(hash string) (status uint64, block uint64, found bool, err error) {
+	result, err := c.call("eth_getTransactionReceipt", []interface{}{hash})
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("getTransactionReceipt failed: %w", err)
+	}
+	if isNull(result) {
+		return 0, 0, false, nil
+	}
+	var receipt struct {
+		Status      string `json:"status"`
+		BlockNumber string `json:"blockNumber"`
+	}
+	if err := json.Unmarshal(result, &receipt); err != nil {
+		return 0, 0, false, fmt.Errorf("failed to parse receipt: %w", err)
+	}
+	fmt.Sscanf(receipt.Status, "0x%x", &status)
+	fmt.Sscanf(receipt.BlockNumber, "0x%x", &block)
+	return status, block, true, nil
+}
+
+// isNull reports whether a JSON-RPC result is null
+func isNull(result json.RawMessage) bool {
+	return len(result) == 0 || string(result) == "null"
+}
+
+// parseHexBig parses a 0x-prefixed hex quantity
+func parseHexBig(s string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(s, "0x"), 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hex quantity: %q", s)
+	}
+	return n, nil
+}
+
 // CheckConnection verifies the RPC connection is working
 func (c *RPCClient) CheckConnection() error {
 	_, err := c.call("eth_blockNumber", []interface{}{})
@@ -6,9 +6,12 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/big"
 	"net"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -16,78 +19,174 @@ import (
 
 // Server represents the Stratum proxy server
 type Server struct {
-	config           *ServerConfig
-	rpcClient        *RPCClient
+	config           *ServerConfig  // Replaced as a whole on reload
+	configMu         sync.RWMutex
+	upstreams        *UpstreamPool
+	workers          workerRegistry // State of recently disconnected workers
 	listener         net.Listener
+	portListeners    []portListener // Additional stratum ports
+	apiServer        *http.Server   // Nil if the HTTP API is disabled
 	miners           map[string]*Miner
 	minersMu         sync.RWMutex
 	currentWork      *WorkPackage
 	currentJob       *Job
 	workMu           sync.RWMutex
 	stats            *Stats
+	verifier         *ShareVerifier // Nil if share verification is disabled
+	ledger           *Ledger        // Nil unless running as a pool
+	bans             *BanList       // Nil if no ban list is configured
+	blockReward      *big.Int       // Miner reward of the block being mined (PPS)
 	jobCounter       uint64
 	connectionCount  int           // Current number of connections
-	connectionCountMu sync.Mutex   // Protects connectionCount
+	connectionsPerIP map[string]int // Current number of connections by remote IP
+	connectionCountMu sync.Mutex   // Protects connectionCount and connectionsPerIP
 	stopCh           chan struct{}
 	wg               sync.WaitGroup
 }
 
 // NewServer creates a new Stratum server
 func NewServer(config *ServerConfig) (*Server, error) {
-	rpcClient := NewRPCClient(config.GethRPC)
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	configs := config.Upstreams
+	if len(configs) == 0 {
+		configs = []UpstreamConfig{{URL: config.GethRPC}}
+	}
+	upstreams := NewUpstreamPool(configs, config.MaxUpstreamLag)
 
 	// Test connection
-	if err := rpcClient.CheckConnection(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Geth: %w", err)
+	if !upstreams.CheckHealth() {
+		for _, status := range upstreams.Status() {
+			log.Printf("❌ Upstream %s: %s", status.Name, status.LastError)
+		}
+		return nil, errors.New("failed to connect to Geth: no healthy upstream")
+	}
+
+	var verifier *ShareVerifier
+	if config.VerifyShares {
+		hasher, err := newRandomXHasher(config.VerifyFullMode)
+		if err != nil {
+			return nil, fmt.Errorf("share verification unavailable: %w", err)
+		}
+		verifier = NewShareVerifier(hasher, config.SpotCheckRate, config.TrustThreshold)
+	}
+
+	// Pools keep their accounting in the ledger
+	var ledger *Ledger
+	if config.PoolAddress != "" && config.LedgerDir != "" {
+		var err error
+		if ledger, err = OpenLedger(config.LedgerDir); err != nil {
+			return nil, fmt.Errorf("failed to open ledger: %w", err)
+		}
+	}
+
+	// Bans survive restarts in the ban list
+	var bans *BanList
+	if config.BanFile != "" {
+		var err error
+		if bans, err = OpenBanList(config.BanFile); err != nil {
+			return nil, err
+		}
+		log.Printf("🛡️  Ban list %s: %d bans in effect", config.BanFile, len(bans.List()))
 	}
 
 	return &Server{
 		config:     config,
-		rpcClient:  rpcClient,
+		upstreams:  upstreams,
 		miners:     make(map[string]*Miner),
 		stats:      NewStats(),
+		verifier:   verifier,
+		ledger:     ledger,
+		bans:       bans,
 		stopCh:     make(chan struct{}),
 	}, nil
 }
 
 // Start starts the server
 func (s *Server) Start() error {
-	listener, err := net.Listen("tcp", s.config.ListenAddr)
+	listener, err := net.Listen("tcp", s.cfg().ListenAddr)
 	if err != nil {
 		return fmt.Errorf("failed to listen: %w", err)
 	}
 	s.listener = listener
 
-	// Start work updater
-	s.wg.Add(1)
+	// Open additional ports, possibly with TLS
+	if err := s.listenPorts(); err != nil {
+		s.closeListeners()
+		return err
+	}
+
+	// Start HTTP stats API and metrics
+	if s.cfg().APIAddr != "" {
+		if err := s.startAPI(); err != nil {
+			s.closeListeners()
+			return err
+		}
+	}
+
+	// Start work updater and upstream health checks
+	s.wg.Add(2)
 	go s.workUpdater()
+	go s.upstreamMonitor()
 
 	// Start stats reporter
 	s.wg.Add(1)
 	go s.statsReporter()
 
+	// Start block unlocker and payouts
+	if s.ledger != nil {
+		s.wg.Add(2)
+		go s.blockUnlocker()
+		go s.payoutWorker()
+	}
+
 	// Accept connections
-	s.wg.Add(1)
-	go s.acceptConnections()
+	s.wg.Add(1 + len(s.portListeners))
+	go s.acceptConnections(listener, s.cfg().InitialDiff)
+	for _, port := range s.portListeners {
+		go s.acceptConnections(port.listener, port.port.InitialDiff)
+	}
 
 	return nil
 }
 
+// closeListeners closes the stratum listeners
+func (s *Server) closeListeners() {
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for _, port := range s.portListeners {
+		port.listener.Close()
+	}
+}
+
 // Stop stops the server
 func (s *Server) Stop() {
 	close(s.stopCh)
-	if s.listener != nil {
-		s.listener.Close()
+	s.closeListeners()
+	if s.apiServer != nil {
+		s.apiServer.Close()
 	}
 	s.wg.Wait()
+
+	if s.verifier != nil {
+		s.verifier.Close()
+	}
+	if s.ledger != nil {
+		if err := s.ledger.Close(); err != nil {
+			log.Printf("❌ Failed to close ledger: %v", err)
+		}
+	}
 }
 
-// acceptConnections accepts incoming miner connections
-func (s *Server) acceptConnections() {
+// acceptConnections accepts incoming miner connections on a listener, starting
+// miners at the listener's initial difficulty
+func (s *Server) acceptConnections(listener net.Listener, initialDiff float64) {
 	defer s.wg.Done()
 
 	for {
-		conn, err := s.listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
 			select {
 			case <-s.stopCh:
@@ -98,40 +197,73 @@ func (s *Server) acceptConnections() {
 			}
 		}
 
-		// Check connection limit (DoS protection)
-		if s.config.MaxConnections > 0 {
-			s.connectionCountMu.Lock()
-			if s.connectionCount >= s.config.MaxConnections {
-				s.connectionCountMu.Unlock()
-				log.Printf("🚫 Connection limit reached (%d), rejecting %s",
-					s.config.MaxConnections, conn.RemoteAddr())
+		// Drop banned clients before reading anything from them
+		ip := remoteIP(conn.RemoteAddr().String())
+		if s.bans != nil {
+			if ban := s.bans.CheckIP(ip); ban != nil {
+				if s.cfg().Verbose {
+					log.Printf("🚫 Rejecting banned %s: %s", conn.RemoteAddr(), ban.Reason)
+				}
+				s.stats.RecordRejectedConnection(ConnRejectBanned)
 				conn.Close()
 				continue
 			}
-			s.connectionCount++
-			s.connectionCountMu.Unlock()
+		}
+
+		// Check connection limits (DoS protection)
+		if reason := s.openConnection(ip); reason != "" {
+			log.Printf("🚫 Connection limit reached (%s), rejecting %s", reason, conn.RemoteAddr())
+			s.stats.RecordRejectedConnection(reason)
+			conn.Close()
+			continue
 		}
 
 		s.wg.Add(1)
-		go s.handleMiner(conn)
+		go s.handleMiner(conn, initialDiff)
+	}
+}
+
+// openConnection counts a new connection from an IP, or returns the limit
+// it exceeds.
+func (s *Server) openConnection(ip string) string {
+	config := s.cfg()
+
+	s.connectionCountMu.Lock()
+	defer s.connectionCountMu.Unlock()
+
+	if config.MaxConnections > 0 && s.connectionCount >= config.MaxConnections {
+		return ConnRejectLimit
+	}
+	if config.MaxConnectionsPerIP > 0 && s.connectionsPerIP[ip] >= config.MaxConnectionsPerIP {
+		return ConnRejectIPLimit
+	}
+	if s.connectionsPerIP == nil {
+		s.connectionsPerIP = make(map[string]int)
+	}
+	s.connectionCount++
+	s.connectionsPerIP[ip]++
+	return ""
+}
+
+// closeConnection uncounts a closed connection from an IP.
+func (s *Server) closeConnection(ip string) {
+	s.connectionCountMu.Lock()
+	defer s.connectionCountMu.Unlock()
+
+	s.connectionCount--
+	if s.connectionsPerIP[ip]--; s.connectionsPerIP[ip] <= 0 {
+		delete(s.connectionsPerIP, ip)
 	}
 }
 
 // handleMiner handles a single miner connection
-func (s *Server) handleMiner(conn net.Conn) {
+func (s *Server) handleMiner(conn net.Conn, initialDiff float64) {
 	defer s.wg.Done()
 	defer conn.Close()
 
-	// Decrement connection count on exit
-	defer func() {
-		if s.config.MaxConnections > 0 {
-			s.connectionCountMu.Lock()
-			s.connectionCount--
-			s.connectionCountMu.Unlock()
-		}
-	}()
-
 	minerID := conn.RemoteAddr().String()
+	defer s.closeConnection(remoteIP(minerID))
+
 	log.Printf("🔌 New connection from %s", minerID)
 
 	// Set absolute deadline for connection (1 hour max)
@@ -151,10 +283,12 @@ func (s *Server) handleMiner(conn net.Conn) {
 	jsonWriter := json.NewEncoder(writer)
 
 	miner := &Miner{
-		ID:             minerID,
+		ID:             minerID, // Replaced by address.worker on login
+		RemoteAddr:     minerID,
+		Conn:           conn,
 		Writer:         jsonWriter,
 		BufferedWriter: writer, // Store for Flush() after notifications
-		Difficulty:     uint64(s.config.InitialDiff),
+		Difficulty:     uint64(initialDiff),
 		ExtraNonce:     extraNonce,
 		LastActivity:   time.Now(),
 		LastShareTime:  time.Now(),
@@ -167,10 +301,15 @@ func (s *Server) handleMiner(conn net.Conn) {
 	s.minersMu.Unlock()
 
 	defer func() {
-		// Clean up miner resources before removal
+		// Clean up miner resources before removal, keeping the worker state
+		// for reconnects
 		miner.mu.Lock()
+		if miner.Address != "" {
+			s.workers.save(miner.ID, miner.saveState())
+		}
 		miner.ShareTimes = nil // Release slice memory
 		miner.CurrentJob = nil // Release job reference
+		miner.Jobs = jobHistory{}
 		miner.mu.Unlock()
 
 		miner.writerMu.Lock()
@@ -183,7 +322,7 @@ func (s *Server) handleMiner(conn net.Conn) {
 		delete(s.miners, minerID)
 		s.minersMu.Unlock()
 
-		if s.config.Verbose {
+		if s.cfg().Verbose {
 			log.Printf("👋 Miner %s disconnected (cleaned up)", minerID)
 		} else {
 			log.Printf("👋 Miner %s disconnected", minerID)
@@ -203,7 +342,7 @@ func (s *Server) handleMiner(conn net.Conn) {
 
 		line, err := reader.ReadBytes('\n')
 		if err != nil {
-			if s.config.Verbose {
+			if s.cfg().Verbose {
 				log.Printf("Read error from %s: %v", minerID, err)
 			}
 			return
@@ -224,7 +363,7 @@ func (s *Server) handleMiner(conn net.Conn) {
 		responseJSON = append(responseJSON, '\n')
 
 		// Debug: log the response we're sending
-		if s.config.Verbose {
+		if s.cfg().Verbose {
 			log.Printf("📤 [%s] Response: %s", minerID, string(responseJSON))
 		}
 
@@ -244,7 +383,7 @@ func (s *Server) handleMiner(conn net.Conn) {
 
 // handleRequest handles a Stratum request
 func (s *Server) handleRequest(miner *Miner, req *StratumRequest) *StratumResponse {
-	if s.config.Verbose {
+	if s.cfg().Verbose {
 		log.Printf("📩 [%s] %s %v", miner.ID, req.Method, req.Params)
 	}
 
@@ -252,6 +391,8 @@ func (s *Server) handleRequest(miner *Miner, req *StratumRequest) *StratumRespon
 	case "login":
 		return s.handleLogin(miner, req)
 	case "submit":
+		start := time.Now()
+		defer func() { s.stats.ObserveSubmit(time.Since(start)) }()
 		return s.handleSubmit(miner, req)
 	case "keepalived":
 		return &StratumResponse{
@@ -272,6 +413,14 @@ func (s *Server) handleRequest(miner *Miner, req *StratumRequest) *StratumRespon
 	}
 }
 
+// workerGracePeriod returns how long disconnected workers are remembered
+func (s *Server) workerGracePeriod() time.Duration {
+	if s.cfg().WorkerGracePeriod > 0 {
+		return s.cfg().WorkerGracePeriod
+	}
+	return defaultWorkerGracePeriod
+}
+
 // handleLogin handles miner login
 func (s *Server) handleLogin(miner *Miner, req *StratumRequest) *StratumResponse {
 	// Try params as object first (xmrig format)
@@ -300,26 +449,92 @@ func (s *Server) handleLogin(miner *Miner, req *StratumRequest) *StratumResponse
 			loginData = obj
 		} else if login, ok := paramsArray[0].(string); ok {
 			// Simple string login
-			miner.Address = login
-			miner.WorkerName = login
-			loginData = nil
+			loginData = map[string]interface{}{"login": login}
 		}
 	}
 
-	// Parse login data
-	if loginData != nil {
-		if login, ok := loginData["login"].(string); ok {
-			miner.Address = login
+	// Parse login data: address[.worker][+difficulty], pass as worker name
+	login, _ := loginData["login"].(string)
+	pass, _ := loginData["pass"].(string)
+	agent, _ := loginData["agent"].(string)
+
+	miner.mu.RLock()
+	loggedIn := miner.Address != ""
+	miner.mu.RUnlock()
+
+	if loggedIn {
+		return &StratumResponse{
+			ID:      req.ID,
+			JSONRPC: "2.0",
+			Error: &StratumError{
+				Code:    -1,
+				Message: "Already logged in",
+			},
 		}
-		if pass, ok := loginData["pass"].(string); ok {
-			miner.WorkerName = pass
+	}
+
+	parsed, err := parseLogin(login, pass)
+	if err != nil {
+		log.Printf("❌ Invalid login from %s: %v", miner.ID, err)
+		return &StratumResponse{
+			ID:      req.ID,
+			JSONRPC: "2.0",
+			Error: &StratumError{
+				Code:    -1,
+				Message: fmt.Sprintf("Invalid login: %v", err),
+			},
 		}
-		if agent, ok := loginData["agent"].(string); ok {
-			miner.Agent = agent
+	}
+
+	if s.bans != nil {
+		if ban := s.bans.CheckAddress(parsed.Address); ban != nil {
+			log.Printf("🚫 Rejecting login of banned address %s from %s", parsed.Address, miner.RemoteAddr)
+			s.stats.RecordRejectedConnection(ConnRejectBanned)
+			return &StratumResponse{
+				ID:      req.ID,
+				JSONRPC: "2.0",
+				Error: &StratumError{
+					Code:    -1,
+					Message: "Banned: " + ban.Reason,
+				},
+			}
 		}
 	}
 
-	log.Printf("✅ Miner %s logged in: %s (%s)", miner.ID, miner.Address, miner.Agent)
+	// Workers are identified by address and name, so that a reconnect within
+	// the grace period resumes their difficulty and stats
+	state := s.workers.restore(parsed.Key(), s.workerGracePeriod())
+
+	miner.mu.Lock()
+	miner.ID = parsed.Key()
+	miner.Address = parsed.Address
+	miner.WorkerName = parsed.Worker
+	miner.FixedDiff = parsed.FixedDiff
+	miner.Agent = agent
+	if state != nil {
+		miner.restoreState(state)
+	}
+	if parsed.FixedDiff > 0 {
+		miner.Difficulty = parsed.FixedDiff
+	}
+	banned, banReason := miner.Banned, miner.BanReason
+	miner.mu.Unlock()
+
+	if banned {
+		return &StratumResponse{
+			ID:      req.ID,
+			JSONRPC: "2.0",
+			Error: &StratumError{
+				Code:    -1,
+				Message: "Banned: " + banReason,
+			},
+		}
+	}
+	if state != nil {
+		log.Printf("✅ Miner %s logged in again from %s (%s), resuming at diff %d", miner.ID, miner.RemoteAddr, agent, miner.Difficulty)
+	} else {
+		log.Printf("✅ Miner %s logged in from %s (%s)", miner.ID, miner.RemoteAddr, agent)
+	}
 
 	// Get current job
 	s.workMu.RLock()
@@ -338,7 +553,10 @@ func (s *Server) handleLogin(miner *Miner, req *StratumRequest) *StratumResponse
 	}
 
 	// Send job to miner
+	miner.mu.Lock()
 	miner.CurrentJob = job
+	miner.Jobs.add(job)
+	miner.mu.Unlock()
 
 	// Create rx-eth-v1 blob with miner's extraNonce
 	// Format: headerHash(32) || extraNonce(4) || const3(3) || nonce4(4)
@@ -435,15 +653,15 @@ func (s *Server) handleSubmit(miner *Miner, req *StratumRequest) *StratumRespons
 	// Rate limiting check (DoS protection)
 	// NOTE: We check rate BEFORE validating share to prevent spam
 	// But we DON'T update timestamp yet - only after valid share
-	if s.config.ShareRateLimit > 0 {
+	if s.cfg().ShareRateLimit > 0 {
 		now := time.Now()
 		miner.mu.Lock()
 		timeSinceLastShare := now.Sub(miner.LastShareSubmitTime).Seconds()
-		minInterval := 1.0 / s.config.ShareRateLimit // Minimum seconds between shares
+		minInterval := 1.0 / s.cfg().ShareRateLimit // Minimum seconds between shares
 
 		if timeSinceLastShare < minInterval && !miner.LastShareSubmitTime.IsZero() {
 			miner.mu.Unlock()
-			if s.config.Verbose {
+			if s.cfg().Verbose {
 				log.Printf("⚠️  Rate limit exceeded for %s (%.3fs < %.3fs)",
 					miner.ID, timeSinceLastShare, minInterval)
 			}
@@ -464,21 +682,30 @@ func (s *Server) handleSubmit(miner *Miner, req *StratumRequest) *StratumRespons
 	nonceStr, _ := submitData["nonce"].(string)
 	resultStr, _ := submitData["result"].(string)
 
-	if s.config.Verbose {
+	if s.cfg().Verbose {
 		log.Printf("📤 Share from %s: job=%s nonce=%s result=%s",
 			miner.ID, jobID, nonceStr, resultStr)
 	}
 
-	// Validate job
-	if miner.CurrentJob == nil || miner.CurrentJob.JobID != jobID {
-		log.Printf("❌ Stale share from %s", miner.ID)
+	// Validate job: shares for any recent job of the current height are fine,
+	// older or expired jobs can't become blocks anymore and are stale
+	miner.mu.RLock()
+	entry := miner.Jobs.get(jobID)
+	miner.mu.RUnlock()
 
-		// Update invalid share count with lock
+	s.workMu.RLock()
+	networkJob := s.currentJob
+	s.workMu.RUnlock()
+
+	if entry == nil || (networkJob != nil && entry.job.Height < networkJob.Height) {
+		log.Printf("⏰ Stale share from %s (job %s)", miner.ID, jobID)
+
+		// Stale shares are expected around new blocks, don't count them as invalid
 		miner.mu.Lock()
-		miner.SharesInvalid++
+		miner.SharesStale++
 		miner.mu.Unlock()
 
-		s.stats.RecordShare(false)
+		s.stats.RecordStaleShare()
 		return &StratumResponse{
 			ID:      req.ID,
 			JSONRPC: "2.0",
@@ -499,7 +726,7 @@ func (s *Server) handleSubmit(miner *Miner, req *StratumRequest) *StratumRespons
 		miner.SharesInvalid++
 		miner.mu.Unlock()
 
-		s.stats.RecordShare(false)
+		s.stats.RecordRejectedShare(RejectMalformed)
 		return &StratumResponse{
 			ID:      req.ID,
 			JSONRPC: "2.0",
@@ -520,7 +747,7 @@ func (s *Server) handleSubmit(miner *Miner, req *StratumRequest) *StratumRespons
 		miner.SharesInvalid++
 		miner.mu.Unlock()
 
-		s.stats.RecordShare(false)
+		s.stats.RecordRejectedShare(RejectMalformed)
 		return &StratumResponse{
 			ID:      req.ID,
 			JSONRPC: "2.0",
@@ -532,13 +759,37 @@ func (s *Server) handleSubmit(miner *Miner, req *StratumRequest) *StratumRespons
 	}
 
 	minerNonce4 := binary.LittleEndian.Uint32(minerNonceBytes)
+	job := entry.job
+
+	// Reject nonces already submitted for this job
+	miner.mu.Lock()
+	fresh := entry.submit(minerNonce4)
+	if !fresh {
+		miner.SharesInvalid++
+		miner.SharesInvalidStreak++
+	}
+	miner.mu.Unlock()
+
+	if !fresh {
+		log.Printf("❌ Duplicate share from %s (job %s, nonce %s)", miner.ID, jobID, nonceStr)
+
+		s.stats.RecordRejectedShare(RejectDuplicate)
+		return &StratumResponse{
+			ID:      req.ID,
+			JSONRPC: "2.0",
+			Error: &StratumError{
+				Code:    -1,
+				Message: "Duplicate share",
+			},
+		}
+	}
 
 	// Combine extraNonce (high 32 bits) and minerNonce (low 32 bits)
 	// nonce64 = (extraNonce << 32) | minerNonce4
 	nonce64 := (uint64(miner.ExtraNonce) << 32) | uint64(minerNonce4)
 	nonceHex := fmt.Sprintf("0x%016x", nonce64)
 
-	if s.config.Verbose {
+	if s.cfg().Verbose {
 		log.Printf("🔢 Nonce: extraNonce=%08x minerNonce=%08x combined=%016x",
 			miner.ExtraNonce, minerNonce4, nonce64)
 	}
@@ -552,11 +803,39 @@ func (s *Server) handleSubmit(miner *Miner, req *StratumRequest) *StratumRespons
 	// This allows us to validate low-difficulty shares locally
 	shareValid, shareDiff := ValidateShare(resultStr, miner.Difficulty)
 
-	if s.config.Verbose {
+	if s.cfg().Verbose {
 		log.Printf("🔍 Share validation: hash=%s shareDiff=%d minerDiff=%d valid=%v",
 			resultStr[:18]+"...", shareDiff, miner.Difficulty, shareValid)
 	}
 
+	// Get network difficulty from current work to detect block candidates
+	s.workMu.RLock()
+	networkDifficulty := uint64(0)
+	if s.currentWork != nil {
+		networkDifficulty, _ = TargetToDifficulty(s.currentWork.Target)
+	}
+	s.workMu.RUnlock()
+
+	isBlock := shareDiff >= networkDifficulty && networkDifficulty > 0
+
+	// The result is only claimed by the miner, recompute the hash before
+	// crediting the share. Trusted miners may only be spot-checked, but block
+	// candidates are always verified before reaching geth.
+	if shareValid && s.verifier != nil {
+		miner.mu.RLock()
+		verifiedShares := miner.SharesVerified
+		miner.mu.RUnlock()
+
+		if s.verifier.ShouldVerify(verifiedShares, isBlock) {
+			if err := s.verifier.Verify(job, miner.ExtraNonce, minerNonce4, resultStr); err != nil {
+				return s.rejectUnverifiedShare(miner, req, err)
+			}
+			miner.mu.Lock()
+			miner.SharesVerified++
+			miner.mu.Unlock()
+		}
+	}
+
 	if !shareValid {
 		log.Printf("❌ Share below difficulty from %s (got %d, need %d)",
 			miner.ID, shareDiff, miner.Difficulty)
@@ -567,7 +846,7 @@ func (s *Server) handleSubmit(miner *Miner, req *StratumRequest) *StratumRespons
 		miner.SharesInvalidStreak++
 
 		// Check ban threshold
-		if s.config.MaxInvalidStreak > 0 && miner.SharesInvalidStreak >= s.config.MaxInvalidStreak {
+		if s.cfg().MaxInvalidStreak > 0 && miner.SharesInvalidStreak >= s.cfg().MaxInvalidStreak {
 			miner.Banned = true
 			miner.BanReason = fmt.Sprintf("Exceeded max invalid shares (%d consecutive)", miner.SharesInvalidStreak)
 			log.Printf("🚫 BANNED miner %s: %s", miner.ID, miner.BanReason)
@@ -575,10 +854,12 @@ func (s *Server) handleSubmit(miner *Miner, req *StratumRequest) *StratumRespons
 		banned := miner.Banned
 		miner.mu.Unlock()
 
-		s.stats.RecordShare(false)
+		s.stats.RecordRejectedShare(RejectLowDifficulty)
 
 		// Disconnect banned miners
 		if banned {
+			s.stats.RecordBan(BanInvalidStreak)
+			s.banMinerIP(miner, miner.BanReason)
 			return &StratumResponse{
 				ID:      req.ID,
 				JSONRPC: "2.0",
@@ -607,9 +888,11 @@ func (s *Server) handleSubmit(miner *Miner, req *StratumRequest) *StratumRespons
 	miner.SharesValid++
 	miner.SharesInvalidStreak = 0 // Reset invalid streak on valid share
 	miner.TotalDifficulty += miner.Difficulty // Track contribution for pool payouts
+	creditedDiff := miner.Difficulty
+	minerAddress := miner.Address
 
 	// Update rate limit timestamp ONLY on valid share (prevents rate limit bypass)
-	if s.config.ShareRateLimit > 0 {
+	if s.cfg().ShareRateLimit > 0 {
 		miner.LastShareSubmitTime = time.Now()
 	}
 
@@ -635,58 +918,57 @@ func (s *Server) handleSubmit(miner *Miner, req *StratumRequest) *StratumRespons
 	// Adjust difficulty based on configured window
 	oldDiff := miner.Difficulty
 	difficultyChanged := false
-	varDiffWindow := s.config.VarDiffWindow
+	varDiffWindow := s.cfg().VarDiffWindow
 	if varDiffWindow == 0 {
 		varDiffWindow = 10 // Default fallback
 	}
 
-	if miner.SharesValid%varDiffWindow == 0 && len(miner.ShareTimes) >= int(varDiffWindow) {
+	if miner.FixedDiff == 0 && miner.SharesValid%varDiffWindow == 0 && len(miner.ShareTimes) >= int(varDiffWindow) {
 		// Calculate share rate based on configured window
 		lastN := miner.ShareTimes[len(miner.ShareTimes)-int(varDiffWindow):]
 		timeSpan := now.Sub(lastN[0]).Minutes()
 		if timeSpan > 0 {
 			shareRate := float64(varDiffWindow) / timeSpan // shares per minute
 			// Convert target from seconds to shares/minute
-			targetRate := 60.0 / s.config.VarDiffTarget // shares per minute
+			targetRate := 60.0 / s.cfg().VarDiffTarget // shares per minute
 			miner.Difficulty = AdjustDifficulty(miner.Difficulty, shareRate, targetRate)
 
 			if miner.Difficulty != oldDiff {
 				difficultyChanged = true
 				log.Printf("📊 Adjusted difficulty for %s: %d → %d (hashrate: %.2f H/s, target: %.1fs/share)",
-					miner.ID, oldDiff, miner.Difficulty, miner.Hashrate, s.config.VarDiffTarget)
+					miner.ID, oldDiff, miner.Difficulty, miner.Hashrate, s.cfg().VarDiffTarget)
 			}
 		}
 	}
 
+	newDiff := miner.Difficulty
 	currentJob := miner.CurrentJob
 	miner.LastShareTime = now
 	miner.mu.Unlock()
 
 	// If difficulty changed, push new job with updated target
-	if difficultyChanged && currentJob != nil {
-		s.pushJob(miner, currentJob)
+	if difficultyChanged {
+		s.stats.RecordVarDiff(oldDiff, newDiff)
+		if currentJob != nil {
+			s.pushJob(miner, currentJob)
+		}
 	}
 
 	s.stats.RecordShare(true)
 
-	// Check if this share meets NETWORK difficulty (potential block)
-	// Get network difficulty from current work
-	s.workMu.RLock()
-	networkDifficulty := uint64(0)
-	if s.currentWork != nil {
-		networkDifficulty, _ = TargetToDifficulty(s.currentWork.Target)
+	if s.ledger != nil {
+		s.recordShare(minerAddress, creditedDiff, networkDifficulty)
 	}
-	s.workMu.RUnlock()
-
-	isBlock := shareDiff >= networkDifficulty && networkDifficulty > 0
 
+	// Check if this share meets NETWORK difficulty (potential block)
 	if isBlock {
 		log.Printf("🎉 BLOCK CANDIDATE from %s! (diff: %d >= %d)", miner.ID, shareDiff, networkDifficulty)
 
 		// Submit to geth for block validation
-		accepted, err := s.rpcClient.SubmitWork(
+		accepted, err := s.upstreams.SubmitWork(
+			job.Upstream,
 			nonceHex,
-			miner.CurrentJob.HeaderHash,
+			job.HeaderHash,
 			resultStr, // Use result as mixDigest
 		)
 
@@ -696,28 +978,38 @@ func (s *Server) handleSubmit(miner *Miner, req *StratumRequest) *StratumRespons
 			// The share itself is valid for pool purposes
 		} else if accepted {
 			log.Printf("🎉🎉🎉 BLOCK ACCEPTED by network from %s!", miner.ID)
-			s.stats.RecordBlock()
-
 			// Update miner's block count
 			miner.mu.Lock()
 			miner.BlocksFound++
-			minerAddress := miner.Address
 			minerBlocks := miner.BlocksFound
+			workerName := miner.WorkerName
 			miner.mu.Unlock()
 
+			s.stats.RecordBlock(FoundBlock{
+				Height: job.Height,
+				Nonce:  nonce64,
+				Finder: minerAddress,
+				Worker: workerName,
+				Found:  time.Now(),
+			})
+
 			// Pool fee information
-			if s.config.PoolAddress != "" {
-				log.Printf("💰 Block mined to pool address: %s", s.config.PoolAddress)
-				log.Printf("💰 Pool fee: %.2f%% (operator keeps fee, pays out based on shares)", s.config.PoolFee)
-				log.Printf("💰 Miner %s contributed this block (address: %s, total blocks: %d)",
+			if s.cfg().PoolAddress != "" {
+				log.Printf("💰 Block mined to pool address: %s", s.cfg().PoolAddress)
+				log.Printf("💰 Miner %s found this block (address: %s, total blocks: %d)",
 					miner.ID, minerAddress, minerBlocks)
-				// TODO: Implement automated payout system based on share contributions
-				// For now, operator must manually calculate payouts using share logs
 			} else {
-				log.Printf("💰 Block reward goes to miner address: %s", minerAddress)
+				log.Printf("💰 Block mined to the etherbase of the node, found by %s", minerAddress)
+			}
+			// Rewards are credited by the block unlocker once the block matured
+			if s.ledger != nil {
+				if err := s.ledger.RecordBlock(job.Height, nonce64, minerAddress); err != nil {
+					log.Printf("❌ Failed to record block %d: %v", job.Height, err)
+				}
 			}
 		} else {
 			log.Printf("⚠️  Block rejected by network from %s (but share is valid)", miner.ID)
+			s.stats.RecordRejectedBlock()
 		}
 	}
 
@@ -729,6 +1021,45 @@ func (s *Server) handleSubmit(miner *Miner, req *StratumRequest) *StratumRespons
 	}
 }
 
+// rejectUnverifiedShare rejects a share that failed hash verification. Miners
+// reporting a hash that doesn't match their share are banned right away, as
+// that can't happen by accident.
+func (s *Server) rejectUnverifiedShare(miner *Miner, req *StratumRequest, err error) *StratumResponse {
+	s.stats.RecordRejectedShare(RejectUnverified)
+
+	miner.mu.Lock()
+	miner.SharesInvalid++
+	if !errors.Is(err, errForgedResult) {
+		miner.mu.Unlock()
+
+		log.Printf("⚠️  Share verification failed for %s: %v", miner.ID, err)
+		return &StratumResponse{
+			ID:      req.ID,
+			JSONRPC: "2.0",
+			Error: &StratumError{
+				Code:    -1,
+				Message: "Share verification failed",
+			},
+		}
+	}
+	miner.Banned = true
+	miner.BanReason = "Forged share result"
+	banReason := miner.BanReason
+	miner.mu.Unlock()
+
+	s.stats.RecordBan(BanForgedShare)
+	log.Printf("🚫 BANNED miner %s: %s", miner.ID, banReason)
+	s.banMinerIP(miner, banReason)
+	return &StratumResponse{
+		ID:      req.ID,
+		JSONRPC: "2.0",
+		Error: &StratumError{
+			Code:    -1,
+			Message: "Banned: " + banReason,
+		},
+	}
+}
+
 // workUpdater fetches new work from Geth periodically
 func (s *Server) workUpdater() {
 	defer s.wg.Done()
@@ -746,11 +1077,36 @@ func (s *Server) workUpdater() {
 	}
 }
 
+// upstreamMonitor health checks the upstreams periodically, failing over to
+// a healthy one if needed
+func (s *Server) upstreamMonitor() {
+	defer s.wg.Done()
+
+	interval := s.cfg().HealthCheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			if !s.upstreams.CheckHealth() {
+				log.Printf("⚠️  No healthy upstream, keeping %s", s.upstreams.Active().Name)
+			}
+		}
+	}
+}
+
 // updateWork fetches new work and distributes to miners
 func (s *Server) updateWork() {
-	work, err := s.rpcClient.GetWork()
+	upstream := s.upstreams.Active()
+	work, err := upstream.client.GetWork()
 	if err != nil {
-		if s.config.Verbose {
+		if s.cfg().Verbose {
 			log.Printf("⚠️  Failed to get work: %v", err)
 		}
 		return
@@ -773,16 +1129,28 @@ func (s *Server) updateWork() {
 	s.jobCounter++
 	jobID := fmt.Sprintf("%d", s.jobCounter)
 
-	job, err := WorkToJob(work, jobID, s.config.Algorithm)
+	job, err := WorkToJob(work, jobID, s.cfg().Algorithm)
 	if err != nil {
 		log.Printf("❌ Failed to create job: %v", err)
 		return
 	}
+	job.Upstream = upstream
+
+	// PPS credits shares at the reward of the block being mined
+	var reward *big.Int
+	if s.ledger != nil && s.cfg().PayoutScheme == SchemePPS {
+		if reward, err = upstream.client.GetBlockReward(job.Height); err != nil {
+			log.Printf("⚠️  Failed to get block reward: %v", err)
+		}
+	}
 
 	// Update current work
 	s.workMu.Lock()
 	s.currentWork = work
 	s.currentJob = job
+	if reward != nil {
+		s.blockReward = reward
+	}
 	s.workMu.Unlock()
 
 	log.Printf("📦 New job %s: block %d, seed %s",
@@ -817,7 +1185,7 @@ func (s *Server) broadcastJob(job *Job) {
 		successCount++
 	}
 
-	if s.config.Verbose {
+	if s.cfg().Verbose {
 		log.Printf("📢 Broadcasted job %s to %d/%d miners", job.JobID, successCount, len(miners))
 	}
 }
@@ -827,6 +1195,7 @@ func (s *Server) pushJob(miner *Miner, job *Job) {
 	// Lock miner state to read values and update current job
 	miner.mu.Lock()
 	miner.CurrentJob = job
+	miner.Jobs.add(job)
 	extraNonce := miner.ExtraNonce
 	difficulty := miner.Difficulty
 	minerID := miner.ID
@@ -864,7 +1233,7 @@ func (s *Server) pushJob(miner *Miner, job *Job) {
 
 	if miner.Writer != nil {
 		if err := miner.Writer.Encode(notification); err != nil {
-			if s.config.Verbose {
+			if s.cfg().Verbose {
 				log.Printf("⚠️  Failed to push job to %s: %v", minerID, err)
 			}
 			return
@@ -873,14 +1242,14 @@ func (s *Server) pushJob(miner *Miner, job *Job) {
 		// CRITICAL: Flush buffer so notification is actually sent!
 		if miner.BufferedWriter != nil {
 			if err := miner.BufferedWriter.Flush(); err != nil {
-				if s.config.Verbose {
+				if s.cfg().Verbose {
 					log.Printf("⚠️  Failed to flush buffer for %s: %v", minerID, err)
 				}
 				return
 			}
 		}
 
-		if s.config.Verbose {
+		if s.cfg().Verbose {
 			log.Printf("📤 Pushed job %s to %s (diff: %d)", job.JobID, minerID, difficulty)
 		}
 	}
@@ -899,6 +1268,7 @@ func (s *Server) statsReporter() {
 			return
 		case <-ticker.C:
 			s.printStats()
+			s.workers.prune(s.workerGracePeriod())
 		}
 	}
 }
@@ -936,7 +1306,7 @@ func (s *Server) printStats() {
 		}
 		miner.mu.RUnlock()
 
-		if time.Since(lastActivity) < 2*time.Minute {
+		if time.Since(lastActivity) < activeMinerWindow {
 			activeMiners++
 			totalHashrate += hashrate
 		}
@@ -950,13 +1320,13 @@ func (s *Server) printStats() {
 	s.stats.UpdateHashrate(totalHashrate)
 
 	// Now get the fresh stats
-	total, active, shares, valid, invalid, blocks, hashrate, uptime := s.stats.GetStats()
+	total, active, shares, valid, invalid, stale, blocks, hashrate, uptime := s.stats.GetStats()
 
-	log.Printf("📊 Stats: Miners=%d/%d Shares=%d/%d/%d Blocks=%d Hashrate=%.2f H/s Uptime=%s",
-		active, total, valid, invalid, shares, blocks, hashrate, uptime.Round(time.Second))
+	log.Printf("📊 Stats: Miners=%d/%d Shares=%d/%d/%d/%d Blocks=%d Hashrate=%.2f H/s Uptime=%s",
+		active, total, valid, invalid, stale, shares, blocks, hashrate, uptime.Round(time.Second))
 
 	// Pool fee stats (if pool mode enabled)
-	if s.config.PoolAddress != "" && totalContribution > 0 && s.config.Verbose {
+	if s.cfg().PoolAddress != "" && totalContribution > 0 && s.cfg().Verbose {
 		log.Printf("💰 Pool Contributions:")
 		for _, ms := range minerStats {
 			if ms.SharesValid > 0 {
@@ -17,7 +17,7 @@ func TestServerLoginAndSubmit(t *testing.T) {
 	work := [4]string{
 		"0x0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
 		"0xabcdefabcdefabcdefabcdefabcdefabcdefabcdefabcdefabcdefabcdefabcd",
-		"0x00000000ffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", // Network difficulty 1, every share is a block
 		"0x1",
 	}
 
@@ -39,7 +39,7 @@ func TestServerLoginAndSubmit(t *testing.T) {
 		resp := JSONRPCResponse{JSONRPC: "2.0", ID: req.ID}
 		switch req.Method {
 		case "eth_blockNumber":
-			resp.Result = mustRaw("0x1")
+			resp.Result = mustRaw("0x0") // Work is for the next block
 		case "randomx_getWork":
 			resp.Result = mustRaw(work)
 		case "randomx_submitWork":
@@ -101,7 +101,7 @@ func TestServerLoginAndSubmit(t *testing.T) {
 		"jsonrpc": "2.0",
 		"method":  "login",
 		"params": map[string]interface{}{
-			"login": "0xabc",
+			"login": "0x000000000000000000000000000000000000abcd",
 			"pass":  "worker1",
 			"agent": "xmrig/test",
 		},
@@ -176,7 +176,7 @@ func TestServerConcurrentMiners(t *testing.T) {
 	work := [4]string{
 		"0x0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
 		"0xabcdefabcdefabcdefabcdefabcdefabcdefabcdefabcdefabcdefabcdefabcd",
-		"0x00000000ffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", // Network difficulty 1, every share is a block
 		"0x1",
 	}
 
@@ -202,7 +202,7 @@ func TestServerConcurrentMiners(t *testing.T) {
 		resp := JSONRPCResponse{JSONRPC: "2.0", ID: req.ID}
 		switch req.Method {
 		case "eth_blockNumber":
-			resp.Result = mustRaw("0x1")
+			resp.Result = mustRaw("0x0") // Work is for the next block
 		case "randomx_getWork":
 			resp.Result = mustRaw(work)
 		case "randomx_submitWork":
@@ -277,7 +277,7 @@ func TestServerConcurrentMiners(t *testing.T) {
 				"jsonrpc": "2.0",
 				"method":  "login",
 				"params": map[string]interface{}{
-					"login": fmt.Sprintf("0xminer%02d", idx),
+					"login": fmt.Sprintf("0x%040x", idx+1),
 					"pass":  fmt.Sprintf("worker-%d", idx),
 					"agent": "xmrig/test",
 				},
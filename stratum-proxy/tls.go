@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"time"
+)
+
+// certificateValidity is the lifetime of generated certificates. Miners pin
+// the fingerprint rather than validating the chain, so it can be long.
+const certificateValidity = 10 * 365 * 24 * time.Hour
+
+// loadOrCreateCertificate loads the TLS certificate of the stratum+ssl ports.
+// If neither file exists, a self-signed certificate is generated and saved
+// so that its fingerprint stays the same across restarts. Without file names
+// the certificate is only kept in memory.
+func loadOrCreateCertificate(certFile, keyFile string) (tls.Certificate, error) {
+	if certFile != "" && keyFile != "" {
+		_, certErr := os.Stat(certFile)
+		_, keyErr := os.Stat(keyFile)
+
+		switch {
+		case certErr == nil && keyErr == nil:
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return tls.Certificate{}, fmt.Errorf("failed to load TLS certificate: %w", err)
+			}
+			return cert, nil
+		case !errors.Is(certErr, os.ErrNotExist) || !errors.Is(keyErr, os.ErrNotExist):
+			return tls.Certificate{}, fmt.Errorf("TLS certificate %s and key %s must both exist or both be missing", certFile, keyFile)
+		}
+	}
+	certPEM, keyPEM, err := generateCertificate()
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate TLS certificate: %w", err)
+	}
+	if certFile != "" && keyFile != "" {
+		if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			return tls.Certificate{}, err
+		}
+		if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+			return tls.Certificate{}, err
+		}
+		log.Printf("🔐 Generated self-signed TLS certificate %s", certFile)
+	} else {
+		log.Printf("⚠️  Using a temporary self-signed TLS certificate, its fingerprint changes on restart")
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// generateCertificate creates a self-signed ECDSA certificate, PEM encoded.
+func generateCertificate() (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Ducros Stratum Proxy"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// certificateFingerprint returns the SHA-256 fingerprint of a certificate, in
+// the format of xmrig's "tls-fingerprint" option.
+func certificateFingerprint(cert tls.Certificate) string {
+	sum := sha256.Sum256(cert.Certificate[0])
+	return hex.EncodeToString(sum[:])
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"math/big"
@@ -34,6 +35,7 @@ func WorkToJob(work *WorkPackage, jobID string, algo string) (*Job, error) {
 		SeedHash:   work.SeedHash,
 		HeaderHash: work.HeaderHash,
 		Difficulty: difficulty,
+		CreatedAt:  work.ReceivedAt,
 	}
 
 	return job, nil
@@ -115,17 +117,21 @@ func ExtractNonceFromBlobRxEth(blob string) (string, error) {
 	return nonceHex, nil
 }
 
-// CalculateHash computes the RandomX hash for verification
-// This is a simplified version - actual hashing should use RandomX
-func CalculateHash(headerHash, nonce string) string {
-	// In production, this would:
-	// 1. Initialize RandomX with seed
-	// 2. Compute RandomX(headerHash + nonce)
-	// 3. Return the hash
-
-	// For now, we let Geth do the verification via submitWork
-	// Return a placeholder that indicates we need Geth to verify
-	return "0x" + strings.Repeat("0", 64)
+// BuildPreimageRxEth builds the 43-byte rx-eth-v1 preimage hashed by the miner
+// for a given job and nonce, i.e. the blob sent to the miner with the nonce
+// filled in at offset 39 (little-endian). This is the same input geth hashes
+// when verifying the block.
+func BuildPreimageRxEth(headerHash string, extraNonce uint32, minerNonce uint32) ([]byte, error) {
+	blob, err := createBlobRxEth(headerHash, extraNonce)
+	if err != nil {
+		return nil, err
+	}
+	preimage, err := hex.DecodeString(blob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob hex: %w", err)
+	}
+	binary.LittleEndian.PutUint32(preimage[39:], minerNonce)
+	return preimage, nil
 }
 
 // TargetToDifficulty converts a hex target to difficulty
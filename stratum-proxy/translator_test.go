@@ -77,7 +77,10 @@ func TestBlobFormat(t *testing.T) {
 	headerHash := "0xabcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890"
 	extraNonce := uint32(0xDEADBEEF)
 
-	blob := createBlobRxEth(headerHash, extraNonce)
+	blob, err := createBlobRxEth(headerHash, extraNonce)
+	if err != nil {
+		t.Fatalf("Failed to create blob: %v", err)
+	}
 
 	// Decode hex
 	blobBytes, err := hex.DecodeString(blob)
@@ -179,3 +182,27 @@ func TestDifficultyAdjustment(t *testing.T) {
 	// At minimum, verify the function doesn't crash
 	t.Logf("Difficulty adjustments: fast=%d slow=%d current=%d", fastDiff, slowDiff, currentDiff)
 }
+
+// TestPreimageRxEth verifies the hashed preimage is the blob with the miner nonce filled in
+func TestPreimageRxEth(t *testing.T) {
+	headerHash := "0xabcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890"
+
+	blob, err := createBlobRxEth(headerHash, 0xDEADBEEF)
+	if err != nil {
+		t.Fatalf("Failed to create blob: %v", err)
+	}
+	preimage, err := BuildPreimageRxEth(headerHash, 0xDEADBEEF, 0x12345678)
+	if err != nil {
+		t.Fatalf("Failed to build preimage: %v", err)
+	}
+	if len(preimage) != 43 {
+		t.Fatalf("Preimage length = %d, want 43", len(preimage))
+	}
+	// Everything but the nonce matches the blob, the nonce is little-endian
+	if have, want := hex.EncodeToString(preimage[:39]), blob[:78]; have != want {
+		t.Errorf("Preimage prefix = %s, want %s", have, want)
+	}
+	if have, want := hex.EncodeToString(preimage[39:]), "78563412"; have != want {
+		t.Errorf("Preimage nonce = %s, want %s", have, want)
+	}
+}
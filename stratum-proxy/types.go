@@ -3,6 +3,8 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"math/big"
+	"net"
 	"sync"
 	"time"
 )
@@ -60,6 +62,7 @@ type Job struct {
 	HeaderHash  string    `json:"-"`             // Internal: header hash for verification
 	Difficulty  uint64    `json:"-"`             // Internal: actual difficulty
 	CreatedAt   time.Time `json:"-"`             // Internal: job creation time
+	Upstream    *Upstream `json:"-"`             // Internal: node the work came from
 }
 
 // JobResponse is the complete job format for xmrig RandomX
@@ -75,14 +78,18 @@ type JobResponse struct {
 
 // Miner represents a connected miner
 type Miner struct {
-	ID            string                  // Unique miner ID
+	ID            string                  // Worker key (address.worker), remote address before login
+	RemoteAddr    string                  // Remote address of the connection
+	Conn          net.Conn                // Connection, closed to kick the miner
 	Writer        *json.Encoder           // JSON encoder for pushing notifications
 	BufferedWriter *bufio.Writer          // Underlying buffered writer (for Flush)
 	Agent         string                  // Miner software (e.g., "xmrig/6.18.0")
 	WorkerName    string                  // Worker name
+	FixedDiff     uint64                  // Difficulty requested with address+diff (0 = vardiff)
 	Address       string                  // Payout address
 	Difficulty    uint64                  // Current difficulty
 	CurrentJob    *Job                    // Current mining job
+	Jobs          jobHistory              // Recent jobs shares are accepted for
 	ExtraNonce    uint32                  // 4-byte session-specific nonce for rx-eth-v1
 	LastActivity  time.Time               // Last seen
 	LastShareTime time.Time               // Time of last share submission
@@ -90,6 +97,8 @@ type Miner struct {
 	SharesValid   uint64                  // Valid shares submitted
 	SharesInvalid uint64                  // Invalid shares
 	SharesInvalidStreak uint64            // Consecutive invalid shares
+	SharesStale   uint64                  // Shares for outdated jobs
+	SharesVerified uint64                 // Shares whose hash was recomputed and matched
 	Hashrate      float64                  // Estimated hashrate (H/s)
 	Banned        bool                     // Whether miner is banned
 	BanReason     string                   // Reason for ban
@@ -114,6 +123,10 @@ type Share struct {
 type ServerConfig struct {
 	ListenAddr         string
 	GethRPC            string
+	Upstreams          []UpstreamConfig // Geth nodes by priority (GethRPC if empty)
+	MaxUpstreamLag     uint64           // Blocks an upstream may lag behind the best one
+	HealthCheckInterval time.Duration   // Time between upstream health checks
+	WorkerGracePeriod  time.Duration    // How long disconnected workers keep their state
 	InitialDiff        float64
 	PoolAddress        string
 	PoolFee            float64
@@ -123,7 +136,32 @@ type ServerConfig struct {
 	VarDiffWindow      uint64   // Number of shares for vardiff calculation
 	MaxInvalidStreak   uint64   // Max invalid shares before ban
 	MaxConnections     int      // Max concurrent miner connections (0 = unlimited)
+	MaxConnectionsPerIP int     // Max concurrent connections per remote IP (0 = unlimited)
+	BanFile            string        // Ban list file (empty = bans only last for the connection)
+	BanDuration        time.Duration // How long automatic bans last (0 = connection only)
+	AdminToken         string        // Bearer token of the admin API (empty = disabled)
 	ShareRateLimit     float64  // Max shares per second per miner (0 = unlimited)
+	VerifyShares       bool     // Recompute share hashes with RandomX instead of trusting miners
+	VerifyFullMode     bool     // Verify with the full RandomX dataset instead of light mode
+	SpotCheckRate      float64  // Fraction of shares verified for trusted miners (1 = all)
+	TrustThreshold     uint64   // Verified shares after which a miner is trusted
+	LedgerDir          string        // Directory of the share ledger (pool mode)
+	PayoutScheme       string        // SchemePPLNS or SchemePPS
+	PPLNSWindow        float64       // PPLNS window in multiples of the network difficulty
+	BlockConfirmations uint64        // Confirmations before block rewards are credited
+	PayoutThreshold    *big.Int      // Minimum balance paid out (wei)
+	PayoutInterval     time.Duration // Time between payout batches
+	APIAddr            string        // HTTP stats API and metrics listen address (empty = disabled)
+	Ports              []PortConfig  // Additional stratum ports
+	TLSCertFile        string        // Certificate of the TLS ports, generated if missing
+	TLSKeyFile         string        // Private key of the TLS ports
+}
+
+// PortConfig is an additional stratum port
+type PortConfig struct {
+	Addr        string  // Listen address
+	InitialDiff float64 // Starting difficulty of the miners on this port
+	TLS         bool    // Serve stratum+ssl instead of plain TCP
 }
 
 // WorkPackage represents work from Geth (eth_getWork)
@@ -135,6 +173,40 @@ type WorkPackage struct {
 	ReceivedAt  time.Time
 }
 
+// Share rejection reasons, as reported in the metrics
+const (
+	RejectMalformed     = "malformed"      // Unparseable nonce
+	RejectDuplicate     = "duplicate"      // Nonce already submitted for the job
+	RejectLowDifficulty = "low_difficulty" // Hash above the miner's target
+	RejectUnverified    = "unverified"     // Hash didn't match the RandomX result
+)
+
+// Ban reasons, as reported in the metrics
+const (
+	BanInvalidStreak = "invalid_streak"
+	BanForgedShare   = "forged_share"
+	BanAdmin         = "admin" // Added through the admin API
+)
+
+// Connection rejection reasons, as reported in the metrics
+const (
+	ConnRejectBanned  = "banned"   // Banned IP, subnet or address
+	ConnRejectLimit   = "limit"    // Max connections reached
+	ConnRejectIPLimit = "ip_limit" // Max connections per IP reached
+)
+
+// maxRecentBlocks is the number of found blocks kept in the stats
+const maxRecentBlocks = 100
+
+// FoundBlock is a block found by a miner of the proxy
+type FoundBlock struct {
+	Height uint64    `json:"height"`
+	Nonce  uint64    `json:"nonce"`
+	Finder string    `json:"finder"`
+	Worker string    `json:"worker,omitempty"`
+	Found  time.Time `json:"found"`
+}
+
 // Stats holds server statistics
 type Stats struct {
 	StartTime      time.Time
@@ -143,16 +215,40 @@ type Stats struct {
 	TotalShares    uint64
 	ValidShares    uint64
 	InvalidShares  uint64
+	StaleShares    uint64
 	BlocksFound    uint64
 	TotalHashrate  float64
+	Rejected       map[string]uint64 // Invalid shares per rejection reason
+	Bans           map[string]uint64 // Bans per reason
+	ConnectionsRejected map[string]uint64 // Refused connections and logins per reason
+	VarDiffUp      uint64            // Difficulty increases
+	VarDiffDown    uint64            // Difficulty decreases
+	BlocksRejected uint64            // Block candidates refused by Geth
+	SubmitLatency  *histogram        // Time to process share submissions
+	RecentBlocks   []FoundBlock      // Last found blocks, oldest first
 	mu             sync.RWMutex
 }
 
 // NewStats creates a new Stats instance
 func NewStats() *Stats {
-	return &Stats{
-		StartTime: time.Now(),
+	stats := &Stats{
+		StartTime:     time.Now(),
+		Rejected:      make(map[string]uint64),
+		Bans:          make(map[string]uint64),
+		ConnectionsRejected: make(map[string]uint64),
+		SubmitLatency: newHistogram(submitLatencyBuckets),
+	}
+	// Report every reason from the start, so that rates are defined
+	for _, reason := range []string{RejectMalformed, RejectDuplicate, RejectLowDifficulty, RejectUnverified} {
+		stats.Rejected[reason] = 0
 	}
+	for _, reason := range []string{BanInvalidStreak, BanForgedShare, BanAdmin} {
+		stats.Bans[reason] = 0
+	}
+	for _, reason := range []string{ConnRejectBanned, ConnRejectLimit, ConnRejectIPLimit} {
+		stats.ConnectionsRejected[reason] = 0
+	}
+	return stats
 }
 
 // RecordShare records a share submission
@@ -168,11 +264,87 @@ func (s *Stats) RecordShare(valid bool) {
 	}
 }
 
+// RecordRejectedShare records an invalid share and why it was rejected
+func (s *Stats) RecordRejectedShare(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.TotalShares++
+	s.InvalidShares++
+	s.Rejected[reason]++
+}
+
+// RecordStaleShare records a share submitted for an outdated job
+func (s *Stats) RecordStaleShare() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.TotalShares++
+	s.StaleShares++
+}
+
 // RecordBlock records a found block
-func (s *Stats) RecordBlock() {
+func (s *Stats) RecordBlock(block FoundBlock) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.BlocksFound++
+
+	s.RecentBlocks = append(s.RecentBlocks, block)
+	if len(s.RecentBlocks) > maxRecentBlocks {
+		s.RecentBlocks = s.RecentBlocks[1:]
+	}
+}
+
+// RecordRejectedBlock records a block candidate refused by Geth
+func (s *Stats) RecordRejectedBlock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.BlocksRejected++
+}
+
+// RecordBan records a banned miner
+func (s *Stats) RecordBan(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Bans[reason]++
+}
+
+// RecordRejectedConnection records a connection or login refused
+func (s *Stats) RecordRejectedConnection(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ConnectionsRejected[reason]++
+}
+
+// RecordVarDiff records a difficulty adjustment
+func (s *Stats) RecordVarDiff(oldDiff, newDiff uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if newDiff > oldDiff {
+		s.VarDiffUp++
+	} else {
+		s.VarDiffDown++
+	}
+}
+
+// ObserveSubmit records the time taken to process a share submission
+func (s *Stats) ObserveSubmit(elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.SubmitLatency.observe(elapsed.Seconds())
+}
+
+// GetRecentBlocks returns the last found blocks, newest first
+func (s *Stats) GetRecentBlocks() []FoundBlock {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blocks := make([]FoundBlock, len(s.RecentBlocks))
+	for i, block := range s.RecentBlocks {
+		blocks[len(blocks)-1-i] = block
+	}
+	return blocks
 }
 
 // UpdateHashrate updates the network hashrate
@@ -191,10 +363,10 @@ func (s *Stats) UpdateMiners(total, active int) {
 }
 
 // GetStats returns current stats (thread-safe)
-func (s *Stats) GetStats() (total, active int, shares, valid, invalid, blocks uint64, hashrate float64, uptime time.Duration) {
+func (s *Stats) GetStats() (total, active int, shares, valid, invalid, stale, blocks uint64, hashrate float64, uptime time.Duration) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	return s.TotalMiners, s.ActiveMiners, s.TotalShares, s.ValidShares,
-	       s.InvalidShares, s.BlocksFound, s.TotalHashrate, time.Since(s.StartTime)
+	       s.InvalidShares, s.StaleShares, s.BlocksFound, s.TotalHashrate, time.Since(s.StartTime)
 }
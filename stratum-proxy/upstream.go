@@ -0,0 +1,308 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultHealthCheckInterval is how often upstreams are checked if the
+// interval isn't configured.
+const defaultHealthCheckInterval = 5 * time.Second
+
+// upstreamFailureThreshold is the number of consecutive failed health checks
+// after which an upstream is considered unhealthy. A single timeout, or a
+// check racing with a new block, doesn't cause a failover.
+const upstreamFailureThreshold = 2
+
+// UpstreamConfig is a Geth node the proxy can get work from
+type UpstreamConfig struct {
+	URL      string `json:"url"`
+	Priority int    `json:"priority"` // Lower is preferred
+}
+
+// Upstream is a Geth node along with its last known health. The name, URL,
+// priority and client never change, the health is protected by the pool lock.
+type Upstream struct {
+	Name     string
+	URL      string
+	Priority int
+	client   *RPCClient
+
+	healthy    bool
+	height     uint64 // Head block number
+	workHeight uint64 // Block number of the work handed out
+	failures   int    // Consecutive failed checks
+	lastError  string
+	checked    time.Time
+}
+
+// UpstreamStatus is the API view of an upstream
+type UpstreamStatus struct {
+	Name       string    `json:"name"`
+	Priority   int       `json:"priority"`
+	Active     bool      `json:"active"`
+	Healthy    bool      `json:"healthy"`
+	Height     uint64    `json:"height"`
+	WorkHeight uint64    `json:"workHeight"`
+	Failures   int       `json:"failures"`
+	LastError  string    `json:"lastError,omitempty"`
+	Checked    time.Time `json:"checked"`
+}
+
+// UpstreamPool keeps the Geth upstreams, health checks them and fails over
+// to the preferred healthy one.
+type UpstreamPool struct {
+	upstreams []*Upstream // Ordered by priority
+	active    *Upstream
+	maxLag    uint64 // Blocks an upstream may lag behind the best one
+	failovers uint64
+	mu        sync.RWMutex
+}
+
+// upstreamName returns a label for an upstream URL, without credentials.
+func upstreamName(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return rawURL
+}
+
+// parseUpstreams parses a comma separated list of Geth RPC endpoints, in
+// order of priority.
+func parseUpstreams(spec string) []UpstreamConfig {
+	var upstreams []UpstreamConfig
+	for _, field := range strings.Split(spec, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			upstreams = append(upstreams, UpstreamConfig{URL: field, Priority: len(upstreams)})
+		}
+	}
+	return upstreams
+}
+
+// NewUpstreamPool creates a pool of upstreams. The preferred one is active
+// until the first health check.
+func NewUpstreamPool(configs []UpstreamConfig, maxLag uint64) *UpstreamPool {
+	pool := &UpstreamPool{maxLag: maxLag}
+	for _, config := range configs {
+		pool.upstreams = append(pool.upstreams, &Upstream{
+			Name:     upstreamName(config.URL),
+			URL:      config.URL,
+			Priority: config.Priority,
+			client:   NewRPCClient(config.URL),
+		})
+	}
+	sort.SliceStable(pool.upstreams, func(i, j int) bool {
+		return pool.upstreams[i].Priority < pool.upstreams[j].Priority
+	})
+	if len(pool.upstreams) > 0 {
+		pool.active = pool.upstreams[0]
+	}
+	return pool
+}
+
+// SetMaxLag changes how many blocks an upstream may lag behind the best one,
+// from the next health check on.
+func (p *UpstreamPool) SetMaxLag(maxLag uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.maxLag = maxLag
+}
+
+// Active returns the upstream work is fetched from
+func (p *UpstreamPool) Active() *Upstream {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.active
+}
+
+// Client returns the RPC client of the active upstream
+func (p *UpstreamPool) Client() *RPCClient {
+	return p.Active().client
+}
+
+// upstreamProbe is the result of checking an upstream
+type upstreamProbe struct {
+	height     uint64
+	workHeight uint64
+	err        error
+}
+
+// probe checks that an upstream answers and hands out work
+func (u *Upstream) probe() upstreamProbe {
+	height, err := u.client.GetBlockNumber()
+	if err != nil {
+		return upstreamProbe{err: err}
+	}
+	work, err := u.client.GetWork()
+	if err != nil {
+		return upstreamProbe{height: height, err: err}
+	}
+	var workHeight uint64
+	fmt.Sscanf(work.BlockNumber, "0x%x", &workHeight)
+	return upstreamProbe{height: height, workHeight: workHeight}
+}
+
+// CheckHealth probes all upstreams in parallel and fails over if the active
+// one became unhealthy, or back to a preferred one that recovered. An upstream
+// is healthy if it answers, doesn't lag more than maxLag blocks behind the
+// best upstream and hands out work on top of its head. It returns whether any
+// upstream is healthy.
+func (p *UpstreamPool) CheckHealth() bool {
+	probes := make([]upstreamProbe, len(p.upstreams))
+
+	var wg sync.WaitGroup
+	for i, upstream := range p.upstreams {
+		wg.Add(1)
+		go func(i int, upstream *Upstream) {
+			defer wg.Done()
+			probes[i] = upstream.probe()
+		}(i, upstream)
+	}
+	wg.Wait()
+
+	var best uint64
+	for _, probe := range probes {
+		if probe.err == nil && probe.height > best {
+			best = probe.height
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for i, upstream := range p.upstreams {
+		probe := probes[i]
+		upstream.checked = now
+
+		problem := ""
+		switch {
+		case probe.err != nil:
+			problem = probe.err.Error()
+		case probe.height+p.maxLag < best:
+			problem = fmt.Sprintf("%d blocks behind", best-probe.height)
+		case probe.workHeight <= probe.height:
+			problem = fmt.Sprintf("work for block %d on head %d", probe.workHeight, probe.height)
+		}
+		if probe.err == nil {
+			upstream.height, upstream.workHeight = probe.height, probe.workHeight
+		}
+		upstream.lastError = problem
+
+		if problem != "" {
+			upstream.failures++
+			if upstream.healthy && upstream.failures >= upstreamFailureThreshold {
+				upstream.healthy = false
+				log.Printf("⚠️  Upstream %s is unhealthy: %s", upstream.Name, problem)
+			}
+			continue
+		}
+		upstream.failures = 0
+		if !upstream.healthy {
+			upstream.healthy = true
+			log.Printf("✅ Upstream %s is healthy (block %d)", upstream.Name, probe.height)
+		}
+	}
+	return p.selectActive()
+}
+
+// selectActive activates the preferred healthy upstream. Without any, the
+// active upstream is kept. It must be called with the lock held.
+func (p *UpstreamPool) selectActive() bool {
+	for _, upstream := range p.upstreams {
+		if !upstream.healthy {
+			continue
+		}
+		if upstream != p.active {
+			log.Printf("🔀 Failing over from upstream %s to %s", p.active.Name, upstream.Name)
+			p.active = upstream
+			p.failovers++
+		}
+		return true
+	}
+	return false
+}
+
+// SubmitWork submits a block solution to all healthy upstreams in parallel,
+// as well as to the upstream the job came from. Geth only accepts solutions
+// for work it handed out, so this gets the block to the issuing node even if
+// it isn't the active one anymore, and to any other node that has the same
+// pending work. The solution is accepted if any upstream accepts it.
+func (p *UpstreamPool) SubmitWork(origin *Upstream, nonce, headerHash, mixDigest string) (bool, error) {
+	p.mu.RLock()
+	var targets []*Upstream
+	for _, upstream := range p.upstreams {
+		if upstream.healthy || upstream == origin || upstream == p.active {
+			targets = append(targets, upstream)
+		}
+	}
+	p.mu.RUnlock()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted bool
+		errs     []error
+	)
+	for _, upstream := range targets {
+		wg.Add(1)
+		go func(upstream *Upstream) {
+			defer wg.Done()
+
+			ok, err := upstream.client.SubmitWork(nonce, headerHash, mixDigest)
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", upstream.Name, err))
+				return
+			}
+			if ok {
+				log.Printf("📨 Block solution accepted by upstream %s", upstream.Name)
+				accepted = true
+			}
+		}(upstream)
+	}
+	wg.Wait()
+
+	if !accepted && len(errs) == len(targets) {
+		return false, errors.Join(errs...)
+	}
+	return accepted, nil
+}
+
+// Failovers returns the number of times the active upstream changed
+func (p *UpstreamPool) Failovers() uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.failovers
+}
+
+// Status returns the health of all upstreams, by priority
+func (p *UpstreamPool) Status() []UpstreamStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	status := make([]UpstreamStatus, len(p.upstreams))
+	for i, upstream := range p.upstreams {
+		status[i] = UpstreamStatus{
+			Name:       upstream.Name,
+			Priority:   upstream.Priority,
+			Active:     upstream == p.active,
+			Healthy:    upstream.healthy,
+			Height:     upstream.height,
+			WorkHeight: upstream.workHeight,
+			Failures:   upstream.failures,
+			LastError:  upstream.lastError,
+			Checked:    upstream.checked,
+		}
+	}
+	return status
+}
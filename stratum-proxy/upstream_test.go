@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeNode is a Geth node serving work on top of its head.
+type fakeNode struct {
+	mu        sync.Mutex
+	head      uint64
+	down      bool
+	accept    bool // Whether submitted solutions are accepted
+	submitted int
+}
+
+func (n *fakeNode) serve(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		if n.down {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		var req JSONRPCRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode rpc request: %v", err)
+			return
+		}
+		resp := JSONRPCResponse{JSONRPC: "2.0", ID: req.ID}
+		switch req.Method {
+		case "eth_blockNumber":
+			resp.Result = mustRaw(fmt.Sprintf("0x%x", n.head))
+		case "randomx_getWork":
+			resp.Result = mustRaw([4]string{
+				fmt.Sprintf("0x%064x", n.head+1),
+				"0x" + fmt.Sprintf("%064x", 0),
+				"0x00000000ffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+				fmt.Sprintf("0x%x", n.head+1),
+			})
+		case "randomx_submitWork":
+			n.submitted++
+			resp.Result = mustRaw(n.accept)
+		default:
+			resp.Error = &RPCError{Code: -32601, Message: "method not found"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func (n *fakeNode) set(head uint64, down bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.head, n.down = head, down
+}
+
+func TestUpstreamFailover(t *testing.T) {
+	primary, backup := &fakeNode{head: 100}, &fakeNode{head: 100}
+	primaryServer, backupServer := primary.serve(t), backup.serve(t)
+	defer primaryServer.Close()
+	defer backupServer.Close()
+
+	pool := NewUpstreamPool(parseUpstreams(primaryServer.URL+","+backupServer.URL), 2)
+	if !pool.CheckHealth() || pool.Active().URL != primaryServer.URL {
+		t.Fatalf("primary not active after first check: %+v", pool.Status())
+	}
+	// A single failed check doesn't fail over, a second one does
+	primary.set(100, true)
+	pool.CheckHealth()
+	if pool.Active().URL != primaryServer.URL {
+		t.Fatalf("failed over after a single failed check")
+	}
+	pool.CheckHealth()
+	if pool.Active().URL != backupServer.URL {
+		t.Fatalf("no failover after primary went down: %+v", pool.Status())
+	}
+	// The primary comes back, but lagging behind
+	primary.set(100, false)
+	backup.set(110, false)
+	pool.CheckHealth()
+	pool.CheckHealth()
+	if pool.Active().URL != backupServer.URL {
+		t.Fatalf("failed back to a lagging upstream: %+v", pool.Status())
+	}
+	// Once synced, the preferred upstream takes over again
+	primary.set(110, false)
+	pool.CheckHealth()
+	if pool.Active().URL != primaryServer.URL {
+		t.Fatalf("no failback to the synced primary: %+v", pool.Status())
+	}
+	if have := pool.Failovers(); have != 2 {
+		t.Fatalf("failovers: have %d, want 2", have)
+	}
+}
+
+func TestUpstreamSubmitWork(t *testing.T) {
+	primary, backup := &fakeNode{head: 100}, &fakeNode{head: 100, accept: true}
+	primaryServer, backupServer := primary.serve(t), backup.serve(t)
+	defer primaryServer.Close()
+	defer backupServer.Close()
+
+	pool := NewUpstreamPool(parseUpstreams(primaryServer.URL+","+backupServer.URL), 2)
+	pool.CheckHealth()
+
+	// Solutions go to every healthy upstream, one acceptance is enough
+	accepted, err := pool.SubmitWork(pool.Active(), "0x01", "0x02", "0x03")
+	if err != nil || !accepted {
+		t.Fatalf("submit: have %v, %v, want accepted", accepted, err)
+	}
+	if primary.submitted != 1 || backup.submitted != 1 {
+		t.Fatalf("submissions: have %d/%d, want 1/1", primary.submitted, backup.submitted)
+	}
+	// With every upstream down, the error is reported
+	primary.set(100, true)
+	backup.set(100, true)
+	if accepted, err := pool.SubmitWork(nil, "0x01", "0x02", "0x03"); accepted || err == nil {
+		t.Fatalf("submit to down upstreams: have %v, %v, want error", accepted, err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+// Share verification
+//
+// Miners report the RandomX hash of their share along with the nonce, but the
+// reported hash cannot be trusted: anyone can claim a low hash to get pool
+// credit or make the proxy submit bogus blocks to geth. The verifier rebuilds
+// the rx-eth-v1 preimage from the job and the nonce, hashes it with RandomX and
+// compares the result with the claimed one.
+
+var (
+	// errForgedResult is returned if the hash reported by a miner doesn't match
+	// the RandomX hash of its share.
+	errForgedResult = errors.New("result does not match share hash")
+
+	// errNoRandomX is returned if share verification is requested but the proxy
+	// was built without the RandomX library.
+	errNoRandomX = errors.New("built without RandomX support (rebuild with cgo and -tags randomx)")
+)
+
+// Hasher computes RandomX hashes for a given seed.
+type Hasher interface {
+	// Hash returns the RandomX hash of input, keyed by the epoch seed.
+	Hash(seed []byte, input []byte) ([32]byte, error)
+
+	// Close releases the RandomX caches, datasets and VMs.
+	Close()
+}
+
+// ShareVerifier decides which shares to verify and checks them against a
+// RandomX hasher.
+type ShareVerifier struct {
+	hasher         Hasher
+	spotCheckRate  float64 // Fraction of shares verified for trusted miners
+	trustThreshold uint64  // Verified shares after which a miner is trusted
+
+	rand *rand.Rand
+	mu   sync.Mutex // Protects rand and the spot check policy
+}
+
+// NewShareVerifier creates a share verifier on top of a RandomX hasher. Every
+// share is verified, unless spotCheckRate is below 1, in which case only that
+// fraction of shares is verified for miners that already had trustThreshold
+// shares verified.
+func NewShareVerifier(hasher Hasher, spotCheckRate float64, trustThreshold uint64) *ShareVerifier {
+	v := &ShareVerifier{
+		hasher: hasher,
+		rand:   rand.New(rand.NewSource(rand.Int63())),
+	}
+	v.SetPolicy(spotCheckRate, trustThreshold)
+	return v
+}
+
+// SetPolicy changes the spot check rate and the trust threshold. It applies
+// from the next share on, as trust is derived from the verified share count.
+func (v *ShareVerifier) SetPolicy(spotCheckRate float64, trustThreshold uint64) {
+	if spotCheckRate > 1 {
+		spotCheckRate = 1
+	}
+	if spotCheckRate < 0 {
+		spotCheckRate = 0
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.spotCheckRate, v.trustThreshold = spotCheckRate, trustThreshold
+}
+
+// ShouldVerify reports whether a share from a miner with the given number of
+// verified shares has to be verified. Block candidates are always verified so
+// that geth never receives unchecked solutions.
+func (v *ShareVerifier) ShouldVerify(verifiedShares uint64, blockCandidate bool) bool {
+	if blockCandidate {
+		return true
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.spotCheckRate >= 1 || verifiedShares < v.trustThreshold {
+		return true
+	}
+	return v.rand.Float64() < v.spotCheckRate
+}
+
+// Verify hashes the share of a job and checks it against the result claimed by
+// the miner. It returns errForgedResult if they differ.
+func (v *ShareVerifier) Verify(job *Job, extraNonce uint32, minerNonce uint32, result string) error {
+	claimed, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+	if err != nil || len(claimed) != 32 {
+		return fmt.Errorf("%w: malformed result", errForgedResult)
+	}
+	seed, err := hex.DecodeString(strings.TrimPrefix(job.SeedHash, "0x"))
+	if err != nil || len(seed) != 32 {
+		return fmt.Errorf("invalid job seed hash: %s", job.SeedHash)
+	}
+	preimage, err := BuildPreimageRxEth(job.HeaderHash, extraNonce, minerNonce)
+	if err != nil {
+		return err
+	}
+	hash, err := v.hasher.Hash(seed, preimage)
+	if err != nil {
+		return fmt.Errorf("randomx hash failed: %w", err)
+	}
+	if !bytes.Equal(hash[:], claimed) {
+		return errForgedResult
+	}
+	return nil
+}
+
+// Close releases the resources of the underlying hasher.
+func (v *ShareVerifier) Close() {
+	v.hasher.Close()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// fakeHasher stands in for RandomX, hashing the seed and the input with SHA256.
+type fakeHasher struct {
+	calls int
+}
+
+func (h *fakeHasher) Hash(seed []byte, input []byte) ([32]byte, error) {
+	h.calls++
+	return sha256.Sum256(append(append([]byte{}, seed...), input...)), nil
+}
+
+func (h *fakeHasher) Close() {}
+
+func testJob() *Job {
+	return &Job{
+		JobID:      "1",
+		SeedHash:   "0xabcdefabcdefabcdefabcdefabcdefabcdefabcdefabcdefabcdefabcdefabcd",
+		HeaderHash: "0x0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+}
+
+// shareResult returns the result an honest miner reports for a share.
+func shareResult(t *testing.T, job *Job, extraNonce, minerNonce uint32) string {
+	t.Helper()
+
+	seed, _ := hex.DecodeString(job.SeedHash[2:])
+	preimage, err := BuildPreimageRxEth(job.HeaderHash, extraNonce, minerNonce)
+	if err != nil {
+		t.Fatalf("build preimage: %v", err)
+	}
+	hash, _ := new(fakeHasher).Hash(seed, preimage)
+	return hex.EncodeToString(hash[:])
+}
+
+func TestShareVerifierVerify(t *testing.T) {
+	var (
+		job      = testJob()
+		verifier = NewShareVerifier(new(fakeHasher), 1, 0)
+		result   = shareResult(t, job, 0xdeadbeef, 0x12345678)
+	)
+	if err := verifier.Verify(job, 0xdeadbeef, 0x12345678, result); err != nil {
+		t.Fatalf("honest share rejected: %v", err)
+	}
+	if err := verifier.Verify(job, 0xdeadbeef, 0x12345678, "0x"+result); err != nil {
+		t.Fatalf("honest share with 0x prefix rejected: %v", err)
+	}
+	// A result valid for another nonce, extra nonce or a made up one is forged
+	if err := verifier.Verify(job, 0xdeadbeef, 0x12345679, result); !errors.Is(err, errForgedResult) {
+		t.Fatalf("share with wrong nonce: have %v, want %v", err, errForgedResult)
+	}
+	if err := verifier.Verify(job, 0xdeadbeee, 0x12345678, result); !errors.Is(err, errForgedResult) {
+		t.Fatalf("share with wrong extra nonce: have %v, want %v", err, errForgedResult)
+	}
+	if err := verifier.Verify(job, 0xdeadbeef, 0x12345678, "00"+result[2:]); !errors.Is(err, errForgedResult) {
+		t.Fatalf("share with low result: have %v, want %v", err, errForgedResult)
+	}
+	if err := verifier.Verify(job, 0xdeadbeef, 0x12345678, "zz"); !errors.Is(err, errForgedResult) {
+		t.Fatalf("share with malformed result: have %v, want %v", err, errForgedResult)
+	}
+}
+
+func TestShareVerifierSpotCheck(t *testing.T) {
+	// Without spot checking every share is verified
+	verifier := NewShareVerifier(new(fakeHasher), 1, 0)
+	for i := uint64(0); i < 100; i++ {
+		if !verifier.ShouldVerify(i, false) {
+			t.Fatalf("share %d not verified without spot checking", i)
+		}
+	}
+	// Untrusted miners and block candidates are always verified
+	verifier = NewShareVerifier(new(fakeHasher), 0, 10)
+	for i := uint64(0); i < 10; i++ {
+		if !verifier.ShouldVerify(i, false) {
+			t.Fatalf("share %d of untrusted miner not verified", i)
+		}
+	}
+	if verifier.ShouldVerify(10, false) {
+		t.Fatalf("share of trusted miner verified with zero spot check rate")
+	}
+	if !verifier.ShouldVerify(10, true) {
+		t.Fatalf("block candidate of trusted miner not verified")
+	}
+	// Trusted miners are checked at roughly the configured rate
+	verifier = NewShareVerifier(new(fakeHasher), 0.25, 0)
+
+	checked := 0
+	for i := 0; i < 10000; i++ {
+		if verifier.ShouldVerify(100, false) {
+			checked++
+		}
+	}
+	if checked < 2000 || checked > 3000 {
+		t.Fatalf("spot checked %d of 10000 shares, want about 2500", checked)
+	}
+}